@@ -18,11 +18,16 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"heart/docs"
 	"heart/internal/awsx"
+	"heart/internal/background"
 	"heart/internal/config"
+	"heart/internal/dbx"
 	"heart/internal/firebasex"
+	"heart/internal/localdb"
+	"heart/internal/middleware"
 	"heart/internal/routerx"
 	"strings"
 
@@ -33,22 +38,35 @@ import (
 	"os"
 )
 
-func Init() {
+// Init reads the config and sets the API up, against AWS or, when the config keeps the data
+// locally, without it. It returns the stores the handlers read and write through, and the
+// services they keep files and run jobs with.
+// MODE=memory keeps the data in memory whatever the config says.
+func Init(mode string) (dbx.Stores, awsx.Services) {
+	cfg, err := config.NewOfflineAppConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	if mode == "memory" {
+		cfg.Storage = config.StorageMemory
+	}
+
+	if cfg.Local() {
+		return initLocal(cfg)
+	}
+	return initAWS()
+}
+
+func initAWS() (dbx.Stores, awsx.Services) {
 	var err error
 	config.App, err = config.NewAppConfig()
 
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
-		return
 	}
 
-	if config.App.DocsEnabled {
-		docs.SwaggerInfo.Host = config.App.Host
-		docs.SwaggerInfo.BasePath = config.App.BasePath
-		if !strings.HasPrefix(config.App.Host, "localhost") {
-			docs.SwaggerInfo.Schemes = []string{"https"}
-		}
-	}
+	initDocs()
 
 	if config.App.Credentials != "" {
 		if err := firebasex.Init(config.App.Credentials); err != nil {
@@ -58,24 +76,84 @@ func Init() {
 
 	if err := awsx.Init(context.Background(), config.App.AwsConfig); err != nil {
 		log.Fatal("Failed to initialize AWS clients:", err)
-		return
 	}
+
+	return dbx.NewStores(dbx.Dynamo{}), awsx.NewServices()
 }
 
-func main() {
-	Init()
+// initLocal sets the API up to run without AWS: the data is kept in SQLite or in memory, objects
+// on disk, and background jobs run in the process. Without Firebase it refuses to start unless
+// AUTH asks for bearer tokens to be taken for the IDs of the users they stand for.
+func initLocal(cfg *config.AppConfig) (dbx.Stores, awsx.Services) {
+	cfg.UploadBucket = cmp.Or(cfg.UploadBucket, "uploads")
+	cfg.MediaBucket = cmp.Or(cfg.MediaBucket, "media")
+
+	files, err := awsx.UseFiles(cfg.FilesDir, cfg.FilesURL)
+	if err != nil {
+		log.Fatal("Failed to set up local files:", err)
+	}
+	cfg.MediaDistributionAlias = cmp.Or(cfg.MediaDistributionAlias, files.URL+"/"+cfg.MediaBucket)
+
+	config.App = cfg
+	initDocs()
+
+	if cfg.Credentials != "" {
+		if err := firebasex.Init(cfg.Credentials); err != nil {
+			log.Fatal("Failed to initialize Firebase client:", err)
+		}
+	} else if cfg.Auth == config.AuthTrustBearer {
+		log.Printf("[WARNING] AUTH=%s: bearer tokens are taken for user IDs unchecked, anyone can act as any user. Never expose this server.", config.AuthTrustBearer)
+		middleware.TrustBearerTokens()
+	} else {
+		log.Fatalf("No FIREBASE_CREDENTIALS to check bearer tokens with; set them, or AUTH=%s to trust the tokens as user IDs", config.AuthTrustBearer)
+	}
+
+	var store *localdb.Store
+	switch cfg.Storage {
+	case config.StorageSQLite:
+		store, err = localdb.OpenSQLite(context.Background(), cfg.SQLitePath)
+		if err != nil {
+			log.Fatal("Failed to open SQLite:", err)
+		}
+	case config.StorageMemory:
+		store = localdb.NewMemory()
+	default:
+		log.Fatalf("Unknown storage %q", cfg.Storage)
+	}
+
+	stores := dbx.NewStores(store)
+	jobs, err := background.NewLocal(context.Background(), stores, store, cfg.AccountDeletionOffset)
+	if err != nil {
+		log.Fatal("Failed to set up background jobs:", err)
+	}
 
-	r := routerx.Router(config.App.CORSOrigins)
+	return stores, awsx.Services{Objects: awsx.NewServices().Objects, Jobs: jobs}
+}
 
+func initDocs() {
+	if config.App.DocsEnabled {
+		docs.SwaggerInfo.Host = config.App.Host
+		docs.SwaggerInfo.BasePath = config.App.BasePath
+		if !strings.HasPrefix(config.App.Host, "localhost") {
+			docs.SwaggerInfo.Schemes = []string{"https"}
+		}
+	}
+}
+
+func main() {
 	mode := os.Getenv("MODE")
 
+	stores, services := Init(mode)
+
+	r := routerx.Router(config.App.CORSOrigins, stores, services)
+
 	if mode == "lambda" {
 		// Route Gin router with the Lambda adapter
 		log.Println("Running in Lambda mode...")
 		lambda.Start(ginadapter.New(r).ProxyWithContext)
 	} else {
-		// Default: local dev mode
-		log.Println("Running in local mode on :8080...")
+		// Default: local dev mode, against AWS or with the data kept locally
+		log.Printf("Running in %s mode on :8080, keeping data in %s...", cmp.Or(mode, "local"), config.App.Storage)
 		if err := r.Run(":8080"); err != nil {
 			log.Fatal("Server failed to start:", err)
 		}
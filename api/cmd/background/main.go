@@ -2,11 +2,11 @@ package main
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"heart/internal/awsx"
+	"heart/internal/background"
 	"heart/internal/config"
+	"heart/internal/dbx"
 	"heart/internal/firebasex"
-	"heart/internal/models"
 	"heart/internal/routerx"
 	"log"
 
@@ -14,46 +14,10 @@ import (
 )
 
 func handler(ctx context.Context, event map[string]interface{}) (map[string]interface{}, error) {
-	log.Printf("Received event: %+v", event)
-
-	eventType, ok := event["Event"].(string)
-	if !ok {
-		return nil, models.NewValidationError(errors.New("missing Event field"))
-	}
-
-	switch eventType {
-	case "AccountDeletion":
-		payload, ok := event["Payload"].(map[string]interface{})
-		if !ok {
-			return nil, models.NewValidationError(errors.New("missing Payload field"))
-		}
-
-		userID, ok := payload["user_id"].(string)
-		if !ok {
-			return nil, models.NewValidationError(errors.New("missing user_id field"))
-		}
-
-		err := firebasex.DeleteUser(ctx, userID)
-		if err != nil {
-			return nil, models.NewServerError(err)
-		}
-
-		return map[string]interface{}{
-			"statusCode": 200,
-			"body":       fmt.Sprintf("Successfully deleted account for user %s", userID),
-		}, nil
-	default:
-		return nil, models.NewValidationError(errors.New("invalid event type"))
-	}
+	return background.Handle(ctx, dbx.NewStores(dbx.Dynamo{}), event)
 }
 
-func initFirebase() error {
-	cfg, err := config.NewFirebaseConfig()
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
+func initFirebase(cfg config.FirebaseConfig) error {
 	if cfg.Credentials != "" {
 		if err := firebasex.Init(cfg.Credentials); err != nil {
 			log.Printf("Failed to initialize Firebase client: %s", err)
@@ -64,10 +28,37 @@ func initFirebase() error {
 	return nil
 }
 
+func initAws(cfg *config.BackgroundConfig) error {
+	config.App = &config.AppConfig{
+		AwsConfig: config.AwsConfig{
+			DynamoDBConfig:   cfg.DynamoDBConfig,
+			S3Config:         config.S3Config{MediaBucket: cfg.MediaBucket},
+			CloudFrontConfig: cfg.CloudFrontConfig,
+			AwsRegion:        cfg.AwsRegion,
+		},
+		FirebaseConfig: cfg.FirebaseConfig,
+	}
+
+	if err := awsx.Init(context.Background(), config.App.AwsConfig); err != nil {
+		log.Printf("Failed to initialize AWS clients: %s", err)
+		return err
+	}
+
+	return nil
+}
+
 func main() {
 	log.Printf("Starting Heart API Background - version: %s", routerx.String())
-	err := initFirebase()
+	cfg, err := config.NewBackgroundConfig()
 	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := initFirebase(cfg.FirebaseConfig); err != nil {
+		return
+	}
+
+	if err := initAws(cfg); err != nil {
 		return
 	}
 
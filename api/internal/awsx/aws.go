@@ -7,13 +7,17 @@ import (
 	"fmt"
 	env "heart/internal/config"
 	"html"
+	"io"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/scheduler"
 	"github.com/aws/aws-sdk-go-v2/service/scheduler/types"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
@@ -26,12 +30,15 @@ type DynamoDbAPI interface {
 	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
 	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
 	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
 }
 
 var (
 	Db       DynamoDbAPI
 	Env      env.AwsConfig
 	events   *scheduler.Client
+	lambdas  *lambda.Client
 	S3       *s3.Client
 	s3Signer *s3.PresignClient
 	SNS      *sns.Client
@@ -49,6 +56,7 @@ func Init(ctx context.Context, c env.AwsConfig) error {
 	}
 
 	events = scheduler.NewFromConfig(cfg)
+	lambdas = lambda.NewFromConfig(cfg)
 	S3 = s3.NewFromConfig(cfg)
 	s3Signer = s3.NewPresignClient(S3)
 	SNS = sns.NewFromConfig(cfg)
@@ -63,6 +71,10 @@ func GeneratePresignedPostURL(
 	contentType string,
 	tagging *map[string]string,
 ) (*s3.PresignedPostRequest, error) {
+	if files != nil {
+		return files.presignPost(bucket, key, contentType, tagging), nil
+	}
+
 	input := s3.PutObjectInput{
 		Bucket:      aws.String(bucket),
 		Key:         aws.String(key),
@@ -108,6 +120,44 @@ func GeneratePresignedPostURL(
 	return request, nil
 }
 
+// GeneratePresignedGetURL returns a link that lets anyone holding it download the object until it expires.
+func GeneratePresignedGetURL(ctx context.Context, bucket string, key string, expires time.Duration) (string, error) {
+	if files != nil {
+		return files.ObjectURL(bucket, key), nil
+	}
+
+	input := s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+
+	request, err := s3Signer.PresignGetObject(ctx, &input, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	return request.URL, nil
+}
+
+func PutObject(ctx context.Context, bucket string, key string, contentType string, body io.Reader) error {
+	if files != nil {
+		return files.write(bucket, key, body, false)
+	}
+
+	input := s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Body:        body,
+	}
+
+	if _, err := S3.PutObject(ctx, &input); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+
+	return nil
+}
+
 func buildTags(tags map[string]string) string {
 	var b strings.Builder
 	b.WriteString("<Tagging><TagSet>")
@@ -123,6 +173,10 @@ func buildTags(tags map[string]string) string {
 }
 
 func DeleteObject(ctx context.Context, bucket string, key string) (*s3.DeleteObjectOutput, error) {
+	if files != nil {
+		return &s3.DeleteObjectOutput{}, files.delete(bucket, key)
+	}
+
 	options := s3.DeleteObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
@@ -130,21 +184,73 @@ func DeleteObject(ctx context.Context, bucket string, key string) (*s3.DeleteObj
 	return S3.DeleteObject(ctx, &options)
 }
 
+// DeleteObjectsWithPrefix removes every object in the bucket whose key starts with prefix
+// and returns how many were deleted. The prefix must end in a slash, so that it stands for
+// a folder rather than the start of any key, like "avatars/ab" would of "avatars/abc".
+func DeleteObjectsWithPrefix(ctx context.Context, bucket string, prefix string) (int, error) {
+	if !strings.HasSuffix(prefix, "/") {
+		return 0, fmt.Errorf("prefix %q does not end in a slash", prefix)
+	}
+
+	if files != nil {
+		return files.deleteWithPrefix(bucket, prefix)
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(
+		S3,
+		&s3.ListObjectsV2Input{
+			Bucket: aws.String(bucket),
+			Prefix: aws.String(prefix),
+		},
+	)
+
+	deleted := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+		}
+
+		if len(page.Contents) == 0 {
+			continue
+		}
+
+		// a page holds at most 1000 keys, which is also the DeleteObjects limit
+		objects := make([]s3types.ObjectIdentifier, len(page.Contents))
+		for i, o := range page.Contents {
+			objects[i] = s3types.ObjectIdentifier{Key: o.Key}
+		}
+
+		out, err := S3.DeleteObjects(
+			ctx,
+			&s3.DeleteObjectsInput{
+				Bucket: aws.String(bucket),
+				Delete: &s3types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+			},
+		)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete objects under %s: %w", prefix, err)
+		}
+
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return deleted, fmt.Errorf("failed to delete %s: %s", aws.ToString(e.Key), aws.ToString(e.Message))
+		}
+
+		deleted += len(objects)
+	}
+
+	return deleted, nil
+}
+
 func CreateAccountDeletionSchedule(ctx context.Context, userId string) (*time.Time, *string, error) {
 	scheduleName := fmt.Sprintf("account-deletion-%s", userId)
 	when := time.Now().UTC().AddDate(0, 0, Env.AccountDeletionOffset)
 	desc := fmt.Sprintf("Deletes user %s account after %d days", userId, Env.AccountDeletionOffset)
 
-	payload, err := json.Marshal(
-		map[string]any{
-			"Event": "AccountDeletion",
-			"Payload": map[string]string{
-				"user_id": userId,
-			},
-		},
-	)
+	payload, err := BackgroundEvent("AccountDeletion", map[string]string{"user_id": userId})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to marshal input payload: %w", err)
+		return nil, nil, err
 	}
 
 	input := scheduler.CreateScheduleInput{
@@ -202,6 +308,40 @@ func DeleteAccountDeletionSchedule(ctx context.Context, scheduleArn *string) err
 	return err
 }
 
+// InvokeBackground hands the event over to the background function without waiting for it to finish.
+func InvokeBackground(ctx context.Context, event string, payload any) error {
+	body, err := BackgroundEvent(event, payload)
+	if err != nil {
+		return err
+	}
+
+	input := lambda.InvokeInput{
+		FunctionName:   aws.String(Env.BackgroundFunctionArn),
+		InvocationType: lambdatypes.InvocationTypeEvent,
+		Payload:        body,
+	}
+
+	if _, err := lambdas.Invoke(ctx, &input); err != nil {
+		return fmt.Errorf("failed to invoke background function: %w", err)
+	}
+
+	return nil
+}
+
+// BackgroundEvent builds the envelope the background function expects.
+func BackgroundEvent(event string, payload any) ([]byte, error) {
+	body, err := json.Marshal(
+		map[string]any{
+			"Event":   event,
+			"Payload": payload,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal input payload: %w", err)
+	}
+	return body, nil
+}
+
 func sendSnsMessage(ctx context.Context, topicArn string, message any) error {
 	var m string
 
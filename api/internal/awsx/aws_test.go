@@ -1,6 +1,7 @@
 package awsx
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 
@@ -60,3 +61,13 @@ func TestBuildTags_UnicodeValues(t *testing.T) {
 
 	assert.Contains(t, result, "<Tag><Key>name</Key><Value>健身</Value></Tag>")
 }
+
+func TestBackgroundEvent_Envelope(t *testing.T) {
+	body, err := BackgroundEvent("DataExport", map[string]string{"user_id": "abc123"})
+	assert.NoError(t, err)
+
+	var decoded map[string]any
+	assert.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Equal(t, "DataExport", decoded["Event"])
+	assert.Equal(t, map[string]any{"user_id": "abc123"}, decoded["Payload"])
+}
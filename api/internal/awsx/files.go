@@ -0,0 +1,203 @@
+package awsx
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Files keeps objects on the local filesystem, one directory per bucket, for running the API
+// without S3. Once UseFiles has set it up, the object functions of this package work on it instead.
+type Files struct {
+	Dir    string // where the buckets are kept
+	URL    string // where the API serves the buckets back, e.g. http://localhost:8080/files
+	secret []byte // signs upload links; a restart voids those still out
+}
+
+// ErrBadUpload is what Files.Save returns for an upload its link does not allow.
+var ErrBadUpload = errors.New("upload does not match its link")
+
+var files *Files
+
+// LocalFiles returns where objects are kept when UseFiles stands in for S3, nil otherwise.
+func LocalFiles() *Files {
+	return files
+}
+
+// UseFiles has objects kept under dir and served from url rather than S3.
+func UseFiles(dir, url string) (*Files, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	f := &Files{Dir: dir, URL: strings.TrimSuffix(url, "/"), secret: make([]byte, 32)}
+	if _, err := rand.Read(f.secret); err != nil {
+		return nil, err
+	}
+
+	files = f
+	return f, nil
+}
+
+// Path returns where the object is kept, or an error for keys that would reach outside its bucket.
+func (f *Files) Path(bucket, key string) (string, error) {
+	if !filepath.IsLocal(bucket) || strings.ContainsAny(bucket, `/\`) || !filepath.IsLocal(key) {
+		return "", fs.ErrInvalid
+	}
+	return filepath.Join(f.Dir, bucket, filepath.FromSlash(key)), nil
+}
+
+// ObjectURL is where anyone can download the object from.
+func (f *Files) ObjectURL(bucket, key string) string {
+	return fmt.Sprintf("%s/%s/%s", f.URL, bucket, key)
+}
+
+// presignPost hands out the fields of a form upload the way S3 does: post them along with
+// the file to the URL. The signature pins them, so the key or the tags cannot be swapped.
+func (f *Files) presignPost(bucket, key, contentType string, tagging *map[string]string) *s3.PresignedPostRequest {
+	values := map[string]string{
+		"key":          key,
+		"Content-Type": contentType,
+		"expires":      strconv.FormatInt(time.Now().Add(15*time.Minute).Unix(), 10),
+	}
+	if tagging != nil {
+		values["tagging"] = buildTags(*tagging)
+	}
+	values["signature"] = f.sign(bucket, values)
+
+	return &s3.PresignedPostRequest{URL: fmt.Sprintf("%s/%s", f.URL, bucket), Values: values}
+}
+
+func (f *Files) sign(bucket string, values map[string]string) string {
+	mac := hmac.New(sha256.New, f.secret)
+	for _, part := range []string{bucket, values["key"], values["Content-Type"], values["tagging"], values["expires"]} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Save keeps a file posted through a link from GeneratePresignedPostURL, once its fields check out.
+// An upload tagged with a destination, as the media pipeline would move it, lands in that bucket.
+func (f *Files) Save(bucket string, fields map[string]string, body io.Reader) (string, error) {
+	if !hmac.Equal([]byte(fields["signature"]), []byte(f.sign(bucket, fields))) {
+		return "", fmt.Errorf("%w: bad signature", ErrBadUpload)
+	}
+
+	expires, err := strconv.ParseInt(fields["expires"], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return "", fmt.Errorf("%w: link expired", ErrBadUpload)
+	}
+
+	if destination := tagValue(fields["tagging"], "destination"); destination != "" {
+		bucket = destination
+	}
+
+	key := fields["key"]
+	if err := f.write(bucket, key, io.LimitReader(body, maxContentLength+1), true); err != nil {
+		return "", err
+	}
+	return f.ObjectURL(bucket, key), nil
+}
+
+// write puts the object in place whole, or leaves what was there before.
+func (f *Files) write(bucket, key string, body io.Reader, limited bool) error {
+	path, err := f.Path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, body)
+	if err == nil && limited && (n < minContentLength || n > maxContentLength) {
+		err = fmt.Errorf("%w: %d bytes is out of range", ErrBadUpload, n)
+	}
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *Files) delete(bucket, key string) error {
+	path, err := f.Path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *Files) deleteWithPrefix(bucket, prefix string) (int, error) {
+	root, err := f.Path(bucket, ".")
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil || !strings.HasPrefix(filepath.ToSlash(rel), prefix) {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		deleted++
+		return nil
+	})
+	return deleted, err
+}
+
+// tagValue reads one tag out of a tag set as buildTags writes it.
+func tagValue(tagging, key string) string {
+	var set struct {
+		Tags []struct {
+			Key   string `xml:"Key"`
+			Value string `xml:"Value"`
+		} `xml:"TagSet>Tag"`
+	}
+	if xml.Unmarshal([]byte(tagging), &set) != nil {
+		return ""
+	}
+
+	for _, tag := range set.Tags {
+		if tag.Key == key {
+			return tag.Value
+		}
+	}
+	return ""
+}
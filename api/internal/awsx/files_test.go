@@ -0,0 +1,105 @@
+package awsx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useFiles(t *testing.T) *Files {
+	t.Helper()
+	f, err := UseFiles(t.TempDir(), "http://localhost:8080/files/")
+	require.NoError(t, err)
+	t.Cleanup(func() { files = nil })
+	return f
+}
+
+func TestFiles_SavesUploadsWhereTheyAreHeaded(t *testing.T) {
+	f := useFiles(t)
+	image := bytes.Repeat([]byte{1}, minContentLength)
+
+	tags := map[string]string{"destination": "media", "userId": "u1"}
+	link, err := GeneratePresignedPostURL(context.Background(), "uploads", "workouts/abc/1.jpg", "image/jpeg", &tags)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/files/uploads", link.URL)
+
+	url, err := f.Save("uploads", link.Values, bytes.NewReader(image))
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/files/media/workouts/abc/1.jpg", url)
+
+	saved, err := os.ReadFile(filepath.Join(f.Dir, "media", "workouts", "abc", "1.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, image, saved)
+
+	_, err = DeleteObject(context.Background(), "media", "workouts/abc/1.jpg")
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(f.Dir, "media", "workouts", "abc", "1.jpg"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestFiles_RefusesUploadsTheLinkDoesNotAllow(t *testing.T) {
+	f := useFiles(t)
+	image := bytes.Repeat([]byte{1}, minContentLength)
+
+	link, err := GeneratePresignedPostURL(context.Background(), "uploads", "avatars/u1", "image/jpeg", nil)
+	require.NoError(t, err)
+
+	swapped := map[string]string{}
+	for k, v := range link.Values {
+		swapped[k] = v
+	}
+	swapped["key"] = "avatars/u2"
+	_, err = f.Save("uploads", swapped, bytes.NewReader(image))
+	assert.ErrorIs(t, err, ErrBadUpload)
+
+	_, err = f.Save("media", link.Values, bytes.NewReader(image))
+	assert.ErrorIs(t, err, ErrBadUpload, "signed for another bucket")
+
+	_, err = f.Save("uploads", link.Values, bytes.NewReader([]byte("too small")))
+	assert.ErrorIs(t, err, ErrBadUpload)
+
+	_, err = f.Path("uploads", "../../etc/passwd")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+}
+
+func TestFiles_DeletesWithPrefix(t *testing.T) {
+	f := useFiles(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"exports/u1/a.zip", "exports/u1/b.zip", "exports/u2/a.zip"} {
+		require.NoError(t, PutObject(ctx, "media", key, "application/zip", bytes.NewReader([]byte("zip"))))
+	}
+
+	deleted, err := DeleteObjectsWithPrefix(ctx, "media", "exports/u1/")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	_, err = os.Stat(filepath.Join(f.Dir, "media", "exports", "u2", "a.zip"))
+	assert.NoError(t, err)
+
+	deleted, err = DeleteObjectsWithPrefix(ctx, "nothing-here", "exports/")
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func TestDeleteObjectsWithPrefix_OnlyFolders(t *testing.T) {
+	f := useFiles(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"avatars/u1", "avatars/u12"} {
+		require.NoError(t, PutObject(ctx, "media", key, "image/jpeg", bytes.NewReader([]byte("jpg"))))
+	}
+
+	_, err := DeleteObjectsWithPrefix(ctx, "media", "avatars/u1")
+	assert.Error(t, err)
+
+	_, err = os.Stat(filepath.Join(f.Dir, "media", "avatars", "u12"))
+	assert.NoError(t, err, "another user's avatar")
+}
@@ -0,0 +1,65 @@
+package awsx
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Objects is the object storage media and exports are kept in: S3, or the local files standing in for it.
+type Objects interface {
+	GeneratePresignedPostURL(ctx context.Context, bucket string, key string, contentType string, tagging *map[string]string) (*s3.PresignedPostRequest, error)
+	GeneratePresignedGetURL(ctx context.Context, bucket string, key string, expires time.Duration) (string, error)
+	DeleteObject(ctx context.Context, bucket string, key string) (*s3.DeleteObjectOutput, error)
+}
+
+// Jobs hands work over to the background function, now or, for account deletions, later,
+// and tells monitoring what users report.
+type Jobs interface {
+	InvokeBackground(ctx context.Context, event string, payload any) error
+	CreateAccountDeletionSchedule(ctx context.Context, userId string) (*time.Time, *string, error)
+	DeleteAccountDeletionSchedule(ctx context.Context, scheduleArn *string) error
+	SendToMonitoring(ctx context.Context, message any) error
+}
+
+// Services are what the handlers call on besides the data stores.
+type Services struct {
+	Objects Objects
+	Jobs    Jobs
+}
+
+// NewServices returns the services as Init, or UseFiles, set them up.
+func NewServices() Services {
+	return Services{Objects: services{}, Jobs: services{}}
+}
+
+type services struct{}
+
+func (services) GeneratePresignedPostURL(ctx context.Context, bucket string, key string, contentType string, tagging *map[string]string) (*s3.PresignedPostRequest, error) {
+	return GeneratePresignedPostURL(ctx, bucket, key, contentType, tagging)
+}
+
+func (services) GeneratePresignedGetURL(ctx context.Context, bucket string, key string, expires time.Duration) (string, error) {
+	return GeneratePresignedGetURL(ctx, bucket, key, expires)
+}
+
+func (services) DeleteObject(ctx context.Context, bucket string, key string) (*s3.DeleteObjectOutput, error) {
+	return DeleteObject(ctx, bucket, key)
+}
+
+func (services) InvokeBackground(ctx context.Context, event string, payload any) error {
+	return InvokeBackground(ctx, event, payload)
+}
+
+func (services) CreateAccountDeletionSchedule(ctx context.Context, userId string) (*time.Time, *string, error) {
+	return CreateAccountDeletionSchedule(ctx, userId)
+}
+
+func (services) DeleteAccountDeletionSchedule(ctx context.Context, scheduleArn *string) error {
+	return DeleteAccountDeletionSchedule(ctx, scheduleArn)
+}
+
+func (services) SendToMonitoring(ctx context.Context, message any) error {
+	return SendToMonitoring(ctx, message)
+}
@@ -0,0 +1,102 @@
+// Package background holds the jobs the API hands over rather than doing them within a request:
+// purging a deleted account, building a personal data export and rebuilding exercise history.
+// The background Lambda runs them on AWS; Local runs them in the API process without it.
+package background
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"heart/internal/dbx"
+	"heart/internal/firebasex"
+	"heart/internal/models"
+	"log"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// Handle runs the job the event names over the given stores, and returns the invocation result.
+// Events are what awsx.InvokeBackground sends: the job under "Event", its input under "Payload".
+func Handle(ctx context.Context, stores dbx.Stores, event map[string]interface{}) (map[string]interface{}, error) {
+	log.Printf("Received event: %+v", event)
+
+	eventType, ok := event["Event"].(string)
+	if !ok {
+		return nil, models.NewValidationError(errors.New("missing Event field"))
+	}
+
+	switch eventType {
+	case "AccountDeletion":
+		userID, err := payloadUserID(event)
+		if err != nil {
+			return nil, err
+		}
+
+		report, err := purgeAccount(ctx, stores, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		// offline, without Firebase, there is no sign-in to delete
+		if firebasex.AuthClient != nil {
+			err = firebasex.DeleteUser(ctx, userID)
+			if err != nil && !auth.IsUserNotFound(err) { // already gone on a retry
+				return nil, models.NewServerError(err)
+			}
+		}
+
+		return map[string]interface{}{
+			"statusCode":     200,
+			"body":           fmt.Sprintf("Successfully deleted account for user %s (%s)", userID, report),
+			"deletedItems":   report.Items,
+			"deletedObjects": report.Objects,
+		}, nil
+	case "DataExport":
+		userID, err := payloadUserID(event)
+		if err != nil {
+			return nil, err
+		}
+
+		export, err := exportData(ctx, stores, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"statusCode": 200,
+			"body":       fmt.Sprintf("Successfully exported data for user %s", userID),
+			"key":        *export.ObjectKey,
+		}, nil
+	case "RebuildHistory":
+		userID, err := payloadUserID(event)
+		if err != nil {
+			return nil, err
+		}
+
+		count, err := stores.Workouts.RebuildHistory(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"statusCode": 200,
+			"body":       fmt.Sprintf("Successfully rebuilt exercise history of %d workouts for user %s", count, userID),
+		}, nil
+	default:
+		return nil, models.NewValidationError(errors.New("invalid event type"))
+	}
+}
+
+func payloadUserID(event map[string]interface{}) (string, error) {
+	payload, ok := event["Payload"].(map[string]interface{})
+	if !ok {
+		return "", models.NewValidationError(errors.New("missing Payload field"))
+	}
+
+	userID, ok := payload["user_id"].(string)
+	if !ok {
+		return "", models.NewValidationError(errors.New("missing user_id field"))
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,144 @@
+package background
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"heart/internal/awsx"
+	"heart/internal/config"
+	"heart/internal/dbx"
+	"heart/internal/export"
+	"heart/internal/models"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const exportPageSize = 100
+
+// exportData gathers everything the user has stored with us into a zip in the media bucket
+// and marks their export as ready to download.
+// A failure is recorded on the export before it is returned, so the app can stop waiting.
+func exportData(ctx context.Context, stores dbx.Stores, userId string) (*models.DataExport, error) {
+	current, err := stores.Accounts.GetDataExport(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := buildExport(ctx, stores, userId, current)
+	if err != nil {
+		current.Status = models.ExportFailed
+		if _, saveErr := stores.Accounts.SaveDataExport(ctx, *current); saveErr != nil {
+			log.Printf("Failed to mark export of user %s as failed: %v", userId, saveErr)
+		}
+		return nil, err
+	}
+
+	previous := current.ObjectKey
+	now := time.Now().UTC()
+	current.Status = models.ExportReady
+	current.CompletedAt = &now
+	current.ObjectKey = &key
+
+	saved, err := stores.Accounts.SaveDataExport(ctx, *current)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the latest export is kept
+	if previous != nil && *previous != key {
+		if _, err := awsx.DeleteObject(ctx, config.App.MediaBucket, *previous); err != nil {
+			log.Printf("Failed to delete previous export %s: %v", *previous, err)
+		}
+	}
+
+	return saved, nil
+}
+
+// buildExport writes the archive, with the export being built in it, and returns its key in the media bucket.
+func buildExport(ctx context.Context, stores dbx.Stores, userId string, current *models.DataExport) (string, error) {
+	takeout, err := gatherTakeout(ctx, stores, userId)
+	if err != nil {
+		return "", err
+	}
+	takeout.Export = current
+
+	var archive bytes.Buffer
+	if err := export.WriteArchive(&archive, takeout); err != nil {
+		return "", models.NewServerError(err)
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", models.NewServerError(err)
+	}
+
+	key := fmt.Sprintf("%s%s.zip", config.App.ExportPrefix(userId), id)
+	if err := awsx.PutObject(ctx, config.App.MediaBucket, key, "application/zip", &archive); err != nil {
+		return "", models.NewServerError(err)
+	}
+
+	return key, nil
+}
+
+func gatherTakeout(ctx context.Context, stores dbx.Stores, userId string) (*export.Takeout, error) {
+	profile, err := stores.Accounts.GetAccount(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	takeout := &export.Takeout{Profile: profile}
+	if profile != nil {
+		account := models.NewUserInternal(profile)
+		takeout.Account = &account
+	}
+
+	cursor := ""
+	for {
+		workouts, next, err := stores.Workouts.GetWorkouts(ctx, userId, exportPageSize, cursor, models.WorkoutFilter{})
+		if err != nil {
+			return nil, err
+		}
+		takeout.Workouts = append(takeout.Workouts, models.NewWorkoutsArray(workouts, config.App.MediaDistributionAlias)...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	templates, err := stores.Templates.GetTemplates(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	takeout.Templates = models.NewTemplateArray(templates)
+
+	programs, err := stores.Templates.GetPrograms(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	takeout.Programs = models.NewProgramArray(programs)
+
+	exercises, err := stores.Exercises.GetOwnExercises(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exercises {
+		takeout.Exercises = append(takeout.Exercises, models.NewExerciseOut(&e))
+	}
+
+	cursor = ""
+	for {
+		images, next, err := stores.Workouts.GetWorkoutGallery(ctx, userId, exportPageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		takeout.Progress = append(takeout.Progress, images...)
+		if next == nil {
+			break
+		}
+		cursor = *next
+	}
+
+	return takeout, nil
+}
@@ -0,0 +1,157 @@
+package background
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"heart/internal/awsx"
+	"heart/internal/dbx"
+	"heart/internal/localdb"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Local runs the background jobs in the API process, for running it without AWS. Jobs handed
+// over run at once, scheduled ones at their time, kept in the store so that a restart re-arms
+// them, and what goes to monitoring goes to the log.
+type Local struct {
+	stores    dbx.Stores
+	schedules *localdb.Store
+	offset    int // days before a deleted account is purged
+
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+	jobs   sync.WaitGroup
+}
+
+// NewLocal returns jobs that run over the stores, with schedules kept in the given store,
+// and arms those kept from before.
+func NewLocal(ctx context.Context, stores dbx.Stores, schedules *localdb.Store, offset int) (*Local, error) {
+	l := &Local{stores: stores, schedules: schedules, offset: offset, timers: map[string]*time.Timer{}}
+
+	kept, err := schedules.GetSchedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range kept {
+		l.arm(s)
+	}
+
+	return l, nil
+}
+
+// InvokeBackground runs the job without waiting for it to finish, as the background function would.
+func (l *Local) InvokeBackground(_ context.Context, event string, payload any) error {
+	envelope, err := decodeEvent(event, payload)
+	if err != nil {
+		return err
+	}
+
+	l.jobs.Add(1)
+	go func() {
+		defer l.jobs.Done()
+		l.run(envelope)
+	}()
+	return nil
+}
+
+// CreateAccountDeletionSchedule has the account purged once the deletion offset has passed.
+// Like on AWS, an account already scheduled keeps its schedule, and gets none back.
+func (l *Local) CreateAccountDeletionSchedule(ctx context.Context, userId string) (*time.Time, *string, error) {
+	name := fmt.Sprintf("account-deletion-%s", userId)
+	when := time.Now().UTC().AddDate(0, 0, l.offset)
+
+	schedule := localdb.NewSchedule(name, "AccountDeletion", userId, when)
+	saved, err := l.schedules.SaveSchedule(ctx, schedule)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+	if !saved {
+		return &when, nil, nil
+	}
+
+	l.arm(schedule)
+	return &when, &name, nil
+}
+
+// DeleteAccountDeletionSchedule calls off a purge scheduled before.
+func (l *Local) DeleteAccountDeletionSchedule(ctx context.Context, scheduleArn *string) error {
+	if scheduleArn == nil {
+		return nil
+	}
+
+	parts := strings.Split(*scheduleArn, "/")
+	name := parts[len(parts)-1]
+
+	l.mu.Lock()
+	if timer, ok := l.timers[name]; ok {
+		timer.Stop()
+		delete(l.timers, name)
+	}
+	l.mu.Unlock()
+
+	return l.schedules.DeleteSchedule(ctx, name)
+}
+
+// SendToMonitoring logs the message, there being no topic to send it to.
+func (l *Local) SendToMonitoring(_ context.Context, message any) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	log.Printf("[MONITORING] %s", body)
+	return nil
+}
+
+// Wait blocks until the jobs handed over so far have finished.
+func (l *Local) Wait() {
+	l.jobs.Wait()
+}
+
+// arm runs the schedule at its time, at once if that has passed, and drops it once it has run.
+func (l *Local) arm(s localdb.Schedule) {
+	envelope, err := decodeEvent(s.Event, map[string]string{"user_id": s.UserID})
+	if err != nil {
+		log.Printf("[ERROR] arming schedule %s: %v", s.Name(), err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.timers[s.Name()] = time.AfterFunc(time.Until(s.At), func() {
+		l.mu.Lock()
+		delete(l.timers, s.Name())
+		l.mu.Unlock()
+
+		l.run(envelope)
+		if err := l.schedules.DeleteSchedule(context.Background(), s.Name()); err != nil {
+			log.Printf("[ERROR] dropping schedule %s: %v", s.Name(), err)
+		}
+	})
+}
+
+func (l *Local) run(event map[string]interface{}) {
+	if _, err := Handle(context.Background(), l.stores, event); err != nil {
+		log.Printf("[ERROR] background job %v: %v", event["Event"], err)
+	}
+}
+
+// decodeEvent builds the event as the background function receives it.
+func decodeEvent(event string, payload any) (map[string]interface{}, error) {
+	body, err := awsx.BackgroundEvent(event, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope map[string]interface{}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to read event: %w", err)
+	}
+	return envelope, nil
+}
+
+var _ awsx.Jobs = (*Local)(nil)
@@ -0,0 +1,80 @@
+package background
+
+import (
+	"context"
+	"heart/internal/awsx"
+	"heart/internal/config"
+	"heart/internal/dbx"
+	"heart/internal/localdb"
+	"heart/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLocal_RunsSchedulesDueWhileItWasDown(t *testing.T) {
+	ctx := context.Background()
+	config.App = &config.AppConfig{}
+	config.App.MediaBucket = "media"
+	_, err := awsx.UseFiles(t.TempDir(), "http://example.com/files")
+	require.NoError(t, err)
+
+	store := localdb.NewMemory()
+	_, err = store.SaveAccount(ctx, "u1", models.User{})
+	require.NoError(t, err)
+	_, err = store.SaveSchedule(ctx, localdb.NewSchedule("account-deletion-u1", "AccountDeletion", "u1", time.Now().Add(-time.Hour)))
+	require.NoError(t, err)
+
+	_, err = NewLocal(ctx, dbx.NewStores(store), store, 30)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		schedules, err := store.GetSchedules(ctx)
+		return err == nil && len(schedules) == 0
+	}, time.Second, 10*time.Millisecond)
+	account, err := store.GetAccount(ctx, "u1")
+	require.NoError(t, err)
+	assert.Nil(t, account, "the account is purged")
+}
+
+func TestLocal_DeleteAccountDeletionSchedule_CallsThePurgeOff(t *testing.T) {
+	ctx := context.Background()
+	store := localdb.NewMemory()
+	jobs, err := NewLocal(ctx, dbx.NewStores(store), store, 30)
+	require.NoError(t, err)
+
+	when, name, err := jobs.CreateAccountDeletionSchedule(ctx, "u1")
+	require.NoError(t, err)
+	require.NotNil(t, name)
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, 30), *when, time.Minute)
+
+	_, again, err := jobs.CreateAccountDeletionSchedule(ctx, "u1")
+	require.NoError(t, err)
+	assert.Nil(t, again, "an account already scheduled keeps its schedule")
+
+	require.NoError(t, jobs.DeleteAccountDeletionSchedule(ctx, name))
+	schedules, err := store.GetSchedules(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, schedules)
+	assert.Empty(t, jobs.timers)
+}
+
+func TestHandle_RebuildsHistoryThroughTheStores(t *testing.T) {
+	ctx := context.Background()
+	store := localdb.NewMemory()
+	_, err := store.ImportWorkouts(ctx, []models.Workout{{
+		PK:        "USER#u1",
+		SK:        "WORKOUT#w1",
+		Exercises: []models.WorkoutExercise{{ExerciseID: "Squat", Sets: []models.Set{{ID: "s1", Reps: 5}}}},
+	}})
+	require.NoError(t, err)
+
+	event, err := decodeEvent("RebuildHistory", map[string]string{"user_id": "u1"})
+	require.NoError(t, err)
+	result, err := Handle(ctx, dbx.NewStores(store), event)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Successfully rebuilt exercise history of 1 workouts for user u1", result["body"])
+}
@@ -0,0 +1,68 @@
+package background
+
+import (
+	"context"
+	"fmt"
+	"heart/internal/awsx"
+	"heart/internal/config"
+	"heart/internal/dbx"
+	"heart/internal/models"
+	"log"
+	"strings"
+)
+
+// purgeReport is what a purge leaves behind in the invocation result.
+type purgeReport struct {
+	Items   int `json:"items"`
+	Objects int `json:"objects"`
+}
+
+func (r *purgeReport) String() string {
+	return fmt.Sprintf("%d items, %d objects", r.Items, r.Objects)
+}
+
+// purgeAccount deletes everything the user has stored with us: every item under their partition,
+// the templates they shared, and every media object keyed by their ID or by one of their workouts.
+// Media goes first, since workout images can only be found through the workout items;
+// that way a failed attempt can be retried from the start.
+func purgeAccount(ctx context.Context, stores dbx.Stores, userId string) (*purgeReport, error) {
+	keys, err := stores.Accounts.GetAccountItemKeys(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := []string{config.App.ExportPrefix(userId), config.App.ExerciseImagePrefix(userId)}
+	var shared []models.ItemKey
+	for _, k := range keys {
+		if workoutId, ok := strings.CutPrefix(k.SK, models.WorkoutKey); ok {
+			prefixes = append(prefixes, config.App.WorkoutImagePrefix(userId, workoutId))
+		}
+		if code, ok := strings.CutPrefix(k.SK, models.ShareKey); ok {
+			shared = append(shared, models.ItemKey{PK: models.SharedKey + code, SK: models.SharedKey + code})
+		}
+	}
+	keys = append(keys, shared...)
+
+	report := &purgeReport{}
+
+	// the avatar key is no prefix: it is the start of every longer user ID too
+	if _, err := awsx.DeleteObject(ctx, config.App.MediaBucket, config.App.AvatarKey(userId)); err != nil {
+		return report, models.NewServerError(err)
+	}
+
+	for _, prefix := range prefixes {
+		deleted, err := awsx.DeleteObjectsWithPrefix(ctx, config.App.MediaBucket, prefix)
+		report.Objects += deleted
+		if err != nil {
+			return report, models.NewServerError(err)
+		}
+	}
+
+	report.Items, err = stores.Accounts.DeleteItems(ctx, keys)
+	if err != nil {
+		return report, err
+	}
+
+	log.Printf("Purged user %s: %d items, %d objects", userId, report.Items, report.Objects)
+	return report, nil
+}
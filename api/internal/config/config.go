@@ -1,10 +1,14 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"reflect"
 	"strconv"
+
+	"github.com/Masterminds/semver/v3"
 )
 
 type SchedulerConfig struct {
@@ -28,6 +32,26 @@ func (c *S3Config) AvatarKey(userId string) string {
 	return fmt.Sprintf("avatars/%s", userId)
 }
 
+// ExportPrefix returns the key prefix under which the user's data exports are stored.
+func (c *S3Config) ExportPrefix(userId string) string {
+	return fmt.Sprintf("exports/%s/", userId)
+}
+
+// WorkoutImagePrefix returns the key prefix under which images of the given workout are stored.
+// The user and workout IDs are hashed so the keys don't leak them.
+func (c *S3Config) WorkoutImagePrefix(userId, workoutId string) string {
+	h := sha256.Sum256([]byte(userId + ":" + workoutId))
+	hash := hex.EncodeToString(h[:])[:16] // 16 hex chars = 64 bits, plenty unique
+	return fmt.Sprintf("workouts/%s/", hash)
+}
+
+// ExerciseImagePrefix returns the key prefix under which images of the user's own exercises are stored.
+// The user ID is hashed so the keys don't leak it.
+func (c *S3Config) ExerciseImagePrefix(userId string) string {
+	h := sha256.Sum256([]byte(userId))
+	return fmt.Sprintf("custom-exercises/%s/", hex.EncodeToString(h[:])[:16])
+}
+
 type LambdaConfig struct {
 	BackgroundFunctionArn  string `env:"BACKGROUND_FUNCTION" required:"true"`
 	BackgroundFunctionRole string `env:"BACKGROUND_ROLE" required:"true"`
@@ -52,22 +76,81 @@ type SentryConfig struct {
 	SentryDSN string `env:"SENTRY_DSN"`
 }
 
+// AuthTrustBearer lets FirebaseConfig.Auth take bearer tokens for user IDs when Firebase isn't set up.
+// Anyone can then act as any user, so it's only for trying the API out.
+const AuthTrustBearer = "insecure-trust-bearer"
+
 type FirebaseConfig struct {
 	Credentials string `env:"FIREBASE_CREDENTIALS"`
+	Auth        string `env:"AUTH"`
 }
 
 type DynamoDBConfig struct {
 	WorkoutsTable string `env:"WORKOUTS_TABLE" required:"true"`
+	SyncIndex     string `env:"SYNC_INDEX" default:"updated"` // PK + updated_at
+}
+
+// Where StorageConfig.Storage can keep the data.
+const (
+	StorageDynamoDB = "dynamodb"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+)
+
+// StorageConfig says where the API keeps its data. Anything but DynamoDB needs no AWS:
+// objects are then kept under FilesDir and served back from FilesURL.
+type StorageConfig struct {
+	Storage    string `env:"STORAGE" default:"dynamodb"`
+	SQLitePath string `env:"SQLITE_PATH" default:"heart.db"`
+	FilesDir   string `env:"FILES_DIR" default:"files"`
+	FilesURL   string `env:"FILES_URL" default:"http://localhost:8080/files"`
+}
+
+// Local tells whether the data is kept without AWS.
+func (c *StorageConfig) Local() bool {
+	return c.Storage != StorageDynamoDB
+}
+
+// ClientConfig says how the API answers apps, by their version.
+type ClientConfig struct {
+	ProblemDetailsAppVersion string `env:"PROBLEM_DETAILS_APP_VERSION"`
+	// ProblemDetailsSince is ProblemDetailsAppVersion, parsed once the config is read; nil if it is not set.
+	// Apps older than it get errors in the bodies they were built for, not as problem details.
+	ProblemDetailsSince *semver.Version
+}
+
+func (c *ClientConfig) parse() error {
+	if c.ProblemDetailsAppVersion == "" {
+		return nil
+	}
+
+	v, err := semver.NewVersion(c.ProblemDetailsAppVersion)
+	if err != nil {
+		return fmt.Errorf("invalid version for PROBLEM_DETAILS_APP_VERSION: %v", err)
+	}
+	c.ProblemDetailsSince = v
+	return nil
 }
 
 type AppConfig struct {
 	AwsConfig
+	StorageConfig
 	SentryConfig
 	FirebaseConfig
 	SwaggerConfig
+	ClientConfig
 	CORSOrigins string `env:"CORS_ORIGINS" default:"*"` // Comma-separated list of allowed origins
 }
 
+// BackgroundConfig is what the background Lambda needs to clean up after users.
+type BackgroundConfig struct {
+	FirebaseConfig
+	DynamoDBConfig
+	CloudFrontConfig
+	MediaBucket string `env:"MEDIA_BUCKET" required:"true"`
+	AwsRegion   string `env:"REGION" required:"true"`
+}
+
 type SwaggerConfig struct {
 	Host        string `env:"SWAGGER_HOST" default:"localhost:8080"`
 	DocsEnabled bool   `env:"SWAGGER_DOCS_ENABLED" default:"true"`
@@ -79,6 +162,23 @@ func NewAppConfig() (*AppConfig, error) {
 	if err := populate(cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.ClientConfig.parse(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// NewOfflineAppConfig reads the config like NewAppConfig, but leaves unset what is required only
+// to reach AWS and Firebase, for running the API without either.
+func NewOfflineAppConfig() (*AppConfig, error) {
+	cfg := &AppConfig{}
+	v := reflect.ValueOf(cfg).Elem()
+	if err := fromEnv(v, v.Type(), false); err != nil {
+		return nil, err
+	}
+	if err := cfg.ClientConfig.parse(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
 
@@ -90,19 +190,32 @@ func NewFirebaseConfig() (config *FirebaseConfig, err error) {
 	return cfg, nil
 }
 
-func fromEnv(v reflect.Value, t reflect.Type) error {
+func NewBackgroundConfig() (*BackgroundConfig, error) {
+	cfg := &BackgroundConfig{}
+	if err := populate(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// fromEnv fills the fields of v from the environment. Unless strict, required fields that are not set
+// are left empty rather than failing.
+func fromEnv(v reflect.Value, t reflect.Type, strict bool) error {
 	for i := 0; i < v.NumField(); i++ {
 		field := t.Field(i)
 		fieldVal := v.Field(i)
 
 		if field.Type.Kind() == reflect.Struct {
-			if err := fromEnv(fieldVal, field.Type); err != nil {
+			if err := fromEnv(fieldVal, field.Type, strict); err != nil {
 				return err
 			}
 			continue
 		}
 
 		envKey := field.Tag.Get("env")
+		if envKey == "" {
+			continue // worked out from the rest once read
+		}
 		defaultVal := field.Tag.Get("default")
 		required := field.Tag.Get("required") == "true"
 
@@ -114,7 +227,10 @@ func fromEnv(v reflect.Value, t reflect.Type) error {
 		} else if defaultVal != "" {
 			finalVal = defaultVal
 		} else if required {
-			return fmt.Errorf("required env var %s not set", envKey)
+			if strict {
+				return fmt.Errorf("required env var %s not set", envKey)
+			}
+			continue
 		}
 
 		switch field.Type.Kind() {
@@ -143,7 +259,7 @@ func populate(cfg any) error {
 	v := reflect.ValueOf(cfg).Elem()
 	t := v.Type()
 
-	if err := fromEnv(v, t); err != nil {
+	if err := fromEnv(v, t, true); err != nil {
 		return err
 	}
 	return nil
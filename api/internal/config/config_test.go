@@ -67,6 +67,55 @@ func TestNewAppConfig_InvalidInt(t *testing.T) {
 	assert.Contains(t, err.Error(), "ACCOUNT_DELETION_OFFSET")
 }
 
+func TestNewOfflineAppConfig_LeavesRequiredUnset(t *testing.T) {
+	t.Setenv("WORKOUTS_TABLE", "")
+	_ = os.Unsetenv("WORKOUTS_TABLE")
+
+	cfg, err := NewOfflineAppConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "", cfg.WorkoutsTable)
+	assert.Equal(t, 30, cfg.AccountDeletionOffset)
+	assert.Equal(t, "*", cfg.CORSOrigins)
+
+	_, err = NewAppConfig()
+	assert.Error(t, err)
+}
+
+func TestStorageConfig_DefaultsToDynamoDB(t *testing.T) {
+	cfg, err := NewOfflineAppConfig()
+	require.NoError(t, err)
+	assert.Equal(t, StorageDynamoDB, cfg.Storage)
+	assert.False(t, cfg.Local())
+
+	t.Setenv("STORAGE", StorageSQLite)
+	t.Setenv("SQLITE_PATH", "/var/lib/heart/heart.db")
+
+	cfg, err = NewOfflineAppConfig()
+	require.NoError(t, err)
+	assert.True(t, cfg.Local())
+	assert.Equal(t, "/var/lib/heart/heart.db", cfg.SQLitePath)
+	assert.Equal(t, "files", cfg.FilesDir)
+}
+
+func TestNewAppConfig_ParsesTheProblemDetailsVersionOnce(t *testing.T) {
+	setMinimalAppEnv(t)
+
+	cfg, err := NewAppConfig()
+	require.NoError(t, err)
+	assert.Nil(t, cfg.ProblemDetailsSince)
+
+	t.Setenv("PROBLEM_DETAILS_APP_VERSION", "2.9.0")
+	cfg, err = NewAppConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "2.9.0", cfg.ProblemDetailsSince.String())
+
+	t.Setenv("PROBLEM_DETAILS_APP_VERSION", "two point nine")
+	_, err = NewAppConfig()
+	require.ErrorContains(t, err, "PROBLEM_DETAILS_APP_VERSION")
+	_, err = NewOfflineAppConfig()
+	require.ErrorContains(t, err, "PROBLEM_DETAILS_APP_VERSION")
+}
+
 func TestFromEnv_RequiredMissing(t *testing.T) {
 	// Don't set FOO to trigger required failure
 	t.Setenv("FOO", "") // note: Setenv sets it. We must actually unset to test required missing.
@@ -79,7 +128,7 @@ func TestFromEnv_RequiredMissing(t *testing.T) {
 	var r req
 	v := reflect.ValueOf(&r).Elem()
 	typ := v.Type()
-	err := fromEnv(v, typ)
+	err := fromEnv(v, typ, true)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "FOO")
 }
@@ -100,9 +149,21 @@ func TestS3Helpers(t *testing.T) {
 	c := S3Config{MediaBucket: "media-bkt"}
 	// AvatarKey
 	assert.Equal(t, "avatars/user-1", c.AvatarKey("user-1"))
+	// ExportPrefix
+	assert.Equal(t, "exports/user-1/", c.ExportPrefix("user-1"))
 	// UploadDestinationTag must return a map with destination key pointing to MediaBucket
 	tag := c.UploadDestinationTag()
 	assert.Equal(t, map[string]string{"destination": "media-bkt"}, tag)
+	// WorkoutImagePrefix is stable per user and workout and never leaks either ID
+	prefix := c.WorkoutImagePrefix("user-1", "w1")
+	assert.Equal(t, prefix, c.WorkoutImagePrefix("user-1", "w1"))
+	assert.NotEqual(t, prefix, c.WorkoutImagePrefix("user-1", "w2"))
+	assert.Regexp(t, `^workouts/[0-9a-f]{16}/$`, prefix)
+	// ExerciseImagePrefix is stable per user and never leaks the ID
+	exercises := c.ExerciseImagePrefix("user-1")
+	assert.Equal(t, exercises, c.ExerciseImagePrefix("user-1"))
+	assert.NotEqual(t, exercises, c.ExerciseImagePrefix("user-2"))
+	assert.Regexp(t, `^custom-exercises/[0-9a-f]{16}/$`, exercises)
 }
 
 func TestNewFirebaseConfig(t *testing.T) {
@@ -7,6 +7,7 @@ import (
 	"heart/internal/config"
 	"heart/internal/models"
 	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -164,3 +165,90 @@ func RemoveAvatar(ctx context.Context, userId string) error {
 	}
 	return nil
 }
+
+// GetAccountItemKeys pages through the user's partition and returns the keys of every item in it:
+// the account itself, workouts, templates, own exercises and progress images.
+func GetAccountItemKeys(ctx context.Context, userId string) ([]models.ItemKey, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(config.App.WorkoutsTable),
+		KeyConditionExpression: aws.String("#PK = :PK"),
+		ProjectionExpression:   aws.String("#PK, #SK"),
+		ExpressionAttributeNames: map[string]string{
+			"#PK": "PK",
+			"#SK": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":PK": &types.AttributeValueMemberS{Value: models.UserKey + userId},
+		},
+	}
+
+	var keys []models.ItemKey
+	for {
+		result, err := awsx.Db.Query(ctx, input)
+		if err != nil {
+			return nil, models.NewServerError(err)
+		}
+
+		var page []models.ItemKey
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, models.NewServerError(err)
+		}
+		keys = append(keys, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return keys, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// DeleteItems batch-deletes the given items and returns how many were deleted.
+func DeleteItems(ctx context.Context, keys []models.ItemKey) (int, error) {
+	requests := make([]types.WriteRequest, 0, len(keys))
+	for _, k := range keys {
+		requests = append(requests, types.WriteRequest{
+			DeleteRequest: &types.DeleteRequest{
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: k.PK},
+					"SK": &types.AttributeValueMemberS{Value: k.SK},
+				},
+			},
+		})
+	}
+
+	return batchWrite(ctx, requests)
+}
+
+// batchWrite submits the requests in batches and returns how many went through.
+// Requests DynamoDB leaves unprocessed are resubmitted until none are left.
+func batchWrite(ctx context.Context, requests []types.WriteRequest) (int, error) {
+	written := 0
+	for start := 0; start < len(requests); start += batchWriteLimit {
+		end := min(start+batchWriteLimit, len(requests))
+
+		pending := map[string][]types.WriteRequest{config.App.WorkoutsTable: requests[start:end]}
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == maxBatchAttempts {
+				return written, models.NewServerError(fmt.Errorf("gave up on %d unprocessed writes", len(pending[config.App.WorkoutsTable])))
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt*attempt) * 50 * time.Millisecond)
+			}
+
+			out, err := awsx.Db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return written, models.NewServerError(err)
+			}
+			pending = out.UnprocessedItems
+		}
+
+		written += end - start
+	}
+
+	return written, nil
+}
+
+const (
+	batchWriteLimit  = 25 // DynamoDB's cap on a single BatchWriteItem call
+	maxBatchAttempts = 5
+)
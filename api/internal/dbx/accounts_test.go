@@ -2,6 +2,7 @@ package dbx
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"heart/internal/awsx"
@@ -155,3 +156,90 @@ func TestSaveAccount_SetsUsernameNullWhenNil(t *testing.T) {
 		t.Fatalf("expected :username to be NULL, got %#v", captured.ExpressionAttributeValues[":username"])
 	}
 }
+
+func TestGetAccountItemKeys_PagesThroughPartition(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	pages := [][]models.ItemKey{
+		{{PK: "USER#u1", SK: "USER#u1"}, {PK: "USER#u1", SK: "WORKOUT#w1"}},
+		{{PK: "USER#u1", SK: "TEMPLATE#t1"}},
+	}
+
+	calls := 0
+	awsx.Db = &mockDynamo{
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			pk, ok := p.ExpressionAttributeValues[":PK"].(*types.AttributeValueMemberS)
+			if !ok || pk.Value != "USER#u1" {
+				t.Fatalf("unexpected :PK %#v", p.ExpressionAttributeValues[":PK"])
+			}
+			if calls == 0 && p.ExclusiveStartKey != nil {
+				t.Fatalf("first page should not have a start key")
+			}
+			if calls == 1 && p.ExclusiveStartKey == nil {
+				t.Fatalf("second page should continue from the last key")
+			}
+
+			out := &dynamodb.QueryOutput{}
+			for _, k := range pages[calls] {
+				item, err := attributevalue.MarshalMap(k)
+				if err != nil {
+					t.Fatalf("marshal err: %v", err)
+				}
+				out.Items = append(out.Items, item)
+			}
+			if calls == 0 {
+				out.LastEvaluatedKey = out.Items[len(out.Items)-1]
+			}
+			calls++
+			return out, nil
+		},
+	}
+
+	keys, err := GetAccountItemKeys(context.Background(), "u1")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 queries, got %d", calls)
+	}
+	if len(keys) != 3 || keys[2].SK != "TEMPLATE#t1" {
+		t.Fatalf("unexpected keys: %#v", keys)
+	}
+}
+
+func TestDeleteItems_BatchesAndRetriesUnprocessed(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	keys := make([]models.ItemKey, 30)
+	for i := range keys {
+		keys[i] = models.ItemKey{PK: "USER#u1", SK: fmt.Sprintf("WORKOUT#w%d", i)}
+	}
+
+	var sizes []int
+	awsx.Db = &mockDynamo{
+		BatchWriteItemFn: func(ctx context.Context, p *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			requests := p.RequestItems["test-table"]
+			sizes = append(sizes, len(requests))
+			if len(sizes) == 1 {
+				// leave the last two for a retry
+				return &dynamodb.BatchWriteItemOutput{
+					UnprocessedItems: map[string][]types.WriteRequest{"test-table": requests[23:]},
+				}, nil
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	deleted, err := DeleteItems(context.Background(), keys)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if deleted != 30 {
+		t.Fatalf("expected 30 deleted, got %d", deleted)
+	}
+	if fmt.Sprint(sizes) != "[25 2 5]" {
+		t.Fatalf("unexpected batch sizes: %v", sizes)
+	}
+}
@@ -3,9 +3,12 @@ package dbx
 import (
 	"context"
 	"testing"
+	"time"
 
 	"heart/internal/config"
+	"heart/internal/models"
 
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
@@ -17,6 +20,8 @@ type mockDynamo struct {
 	DeleteItemFn         func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
 	QueryFn              func(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
 	TransactWriteItemsFn func(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	BatchWriteItemFn     func(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	BatchGetItemFn       func(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
 }
 
 func (m *mockDynamo) GetItem(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
@@ -43,6 +48,20 @@ func (m *mockDynamo) TransactWriteItems(ctx context.Context, p *dynamodb.Transac
 	return m.TransactWriteItemsFn(ctx, p, optFns...)
 }
 
+func (m *mockDynamo) BatchWriteItem(ctx context.Context, p *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	return m.BatchWriteItemFn(ctx, p, optFns...)
+}
+
+func (m *mockDynamo) BatchGetItem(ctx context.Context, p *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	return m.BatchGetItemFn(ctx, p, optFns...)
+}
+
+// historyBuilt answers the lookup EnsureHistory makes: the history is complete.
+func historyBuilt(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	item, err := attributevalue.MarshalMap(models.NewHistoryBuilt("u1", time.Now()))
+	return &dynamodb.GetItemOutput{Item: item}, err
+}
+
 func setupTest(t *testing.T) func() {
 	t.Helper()
 	config.App = &config.AppConfig{AwsConfig: config.AwsConfig{DynamoDBConfig: config.DynamoDBConfig{WorkoutsTable: "test-table"}}}
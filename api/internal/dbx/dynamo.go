@@ -0,0 +1,212 @@
+package dbx
+
+import (
+	"context"
+	"heart/internal/models"
+	"time"
+)
+
+// Dynamo is the Store kept in the DynamoDB table of the config, through the functions of this package.
+type Dynamo struct{}
+
+var _ Store = Dynamo{}
+
+// WorkoutStore
+
+func (Dynamo) GetWorkout(ctx context.Context, userId string, workoutId string) (*models.Workout, error) {
+	return GetWorkout(ctx, userId, workoutId)
+}
+
+func (Dynamo) SaveWorkout(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error) {
+	return SaveWorkout(ctx, in, expected)
+}
+
+func (Dynamo) ImportWorkouts(ctx context.Context, workouts []models.Workout) (int, error) {
+	return ImportWorkouts(ctx, workouts)
+}
+
+func (Dynamo) DeleteWorkout(ctx context.Context, userId string, workoutId string) error {
+	return DeleteWorkout(ctx, userId, workoutId)
+}
+
+func (Dynamo) GetWorkouts(ctx context.Context, userId string, limit int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error) {
+	return GetWorkouts(ctx, userId, limit, cursor, filter)
+}
+
+func (Dynamo) GetWorkoutsBetween(ctx context.Context, userId string, from, to time.Time) ([]models.Workout, error) {
+	return GetWorkoutsBetween(ctx, userId, from, to)
+}
+
+func (Dynamo) RemoveWorkoutImage(ctx context.Context, userId, workoutId, imageId string) error {
+	return RemoveWorkoutImage(ctx, userId, workoutId, imageId)
+}
+
+func (Dynamo) GetWorkoutGallery(ctx context.Context, userId string, limit int, cursor string) ([]models.ImageOut, *string, error) {
+	return GetWorkoutGallery(ctx, userId, limit, cursor)
+}
+
+func (Dynamo) GetExerciseHistory(ctx context.Context, userId string, exercise string, limit int, cursor string) ([]models.HistoryEntry, string, error) {
+	return GetExerciseHistory(ctx, userId, exercise, limit, cursor)
+}
+
+func (Dynamo) RebuildHistory(ctx context.Context, userId string) (int, error) {
+	return RebuildHistory(ctx, userId)
+}
+
+func (Dynamo) GetRecords(ctx context.Context, userId string, exercise string) (*models.PersonalRecords, error) {
+	return GetRecords(ctx, userId, exercise)
+}
+
+func (Dynamo) UpdateRecords(ctx context.Context, userId string, workout *models.Workout) (map[string][]models.RecordKind, error) {
+	return UpdateRecords(ctx, userId, workout)
+}
+
+func (Dynamo) ApplyRecords(ctx context.Context, userId string, workouts []models.Workout) error {
+	return ApplyRecords(ctx, userId, workouts)
+}
+
+// ExerciseStore
+
+func (Dynamo) GetExercises(ctx context.Context) ([]models.Exercise, error) {
+	return GetExercises(ctx)
+}
+
+func (Dynamo) MakeExercise(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
+	return MakeExercise(ctx, in, userId)
+}
+
+func (Dynamo) GetOwnExercises(ctx context.Context, userId string) ([]models.Exercise, error) {
+	return GetOwnExercises(ctx, userId)
+}
+
+func (Dynamo) GetOwnExercise(ctx context.Context, userId string, name string) (*models.Exercise, error) {
+	return GetOwnExercise(ctx, userId, name)
+}
+
+func (Dynamo) EditExercise(ctx context.Context, userId string, exerciseName string, in models.EditExerciseIn) (*models.Exercise, error) {
+	return EditExercise(ctx, userId, exerciseName, in)
+}
+
+func (Dynamo) RenameExercise(ctx context.Context, userId string, from string, to string) (*models.Exercise, error) {
+	return RenameExercise(ctx, userId, from, to)
+}
+
+func (Dynamo) DeleteExercise(ctx context.Context, userId string, name string, cascade bool) (*models.Exercise, error) {
+	return DeleteExercise(ctx, userId, name, cascade)
+}
+
+func (Dynamo) MergeExercise(ctx context.Context, userId string, from string, to string) (*models.Exercise, error) {
+	return MergeExercise(ctx, userId, from, to)
+}
+
+func (Dynamo) GetAliases(ctx context.Context, userId string) ([]models.ExerciseAlias, error) {
+	return GetAliases(ctx, userId)
+}
+
+// TemplateStore
+
+func (Dynamo) GetTemplates(ctx context.Context, userId string) ([]models.Template, error) {
+	return GetTemplates(ctx, userId)
+}
+
+func (Dynamo) GetTemplate(ctx context.Context, userId string, templateId string) (*models.Template, error) {
+	return GetTemplate(ctx, userId, templateId)
+}
+
+func (Dynamo) SaveTemplate(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+	return SaveTemplate(ctx, in, expected)
+}
+
+func (Dynamo) UpdateTemplate(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+	return UpdateTemplate(ctx, in, expected)
+}
+
+func (Dynamo) RecordTemplateUse(ctx context.Context, template *models.Template, workout *models.Workout) error {
+	return RecordTemplateUse(ctx, template, workout)
+}
+
+func (Dynamo) ReorderTemplates(ctx context.Context, userId string, templateIds []string) error {
+	return ReorderTemplates(ctx, userId, templateIds)
+}
+
+func (Dynamo) DeleteTemplate(ctx context.Context, userId string, templateId string) error {
+	return DeleteTemplate(ctx, userId, templateId)
+}
+
+func (Dynamo) GetPrograms(ctx context.Context, userId string) ([]models.Program, error) {
+	return GetPrograms(ctx, userId)
+}
+
+func (Dynamo) GetProgram(ctx context.Context, userId string, programId string) (*models.Program, error) {
+	return GetProgram(ctx, userId, programId)
+}
+
+func (Dynamo) SaveProgram(ctx context.Context, in models.Program, expected *int, mustExist bool) (*models.Program, error) {
+	return SaveProgram(ctx, in, expected, mustExist)
+}
+
+func (Dynamo) DeleteProgram(ctx context.Context, userId string, programId string) error {
+	return DeleteProgram(ctx, userId, programId)
+}
+
+func (Dynamo) ShareTemplate(ctx context.Context, template *models.Template, custom []models.UserExerciseIn) (*models.SharedTemplate, error) {
+	return ShareTemplate(ctx, template, custom)
+}
+
+func (Dynamo) GetSharedTemplate(ctx context.Context, code string) (*models.SharedTemplate, error) {
+	return GetSharedTemplate(ctx, code)
+}
+
+func (Dynamo) GetShares(ctx context.Context, userId string) ([]models.SharedTemplate, error) {
+	return GetShares(ctx, userId)
+}
+
+func (Dynamo) CountSharedImport(ctx context.Context, code string) error {
+	return CountSharedImport(ctx, code)
+}
+
+func (Dynamo) RevokeShare(ctx context.Context, userId string, code string) error {
+	return RevokeShare(ctx, userId, code)
+}
+
+// AccountStore
+
+func (Dynamo) SaveAccount(ctx context.Context, userId string, in models.User) (*models.User, error) {
+	return SaveAccount(ctx, userId, in)
+}
+
+func (Dynamo) GetAccount(ctx context.Context, userId string) (*models.User, error) {
+	return GetAccount(ctx, userId)
+}
+
+func (Dynamo) ScheduleAccountForDeletion(ctx context.Context, userId string, scheduleArn string, when int64) error {
+	return ScheduleAccountForDeletion(ctx, userId, scheduleArn, when)
+}
+
+func (Dynamo) UndoAccountDeletion(ctx context.Context, userId string) error {
+	return UndoAccountDeletion(ctx, userId)
+}
+
+func (Dynamo) RemoveAvatar(ctx context.Context, userId string) error {
+	return RemoveAvatar(ctx, userId)
+}
+
+func (Dynamo) GetAccountItemKeys(ctx context.Context, userId string) ([]models.ItemKey, error) {
+	return GetAccountItemKeys(ctx, userId)
+}
+
+func (Dynamo) DeleteItems(ctx context.Context, keys []models.ItemKey) (int, error) {
+	return DeleteItems(ctx, keys)
+}
+
+func (Dynamo) GetDataExport(ctx context.Context, userId string) (*models.DataExport, error) {
+	return GetDataExport(ctx, userId)
+}
+
+func (Dynamo) SaveDataExport(ctx context.Context, in models.DataExport) (*models.DataExport, error) {
+	return SaveDataExport(ctx, in)
+}
+
+func (Dynamo) GetChanges(ctx context.Context, userId string, since *models.SyncToken, until time.Time, limit int) (*models.Changes, *models.SyncToken, bool, error) {
+	return GetChanges(ctx, userId, since, until, limit)
+}
@@ -7,9 +7,11 @@ import (
 	"heart/internal/awsx"
 	"heart/internal/config"
 	"heart/internal/models"
+	"heart/internal/validation"
 	"net/url"
+	"slices"
 	"strings"
-	"unicode"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -26,26 +28,23 @@ func GetExercises(ctx context.Context) ([]models.Exercise, error) {
 		},
 	}
 
-	result, err := awsx.Db.Query(ctx, input)
+	// the catalog outgrows a single 1 MB page, so read every one of them
+	all := func(*models.Exercise) bool { return true }
+	exercises, _, err := queryPage(ctx, input, 0, all)
 	if err != nil {
-		return nil, models.NewServerError(err)
-	}
-
-	var exercises []models.Exercise
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &exercises)
-	if err != nil {
-		return nil, models.NewServerError(err)
+		return nil, err
 	}
 
 	return exercises, nil
 }
 
 func MakeExercise(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
-	if !isValidName(in.Name) {
+	if !validation.IsValidName(in.Name) {
 		return nil, models.NewValidationError(fmt.Errorf("exercise name can only contain letters, numbers and spaces"))
 	}
 
 	exercise := models.NewUserExercise(&in, userId)
+	exercise.UpdatedAt = models.Timestamp(time.Now())
 	item, err := attributevalue.MarshalMap(exercise)
 	if err != nil {
 		return nil, models.NewServerError(err)
@@ -87,15 +86,10 @@ func GetOwnExercises(ctx context.Context, userId string) ([]models.Exercise, err
 		},
 	}
 
-	result, err := awsx.Db.Query(ctx, input)
-	if err != nil {
-		return nil, models.NewServerError(err)
-	}
-
-	var exercises []models.Exercise
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &exercises)
+	all := func(*models.Exercise) bool { return true }
+	exercises, _, err := queryPage(ctx, input, 0, all)
 	if err != nil {
-		return nil, models.NewServerError(err)
+		return nil, err
 	}
 
 	for i := range exercises {
@@ -152,6 +146,10 @@ func EditExercise(ctx context.Context, userId string, exerciseName string, in mo
 		return nil, models.NewValidationError(fmt.Errorf("no fields to update"))
 	}
 
+	exprAttrNames["#updated_at"] = "updated_at"
+	exprAttrValues[":updated_at"] = &types.AttributeValueMemberS{Value: models.Timestamp(time.Now())}
+	updateExpr = append(updateExpr, "#updated_at = :updated_at")
+
 	update := strings.Join(updateExpr, ", ")
 
 	if strings.Contains(update, "REMOVE #instructions") {
@@ -213,13 +211,529 @@ func EditExercise(ctx context.Context, userId string, exerciseName string, in mo
 	return &updated, nil
 }
 
-// isValidName checks if the given string contains only letters, numbers, or spaces.
-// Returns true if valid, otherwise false.
-func isValidName(name string) bool {
-	for _, r := range name {
-		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) {
-			return false
+// RenameExercise gives an own exercise a new name, and with it the workouts, templates and
+// programs that refer to it, its history and its personal records. Those are rewritten first
+// and the exercise moved last, so that a rename cut short can be run again to finish it.
+func RenameExercise(ctx context.Context, userId string, from string, to string) (*models.Exercise, error) {
+	to = strings.TrimSpace(to)
+	if !validation.IsValidName(to) {
+		return nil, models.NewValidationError(fmt.Errorf("exercise name can only contain letters, numbers and spaces"))
+	}
+
+	item, err := getOwnExerciseItem(ctx, userId, from)
+	if err != nil {
+		return nil, err
+	}
+
+	taken, err := getOwnExerciseItem(ctx, userId, to)
+	var notFound *models.NotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return nil, err
+	}
+	if taken != nil {
+		return nil, models.NewValidationError(fmt.Errorf("exercise with name '%s' already exists", to))
+	}
+
+	uses, err := getExerciseUses(ctx, userId, from)
+	if err != nil {
+		return nil, err
+	}
+	if err := uses.rewrite(ctx, from, to); err != nil {
+		return nil, err
+	}
+	if err := moveRecords(ctx, userId, from, to); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	fromSK := models.ExerciseKey + url.PathEscape(from)
+	item["SK"] = &types.AttributeValueMemberS{Value: models.ExerciseKey + url.PathEscape(to)}
+	item["name"] = &types.AttributeValueMemberS{Value: to}
+	item["updated_at"] = &types.AttributeValueMemberS{Value: models.Timestamp(now)}
+
+	tombstone, err := attributevalue.MarshalMap(models.NewTombstone(userId, models.KindExercise, fromSK, from, now))
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	tx := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:                aws.String(config.App.WorkoutsTable),
+					Item:                     item,
+					ConditionExpression:      aws.String("attribute_not_exists(#PK)"),
+					ExpressionAttributeNames: map[string]string{"#PK": "PK"},
+				},
+			},
+			{
+				Delete: &types.Delete{
+					TableName: aws.String(config.App.WorkoutsTable),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: models.UserKey + userId},
+						"SK": &types.AttributeValueMemberS{Value: fromSK},
+					},
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName: aws.String(config.App.WorkoutsTable),
+					Item:      tombstone,
+				},
+			},
+		},
+	}
+
+	if _, err := awsx.Db.TransactWriteItems(ctx, tx); err != nil {
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+			aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return nil, models.NewValidationError(fmt.Errorf("exercise with name '%s' already exists", to))
+		}
+		return nil, models.NewServerError(err)
+	}
+
+	var renamed models.Exercise
+	if err := attributevalue.UnmarshalMap(item, &renamed); err != nil {
+		return nil, models.NewServerError(err)
+	}
+	renamed.Name = to
+
+	return &renamed, nil
+}
+
+// DeleteExercise deletes an own exercise. One still in use is refused with a conflict that
+// counts what uses it, unless cascade is set: then it is taken out of the workouts, templates
+// and programs first, and its history and personal records go with it.
+// It returns the exercise as it was, so that its images can go too.
+func DeleteExercise(ctx context.Context, userId string, name string, cascade bool) (*models.Exercise, error) {
+	exercise, err := GetOwnExercise(ctx, userId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	uses, err := getExerciseUses(ctx, userId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if usage := uses.usage(); usage.InUse() && !cascade {
+		return nil, models.NewConflictError("Exercise is still in use", usage, nil)
+	}
+
+	if err := uses.rewrite(ctx, name, ""); err != nil {
+		return nil, err
+	}
+
+	if _, err := batchWrite(ctx, []types.WriteRequest{recordsDelete(userId, name)}); err != nil {
+		return nil, err
+	}
+
+	sk := models.ExerciseKey + url.PathEscape(name)
+	if err := deleteWithTombstone(ctx, userId, models.KindExercise, sk, name); err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	return exercise, nil
+}
+
+// MergeExercise folds an own exercise into another one, the target, which may be from the catalog.
+// What was logged or planned under it moves to the target, its personal records are folded into
+// the target's, and its name, along with any names merged into it before, becomes an alias of the target.
+// It returns the exercise merged away, as it was.
+func MergeExercise(ctx context.Context, userId string, from string, to string) (*models.Exercise, error) {
+	exercise, err := GetOwnExercise(ctx, userId, from)
+	if err != nil {
+		return nil, err
+	}
+
+	uses, err := getExerciseUses(ctx, userId, from)
+	if err != nil {
+		return nil, err
+	}
+	if err := uses.rewrite(ctx, from, to); err != nil {
+		return nil, err
+	}
+
+	if err := ApplyRecords(ctx, userId, uses.workouts); err != nil {
+		return nil, err
+	}
+
+	aliases, err := GetAliases(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// the name merged now, and the names merged into it before, all lead to the target from here on
+	aliases = append(aliases, models.NewExerciseAlias(userId, from, from))
+
+	requests := []types.WriteRequest{recordsDelete(userId, from)}
+	for _, alias := range aliases {
+		if alias.Target != from {
+			continue
+		}
+		alias.Target = to
+
+		item, err := attributevalue.MarshalMap(alias)
+		if err != nil {
+			return nil, models.NewServerError(err)
+		}
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+
+	if _, err := batchWrite(ctx, requests); err != nil {
+		return nil, err
+	}
+
+	sk := models.ExerciseKey + url.PathEscape(from)
+	if err := deleteWithTombstone(ctx, userId, models.KindExercise, sk, from); err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	return exercise, nil
+}
+
+// GetAliases returns the names the user has merged into other exercises.
+func GetAliases(ctx context.Context, userId string) ([]models.ExerciseAlias, error) {
+	input := &dynamodb.QueryInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		ExpressionAttributeNames: map[string]string{
+			"#PK": "PK",
+			"#SK": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":PK":     &types.AttributeValueMemberS{Value: models.UserKey + userId},
+			":PREFIX": &types.AttributeValueMemberS{Value: models.AliasKey},
+		},
+		KeyConditionExpression: aws.String("#PK = :PK AND begins_with(#SK, :PREFIX)"),
+	}
+
+	all := func(*models.ExerciseAlias) bool { return true }
+	aliases, _, err := queryPage(ctx, input, 0, all)
+	if err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
+
+// GetOwnExercise returns an exercise the user made, by name.
+func GetOwnExercise(ctx context.Context, userId string, name string) (*models.Exercise, error) {
+	item, err := getOwnExerciseItem(ctx, userId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var exercise models.Exercise
+	if err := attributevalue.UnmarshalMap(item, &exercise); err != nil {
+		return nil, models.NewServerError(err)
+	}
+	exercise.Name = name
+
+	return &exercise, nil
+}
+
+func getOwnExerciseItem(ctx context.Context, userId string, name string) (map[string]types.AttributeValue, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: models.UserKey + userId},
+			"SK": &types.AttributeValueMemberS{Value: models.ExerciseKey + url.PathEscape(name)},
+		},
+	}
+
+	result, err := awsx.Db.GetItem(ctx, input)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	if result.Item == nil {
+		return nil, models.NewNotFoundError("Exercise not found", nil)
+	}
+
+	return result.Item, nil
+}
+
+// exerciseUses is what refers to an exercise by name.
+type exerciseUses struct {
+	workouts  []models.Workout
+	templates []models.Template
+	programs  []models.Program
+}
+
+// getExerciseUses finds the workouts the exercise was logged in through its history,
+// written first for workouts that have none, and the templates and programs that plan it.
+func getExerciseUses(ctx context.Context, userId string, name string) (*exerciseUses, error) {
+	if err := EnsureHistory(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	pk := models.UserKey + userId
+	input := &dynamodb.QueryInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		ExpressionAttributeNames: map[string]string{
+			"#PK": "PK",
+			"#SK": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":PK":     &types.AttributeValueMemberS{Value: pk},
+			":PREFIX": &types.AttributeValueMemberS{Value: models.HistoryPrefix(name)},
+		},
+		KeyConditionExpression: aws.String("#PK = :PK AND begins_with(#SK, :PREFIX)"),
+		ProjectionExpression:   aws.String("#PK, #SK, workout_id"),
+	}
+
+	all := func(*models.HistoryEntry) bool { return true }
+	entries, _, err := queryPage(ctx, input, 0, all)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]map[string]types.AttributeValue, len(entries))
+	for i, e := range entries {
+		keys[i] = map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+			"SK": &types.AttributeValueMemberS{Value: models.WorkoutKey + e.WorkoutID},
+		}
+	}
+
+	uses := &exerciseUses{}
+	if uses.workouts, err = batchGet[models.Workout](ctx, keys); err != nil {
+		return nil, err
+	}
+
+	templates, err := GetTemplates(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range templates {
+		if slices.ContainsFunc(t.Exercises, func(e models.TemplateExercise) bool { return e.ExerciseID == name }) {
+			uses.templates = append(uses.templates, t)
+		}
+	}
+
+	programs, err := GetPrograms(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range programs {
+		if slices.ContainsFunc(p.Progression, func(r models.Progression) bool { return r.Exercise == name }) {
+			uses.programs = append(uses.programs, p)
+		}
+	}
+
+	return uses, nil
+}
+
+func (u *exerciseUses) usage() models.ExerciseUsageOut {
+	return models.ExerciseUsageOut{
+		Workouts:  len(u.workouts),
+		Templates: len(u.templates),
+		Programs:  len(u.programs),
+	}
+}
+
+// rewriteAttempts is how many times a use that changes under a rewrite is read again and rewritten.
+const rewriteAttempts = 3
+
+// rewrite renames the exercise everywhere it is used, or takes it out when to is empty,
+// and writes each item back as its next version, the history of the workouts included.
+// Each use is written on condition that it is still on the version it was read at, so that
+// an edit made meanwhile is not overwritten. The uses are left as written, less any deleted since.
+func (u *exerciseUses) rewrite(ctx context.Context, from string, to string) error {
+	updatedAt := models.Timestamp(time.Now())
+
+	change := func(e interface {
+		RenameExercise(from, to string) bool
+		RemoveExercise(name string) bool
+	}) {
+		if to == "" {
+			e.RemoveExercise(from)
+		} else {
+			e.RenameExercise(from, to)
+		}
+	}
+
+	var err error
+	u.workouts, err = rewriteUses(ctx, u.workouts, func(w *models.Workout) ([]types.TransactWriteItem, error) {
+		previous := models.NewHistoryEntries(w)
+		change(w)
+		w.UpdatedAt, w.Version = updatedAt, w.Version+1
+		return historyWrites(models.NewHistoryEntries(w), previous)
+	})
+	if err != nil {
+		return err
+	}
+
+	u.templates, err = rewriteUses(ctx, u.templates, func(t *models.Template) ([]types.TransactWriteItem, error) {
+		change(t)
+		t.UpdatedAt, t.Version = updatedAt, t.Version+1
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	u.programs, err = rewriteUses(ctx, u.programs, func(p *models.Program) ([]types.TransactWriteItem, error) {
+		change(p)
+		p.UpdatedAt, p.Version = updatedAt, p.Version+1
+		return nil, nil
+	})
+	return err
+}
+
+// rewriteUses changes each use and writes it back, with the writes the change returns alongside,
+// on condition that it is still on the version it was read at. A use that has moved on since
+// is read again and changed anew, and one deleted since is dropped from those returned.
+func rewriteUses[T any](ctx context.Context, uses []T, change func(*T) ([]types.TransactWriteItem, error)) ([]T, error) {
+	out := uses[:0]
+	for i := range uses {
+		use := uses[i]
+		for attempt := 0; ; attempt++ {
+			if attempt == rewriteAttempts {
+				return nil, models.NewConflictError("A workout, template or program using the exercise kept changing", nil, nil)
+			}
+
+			read, err := attributevalue.MarshalMap(use)
+			if err != nil {
+				return nil, models.NewServerError(err)
+			}
+
+			with, err := change(&use)
+			if err != nil {
+				return nil, err
+			}
+			written, err := putOnVersion(ctx, use, read, with)
+			if err != nil {
+				return nil, err
+			}
+			if written {
+				out = append(out, use)
+				break
+			}
+
+			current, err := awsx.Db.GetItem(ctx, &dynamodb.GetItemInput{
+				TableName: aws.String(config.App.WorkoutsTable),
+				Key:       map[string]types.AttributeValue{"PK": read["PK"], "SK": read["SK"]},
+			})
+			if err != nil {
+				return nil, models.NewServerError(err)
+			}
+			if current.Item == nil {
+				break // deleted meanwhile, nothing left to rewrite
+			}
+
+			var fresh T
+			if err := attributevalue.UnmarshalMap(current.Item, &fresh); err != nil {
+				return nil, models.NewServerError(err)
+			}
+			use = fresh
 		}
 	}
-	return true
+	return out, nil
+}
+
+// putOnVersion writes the item, along with the other writes, if the stored copy is still on
+// the version of read, the item as it was read. It reports false if the copy has moved on.
+// Items that predate versioning count as version zero.
+func putOnVersion(ctx context.Context, item any, read map[string]types.AttributeValue, with []types.TransactWriteItem) (bool, error) {
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return false, models.NewServerError(err)
+	}
+
+	version, ok := read["version"]
+	if !ok {
+		version = &types.AttributeValueMemberN{Value: "0"}
+	}
+
+	put := &types.Put{
+		TableName:                 aws.String(config.App.WorkoutsTable),
+		Item:                      av,
+		ConditionExpression:       aws.String("#version = :read"),
+		ExpressionAttributeNames:  map[string]string{"#version": "version"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":read": version},
+	}
+	if n, ok := version.(*types.AttributeValueMemberN); ok && n.Value == "0" {
+		put.ConditionExpression = aws.String("attribute_exists(#PK) AND (attribute_not_exists(#version) OR #version = :read)")
+		put.ExpressionAttributeNames["#PK"] = "PK"
+	}
+
+	_, err = awsx.Db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{{Put: put}}, with...),
+	})
+	if err != nil {
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+			aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return false, nil
+		}
+		return false, models.NewServerError(err)
+	}
+
+	return true, nil
+}
+
+// historyWrites puts the entries and deletes those of before they leave out.
+func historyWrites(entries []models.HistoryEntry, before []models.HistoryEntry) ([]types.TransactWriteItem, error) {
+	var writes []types.TransactWriteItem
+
+	kept := map[string]bool{}
+	for _, entry := range entries {
+		item, err := attributevalue.MarshalMap(entry)
+		if err != nil {
+			return nil, models.NewServerError(err)
+		}
+		kept[entry.SK] = true
+		writes = append(writes, types.TransactWriteItem{
+			Put: &types.Put{TableName: aws.String(config.App.WorkoutsTable), Item: item},
+		})
+	}
+
+	for _, entry := range before {
+		if kept[entry.SK] {
+			continue
+		}
+		writes = append(writes, types.TransactWriteItem{
+			Delete: &types.Delete{
+				TableName: aws.String(config.App.WorkoutsTable),
+				Key:       historyDelete(entry).DeleteRequest.Key,
+			},
+		})
+	}
+
+	return writes, nil
+}
+
+// moveRecords files the personal records of an exercise under its new name.
+// Records not kept yet are left to be worked out from the history under the new name.
+func moveRecords(ctx context.Context, userId string, from string, to string) error {
+	records, err := storedRecords(ctx, userId, from)
+	if err != nil || records == nil {
+		return err
+	}
+
+	records.SK, records.Exercise = models.RecordsSK(to), to
+
+	item, err := attributevalue.MarshalMap(records)
+	if err != nil {
+		return models.NewServerError(err)
+	}
+
+	_, err = batchWrite(ctx, []types.WriteRequest{
+		{PutRequest: &types.PutRequest{Item: item}},
+		recordsDelete(userId, from),
+	})
+	return err
+}
+
+func recordsDelete(userId string, exercise string) types.WriteRequest {
+	return types.WriteRequest{
+		DeleteRequest: &types.DeleteRequest{
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: models.UserKey + userId},
+				"SK": &types.AttributeValueMemberS{Value: models.RecordsSK(exercise)},
+			},
+		},
+	}
 }
@@ -4,13 +4,17 @@ import (
 	"context"
 	"errors"
 	"heart/internal/models"
+	"strings"
 	"testing"
 
 	"heart/internal/awsx"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type exercise struct {
@@ -41,6 +45,39 @@ func TestGetExercises(t *testing.T) {
 	}
 }
 
+func TestGetExercises_ReadsEveryPage(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	m1, _ := attributevalue.MarshalMap(exercise{PK: "EXERCISE", SK: "EXERCISE#PushUp", Name: "PushUp"})
+	m2, _ := attributevalue.MarshalMap(exercise{PK: "EXERCISE", SK: "EXERCISE#PullUp", Name: "PullUp"})
+
+	calls := 0
+	awsx.Db = &mockDynamo{
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			calls++
+			if p.ExclusiveStartKey == nil {
+				return &dynamodb.QueryOutput{
+					Items:            []map[string]types.AttributeValue{m1},
+					LastEvaluatedKey: map[string]types.AttributeValue{"PK": m1["PK"], "SK": m1["SK"]},
+				}, nil
+			}
+			return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{m2}}, nil
+		},
+	}
+
+	list, err := GetExercises(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 queries, got %d", calls)
+	}
+	if len(list) != 2 || list[1].Name != "EXERCISE#PullUp" {
+		t.Fatalf("expected both pages, got %+v", list)
+	}
+}
+
 func TestMakeExercise_Success(t *testing.T) {
 	teardown := setupTest(t)
 	defer teardown()
@@ -93,3 +130,349 @@ func TestMakeExercise_ServerError(t *testing.T) {
 		t.Fatalf("expected error, got nil")
 	}
 }
+
+// usesMock serves an exercise "Bench" logged in one workout and planned in one template.
+func usesMock(writes *[]types.WriteRequest) *mockDynamo {
+	workout, _ := attributevalue.MarshalMap(models.Workout{
+		PK: "USER#u1", SK: "WORKOUT#w1", Version: 2,
+		Exercises: []models.WorkoutExercise{
+			{ID: "e1", ExerciseID: "Bench", Sets: []models.Set{{ID: "s1", Completed: true, Weight: 60, Reps: 5}}},
+			{ID: "e2", ExerciseID: "Squat"},
+		},
+	})
+	template, _ := attributevalue.MarshalMap(models.Template{
+		PK: "USER#u1", SK: "TEMPLATE#t1", Version: 1,
+		Exercises: []models.TemplateExercise{{ID: "e1", ExerciseID: "Bench"}},
+	})
+	history, _ := attributevalue.MarshalMap(models.HistoryEntry{PK: "USER#u1", SK: "HIST#Bench#w1", WorkoutID: "w1"})
+
+	return &mockDynamo{
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			switch p.Key["SK"].(*types.AttributeValueMemberS).Value {
+			case models.HistoryBuiltKey:
+				return historyBuilt(ctx, p, optFns...)
+			case "EXERCISE#Bench":
+				return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+					"PK":       &types.AttributeValueMemberS{Value: "USER#u1"},
+					"SK":       &types.AttributeValueMemberS{Value: "EXERCISE#Bench"},
+					"name":     &types.AttributeValueMemberS{Value: "Bench"},
+					"category": &types.AttributeValueMemberS{Value: "Barbell"},
+				}}, nil
+			}
+			return &dynamodb.GetItemOutput{}, nil
+		},
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			switch p.ExpressionAttributeValues[":PREFIX"].(*types.AttributeValueMemberS).Value {
+			case "HIST#Bench#":
+				return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{history}}, nil
+			case "TEMPLATE":
+				return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{template}}, nil
+			}
+			return &dynamodb.QueryOutput{}, nil
+		},
+		BatchGetItemFn: func(ctx context.Context, p *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+			return &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]types.AttributeValue{
+				"test-table": {workout},
+			}}, nil
+		},
+		BatchWriteItemFn: func(ctx context.Context, p *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			*writes = append(*writes, p.RequestItems["test-table"]...)
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+		TransactWriteItemsFn: func(ctx context.Context, p *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+			for _, item := range p.TransactItems {
+				if item.Put != nil {
+					*writes = append(*writes, types.WriteRequest{PutRequest: &types.PutRequest{Item: item.Put.Item}})
+				}
+				if item.Delete != nil {
+					*writes = append(*writes, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: item.Delete.Key}})
+				}
+			}
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+}
+
+func TestRenameExercise_RewritesWhatUsesIt(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var writes []types.WriteRequest
+	mock := usesMock(&writes)
+	var txs []*dynamodb.TransactWriteItemsInput
+	write := mock.TransactWriteItemsFn
+	mock.TransactWriteItemsFn = func(ctx context.Context, p *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		txs = append(txs, p)
+		return write(ctx, p, optFns...)
+	}
+	awsx.Db = mock
+
+	renamed, err := RenameExercise(context.Background(), "u1", "Bench", "Bench Press")
+	require.NoError(t, err)
+	assert.Equal(t, "Bench Press", renamed.Name)
+	assert.Equal(t, "Barbell", renamed.Category)
+
+	var workout models.Workout
+	var template models.Template
+	var puts, deletes []string
+	for _, w := range writes {
+		if w.DeleteRequest != nil {
+			deletes = append(deletes, w.DeleteRequest.Key["SK"].(*types.AttributeValueMemberS).Value)
+			continue
+		}
+		sk := w.PutRequest.Item["SK"].(*types.AttributeValueMemberS).Value
+		puts = append(puts, sk)
+		switch sk {
+		case "WORKOUT#w1":
+			require.NoError(t, attributevalue.UnmarshalMap(w.PutRequest.Item, &workout))
+		case "TEMPLATE#t1":
+			require.NoError(t, attributevalue.UnmarshalMap(w.PutRequest.Item, &template))
+		}
+	}
+
+	assert.ElementsMatch(t, []string{
+		"WORKOUT#w1", "HIST#Bench%20Press#w1", "HIST#Squat#w1", "TEMPLATE#t1",
+		"EXERCISE#Bench%20Press", "DELETED#EXERCISE#Bench",
+	}, puts)
+	assert.Equal(t, []string{"HIST#Bench#w1", "EXERCISE#Bench"}, deletes)
+	assert.Equal(t, "Bench Press", workout.Exercises[0].ExerciseID)
+	assert.Equal(t, "Squat", workout.Exercises[1].ExerciseID)
+	assert.Equal(t, 3, workout.Version)
+	assert.Equal(t, "Bench Press", template.Exercises[0].ExerciseID)
+	assert.Equal(t, 2, template.Version)
+
+	// the workout and its history go together, and only over the version that was read
+	require.Len(t, txs, 3)
+	require.Len(t, txs[0].TransactItems, 4)
+	assert.Equal(t, "#version = :read", *txs[0].TransactItems[0].Put.ConditionExpression)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "2"}, txs[0].TransactItems[0].Put.ExpressionAttributeValues[":read"])
+
+	tx := txs[2]
+	require.Len(t, tx.TransactItems, 3)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "EXERCISE#Bench%20Press"}, tx.TransactItems[0].Put.Item["SK"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "EXERCISE#Bench"}, tx.TransactItems[1].Delete.Key["SK"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "DELETED#EXERCISE#Bench"}, tx.TransactItems[2].Put.Item["SK"])
+}
+
+// staleWorkout fails the first times writes of the workout as if it had been saved meanwhile,
+// and serves the copy saved then when it is read again.
+func staleWorkout(t *testing.T, mock *mockDynamo, times int) *[]string {
+	t.Helper()
+
+	newer, err := attributevalue.MarshalMap(models.Workout{
+		PK: "USER#u1", SK: "WORKOUT#w1", Version: 3,
+		Exercises: []models.WorkoutExercise{
+			{ID: "e1", ExerciseID: "Bench"},
+			{ID: "e3", ExerciseID: "Dips"},
+		},
+	})
+	require.NoError(t, err)
+
+	var read []string
+	get, write := mock.GetItemFn, mock.TransactWriteItemsFn
+	mock.GetItemFn = func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		if p.Key["SK"].(*types.AttributeValueMemberS).Value == "WORKOUT#w1" {
+			return &dynamodb.GetItemOutput{Item: newer}, nil
+		}
+		return get(ctx, p, optFns...)
+	}
+	mock.TransactWriteItemsFn = func(ctx context.Context, p *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		if put := p.TransactItems[0].Put; put != nil && put.Item["SK"].(*types.AttributeValueMemberS).Value == "WORKOUT#w1" {
+			read = append(read, put.ExpressionAttributeValues[":read"].(*types.AttributeValueMemberN).Value)
+			if len(read) <= times {
+				return nil, &types.TransactionCanceledException{
+					CancellationReasons: []types.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}},
+				}
+			}
+		}
+		return write(ctx, p, optFns...)
+	}
+	return &read
+}
+
+func TestRenameExercise_RewritesAUseChangedMeanwhileAnew(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var writes []types.WriteRequest
+	mock := usesMock(&writes)
+	read := staleWorkout(t, mock, 1)
+	awsx.Db = mock
+
+	_, err := RenameExercise(context.Background(), "u1", "Bench", "Bench Press")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "3"}, *read)
+
+	var workout models.Workout
+	for _, w := range writes {
+		if w.PutRequest != nil && w.PutRequest.Item["SK"].(*types.AttributeValueMemberS).Value == "WORKOUT#w1" {
+			require.NoError(t, attributevalue.UnmarshalMap(w.PutRequest.Item, &workout))
+		}
+	}
+	assert.Equal(t, 4, workout.Version)
+	assert.Equal(t, []string{"Bench Press", "Dips"}, []string{workout.Exercises[0].ExerciseID, workout.Exercises[1].ExerciseID})
+}
+
+func TestRenameExercise_GivesUpOnAUseThatKeepsChanging(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var writes []types.WriteRequest
+	mock := usesMock(&writes)
+	staleWorkout(t, mock, rewriteAttempts)
+	awsx.Db = mock
+
+	_, err := RenameExercise(context.Background(), "u1", "Bench", "Bench Press")
+
+	var conflict *models.ConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Empty(t, writes)
+}
+
+func TestDeleteExercise_InUse(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var writes []types.WriteRequest
+	awsx.Db = usesMock(&writes)
+
+	_, err := DeleteExercise(context.Background(), "u1", "Bench", false)
+
+	var conflict *models.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, models.ExerciseUsageOut{Workouts: 1, Templates: 1}, conflict.Current)
+	assert.Empty(t, writes)
+}
+
+func TestDeleteExercise_InUseByAWorkoutWithoutHistory(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	// saved before history was kept: the workout is there, its history rows are not
+	workout, err := attributevalue.MarshalMap(models.Workout{
+		PK: "USER#u1", SK: "WORKOUT#w1",
+		Exercises: []models.WorkoutExercise{{ID: "e1", ExerciseID: "Bench"}},
+	})
+	require.NoError(t, err)
+
+	var writes []types.WriteRequest
+	mock := usesMock(&writes)
+	get, query := mock.GetItemFn, mock.QueryFn
+	mock.GetItemFn = func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		if p.Key["SK"].(*types.AttributeValueMemberS).Value == models.HistoryBuiltKey {
+			return &dynamodb.GetItemOutput{}, nil
+		}
+		return get(ctx, p, optFns...)
+	}
+	mock.QueryFn = func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		prefix, ok := p.ExpressionAttributeValues[":PREFIX"].(*types.AttributeValueMemberS)
+		switch {
+		case !ok: // the workouts, read to write their history
+			return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{workout}}, nil
+		case prefix.Value == "HIST#Bench#":
+			var history []map[string]types.AttributeValue
+			for _, w := range writes {
+				if w.PutRequest != nil && strings.HasPrefix(w.PutRequest.Item["SK"].(*types.AttributeValueMemberS).Value, prefix.Value) {
+					history = append(history, w.PutRequest.Item)
+				}
+			}
+			return &dynamodb.QueryOutput{Items: history}, nil
+		}
+		return query(ctx, p, optFns...)
+	}
+	var marked bool
+	mock.PutItemFn = func(ctx context.Context, p *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		marked = p.Item["SK"].(*types.AttributeValueMemberS).Value == models.HistoryBuiltKey
+		return &dynamodb.PutItemOutput{}, nil
+	}
+	awsx.Db = mock
+
+	_, err = DeleteExercise(context.Background(), "u1", "Bench", false)
+
+	var conflict *models.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, 1, conflict.Current.(models.ExerciseUsageOut).Workouts)
+	assert.True(t, marked)
+}
+
+func TestDeleteExercise_Cascades(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var writes []types.WriteRequest
+	awsx.Db = usesMock(&writes)
+
+	deleted, err := DeleteExercise(context.Background(), "u1", "Bench", true)
+	require.NoError(t, err)
+	assert.Equal(t, "Barbell", deleted.Category, "returned as it was")
+
+	var deletes []string
+	for _, w := range writes {
+		if w.DeleteRequest != nil {
+			deletes = append(deletes, w.DeleteRequest.Key["SK"].(*types.AttributeValueMemberS).Value)
+		}
+		if sk := w.PutRequest; sk != nil && sk.Item["SK"].(*types.AttributeValueMemberS).Value == "WORKOUT#w1" {
+			var workout models.Workout
+			require.NoError(t, attributevalue.UnmarshalMap(sk.Item, &workout))
+			assert.Len(t, workout.Exercises, 1)
+		}
+	}
+	assert.ElementsMatch(t, []string{"HIST#Bench#w1", "PR#Bench", "EXERCISE#Bench"}, deletes)
+}
+
+func TestDeleteExercise_NotFound(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var writes []types.WriteRequest
+	awsx.Db = usesMock(&writes)
+
+	_, err := DeleteExercise(context.Background(), "u1", "Nope", true)
+
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestMergeExercise_MovesUsesAndAliases(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var writes []types.WriteRequest
+	mock := usesMock(&writes)
+	uses := mock.QueryFn
+	older, _ := attributevalue.MarshalMap(models.NewExerciseAlias("u1", "Flat Bench", "Bench"))
+	mock.QueryFn = func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		if p.ExpressionAttributeValues[":PREFIX"].(*types.AttributeValueMemberS).Value == "ALIAS#" {
+			return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{older}}, nil
+		}
+		return uses(ctx, p, optFns...)
+	}
+	var records []string
+	mock.PutItemFn = func(ctx context.Context, p *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		records = append(records, p.Item["SK"].(*types.AttributeValueMemberS).Value)
+		return &dynamodb.PutItemOutput{}, nil
+	}
+	awsx.Db = mock
+
+	merged, err := MergeExercise(context.Background(), "u1", "Bench", "Bench Press")
+	require.NoError(t, err)
+	assert.Equal(t, "Bench", merged.Name)
+
+	aliases := map[string]string{}
+	var deleted []string
+	for _, w := range writes {
+		if w.PutRequest == nil {
+			deleted = append(deleted, w.DeleteRequest.Key["SK"].(*types.AttributeValueMemberS).Value)
+			continue
+		}
+		if sk := w.PutRequest.Item["SK"].(*types.AttributeValueMemberS).Value; strings.HasPrefix(sk, "ALIAS#") {
+			var alias models.ExerciseAlias
+			require.NoError(t, attributevalue.UnmarshalMap(w.PutRequest.Item, &alias))
+			aliases[alias.Name] = alias.Target
+		}
+	}
+
+	assert.Equal(t, map[string]string{"Bench": "Bench Press", "Flat Bench": "Bench Press"}, aliases)
+	assert.Contains(t, records, "PR#Bench%20Press")
+	assert.Contains(t, deleted, "EXERCISE#Bench")
+}
@@ -0,0 +1,57 @@
+package dbx
+
+import (
+	"context"
+	"heart/internal/awsx"
+	"heart/internal/config"
+	"heart/internal/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func GetDataExport(ctx context.Context, userId string) (*models.DataExport, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: models.UserKey + userId},
+			"SK": &types.AttributeValueMemberS{Value: models.DataExportKey},
+		},
+	}
+
+	result, err := awsx.Db.GetItem(ctx, input)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	if result.Item == nil {
+		return nil, models.NewNotFoundError("Export not found", nil)
+	}
+
+	var export models.DataExport
+	if err := attributevalue.UnmarshalMap(result.Item, &export); err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	return &export, nil
+}
+
+func SaveDataExport(ctx context.Context, in models.DataExport) (*models.DataExport, error) {
+	item, err := attributevalue.MarshalMap(in)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Item:      item,
+	}
+
+	if _, err := awsx.Db.PutItem(ctx, input); err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	return &in, nil
+}
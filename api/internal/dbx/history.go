@@ -0,0 +1,266 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"heart/internal/awsx"
+	"heart/internal/config"
+	"heart/internal/models"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// historyPageSize is how many workouts RebuildHistory reads at a time.
+const historyPageSize = 100
+
+// historyRebuildTimeout is how long a rebuild started in the background has before a read takes
+// it for lost and has another started.
+const historyRebuildTimeout = 15 * time.Minute
+
+// GetExerciseHistory returns the sets logged for an exercise, one entry per workout, newest first.
+// The cursor is the ID of the last workout on the previous page. Until the user's history is
+// complete, it returns a *models.HistoryPendingError instead.
+func GetExerciseHistory(ctx context.Context, userId string, exercise string, limit int, cursor string) ([]models.HistoryEntry, string, error) {
+	if err := historyReady(ctx, userId); err != nil {
+		return nil, "", err
+	}
+
+	pk := models.UserKey + userId
+	prefix := models.HistoryPrefix(exercise)
+
+	input := &dynamodb.QueryInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		ExpressionAttributeNames: map[string]string{
+			"#PK": "PK",
+			"#SK": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":PK":     &types.AttributeValueMemberS{Value: pk},
+			":PREFIX": &types.AttributeValueMemberS{Value: prefix},
+		},
+		KeyConditionExpression: aws.String("#PK = :PK AND begins_with(#SK, :PREFIX)"),
+		ScanIndexForward:       aws.Bool(false), // workout IDs are timestamps
+		Limit:                  aws.Int32(int32(limit)),
+	}
+
+	// pagination
+	if cursor != "" {
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+			"SK": &types.AttributeValueMemberS{Value: prefix + cursor},
+		}
+	}
+
+	result, err := awsx.Db.Query(ctx, input)
+	if err != nil {
+		return nil, "", models.NewServerError(err)
+	}
+
+	var entries []models.HistoryEntry
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &entries); err != nil {
+		return nil, "", models.NewServerError(err)
+	}
+
+	var nextCursor string
+	if result.LastEvaluatedKey != nil {
+		if skAttr, ok := result.LastEvaluatedKey["SK"]; ok {
+			if skValue, ok := skAttr.(*types.AttributeValueMemberS); ok {
+				nextCursor = strings.TrimPrefix(skValue.Value, prefix)
+			}
+		}
+	}
+
+	return entries, nextCursor, nil
+}
+
+// EnsureHistory writes the history of the user's workouts unless it has been written before.
+// Workouts saved before history was kept have none, so whatever changes history calls this
+// first, and goes through the user's workouts once if need be. Reads call historyReady instead.
+func EnsureHistory(ctx context.Context, userId string) error {
+	built, _, err := getHistoryBuilt(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if built != nil && built.Built() {
+		return nil
+	}
+
+	_, err = RebuildHistory(ctx, userId)
+	return err
+}
+
+// historyReady tells whether the user's history is complete, so that reads need not go through
+// every workout within the request. When it is not, the first read to find out marks it as
+// being built and gets a *models.HistoryPendingError telling it to start the rebuild in the
+// background; the reads after it get one telling them to wait, unless the rebuild takes so long
+// it must have been lost.
+func historyReady(ctx context.Context, userId string) error {
+	built, item, err := getHistoryBuilt(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if built != nil && built.Built() {
+		return nil
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(config.App.WorkoutsTable),
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	}
+	if built != nil {
+		if built.RebuildStartedAt != nil && time.Since(*built.RebuildStartedAt) < historyRebuildTimeout {
+			return &models.HistoryPendingError{}
+		}
+
+		// take the lost rebuild over, unless another read has just done so
+		input.ConditionExpression = aws.String("rebuild_started_at = :seen")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":seen": item["rebuild_started_at"],
+		}
+		if built.RebuildStartedAt == nil {
+			input.ConditionExpression = aws.String("attribute_not_exists(rebuild_started_at)")
+			input.ExpressionAttributeValues = nil
+		}
+	}
+
+	input.Item, err = attributevalue.MarshalMap(models.NewHistoryRebuild(userId, time.Now()))
+	if err != nil {
+		return models.NewServerError(err)
+	}
+
+	if _, err := awsx.Db.PutItem(ctx, input); err != nil {
+		var checkFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &checkFailed) {
+			return &models.HistoryPendingError{}
+		}
+		return models.NewServerError(err)
+	}
+
+	return &models.HistoryPendingError{Start: true}
+}
+
+// getHistoryBuilt reads the item marking the user's history, as well as raw; nil if there is none.
+func getHistoryBuilt(ctx context.Context, userId string) (*models.HistoryBuilt, map[string]types.AttributeValue, error) {
+	result, err := awsx.Db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: models.UserKey + userId},
+			"SK": &types.AttributeValueMemberS{Value: models.HistoryBuiltKey},
+		},
+	})
+	if err != nil {
+		return nil, nil, models.NewServerError(err)
+	}
+
+	if result.Item == nil {
+		return nil, nil, nil
+	}
+
+	var built models.HistoryBuilt
+	if err := attributevalue.UnmarshalMap(result.Item, &built); err != nil {
+		return nil, nil, models.NewServerError(err)
+	}
+
+	return &built, result.Item, nil
+}
+
+// RebuildHistory writes the history of every workout the user has, and marks it as complete.
+// Returns how many workouts it went through.
+func RebuildHistory(ctx context.Context, userId string) (int, error) {
+	count := 0
+	cursor := ""
+	for {
+		workouts, next, err := GetWorkouts(ctx, userId, historyPageSize, cursor, models.WorkoutFilter{})
+		if err != nil {
+			return count, err
+		}
+
+		for i := range workouts {
+			if err := SaveHistory(ctx, &workouts[i], nil); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	item, err := attributevalue.MarshalMap(models.NewHistoryBuilt(userId, time.Now()))
+	if err != nil {
+		return count, models.NewServerError(err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Item:      item,
+	}
+
+	if _, err := awsx.Db.PutItem(ctx, input); err != nil {
+		return count, models.NewServerError(err)
+	}
+
+	return count, nil
+}
+
+// SaveHistory writes the history entries of a saved workout and, given the copy it replaced,
+// drops those of exercises taken out of it since.
+func SaveHistory(ctx context.Context, workout *models.Workout, previous *models.Workout) error {
+	entries := models.NewHistoryEntries(workout)
+
+	kept := map[string]bool{}
+	requests := make([]types.WriteRequest, 0, len(entries))
+	for _, entry := range entries {
+		item, err := attributevalue.MarshalMap(entry)
+		if err != nil {
+			return models.NewServerError(err)
+		}
+
+		kept[entry.SK] = true
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+
+	if previous != nil {
+		for _, entry := range models.NewHistoryEntries(previous) {
+			if !kept[entry.SK] {
+				requests = append(requests, historyDelete(entry))
+			}
+		}
+	}
+
+	_, err := batchWrite(ctx, requests)
+	return err
+}
+
+// DeleteHistory drops the history entries of a workout.
+func DeleteHistory(ctx context.Context, workout *models.Workout) error {
+	entries := models.NewHistoryEntries(workout)
+
+	requests := make([]types.WriteRequest, 0, len(entries))
+	for _, entry := range entries {
+		requests = append(requests, historyDelete(entry))
+	}
+
+	_, err := batchWrite(ctx, requests)
+	return err
+}
+
+func historyDelete(entry models.HistoryEntry) types.WriteRequest {
+	return types.WriteRequest{
+		DeleteRequest: &types.DeleteRequest{
+			Key: map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{Value: entry.PK},
+				"SK": &types.AttributeValueMemberS{Value: entry.SK},
+			},
+		},
+	}
+}
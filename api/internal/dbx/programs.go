@@ -0,0 +1,166 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"heart/internal/awsx"
+	"heart/internal/config"
+	"heart/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func GetPrograms(ctx context.Context, userId string) ([]models.Program, error) {
+	input := &dynamodb.QueryInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		ExpressionAttributeNames: map[string]string{
+			"#PK": "PK",
+			"#SK": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":PK":     &types.AttributeValueMemberS{Value: models.UserKey + userId},
+			":PREFIX": &types.AttributeValueMemberS{Value: models.ProgramKey},
+		},
+		KeyConditionExpression: aws.String("#PK = :PK AND begins_with( #SK , :PREFIX )"),
+	}
+
+	programs, _, err := queryPage[models.Program](ctx, input, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return programs, nil
+}
+
+func GetProgram(ctx context.Context, userId string, programId string) (*models.Program, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: models.UserKey + userId},
+			"SK": &types.AttributeValueMemberS{Value: models.ProgramKey + programId},
+		},
+	}
+
+	result, err := awsx.Db.GetItem(ctx, input)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	if result.Item == nil {
+		return nil, models.NewNotFoundError("Program not found", nil)
+	}
+
+	var program models.Program
+	if err := attributevalue.UnmarshalMap(result.Item, &program); err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	return &program, nil
+}
+
+// SaveProgram upserts the program and bumps its version. A non-nil expected version makes the
+// save fail with a conflict, carrying the stored copy, if the program has moved on since.
+// With mustExist, it only saves over a program that is already there.
+func SaveProgram(ctx context.Context, in models.Program, expected *int, mustExist bool) (*models.Program, error) {
+	in.UpdatedAt = models.Timestamp(time.Now())
+
+	startAV, err := attributevalue.Marshal(in.Start)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+	slotsAV, err := attributevalue.Marshal(in.Slots)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+	progressionAV, err := attributevalue.Marshal(in.Progression)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: in.PK},
+			"SK": &types.AttributeValueMemberS{Value: in.SK},
+		},
+		ExpressionAttributeNames: map[string]string{
+			"#name":        "name",
+			"#weeks":       "weeks",
+			"#start":       "start",
+			"#repeat":      "repeat",
+			"#slots":       "slots",
+			"#progression": "progression",
+			"#updated_at":  "updated_at",
+			"#version":     "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":name":        &types.AttributeValueMemberS{Value: in.Name},
+			":weeks":       &types.AttributeValueMemberN{Value: strconv.Itoa(in.Weeks)},
+			":start":       startAV,
+			":repeat":      &types.AttributeValueMemberBOOL{Value: in.Repeat},
+			":slots":       slotsAV,
+			":progression": progressionAV,
+			":updated_at":  &types.AttributeValueMemberS{Value: in.UpdatedAt},
+		},
+		UpdateExpression: aws.String(
+			"SET #name = :name, #weeks = :weeks, #start = :start, #repeat = :repeat, " +
+				"#slots = :slots, #progression = :progression, #updated_at = :updated_at, " + bumpVersion,
+		),
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	input.ConditionExpression = versionValues(expected, input.ExpressionAttributeValues)
+
+	if mustExist {
+		input.ExpressionAttributeNames["#PK"] = "PK"
+		condition := "attribute_exists(#PK)"
+		if input.ConditionExpression != nil {
+			condition += " AND " + *input.ConditionExpression
+		}
+		input.ConditionExpression = aws.String(condition)
+	}
+
+	result, err := awsx.Db.UpdateItem(ctx, input)
+	if err != nil {
+		var stale *types.ConditionalCheckFailedException
+		if ok := errors.As(err, &stale); ok {
+			return nil, programConflict(stale)
+		}
+		return nil, models.NewServerError(err)
+	}
+
+	var saved models.Program
+	if err := attributevalue.UnmarshalMap(result.Attributes, &saved); err != nil {
+		return nil, models.NewServerError(err)
+	}
+	in.Version = saved.Version
+
+	return &in, nil
+}
+
+func programConflict(stale *types.ConditionalCheckFailedException) error {
+	if stale.Item == nil {
+		return models.NewNotFoundError("Program not found", stale)
+	}
+
+	var current models.Program
+	if err := attributevalue.UnmarshalMap(stale.Item, &current); err != nil {
+		return models.NewServerError(err)
+	}
+
+	return models.NewConflictError("Program was changed on another device", models.NewProgramOut(&current), stale)
+}
+
+func DeleteProgram(ctx context.Context, userId string, programId string) error {
+	err := deleteWithTombstone(ctx, userId, models.KindProgram, models.ProgramKey+programId, programId)
+	if err != nil {
+		return models.NewServerError(err)
+	}
+
+	return nil
+}
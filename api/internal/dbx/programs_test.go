@@ -0,0 +1,105 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"heart/internal/awsx"
+	"heart/internal/models"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveProgram_ReturnsNewVersion(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var captured *dynamodb.UpdateItemInput
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			captured = p
+			return &dynamodb.UpdateItemOutput{Attributes: map[string]types.AttributeValue{
+				"version": &types.AttributeValueMemberN{Value: "4"},
+			}}, nil
+		},
+	}
+
+	program := models.Program{
+		PK:    "USER#u1",
+		SK:    "PROGRAM#p1",
+		Name:  "PPL",
+		Weeks: 1,
+		Start: time.Date(2025, 7, 21, 0, 0, 0, 0, time.UTC),
+		Slots: []models.ProgramSlot{{Week: 1, Day: 1, Template: "push"}},
+	}
+	saved, err := SaveProgram(context.Background(), program, nil, false)
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, saved.Version)
+	assert.Nil(t, captured.ConditionExpression)
+
+	var slots []models.ProgramSlot
+	require.NoError(t, attributevalue.Unmarshal(captured.ExpressionAttributeValues[":slots"], &slots))
+	assert.Equal(t, program.Slots, slots)
+}
+
+func TestSaveProgram_MissingProgram(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var condition string
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			condition = *p.ConditionExpression
+			return nil, &types.ConditionalCheckFailedException{}
+		},
+	}
+
+	_, err := SaveProgram(context.Background(), models.Program{PK: "USER#u1", SK: "PROGRAM#p1"}, nil, true)
+
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "attribute_exists(#PK)", condition)
+}
+
+func TestSaveProgram_StaleVersionReturnsServerCopy(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	current, err := attributevalue.MarshalMap(models.Program{PK: "USER#u1", SK: "PROGRAM#p1", Name: "PPL", Version: 5})
+	require.NoError(t, err)
+
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			return nil, &types.ConditionalCheckFailedException{Item: current}
+		},
+	}
+
+	version := 3
+	_, err = SaveProgram(context.Background(), models.Program{PK: "USER#u1", SK: "PROGRAM#p1"}, &version, false)
+
+	var conflict *models.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, 5, conflict.Current.(models.ProgramOut).Version)
+}
+
+func TestGetProgram_NotFound(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	awsx.Db = &mockDynamo{
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{}, nil
+		},
+	}
+
+	_, err := GetProgram(context.Background(), "u1", "p1")
+
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
@@ -0,0 +1,102 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"heart/internal/awsx"
+	"heart/internal/config"
+	"heart/internal/models"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// queryPage runs a query for a page of up to limit items and returns them with the sort key
+// to resume after, empty once the range is done. With a keep func, items it rejects do not
+// count towards the page, and further pages are read until it fills up.
+func queryPage[T any](ctx context.Context, input *dynamodb.QueryInput, limit int, keep func(*T) bool) ([]T, string, error) {
+	var out []T
+	for {
+		result, err := awsx.Db.Query(ctx, input)
+		if err != nil {
+			return nil, "", models.NewServerError(err)
+		}
+
+		if keep == nil {
+			if err := attributevalue.UnmarshalListOfMaps(result.Items, &out); err != nil {
+				return nil, "", models.NewServerError(err)
+			}
+			return out, sortKey(result.LastEvaluatedKey), nil
+		}
+
+		for i, raw := range result.Items {
+			var item T
+			if err := attributevalue.UnmarshalMap(raw, &item); err != nil {
+				return nil, "", models.NewServerError(err)
+			}
+			if !keep(&item) {
+				continue
+			}
+
+			out = append(out, item)
+			if len(out) == limit {
+				if i == len(result.Items)-1 && len(result.LastEvaluatedKey) == 0 {
+					return out, "", nil
+				}
+				return out, sortKey(raw), nil
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return out, "", nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+func sortKey(key map[string]types.AttributeValue) string {
+	if skAttr, ok := key["SK"]; ok {
+		if skValue, ok := skAttr.(*types.AttributeValueMemberS); ok {
+			return skValue.Value
+		}
+	}
+	return ""
+}
+
+// batchGetLimit is DynamoDB's cap on a single BatchGetItem call.
+const batchGetLimit = 100
+
+// batchGet reads the items with the given keys, in no particular order, leaving out missing ones.
+// Keys DynamoDB leaves unprocessed are resubmitted until none are left.
+func batchGet[T any](ctx context.Context, keys []map[string]types.AttributeValue) ([]T, error) {
+	var out []T
+	for start := 0; start < len(keys); start += batchGetLimit {
+		end := min(start+batchGetLimit, len(keys))
+
+		pending := map[string]types.KeysAndAttributes{config.App.WorkoutsTable: {Keys: keys[start:end]}}
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == maxBatchAttempts {
+				return nil, models.NewServerError(fmt.Errorf("gave up on %d unprocessed reads", len(pending[config.App.WorkoutsTable].Keys)))
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt*attempt) * 50 * time.Millisecond)
+			}
+
+			result, err := awsx.Db.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: pending})
+			if err != nil {
+				return nil, models.NewServerError(err)
+			}
+
+			var page []T
+			if err := attributevalue.UnmarshalListOfMaps(result.Responses[config.App.WorkoutsTable], &page); err != nil {
+				return nil, models.NewServerError(err)
+			}
+			out = append(out, page...)
+			pending = result.UnprocessedKeys
+		}
+	}
+
+	return out, nil
+}
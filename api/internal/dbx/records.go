@@ -0,0 +1,215 @@
+package dbx
+
+import (
+	"context"
+	"heart/internal/awsx"
+	"heart/internal/config"
+	"heart/internal/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// GetRecords returns the personal records of the user for an exercise,
+// empty if they have not logged it yet.
+// Records not kept yet, for workouts logged before they were, are worked out from the history first.
+func GetRecords(ctx context.Context, userId string, exercise string) (*models.PersonalRecords, error) {
+	return getRecords(ctx, userId, exercise, nil)
+}
+
+// getRecords returns the records of an exercise like GetRecords, worked out from the history
+// without the given workouts when they are not kept yet, for those to be folded in after.
+func getRecords(ctx context.Context, userId string, exercise string, without []models.Workout) (*models.PersonalRecords, error) {
+	records, err := storedRecords(ctx, userId, exercise)
+	if err != nil || records != nil {
+		return records, err
+	}
+
+	built := models.NewPersonalRecords(userId, exercise)
+	if err := rebuildRecords(ctx, userId, &built, without); err != nil {
+		return nil, err
+	}
+	return &built, nil
+}
+
+// storedRecords reads the records kept of an exercise, nil if there are none yet.
+func storedRecords(ctx context.Context, userId string, exercise string) (*models.PersonalRecords, error) {
+	records := models.NewPersonalRecords(userId, exercise)
+
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: records.PK},
+			"SK": &types.AttributeValueMemberS{Value: records.SK},
+		},
+	}
+
+	result, err := awsx.Db.GetItem(ctx, input)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	if err := attributevalue.UnmarshalMap(result.Item, &records); err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	return &records, nil
+}
+
+// UpdateRecords folds a saved workout into the personal records of every exercise in it
+// and returns, by set ID, the records its sets have just set.
+func UpdateRecords(ctx context.Context, userId string, workout *models.Workout) (map[string][]models.RecordKind, error) {
+	return updateRecords(ctx, userId, []models.Workout{*workout})
+}
+
+// ApplyRecords folds many workouts at once, oldest first, reading and writing
+// the records of each exercise only once.
+func ApplyRecords(ctx context.Context, userId string, workouts []models.Workout) error {
+	_, err := updateRecords(ctx, userId, workouts)
+	return err
+}
+
+func updateRecords(ctx context.Context, userId string, workouts []models.Workout) (map[string][]models.RecordKind, error) {
+	broken := map[string][]models.RecordKind{}
+	seen := map[string]bool{}
+
+	for _, workout := range workouts {
+		for _, exercise := range workout.Exercises {
+			if seen[exercise.ExerciseID] {
+				continue
+			}
+			seen[exercise.ExerciseID] = true
+
+			records, err := getRecords(ctx, userId, exercise.ExerciseID, workouts)
+			if err != nil {
+				return nil, err
+			}
+
+			// a workout saved again may have been edited down, so records it holds are
+			// recomputed from the history rather than only ever raised
+			if heldBy(records, workouts) {
+				before := records.Clone()
+				if err := rebuildRecords(ctx, userId, records, nil); err != nil {
+					return nil, err
+				}
+				for _, w := range workouts {
+					for setId, kinds := range records.Broken(&before, w.ID()) {
+						broken[setId] = append(broken[setId], kinds...)
+					}
+				}
+				continue
+			}
+
+			changed := false
+			for _, w := range workouts {
+				set := records.Apply(&w)
+				for setId, kinds := range set {
+					broken[setId] = append(broken[setId], kinds...)
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+
+			if err := putRecords(ctx, records); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return broken, nil
+}
+
+// dropRecords recomputes the records a workout held of exercises it no longer has:
+// those taken out of it since, given what it is now, or all of them once it is deleted.
+func dropRecords(ctx context.Context, userId string, previous *models.Workout, now *models.Workout) error {
+	kept := map[string]bool{}
+	if now != nil {
+		for _, exercise := range now.Exercises {
+			kept[exercise.ExerciseID] = true
+		}
+	}
+
+	for _, exercise := range previous.Exercises {
+		if kept[exercise.ExerciseID] {
+			continue
+		}
+		kept[exercise.ExerciseID] = true
+
+		// records not kept yet are worked out from the history, which is already up to date
+		records, err := storedRecords(ctx, userId, exercise.ExerciseID)
+		if err != nil {
+			return err
+		}
+		if records == nil || !records.HeldBy(previous.ID()) {
+			continue
+		}
+		if err := rebuildRecords(ctx, userId, records, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func heldBy(records *models.PersonalRecords, workouts []models.Workout) bool {
+	for i := range workouts {
+		if records.HeldBy(workouts[i].ID()) {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuildRecords recomputes the records of an exercise from its whole history, but for the given
+// workouts, and saves them.
+func rebuildRecords(ctx context.Context, userId string, records *models.PersonalRecords, without []models.Workout) error {
+	if err := EnsureHistory(ctx, userId); err != nil {
+		return err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		ExpressionAttributeNames: map[string]string{
+			"#PK": "PK",
+			"#SK": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":PK":     &types.AttributeValueMemberS{Value: records.PK},
+			":PREFIX": &types.AttributeValueMemberS{Value: models.HistoryPrefix(records.Exercise)},
+		},
+		KeyConditionExpression: aws.String("#PK = :PK AND begins_with(#SK, :PREFIX)"),
+	}
+
+	history, _, err := queryPage(ctx, input, 0, func(*models.HistoryEntry) bool { return true })
+	if err != nil {
+		return err
+	}
+
+	records.Rebuild(models.LeaveOut(history, without))
+	return putRecords(ctx, records)
+}
+
+func putRecords(ctx context.Context, records *models.PersonalRecords) error {
+	item, err := attributevalue.MarshalMap(records)
+	if err != nil {
+		return models.NewServerError(err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Item:      item,
+	}
+
+	if _, err := awsx.Db.PutItem(ctx, input); err != nil {
+		return models.NewServerError(err)
+	}
+	return nil
+}
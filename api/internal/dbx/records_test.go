@@ -0,0 +1,185 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"heart/internal/awsx"
+	"heart/internal/models"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateRecords_WritesOnlyImprovedExercises(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	squat := models.NewPersonalRecords("u1", "Squat")
+	squat.Weight = &models.Record{Value: 200, WorkoutID: "w0", SetID: "old"}
+	squat.OneRepMax = &models.Record{Value: 210, WorkoutID: "w0", SetID: "old"}
+	squatItem, err := attributevalue.MarshalMap(squat)
+	require.NoError(t, err)
+
+	var puts []models.PersonalRecords
+	awsx.Db = &mockDynamo{
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			switch p.Key["SK"].(*types.AttributeValueMemberS).Value {
+			case "PR#Squat":
+				return &dynamodb.GetItemOutput{Item: squatItem}, nil
+			case models.HistoryBuiltKey:
+				return historyBuilt(ctx, p, optFns...)
+			}
+			return &dynamodb.GetItemOutput{}, nil
+		},
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			return &dynamodb.QueryOutput{}, nil // no deadlift logged before
+		},
+		PutItemFn: func(ctx context.Context, p *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+			var records models.PersonalRecords
+			require.NoError(t, attributevalue.UnmarshalMap(p.Item, &records))
+			puts = append(puts, records)
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+
+	workout := &models.Workout{
+		SK:    models.WorkoutKey + "w1",
+		Start: time.Now(),
+		Exercises: []models.WorkoutExercise{
+			{ExerciseID: "Squat", Sets: []models.Set{{ID: "s1", Completed: true, Weight: 150, Reps: 1}}},
+			{ExerciseID: "Deadlift", Sets: []models.Set{{ID: "s2", Completed: true, Weight: 180, Reps: 1}}},
+		},
+	}
+
+	broken, err := UpdateRecords(context.Background(), "u1", workout)
+	require.NoError(t, err)
+
+	// the squat set only sets a reps-at-weight record, the deadlift is a first,
+	// kept once worked out from the history and again with the workout folded in
+	require.Len(t, puts, 3)
+	assert.Equal(t, 200.0, puts[0].Weight.Value)
+	assert.Equal(t, "Deadlift", puts[1].Exercise)
+	assert.True(t, puts[1].Empty())
+	assert.Equal(t, 180.0, puts[2].Weight.Value)
+	assert.Equal(t, []models.RecordKind{models.RecordRepsAtWeight}, broken["s1"])
+	assert.Contains(t, broken["s2"], models.RecordWeight)
+}
+
+func TestUpdateRecords_RebuildsRecordsTheWorkoutHeld(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	// w1 held the weight record with a 200 that has since been edited down to 150
+	squat := models.NewPersonalRecords("u1", "Squat")
+	squat.Weight = &models.Record{Value: 200, WorkoutID: "w1", SetID: "s1"}
+	squatItem, err := attributevalue.MarshalMap(squat)
+	require.NoError(t, err)
+
+	workout := &models.Workout{
+		PK:        "USER#u1",
+		SK:        models.WorkoutKey + "w1",
+		Start:     time.Date(2025, 7, 18, 5, 0, 0, 0, time.UTC),
+		Exercises: []models.WorkoutExercise{{ExerciseID: "Squat", Sets: []models.Set{{ID: "s1", Completed: true, Weight: 150, Reps: 1}}}},
+	}
+	older := &models.Workout{
+		PK:        "USER#u1",
+		SK:        models.WorkoutKey + "w0",
+		Start:     workout.Start.Add(-24 * time.Hour),
+		Exercises: []models.WorkoutExercise{{ExerciseID: "Squat", Sets: []models.Set{{ID: "s0", Completed: true, Weight: 180, Reps: 1}}}},
+	}
+	var history []map[string]types.AttributeValue
+	for _, w := range []*models.Workout{older, workout} {
+		item, err := attributevalue.MarshalMap(models.NewHistoryEntries(w)[0])
+		require.NoError(t, err)
+		history = append(history, item)
+	}
+
+	var puts []models.PersonalRecords
+	awsx.Db = &mockDynamo{
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			if p.Key["SK"].(*types.AttributeValueMemberS).Value == models.HistoryBuiltKey {
+				return historyBuilt(ctx, p, optFns...)
+			}
+			return &dynamodb.GetItemOutput{Item: squatItem}, nil
+		},
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			assert.Equal(t, "HIST#Squat#", p.ExpressionAttributeValues[":PREFIX"].(*types.AttributeValueMemberS).Value)
+			return &dynamodb.QueryOutput{Items: history}, nil
+		},
+		PutItemFn: func(ctx context.Context, p *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+			var records models.PersonalRecords
+			require.NoError(t, attributevalue.UnmarshalMap(p.Item, &records))
+			puts = append(puts, records)
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+
+	broken, err := UpdateRecords(context.Background(), "u1", workout)
+	require.NoError(t, err)
+
+	require.Len(t, puts, 1)
+	assert.Equal(t, 180.0, puts[0].Weight.Value)
+	assert.Equal(t, "w0", puts[0].Weight.WorkoutID)
+	assert.Equal(t, []models.RecordKind{models.RecordRepsAtWeight}, broken["s1"])
+}
+
+func TestUpdateRecords_WorksOutRecordsNotKeptYetFromHistory(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	// the user logged heavier squats before records were kept, and now saves a lighter one
+	older := &models.Workout{
+		PK:    "USER#u1",
+		SK:    models.WorkoutKey + "w0",
+		Start: time.Date(2025, 7, 17, 5, 0, 0, 0, time.UTC),
+		Exercises: []models.WorkoutExercise{{ExerciseID: "Squat", Sets: []models.Set{
+			{ID: "s0", Completed: true, Weight: 180, Reps: 5},
+			{ID: "s00", Completed: true, Weight: 150, Reps: 8},
+		}}},
+	}
+	workout := &models.Workout{
+		PK:        "USER#u1",
+		SK:        models.WorkoutKey + "w1",
+		Start:     older.Start.Add(24 * time.Hour),
+		Exercises: []models.WorkoutExercise{{ExerciseID: "Squat", Sets: []models.Set{{ID: "s1", Completed: true, Weight: 150, Reps: 5}}}},
+	}
+	// the history of the workout being saved is written before its records are updated
+	var history []map[string]types.AttributeValue
+	for _, w := range []*models.Workout{older, workout} {
+		item, err := attributevalue.MarshalMap(models.NewHistoryEntries(w)[0])
+		require.NoError(t, err)
+		history = append(history, item)
+	}
+
+	var puts []models.PersonalRecords
+	awsx.Db = &mockDynamo{
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			if p.Key["SK"].(*types.AttributeValueMemberS).Value == models.HistoryBuiltKey {
+				return historyBuilt(ctx, p, optFns...)
+			}
+			return &dynamodb.GetItemOutput{}, nil // no PR# item yet
+		},
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			return &dynamodb.QueryOutput{Items: history}, nil
+		},
+		PutItemFn: func(ctx context.Context, p *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+			var records models.PersonalRecords
+			require.NoError(t, attributevalue.UnmarshalMap(p.Item, &records))
+			puts = append(puts, records)
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+
+	broken, err := UpdateRecords(context.Background(), "u1", workout)
+	require.NoError(t, err)
+
+	assert.Empty(t, broken)
+	require.NotEmpty(t, puts)
+	assert.Equal(t, 180.0, puts[len(puts)-1].Weight.Value)
+	assert.Equal(t, "w0", puts[len(puts)-1].Weight.WorkoutID)
+}
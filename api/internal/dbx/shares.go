@@ -0,0 +1,215 @@
+package dbx
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"heart/internal/awsx"
+	"heart/internal/config"
+	"heart/internal/models"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// shareAttempts bounds how many codes ShareTemplate draws before giving up on finding a free one.
+const shareAttempts = 3
+
+// ShareTemplate publishes a snapshot of the template under a new code, along with the
+// owner's pointer to it, both or neither.
+func ShareTemplate(ctx context.Context, template *models.Template, custom []models.UserExerciseIn) (*models.SharedTemplate, error) {
+	for range shareAttempts {
+		shared, share := models.NewSharedTemplate(template, custom, models.NewShareCode(), time.Now())
+
+		sharedItem, err := attributevalue.MarshalMap(shared)
+		if err != nil {
+			return nil, models.NewServerError(err)
+		}
+		shareItem, err := attributevalue.MarshalMap(share)
+		if err != nil {
+			return nil, models.NewServerError(err)
+		}
+
+		tx := &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{
+					Put: &types.Put{
+						TableName:                aws.String(config.App.WorkoutsTable),
+						Item:                     sharedItem,
+						ConditionExpression:      aws.String("attribute_not_exists(#PK)"),
+						ExpressionAttributeNames: map[string]string{"#PK": "PK"},
+					},
+				},
+				{
+					Put: &types.Put{
+						TableName: aws.String(config.App.WorkoutsTable),
+						Item:      shareItem,
+					},
+				},
+			},
+		}
+
+		_, err = awsx.Db.TransactWriteItems(ctx, tx)
+		if err == nil {
+			return &shared, nil
+		}
+
+		var canceled *types.TransactionCanceledException
+		if !errors.As(err, &canceled) || len(canceled.CancellationReasons) == 0 ||
+			aws.ToString(canceled.CancellationReasons[0].Code) != "ConditionalCheckFailed" {
+			return nil, models.NewServerError(err)
+		}
+		// the code is taken: draw another
+	}
+
+	return nil, models.NewServerError(fmt.Errorf("no free share code after %d attempts", shareAttempts))
+}
+
+func GetSharedTemplate(ctx context.Context, code string) (*models.SharedTemplate, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: models.SharedKey + code},
+			"SK": &types.AttributeValueMemberS{Value: models.SharedKey + code},
+		},
+	}
+
+	result, err := awsx.Db.GetItem(ctx, input)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	if result.Item == nil {
+		return nil, models.NewNotFoundError("Shared template not found", nil)
+	}
+
+	var shared models.SharedTemplate
+	if err := attributevalue.UnmarshalMap(result.Item, &shared); err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	return &shared, nil
+}
+
+// GetShares returns the templates the user has shared, newest first, with how often each was copied.
+func GetShares(ctx context.Context, userId string) ([]models.SharedTemplate, error) {
+	input := &dynamodb.QueryInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		ExpressionAttributeNames: map[string]string{
+			"#PK": "PK",
+			"#SK": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":PK":     &types.AttributeValueMemberS{Value: models.UserKey + userId},
+			":PREFIX": &types.AttributeValueMemberS{Value: models.ShareKey},
+		},
+		KeyConditionExpression: aws.String("#PK = :PK AND begins_with( #SK , :PREFIX )"),
+	}
+
+	shares, _, err := queryPage[models.Share](ctx, input, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]map[string]types.AttributeValue, len(shares))
+	for i, s := range shares {
+		code := strings.TrimPrefix(s.SK, models.ShareKey)
+		keys[i] = map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: models.SharedKey + code},
+			"SK": &types.AttributeValueMemberS{Value: models.SharedKey + code},
+		}
+	}
+
+	shared, err := batchGet[models.SharedTemplate](ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(shared, func(a, b models.SharedTemplate) int {
+		return cmp.Compare(b.CreatedAt.UnixNano(), a.CreatedAt.UnixNano())
+	})
+
+	return shared, nil
+}
+
+// CountSharedImport adds one to the times the shared template was copied.
+// A share revoked in the meantime is left alone.
+func CountSharedImport(ctx context.Context, code string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: models.SharedKey + code},
+			"SK": &types.AttributeValueMemberS{Value: models.SharedKey + code},
+		},
+		ExpressionAttributeNames: map[string]string{
+			"#PK":      "PK",
+			"#imports": "imports",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+		UpdateExpression:    aws.String("ADD #imports :one"),
+		ConditionExpression: aws.String("attribute_exists(#PK)"),
+	}
+
+	_, err := awsx.Db.UpdateItem(ctx, input)
+	if err != nil {
+		var gone *types.ConditionalCheckFailedException
+		if errors.As(err, &gone) {
+			return nil
+		}
+		return models.NewServerError(err)
+	}
+
+	return nil
+}
+
+// RevokeShare takes a shared template down, along with the owner's pointer to it.
+// Only its owner can revoke it; to anyone else it is not found.
+func RevokeShare(ctx context.Context, userId string, code string) error {
+	tx := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Delete: &types.Delete{
+					TableName: aws.String(config.App.WorkoutsTable),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: models.SharedKey + code},
+						"SK": &types.AttributeValueMemberS{Value: models.SharedKey + code},
+					},
+					ConditionExpression:      aws.String("#owner = :owner"),
+					ExpressionAttributeNames: map[string]string{"#owner": "owner"},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":owner": &types.AttributeValueMemberS{Value: userId},
+					},
+				},
+			},
+			{
+				Delete: &types.Delete{
+					TableName: aws.String(config.App.WorkoutsTable),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: models.UserKey + userId},
+						"SK": &types.AttributeValueMemberS{Value: models.ShareKey + code},
+					},
+				},
+			},
+		},
+	}
+
+	_, err := awsx.Db.TransactWriteItems(ctx, tx)
+	if err != nil {
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+			aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return models.NewNotFoundError("Shared template not found", canceled)
+		}
+		return models.NewServerError(err)
+	}
+
+	return nil
+}
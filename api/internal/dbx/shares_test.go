@@ -0,0 +1,97 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+
+	"heart/internal/awsx"
+	"heart/internal/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareTemplate_DrawsAnotherCodeWhenTaken(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var codes []string
+	awsx.Db = &mockDynamo{
+		TransactWriteItemsFn: func(ctx context.Context, p *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+			require.Len(t, p.TransactItems, 2)
+			codes = append(codes, p.TransactItems[0].Put.Item["PK"].(*types.AttributeValueMemberS).Value)
+			if len(codes) == 1 {
+				return nil, &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+					{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")},
+				}}
+			}
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+
+	template := &models.Template{PK: "USER#u1", SK: "TEMPLATE#t1", Name: "Upper body"}
+	shared, err := ShareTemplate(context.Background(), template, nil)
+
+	require.NoError(t, err)
+	require.Len(t, codes, 2)
+	assert.NotEqual(t, codes[0], codes[1])
+	assert.Equal(t, codes[1], shared.PK)
+	assert.Equal(t, "u1", shared.Owner)
+}
+
+func TestGetSharedTemplate_NotFound(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	awsx.Db = &mockDynamo{
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{}, nil
+		},
+	}
+
+	_, err := GetSharedTemplate(context.Background(), "nope")
+
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestCountSharedImport_IgnoresRevokedShare(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var update string
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			update = *p.UpdateExpression
+			return nil, &types.ConditionalCheckFailedException{}
+		},
+	}
+
+	assert.NoError(t, CountSharedImport(context.Background(), "abc123"))
+	assert.Equal(t, "ADD #imports :one", update)
+}
+
+func TestRevokeShare_SomeoneElsesShare(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var tx *dynamodb.TransactWriteItemsInput
+	awsx.Db = &mockDynamo{
+		TransactWriteItemsFn: func(ctx context.Context, p *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+			tx = p
+			return nil, &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+				{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")},
+			}}
+		},
+	}
+
+	err := RevokeShare(context.Background(), "u2", "abc123")
+
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "#owner = :owner", *tx.TransactItems[0].Delete.ConditionExpression)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "u2"}, tx.TransactItems[0].Delete.ExpressionAttributeValues[":owner"])
+}
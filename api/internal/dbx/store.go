@@ -0,0 +1,95 @@
+package dbx
+
+import (
+	"context"
+	"heart/internal/models"
+	"time"
+)
+
+// WorkoutStore keeps workouts along with what is derived from them:
+// the progress gallery, the history of each exercise and personal records.
+type WorkoutStore interface {
+	GetWorkout(ctx context.Context, userId string, workoutId string) (*models.Workout, error)
+	SaveWorkout(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error)
+	ImportWorkouts(ctx context.Context, workouts []models.Workout) (int, error)
+	DeleteWorkout(ctx context.Context, userId string, workoutId string) error
+	GetWorkouts(ctx context.Context, userId string, limit int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error)
+	GetWorkoutsBetween(ctx context.Context, userId string, from, to time.Time) ([]models.Workout, error)
+	RemoveWorkoutImage(ctx context.Context, userId, workoutId, imageId string) error
+	GetWorkoutGallery(ctx context.Context, userId string, limit int, cursor string) ([]models.ImageOut, *string, error)
+	GetExerciseHistory(ctx context.Context, userId string, exercise string, limit int, cursor string) ([]models.HistoryEntry, string, error)
+	RebuildHistory(ctx context.Context, userId string) (int, error)
+	GetRecords(ctx context.Context, userId string, exercise string) (*models.PersonalRecords, error)
+	UpdateRecords(ctx context.Context, userId string, workout *models.Workout) (map[string][]models.RecordKind, error)
+	ApplyRecords(ctx context.Context, userId string, workouts []models.Workout) error
+}
+
+// ExerciseStore keeps the exercise catalog and the exercises users make of their own,
+// with the names merged into others.
+type ExerciseStore interface {
+	GetExercises(ctx context.Context) ([]models.Exercise, error)
+	MakeExercise(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error)
+	GetOwnExercises(ctx context.Context, userId string) ([]models.Exercise, error)
+	GetOwnExercise(ctx context.Context, userId string, name string) (*models.Exercise, error)
+	EditExercise(ctx context.Context, userId string, exerciseName string, in models.EditExerciseIn) (*models.Exercise, error)
+	RenameExercise(ctx context.Context, userId string, from string, to string) (*models.Exercise, error)
+	DeleteExercise(ctx context.Context, userId string, name string, cascade bool) (*models.Exercise, error)
+	MergeExercise(ctx context.Context, userId string, from string, to string) (*models.Exercise, error)
+	GetAliases(ctx context.Context, userId string) ([]models.ExerciseAlias, error)
+}
+
+// TemplateStore keeps templates, the programs built of them and the templates shared by link.
+type TemplateStore interface {
+	GetTemplates(ctx context.Context, userId string) ([]models.Template, error)
+	GetTemplate(ctx context.Context, userId string, templateId string) (*models.Template, error)
+	SaveTemplate(ctx context.Context, in models.Template, expected *int) (*models.Template, error)
+	UpdateTemplate(ctx context.Context, in models.Template, expected *int) (*models.Template, error)
+	RecordTemplateUse(ctx context.Context, template *models.Template, workout *models.Workout) error
+	ReorderTemplates(ctx context.Context, userId string, templateIds []string) error
+	DeleteTemplate(ctx context.Context, userId string, templateId string) error
+	GetPrograms(ctx context.Context, userId string) ([]models.Program, error)
+	GetProgram(ctx context.Context, userId string, programId string) (*models.Program, error)
+	SaveProgram(ctx context.Context, in models.Program, expected *int, mustExist bool) (*models.Program, error)
+	DeleteProgram(ctx context.Context, userId string, programId string) error
+	ShareTemplate(ctx context.Context, template *models.Template, custom []models.UserExerciseIn) (*models.SharedTemplate, error)
+	GetSharedTemplate(ctx context.Context, code string) (*models.SharedTemplate, error)
+	GetShares(ctx context.Context, userId string) ([]models.SharedTemplate, error)
+	CountSharedImport(ctx context.Context, code string) error
+	RevokeShare(ctx context.Context, userId string, code string) error
+}
+
+// AccountStore keeps accounts and what is kept of them as a whole:
+// data exports, the change feed and, for a purge, the keys of every item.
+type AccountStore interface {
+	SaveAccount(ctx context.Context, userId string, in models.User) (*models.User, error)
+	GetAccount(ctx context.Context, userId string) (*models.User, error)
+	ScheduleAccountForDeletion(ctx context.Context, userId string, scheduleArn string, when int64) error
+	UndoAccountDeletion(ctx context.Context, userId string) error
+	RemoveAvatar(ctx context.Context, userId string) error
+	GetAccountItemKeys(ctx context.Context, userId string) ([]models.ItemKey, error)
+	DeleteItems(ctx context.Context, keys []models.ItemKey) (int, error)
+	GetDataExport(ctx context.Context, userId string) (*models.DataExport, error)
+	SaveDataExport(ctx context.Context, in models.DataExport) (*models.DataExport, error)
+	GetChanges(ctx context.Context, userId string, since *models.SyncToken, until time.Time, limit int) (*models.Changes, *models.SyncToken, bool, error)
+}
+
+// Stores is where the API keeps its data. Its fields may all be backed by the same store.
+type Stores struct {
+	Workouts  WorkoutStore
+	Exercises ExerciseStore
+	Templates TemplateStore
+	Accounts  AccountStore
+}
+
+// Store keeps every kind of data the API has.
+type Store interface {
+	WorkoutStore
+	ExerciseStore
+	TemplateStore
+	AccountStore
+}
+
+// NewStores backs every kind of data by the one store.
+func NewStores(s Store) Stores {
+	return Stores{Workouts: s, Exercises: s, Templates: s, Accounts: s}
+}
@@ -0,0 +1,234 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"heart/internal/awsx"
+	"heart/internal/config"
+	"heart/internal/models"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// GetChanges returns, oldest first, the workouts, templates, programs and own exercises of the user
+// saved after the since token, along with tombstones of those deleted, up to the until instant.
+// It also returns the token to continue from and whether there is more to read before until.
+// A nil since starts a full download instead, see getEverything.
+func GetChanges(ctx context.Context, userId string, since *models.SyncToken, until time.Time, limit int) (*models.Changes, *models.SyncToken, bool, error) {
+	if since == nil || since.Full {
+		return getEverything(ctx, userId, since, until, limit)
+	}
+
+	pk := models.UserKey + userId
+	input := &dynamodb.QueryInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		IndexName: aws.String(config.App.SyncIndex),
+		ExpressionAttributeNames: map[string]string{
+			"#PK":         "PK",
+			"#updated_at": "updated_at",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":PK":    &types.AttributeValueMemberS{Value: pk},
+			":since": &types.AttributeValueMemberS{Value: since.UpdatedAt},
+			":until": &types.AttributeValueMemberS{Value: models.Timestamp(until)},
+		},
+		KeyConditionExpression: aws.String("#PK = :PK AND #updated_at BETWEEN :since AND :until"),
+		ScanIndexForward:       aws.Bool(true),
+		Limit:                  aws.Int32(int32(limit)),
+	}
+
+	if since.SK != "" {
+		// resume right after the last item handed out, which may share its timestamp with the next ones
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			"PK":         &types.AttributeValueMemberS{Value: pk},
+			"SK":         &types.AttributeValueMemberS{Value: since.SK},
+			"updated_at": &types.AttributeValueMemberS{Value: since.UpdatedAt},
+		}
+	} else {
+		// sorts after since itself but before any later timestamp
+		input.ExpressionAttributeValues[":since"] = &types.AttributeValueMemberS{Value: since.UpdatedAt + "~"}
+	}
+
+	result, err := awsx.Db.Query(ctx, input)
+	if err != nil {
+		return nil, nil, false, models.NewServerError(err)
+	}
+
+	changes := &models.Changes{}
+	next := since
+
+	for _, item := range result.Items {
+		var key models.ItemKey
+		if err := attributevalue.UnmarshalMap(item, &key); err != nil {
+			return nil, nil, false, models.NewServerError(err)
+		}
+
+		updatedAt, err := UnmarshalChange(item, key.SK, changes)
+		if err != nil {
+			return nil, nil, false, models.NewServerError(err)
+		}
+
+		next = &models.SyncToken{UpdatedAt: updatedAt, SK: key.SK}
+	}
+
+	return changes, next, result.LastEvaluatedKey != nil, nil
+}
+
+// getEverything reads the synced items of the user straight from the table, by sort key, for a client
+// that has nothing yet: items saved before the sync index existed have no updated_at and are not in it.
+// Once done, the token it hands back has the feed pick up from when the download started.
+func getEverything(ctx context.Context, userId string, since *models.SyncToken, until time.Time, limit int) (*models.Changes, *models.SyncToken, bool, error) {
+	pk := models.UserKey + userId
+	started := models.Timestamp(until)
+	if since != nil {
+		started = since.UpdatedAt
+	}
+
+	values := map[string]types.AttributeValue{":PK": &types.AttributeValueMemberS{Value: pk}}
+	var filters []string
+	for i, prefix := range models.SyncedKeys {
+		name := fmt.Sprintf(":k%d", i)
+		values[name] = &types.AttributeValueMemberS{Value: prefix}
+		filters = append(filters, fmt.Sprintf("begins_with(#SK, %s)", name))
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(config.App.WorkoutsTable),
+		ExpressionAttributeNames:  map[string]string{"#PK": "PK", "#SK": "SK"},
+		ExpressionAttributeValues: values,
+		KeyConditionExpression:    aws.String("#PK = :PK"),
+		FilterExpression:          aws.String(strings.Join(filters, " OR ")),
+		Limit:                     aws.Int32(int32(limit)),
+	}
+
+	if since != nil && since.SK != "" {
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+			"SK": &types.AttributeValueMemberS{Value: since.SK},
+		}
+	}
+
+	result, err := awsx.Db.Query(ctx, input)
+	if err != nil {
+		return nil, nil, false, models.NewServerError(err)
+	}
+
+	changes := &models.Changes{}
+	for _, item := range result.Items {
+		var key models.ItemKey
+		if err := attributevalue.UnmarshalMap(item, &key); err != nil {
+			return nil, nil, false, models.NewServerError(err)
+		}
+		if _, err := UnmarshalChange(item, key.SK, changes); err != nil {
+			return nil, nil, false, models.NewServerError(err)
+		}
+	}
+
+	if result.LastEvaluatedKey == nil {
+		return changes, &models.SyncToken{UpdatedAt: started}, false, nil
+	}
+
+	// the page may stop short of the limit once filtered; resume where the read stopped
+	var last models.ItemKey
+	if err := attributevalue.UnmarshalMap(result.LastEvaluatedKey, &last); err != nil {
+		return nil, nil, false, models.NewServerError(err)
+	}
+	return changes, &models.SyncToken{UpdatedAt: started, SK: last.SK, Full: true}, true, nil
+}
+
+// UnmarshalChange sorts an item from the change feed into its kind and returns when it changed.
+// Items the feed doesn't know about are skipped.
+func UnmarshalChange(item map[string]types.AttributeValue, sk string, changes *models.Changes) (string, error) {
+	switch {
+	case strings.HasPrefix(sk, models.WorkoutKey):
+		var w models.Workout
+		if err := attributevalue.UnmarshalMap(item, &w); err != nil {
+			return "", err
+		}
+		changes.Workouts = append(changes.Workouts, w)
+		return w.UpdatedAt, nil
+
+	case strings.HasPrefix(sk, models.TemplateKey):
+		var t models.Template
+		if err := attributevalue.UnmarshalMap(item, &t); err != nil {
+			return "", err
+		}
+		changes.Templates = append(changes.Templates, t)
+		return t.UpdatedAt, nil
+
+	case strings.HasPrefix(sk, models.ProgramKey):
+		var p models.Program
+		if err := attributevalue.UnmarshalMap(item, &p); err != nil {
+			return "", err
+		}
+		changes.Programs = append(changes.Programs, p)
+		return p.UpdatedAt, nil
+
+	case strings.HasPrefix(sk, models.ExerciseKey):
+		var e models.Exercise
+		if err := attributevalue.UnmarshalMap(item, &e); err != nil {
+			return "", err
+		}
+		name, err := url.PathUnescape(strings.TrimPrefix(e.Name, models.ExerciseKey))
+		if err != nil {
+			return "", err
+		}
+		e.Name = name
+		changes.Exercises = append(changes.Exercises, e)
+		return e.UpdatedAt, nil
+
+	case strings.HasPrefix(sk, models.TombstoneKey):
+		var t models.Tombstone
+		if err := attributevalue.UnmarshalMap(item, &t); err != nil {
+			return "", err
+		}
+		changes.Tombstones = append(changes.Tombstones, t)
+		return t.UpdatedAt, nil
+	}
+
+	var rest struct {
+		UpdatedAt string `dynamodbav:"updated_at"`
+	}
+	if err := attributevalue.UnmarshalMap(item, &rest); err != nil {
+		return "", fmt.Errorf("unexpected item %s in change feed: %w", sk, err)
+	}
+	return rest.UpdatedAt, nil
+}
+
+// deleteWithTombstone deletes an item and leaves a tombstone in its place in one transaction,
+// so that syncing clients learn about the deletion.
+func deleteWithTombstone(ctx context.Context, userId, kind, sk, id string) error {
+	tombstone, err := attributevalue.MarshalMap(models.NewTombstone(userId, kind, sk, id, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	tx := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Delete: &types.Delete{
+					TableName: aws.String(config.App.WorkoutsTable),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: models.UserKey + userId},
+						"SK": &types.AttributeValueMemberS{Value: sk},
+					},
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName: aws.String(config.App.WorkoutsTable),
+					Item:      tombstone,
+				},
+			},
+		},
+	}
+
+	_, err = awsx.Db.TransactWriteItems(ctx, tx)
+	return err
+}
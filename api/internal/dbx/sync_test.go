@@ -0,0 +1,135 @@
+package dbx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"heart/internal/awsx"
+	"heart/internal/models"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetChanges_SortsItemsByKindAndAdvancesToken(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	workout, err := attributevalue.MarshalMap(models.Workout{PK: "USER#u1", SK: "WORKOUT#w1", Name: "Legs", UpdatedAt: "2025-07-18T05:40:48.000001Z"})
+	require.NoError(t, err)
+	exercise, err := attributevalue.MarshalMap(models.UserExercise{
+		PK:             "USER#u1",
+		SK:             "EXERCISE#Cable%20Fly",
+		UserExerciseIn: models.UserExerciseIn{Name: "Cable Fly"},
+		UpdatedAt:      "2025-07-18T05:40:48.000002Z",
+	})
+	require.NoError(t, err)
+	tombstone, err := attributevalue.MarshalMap(models.Tombstone{PK: "USER#u1", SK: "DELETED#TEMPLATE#t1", Kind: models.KindTemplate, ID: "t1", UpdatedAt: "2025-07-18T05:40:48.000003Z"})
+	require.NoError(t, err)
+
+	since := &models.SyncToken{UpdatedAt: "2025-07-18T05:40:48.000000Z", SK: "WORKOUT#w0"}
+	until := time.Date(2025, 7, 18, 6, 0, 0, 0, time.UTC)
+
+	awsx.Db = &mockDynamo{
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			assert.Equal(t, "#PK = :PK AND #updated_at BETWEEN :since AND :until", *p.KeyConditionExpression)
+			assert.True(t, *p.ScanIndexForward)
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "2025-07-18T06:00:00.000000Z"}, p.ExpressionAttributeValues[":until"])
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "WORKOUT#w0"}, p.ExclusiveStartKey["SK"])
+
+			return &dynamodb.QueryOutput{
+				Items:            []map[string]types.AttributeValue{workout, exercise, tombstone},
+				LastEvaluatedKey: tombstone,
+			}, nil
+		},
+	}
+
+	changes, next, more, err := GetChanges(context.Background(), "u1", since, until, 3)
+	require.NoError(t, err)
+
+	assert.True(t, more)
+	require.Len(t, changes.Workouts, 1)
+	assert.Equal(t, "Legs", changes.Workouts[0].Name)
+	require.Len(t, changes.Exercises, 1)
+	assert.Equal(t, "Cable Fly", changes.Exercises[0].Name)
+	require.Len(t, changes.Tombstones, 1)
+	assert.Equal(t, "t1", changes.Tombstones[0].ID)
+
+	assert.Equal(t, "2025-07-18T05:40:48.000003Z", next.UpdatedAt)
+	assert.Equal(t, "DELETED#TEMPLATE#t1", next.SK)
+}
+
+func TestGetChanges_FirstSyncReadsTheTableByKey(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	// saved before the sync index, so it has no updated_at
+	legacy, err := attributevalue.MarshalMap(models.Workout{PK: "USER#u1", SK: "WORKOUT#w1", Name: "Legs"})
+	require.NoError(t, err)
+
+	until := time.Date(2025, 7, 18, 6, 0, 0, 0, time.UTC)
+	calls := 0
+	awsx.Db = &mockDynamo{
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			calls++
+			assert.Nil(t, p.IndexName)
+			assert.Equal(t, "#PK = :PK", *p.KeyConditionExpression)
+			assert.Contains(t, *p.FilterExpression, "begins_with(#SK, :k0)")
+
+			if calls == 1 {
+				assert.Nil(t, p.ExclusiveStartKey)
+				return &dynamodb.QueryOutput{
+					Items:            []map[string]types.AttributeValue{legacy},
+					LastEvaluatedKey: map[string]types.AttributeValue{"PK": legacy["PK"], "SK": &types.AttributeValueMemberS{Value: "PR#Squat"}},
+				}, nil
+			}
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "PR#Squat"}, p.ExclusiveStartKey["SK"])
+			return &dynamodb.QueryOutput{}, nil
+		},
+	}
+
+	changes, next, more, err := GetChanges(context.Background(), "u1", nil, until, 10)
+	require.NoError(t, err)
+	assert.True(t, more)
+	require.Len(t, changes.Workouts, 1)
+	assert.Equal(t, "Legs", changes.Workouts[0].Name)
+	assert.Equal(t, models.SyncToken{UpdatedAt: "2025-07-18T06:00:00.000000Z", SK: "PR#Squat", Full: true}, *next)
+
+	// later pages keep to when the download started, then hand over to the feed
+	_, next, more, err = GetChanges(context.Background(), "u1", next, until.Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.False(t, more)
+	assert.Equal(t, models.SyncToken{UpdatedAt: "2025-07-18T06:00:00.000000Z"}, *next)
+}
+
+func TestDeleteTemplate_LeavesTombstone(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var captured *dynamodb.TransactWriteItemsInput
+	awsx.Db = &mockDynamo{
+		TransactWriteItemsFn: func(ctx context.Context, p *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+			captured = p
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+
+	require.NoError(t, DeleteTemplate(context.Background(), "u1", "t1"))
+	require.NotNil(t, captured)
+	require.Len(t, captured.TransactItems, 2)
+
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "TEMPLATE#t1"}, captured.TransactItems[0].Delete.Key["SK"])
+
+	var tombstone models.Tombstone
+	require.NoError(t, attributevalue.UnmarshalMap(captured.TransactItems[1].Put.Item, &tombstone))
+	assert.Equal(t, "USER#u1", tombstone.PK)
+	assert.Equal(t, "DELETED#TEMPLATE#t1", tombstone.SK)
+	assert.Equal(t, models.KindTemplate, tombstone.Kind)
+	assert.Equal(t, "t1", tombstone.ID)
+	assert.NotEmpty(t, tombstone.UpdatedAt)
+	assert.Greater(t, tombstone.ExpiresAt, time.Now().Unix())
+}
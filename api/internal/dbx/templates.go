@@ -2,9 +2,13 @@ package dbx
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"heart/internal/awsx"
 	"heart/internal/config"
 	"heart/internal/models"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -71,38 +75,197 @@ func GetTemplate(ctx context.Context, userId string, templateId string) (*models
 	return &template, nil
 }
 
-func SaveTemplate(ctx context.Context, in models.Template) (*models.Template, error) {
-	item, err := attributevalue.MarshalMap(in)
+// SaveTemplate upserts the template and bumps its version. A non-nil expected version makes the
+// save fail with a conflict, carrying the stored copy, if the template has moved on since.
+func SaveTemplate(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+	return saveTemplate(ctx, in, expected, false)
+}
+
+// UpdateTemplate saves the template like SaveTemplate, but only over one that already exists.
+func UpdateTemplate(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+	return saveTemplate(ctx, in, expected, true)
+}
+
+func saveTemplate(ctx context.Context, in models.Template, expected *int, mustExist bool) (*models.Template, error) {
+	in.UpdatedAt = models.Timestamp(time.Now())
+
+	exercisesAV, err := attributevalue.Marshal(in.Exercises)
 	if err != nil {
 		return nil, models.NewServerError(err)
 	}
 
-	input := &dynamodb.PutItemInput{
+	input := &dynamodb.UpdateItemInput{
 		TableName: aws.String(config.App.WorkoutsTable),
-		Item:      item,
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: in.PK},
+			"SK": &types.AttributeValueMemberS{Value: in.SK},
+		},
+		ExpressionAttributeNames: map[string]string{
+			"#name":       "name",
+			"#order":      "order",
+			"#exercises":  "exercises",
+			"#updated_at": "updated_at",
+			"#version":    "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":name":       &types.AttributeValueMemberS{Value: in.Name},
+			":order":      &types.AttributeValueMemberN{Value: strconv.Itoa(in.OrderInParent)},
+			":exercises":  exercisesAV,
+			":updated_at": &types.AttributeValueMemberS{Value: in.UpdatedAt},
+		},
+		UpdateExpression: aws.String(
+			"SET #name = :name, #order = :order, #exercises = :exercises, #updated_at = :updated_at, " + bumpVersion,
+		),
+		ReturnValues:                        types.ReturnValueAllNew, // usage is kept apart from edits
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	}
+	input.ConditionExpression = versionValues(expected, input.ExpressionAttributeValues)
 
-	_, err = awsx.Db.PutItem(ctx, input)
+	if mustExist {
+		input.ExpressionAttributeNames["#PK"] = "PK"
+		condition := "attribute_exists(#PK)"
+		if input.ConditionExpression != nil {
+			condition += " AND " + *input.ConditionExpression
+		}
+		input.ConditionExpression = aws.String(condition)
+	}
+
+	result, err := awsx.Db.UpdateItem(ctx, input)
 	if err != nil {
+		var stale *types.ConditionalCheckFailedException
+		if ok := errors.As(err, &stale); ok {
+			return nil, templateConflict(stale)
+		}
 		return nil, models.NewServerError(err)
 	}
 
+	var stored models.Template
+	if err := attributevalue.UnmarshalMap(result.Attributes, &stored); err != nil {
+		return nil, models.NewServerError(err)
+	}
+	in.Version = stored.Version
+	in.TemplateUse = stored.TemplateUse
+
 	return &in, nil
 }
 
-func DeleteTemplate(ctx context.Context, userId string, templateId string) error {
-	pk := "USER#" + userId
-	sk := "TEMPLATE#" + templateId
+// RecordTemplateUse counts a workout started from the template towards its usage.
+// Templates deleted in the meantime are left alone.
+func RecordTemplateUse(ctx context.Context, template *models.Template, workout *models.Workout) error {
+	planned, completed := template.Adherence(workout)
+	key := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: template.PK},
+		"SK": &types.AttributeValueMemberS{Value: template.SK},
+	}
 
-	input := &dynamodb.DeleteItemInput{
+	_, err := awsx.Db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(config.App.WorkoutsTable),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: pk},
-			"SK": &types.AttributeValueMemberS{Value: sk},
+		Key:       key,
+		ExpressionAttributeNames: map[string]string{
+			"#PK":        "PK",
+			"#performed": "performed",
+			"#planned":   "planned_sets",
+			"#completed": "completed_sets",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":       &types.AttributeValueMemberN{Value: "1"},
+			":planned":   &types.AttributeValueMemberN{Value: strconv.Itoa(planned)},
+			":completed": &types.AttributeValueMemberN{Value: strconv.Itoa(completed)},
 		},
+		UpdateExpression:    aws.String("ADD #performed :one, #planned :planned, #completed :completed"),
+		ConditionExpression: aws.String("attribute_exists(#PK)"),
+	})
+	var gone *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &gone) {
+		return models.NewServerError(err)
 	}
 
-	_, err := awsx.Db.DeleteItem(ctx, input)
+	// workouts can be logged out of order, so the latest start only ever moves forward
+	_, err = awsx.Db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		Key:       key,
+		ExpressionAttributeNames: map[string]string{
+			"#PK":   "PK",
+			"#last": "last_performed",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":start": &types.AttributeValueMemberS{Value: models.Timestamp(workout.Start)},
+		},
+		UpdateExpression:    aws.String("SET #last = :start"),
+		ConditionExpression: aws.String("attribute_exists(#PK) AND (attribute_not_exists(#last) OR #last < :start)"),
+	})
+	var stale *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &stale) {
+		return models.NewServerError(err)
+	}
+
+	return nil
+}
+
+// ReorderTemplates gives the templates their place in the list, in the order given,
+// all or none of them. Each one moves to its next version.
+func ReorderTemplates(ctx context.Context, userId string, templateIds []string) error {
+	updatedAt := models.Timestamp(time.Now())
+
+	items := make([]types.TransactWriteItem, len(templateIds))
+	for i, id := range templateIds {
+		values := map[string]types.AttributeValue{
+			":order":      &types.AttributeValueMemberN{Value: strconv.Itoa(i)},
+			":updated_at": &types.AttributeValueMemberS{Value: updatedAt},
+		}
+		versionValues(nil, values)
+
+		items[i] = types.TransactWriteItem{
+			Update: &types.Update{
+				TableName: aws.String(config.App.WorkoutsTable),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: models.UserKey + userId},
+					"SK": &types.AttributeValueMemberS{Value: models.TemplateKey + id},
+				},
+				ExpressionAttributeNames: map[string]string{
+					"#PK":         "PK",
+					"#order":      "order",
+					"#updated_at": "updated_at",
+					"#version":    "version",
+				},
+				ExpressionAttributeValues: values,
+				UpdateExpression:          aws.String("SET #order = :order, #updated_at = :updated_at, " + bumpVersion),
+				ConditionExpression:       aws.String("attribute_exists(#PK)"),
+			},
+		}
+	}
+
+	_, err := awsx.Db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) {
+			for i, reason := range canceled.CancellationReasons {
+				if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+					return models.NewNotFoundError(fmt.Sprintf("Template %s not found", templateIds[i]), canceled)
+				}
+			}
+		}
+		return models.NewServerError(err)
+	}
+
+	return nil
+}
+
+func templateConflict(stale *types.ConditionalCheckFailedException) error {
+	if stale.Item == nil {
+		return models.NewNotFoundError("Template not found", stale)
+	}
+
+	var current models.Template
+	if err := attributevalue.UnmarshalMap(stale.Item, &current); err != nil {
+		return models.NewServerError(err)
+	}
+
+	return models.NewConflictError("Template was changed on another device", models.NewTemplateOut(&current), stale)
+}
+
+func DeleteTemplate(ctx context.Context, userId string, templateId string) error {
+	err := deleteWithTombstone(ctx, userId, models.KindTemplate, "TEMPLATE#"+templateId, templateId)
 	if err != nil {
 		return models.NewServerError(err)
 	}
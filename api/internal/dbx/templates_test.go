@@ -3,10 +3,17 @@ package dbx
 import (
 	"context"
 	"testing"
+	"time"
 
 	"heart/internal/awsx"
+	"heart/internal/models"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetTemplate_NotFound(t *testing.T) {
@@ -28,3 +35,135 @@ func TestGetTemplate_NotFound(t *testing.T) {
 		t.Fatalf("expected 404 NotFound, got %#v", err)
 	}
 }
+
+func TestSaveTemplate_StaleVersionReturnsServerCopy(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	current, err := attributevalue.MarshalMap(models.Template{PK: "USER#u1", SK: "TEMPLATE#t1", Name: "Push", Version: 2})
+	if err != nil {
+		t.Fatalf("marshal err: %v", err)
+	}
+
+	var captured *dynamodb.UpdateItemInput
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			captured = p
+			return nil, &types.ConditionalCheckFailedException{Item: current}
+		},
+	}
+
+	expected := 1
+	_, err = SaveTemplate(context.Background(), models.Template{PK: "USER#u1", SK: "TEMPLATE#t1", Name: "Pull"}, &expected)
+
+	if *captured.ConditionExpression != "#version = :expected" {
+		t.Fatalf("unexpected condition %q", *captured.ConditionExpression)
+	}
+	conflict, ok := err.(*models.ConflictError)
+	if !ok {
+		t.Fatalf("expected ConflictError, got %#v", err)
+	}
+	if out := conflict.Current.(models.TemplateOut); out.Name != "Push" || out.Version != 2 {
+		t.Fatalf("unexpected server copy %#v", out)
+	}
+}
+
+func TestRecordTemplateUse_CountsAndMovesLastPerformedForward(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var updates []*dynamodb.UpdateItemInput
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			updates = append(updates, p)
+			if len(updates) == 2 {
+				// a later workout got there first
+				return nil, &types.ConditionalCheckFailedException{}
+			}
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+
+	template := &models.Template{
+		PK:        "USER#u1",
+		SK:        "TEMPLATE#t1",
+		Exercises: []models.TemplateExercise{{ExerciseID: "Squat", Sets: []models.Set{{}, {}}}},
+	}
+	workout := &models.Workout{
+		Start:     time.Date(2025, 8, 1, 7, 0, 0, 0, time.UTC),
+		Exercises: []models.WorkoutExercise{{ExerciseID: "Squat", Sets: []models.Set{{Completed: true}, {}}}},
+	}
+
+	err := RecordTemplateUse(context.Background(), template, workout)
+
+	assert.NoError(t, err)
+	require.Len(t, updates, 2)
+	assert.Equal(t, "ADD #performed :one, #planned :planned, #completed :completed", *updates[0].UpdateExpression)
+	assert.Equal(t, "2", updates[0].ExpressionAttributeValues[":planned"].(*types.AttributeValueMemberN).Value)
+	assert.Equal(t, "1", updates[0].ExpressionAttributeValues[":completed"].(*types.AttributeValueMemberN).Value)
+	assert.Equal(t, "2025-08-01T07:00:00.000000Z", updates[1].ExpressionAttributeValues[":start"].(*types.AttributeValueMemberS).Value)
+}
+
+func TestUpdateTemplate_OnlyOverAnExistingTemplate(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var conditions []string
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			conditions = append(conditions, *p.ConditionExpression)
+			return nil, &types.ConditionalCheckFailedException{}
+		},
+	}
+
+	version := 2
+	template := models.Template{PK: "USER#u1", SK: "TEMPLATE#t1"}
+	_, err := UpdateTemplate(context.Background(), template, nil)
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+
+	_, _ = UpdateTemplate(context.Background(), template, &version)
+	assert.Equal(t, []string{"attribute_exists(#PK)", "attribute_exists(#PK) AND #version = :expected"}, conditions)
+}
+
+func TestReorderTemplates_OneTransaction(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var orders, keys []string
+	awsx.Db = &mockDynamo{
+		TransactWriteItemsFn: func(ctx context.Context, p *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+			for _, item := range p.TransactItems {
+				keys = append(keys, item.Update.Key["SK"].(*types.AttributeValueMemberS).Value)
+				orders = append(orders, item.Update.ExpressionAttributeValues[":order"].(*types.AttributeValueMemberN).Value)
+			}
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+
+	err := ReorderTemplates(context.Background(), "u1", []string{"t3", "t1", "t2"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"TEMPLATE#t3", "TEMPLATE#t1", "TEMPLATE#t2"}, keys)
+	assert.Equal(t, []string{"0", "1", "2"}, orders)
+}
+
+func TestReorderTemplates_NamesTheMissingTemplate(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	awsx.Db = &mockDynamo{
+		TransactWriteItemsFn: func(ctx context.Context, p *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+			return nil, &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+				{Code: aws.String("None")},
+				{Code: aws.String("ConditionalCheckFailed")},
+			}}
+		},
+	}
+
+	err := ReorderTemplates(context.Background(), "u1", []string{"t1", "gone"})
+
+	var notFound *models.NotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Contains(t, string(notFound.JSON()), "gone")
+}
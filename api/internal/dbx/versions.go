@@ -0,0 +1,30 @@
+package dbx
+
+import (
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// bumpVersion is the update clause that moves an item to its next version.
+const bumpVersion = "#version = if_not_exists(#version, :zero) + :one"
+
+// versionValues adds the operands of bumpVersion and, when the client based its edit
+// on a known version, returns the condition that the stored item is still on it.
+// Version zero stands for a copy never saved before, as do items that predate versioning.
+func versionValues(expected *int, values map[string]types.AttributeValue) *string {
+	values[":zero"] = &types.AttributeValueMemberN{Value: "0"}
+	values[":one"] = &types.AttributeValueMemberN{Value: "1"}
+
+	if expected == nil {
+		return nil // last writer wins, as older clients expect
+	}
+
+	if *expected == 0 {
+		return aws.String("attribute_not_exists(#version)")
+	}
+
+	values[":expected"] = &types.AttributeValueMemberN{Value: strconv.Itoa(*expected)}
+	return aws.String("#version = :expected")
+}
@@ -6,7 +6,9 @@ import (
 	"heart/internal/awsx"
 	"heart/internal/config"
 	"heart/internal/models"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -44,7 +46,10 @@ func GetWorkout(ctx context.Context, userId string, workoutId string) (*models.W
 	return &workout, nil
 }
 
-func SaveWorkout(ctx context.Context, in models.Workout) (*models.Workout, error) {
+// SaveWorkout upserts the workout, bumps its version and brings the exercise history in line.
+// A non-nil expected version makes the save fail with a conflict, carrying the stored copy,
+// if the workout has moved on since.
+func SaveWorkout(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error) {
 	startAV, err := attributevalue.Marshal(in.Start)
 	if err != nil {
 		return nil, models.NewServerError(err)
@@ -54,6 +59,8 @@ func SaveWorkout(ctx context.Context, in models.Workout) (*models.Workout, error
 		return nil, models.NewServerError(err)
 	}
 
+	in.UpdatedAt = models.Timestamp(time.Now())
+
 	input := &dynamodb.UpdateItemInput{
 		TableName: aws.String(config.App.WorkoutsTable),
 		Key: map[string]types.AttributeValue{
@@ -61,20 +68,29 @@ func SaveWorkout(ctx context.Context, in models.Workout) (*models.Workout, error
 			"SK": &types.AttributeValueMemberS{Value: in.SK},
 		},
 		ExpressionAttributeNames: map[string]string{
-			"#start":     "start",
-			"#end":       "end",
-			"#name":      "name",
-			"#exercises": "exercises",
+			"#start":      "start",
+			"#end":        "end",
+			"#name":       "name",
+			"#exercises":  "exercises",
+			"#template":   "template_id",
+			"#updated_at": "updated_at",
+			"#version":    "version",
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":start":     startAV,
-			":exercises": exercisesAV,
+			":start":      startAV,
+			":exercises":  exercisesAV,
+			":updated_at": &types.AttributeValueMemberS{Value: in.UpdatedAt},
 		},
+		ReturnValues:                        types.ReturnValueAllOld, // to know what the history had
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	}
+	input.ConditionExpression = versionValues(expected, input.ExpressionAttributeValues)
 
 	setParts := []string{
 		"#start = :start",
 		"#exercises = :exercises",
+		"#updated_at = :updated_at",
+		bumpVersion,
 	}
 	removeParts := []string{}
 
@@ -100,32 +116,107 @@ func SaveWorkout(ctx context.Context, in models.Workout) (*models.Workout, error
 		removeParts = append(removeParts, "#name")
 	}
 
+	if in.Template != "" {
+		input.ExpressionAttributeValues[":template"] = &types.AttributeValueMemberS{Value: in.Template}
+		setParts = append(setParts, "#template = :template")
+	} else {
+		removeParts = append(removeParts, "#template")
+	}
+
 	updateExpr := "SET " + strings.Join(setParts, ", ")
 	if len(removeParts) > 0 {
 		updateExpr += " REMOVE " + strings.Join(removeParts, ", ")
 	}
 	input.UpdateExpression = aws.String(updateExpr)
 
-	_, err = awsx.Db.UpdateItem(ctx, input)
+	result, err := awsx.Db.UpdateItem(ctx, input)
 	if err != nil {
+		var stale *types.ConditionalCheckFailedException
+		if ok := errors.As(err, &stale); ok {
+			return nil, workoutConflict(stale)
+		}
 		return nil, models.NewServerError(err)
 	}
 
+	var previous *models.Workout
+	in.Version = 1
+	if len(result.Attributes) > 0 {
+		previous = &models.Workout{}
+		if err := attributevalue.UnmarshalMap(result.Attributes, previous); err != nil {
+			return nil, models.NewServerError(err)
+		}
+		in.Version = previous.Version + 1
+	}
+
+	// the workout is saved by now; history that lags behind is restored by the next save
+	if err := SaveHistory(ctx, &in, previous); err != nil {
+		log.Printf("[ERROR] saving exercise history of workout %s: %v", in.ID(), err)
+	} else if previous != nil {
+		if err := dropRecords(ctx, strings.TrimPrefix(in.PK, models.UserKey), previous, &in); err != nil {
+			log.Printf("[ERROR] recomputing records of workout %s: %v", in.ID(), err)
+		}
+	}
+
 	return &in, nil
 }
-func DeleteWorkout(ctx context.Context, userId string, workoutId string) error {
-	pk := models.UserKey + userId
-	sk := models.WorkoutKey + workoutId
 
-	input := &dynamodb.DeleteItemInput{
-		TableName: aws.String(config.App.WorkoutsTable),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: pk},
-			"SK": &types.AttributeValueMemberS{Value: sk},
-		},
+// ImportWorkouts writes new workouts, along with their exercise history, in batches.
+// It does not check for workouts already there; those are overwritten.
+func ImportWorkouts(ctx context.Context, workouts []models.Workout) (int, error) {
+	updatedAt := models.Timestamp(time.Now())
+
+	var requests []types.WriteRequest
+	for _, w := range workouts {
+		w.UpdatedAt = updatedAt
+		w.Version = 1
+
+		item, err := attributevalue.MarshalMap(w)
+		if err != nil {
+			return 0, models.NewServerError(err)
+		}
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+
+		for _, entry := range models.NewHistoryEntries(&w) {
+			item, err := attributevalue.MarshalMap(entry)
+			if err != nil {
+				return 0, models.NewServerError(err)
+			}
+			requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		}
+	}
+
+	if _, err := batchWrite(ctx, requests); err != nil {
+		return 0, err
+	}
+
+	return len(workouts), nil
+}
+
+func workoutConflict(stale *types.ConditionalCheckFailedException) error {
+	if stale.Item == nil {
+		return models.NewNotFoundError("Workout not found", stale)
+	}
+
+	var current models.Workout
+	if err := attributevalue.UnmarshalMap(stale.Item, &current); err != nil {
+		return models.NewServerError(err)
 	}
 
-	_, err := awsx.Db.DeleteItem(ctx, input)
+	return models.NewConflictError(
+		"Workout was changed on another device",
+		models.NewWorkoutOut(&current, config.App.MediaDistributionAlias),
+		stale,
+	)
+}
+
+func DeleteWorkout(ctx context.Context, userId string, workoutId string) error {
+	existing, err := GetWorkout(ctx, userId, workoutId)
+	var notFound *models.NotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return err
+	}
+
+	err = deleteWithTombstone(ctx, userId, models.KindWorkout, models.WorkoutKey+workoutId, workoutId)
 
 	if err != nil {
 		var notFound *types.ConditionalCheckFailedException
@@ -135,11 +226,27 @@ func DeleteWorkout(ctx context.Context, userId string, workoutId string) error {
 		return models.NewServerError(err)
 	}
 
+	if existing != nil {
+		if err := DeleteHistory(ctx, existing); err != nil {
+			log.Printf("[ERROR] deleting exercise history of workout %s: %v", workoutId, err)
+		} else if err := dropRecords(ctx, userId, existing, nil); err != nil {
+			log.Printf("[ERROR] recomputing records of workout %s: %v", workoutId, err)
+		}
+	}
+
 	return nil
 }
 
-func GetWorkouts(ctx context.Context, userId string, limit int, cursor string) ([]models.Workout, string, error) {
+// GetWorkouts returns a page of the user's workouts that pass the filter, newest first.
+// The cursor is the ID of the last workout on the previous page. Filtering by exercise goes
+// through history, so until that is complete it returns a *models.HistoryPendingError.
+func GetWorkouts(ctx context.Context, userId string, limit int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error) {
+	if filter.Exercise != "" {
+		return getWorkoutsWithExercise(ctx, userId, limit, cursor, filter)
+	}
+
 	pk := models.UserKey + userId
+	lower, upper := models.WorkoutKeyRange(models.WorkoutKey, filter.From, filter.To)
 	input := &dynamodb.QueryInput{
 		TableName: aws.String(config.App.WorkoutsTable),
 		ExpressionAttributeNames: map[string]string{
@@ -148,8 +255,8 @@ func GetWorkouts(ctx context.Context, userId string, limit int, cursor string) (
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":PK":     &types.AttributeValueMemberS{Value: pk},
-			":SK_MIN": &types.AttributeValueMemberS{Value: "WORKOUT#"},
-			":SK_MAX": &types.AttributeValueMemberS{Value: "WORKOUT$"}, // Using $ which comes after # in ASCII
+			":SK_MIN": &types.AttributeValueMemberS{Value: lower},
+			":SK_MAX": &types.AttributeValueMemberS{Value: upper},
 		},
 		KeyConditionExpression: aws.String("#PK = :PK AND #SK BETWEEN :SK_MIN AND :SK_MAX"),
 		ScanIndexForward:       aws.Bool(false),
@@ -164,27 +271,133 @@ func GetWorkouts(ctx context.Context, userId string, limit int, cursor string) (
 		}
 	}
 
-	result, err := awsx.Db.Query(ctx, input)
+	var keep func(*models.Workout) bool
+	if filter.Name != "" {
+		keep = func(w *models.Workout) bool { return filter.MatchesName(w.Name) }
+	}
+
+	workouts, last, err := queryPage(ctx, input, limit, keep)
 	if err != nil {
-		return nil, "", models.NewServerError(err)
+		return nil, "", err
 	}
 
-	var workouts []models.Workout
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &workouts)
+	return workouts, strings.TrimPrefix(last, models.WorkoutKey), nil
+}
+
+// getWorkoutsWithExercise finds the workouts through the exercise's history rows,
+// which carry the workout name as well, and then reads them whole.
+func getWorkoutsWithExercise(ctx context.Context, userId string, limit int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error) {
+	if err := historyReady(ctx, userId); err != nil {
+		return nil, "", err
+	}
+
+	pk := models.UserKey + userId
+	prefix := models.HistoryPrefix(filter.Exercise)
+	lower, upper := models.WorkoutKeyRange(prefix, filter.From, filter.To)
+	input := &dynamodb.QueryInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		ExpressionAttributeNames: map[string]string{
+			"#PK": "PK",
+			"#SK": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":PK":     &types.AttributeValueMemberS{Value: pk},
+			":SK_MIN": &types.AttributeValueMemberS{Value: lower},
+			":SK_MAX": &types.AttributeValueMemberS{Value: upper},
+		},
+		KeyConditionExpression: aws.String("#PK = :PK AND #SK BETWEEN :SK_MIN AND :SK_MAX"),
+		ProjectionExpression:   aws.String("#PK, #SK, workout_id, workout_name"),
+		ScanIndexForward:       aws.Bool(false),
+		Limit:                  aws.Int32(int32(limit)),
+	}
+
+	// pagination
+	if cursor != "" {
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+			"SK": &types.AttributeValueMemberS{Value: prefix + cursor},
+		}
+	}
+
+	var keep func(*models.HistoryEntry) bool
+	if filter.Name != "" {
+		keep = func(h *models.HistoryEntry) bool { return filter.MatchesName(h.WorkoutName) }
+	}
+
+	entries, last, err := queryPage(ctx, input, limit, keep)
 	if err != nil {
-		return nil, "", models.NewServerError(err)
+		return nil, "", err
 	}
 
-	var nextCursor string
-	if result.LastEvaluatedKey != nil {
-		if skAttr, ok := result.LastEvaluatedKey["SK"]; ok {
-			if skValue, ok := skAttr.(*types.AttributeValueMemberS); ok {
-				nextCursor = strings.TrimPrefix(skValue.Value, "WORKOUT#")
-			}
+	keys := make([]map[string]types.AttributeValue, len(entries))
+	for i, e := range entries {
+		keys[i] = map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+			"SK": &types.AttributeValueMemberS{Value: models.WorkoutKey + e.WorkoutID},
 		}
 	}
 
-	return workouts, nextCursor, nil
+	found, err := batchGet[models.Workout](ctx, keys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	byId := make(map[string]models.Workout, len(found))
+	for _, w := range found {
+		byId[w.ID()] = w
+	}
+
+	// in history order, skipping any workout deleted while its history lingered
+	workouts := make([]models.Workout, 0, len(entries))
+	for _, e := range entries {
+		if w, ok := byId[e.WorkoutID]; ok {
+			workouts = append(workouts, w)
+		}
+	}
+
+	return workouts, strings.TrimPrefix(last, prefix), nil
+}
+
+// GetWorkoutsBetween returns all workouts the user started between from and to, oldest first,
+// none if from is after to.
+func GetWorkoutsBetween(ctx context.Context, userId string, from, to time.Time) ([]models.Workout, error) {
+	if from.After(to) {
+		return nil, nil
+	}
+
+	lower, upper := models.WorkoutKeyRange(models.WorkoutKey, from, to)
+	input := &dynamodb.QueryInput{
+		TableName: aws.String(config.App.WorkoutsTable),
+		ExpressionAttributeNames: map[string]string{
+			"#PK": "PK",
+			"#SK": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":PK":     &types.AttributeValueMemberS{Value: models.UserKey + userId},
+			":SK_MIN": &types.AttributeValueMemberS{Value: lower},
+			":SK_MAX": &types.AttributeValueMemberS{Value: upper},
+		},
+		KeyConditionExpression: aws.String("#PK = :PK AND #SK BETWEEN :SK_MIN AND :SK_MAX"),
+	}
+
+	var workouts []models.Workout
+	for {
+		result, err := awsx.Db.Query(ctx, input)
+		if err != nil {
+			return nil, models.NewServerError(err)
+		}
+
+		var page []models.Workout
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, models.NewServerError(err)
+		}
+		workouts = append(workouts, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return workouts, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
 }
 
 func RemoveWorkoutImage(ctx context.Context, userId, workoutId, imageId string) error {
@@ -199,6 +412,12 @@ func RemoveWorkoutImage(ctx context.Context, userId, workoutId, imageId string)
 	workoutSK := models.WorkoutKey + workoutId
 	progressSK := models.ProgressKey + workoutId + "#" + imageKey
 
+	values := map[string]types.AttributeValue{
+		":imageset":   &types.AttributeValueMemberSS{Value: []string{imageKey}},
+		":updated_at": &types.AttributeValueMemberS{Value: models.Timestamp(time.Now())},
+	}
+	versionValues(nil, values)
+
 	tx := &dynamodb.TransactWriteItemsInput{
 		TransactItems: []types.TransactWriteItem{
 			{
@@ -208,14 +427,15 @@ func RemoveWorkoutImage(ctx context.Context, userId, workoutId, imageId string)
 						"PK": &types.AttributeValueMemberS{Value: pk},
 						"SK": &types.AttributeValueMemberS{Value: workoutSK},
 					},
-					UpdateExpression: aws.String("DELETE #images :imageset"),
+					// a change like any other, for other devices to sync
+					UpdateExpression: aws.String("SET #updated_at = :updated_at, " + bumpVersion + " DELETE #images :imageset"),
 					ExpressionAttributeNames: map[string]string{
-						"#images": "images",
-					},
-					ExpressionAttributeValues: map[string]types.AttributeValue{
-						":imageset": &types.AttributeValueMemberSS{Value: []string{imageKey}},
+						"#images":     "images",
+						"#updated_at": "updated_at",
+						"#version":    "version",
 					},
-					ConditionExpression: aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
+					ExpressionAttributeValues: values,
+					ConditionExpression:       aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
 				},
 			},
 			{
@@ -53,7 +53,7 @@ func TestGetWorkouts_PaginationAndUnmarshal(t *testing.T) {
 		},
 	}
 
-	workouts, cursor, err := GetWorkouts(context.Background(), "u1", 10, "")
+	workouts, cursor, err := GetWorkouts(context.Background(), "u1", 10, "", models.WorkoutFilter{})
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -73,7 +73,10 @@ func TestDeleteWorkout_NotFoundMapsToNotFoundError(t *testing.T) {
 	defer teardown()
 
 	awsx.Db = &mockDynamo{
-		DeleteItemFn: func(ctx context.Context, p *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{}, nil
+		},
+		TransactWriteItemsFn: func(ctx context.Context, p *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
 			return nil, &types.ConditionalCheckFailedException{}
 		},
 	}
@@ -195,3 +198,490 @@ func TestGetWorkoutGallery_QueryErrorWrapped(t *testing.T) {
 	assert.True(t, errors.As(err, &httpErr))
 	assert.Equal(t, 500, httpErr.Status())
 }
+
+func TestSaveWorkout_UnconditionalWithoutExpectedVersion(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var captured *dynamodb.UpdateItemInput
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			captured = p
+			return &dynamodb.UpdateItemOutput{
+				Attributes: map[string]types.AttributeValue{"version": &types.AttributeValueMemberN{Value: "4"}},
+			}, nil
+		},
+		BatchWriteItemFn: func(ctx context.Context, p *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	saved, err := SaveWorkout(context.Background(), models.Workout{PK: "USER#u1", SK: "WORKOUT#w1"}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, captured.ConditionExpression)
+	assert.Contains(t, *captured.UpdateExpression, "#version = if_not_exists(#version, :zero) + :one")
+	assert.Equal(t, 5, saved.Version)
+}
+
+func TestSaveWorkout_ExpectedVersionGuardsTheWrite(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var conditions []string
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			conditions = append(conditions, *p.ConditionExpression)
+			if expected, ok := p.ExpressionAttributeValues[":expected"]; ok {
+				assert.Equal(t, &types.AttributeValueMemberN{Value: "3"}, expected)
+			}
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+		BatchWriteItemFn: func(ctx context.Context, p *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	zero, three := 0, 3
+	_, err := SaveWorkout(context.Background(), models.Workout{PK: "USER#u1", SK: "WORKOUT#w1"}, &zero)
+	assert.NoError(t, err)
+	_, err = SaveWorkout(context.Background(), models.Workout{PK: "USER#u1", SK: "WORKOUT#w1"}, &three)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"attribute_not_exists(#version)", "#version = :expected"}, conditions)
+}
+
+func TestSaveWorkout_StaleVersionReturnsServerCopy(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	current, err := attributevalue.MarshalMap(models.Workout{PK: "USER#u1", SK: "WORKOUT#w1", Name: "Tablet edit", Version: 5})
+	assert.NoError(t, err)
+
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			assert.Equal(t, types.ReturnValuesOnConditionCheckFailureAllOld, p.ReturnValuesOnConditionCheckFailure)
+			return nil, &types.ConditionalCheckFailedException{Item: current}
+		},
+	}
+
+	expected := 4
+	_, err = SaveWorkout(context.Background(), models.Workout{PK: "USER#u1", SK: "WORKOUT#w1", Name: "Phone edit"}, &expected)
+
+	var conflict *models.ConflictError
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, 409, conflict.Status())
+		out := conflict.Current.(models.WorkoutOut)
+		assert.Equal(t, "Tablet edit", out.Name)
+		assert.Equal(t, 5, out.Version)
+	}
+}
+
+func TestSaveWorkout_ExpectedVersionOfDeletedWorkout(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			return nil, &types.ConditionalCheckFailedException{}
+		},
+	}
+
+	expected := 2
+	_, err := SaveWorkout(context.Background(), models.Workout{PK: "USER#u1", SK: "WORKOUT#w1"}, &expected)
+
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestSaveWorkout_ReplacesHistoryOfRemovedExercises(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	previous, err := attributevalue.MarshalMap(models.Workout{
+		PK: "USER#u1",
+		SK: "WORKOUT#w1",
+		Exercises: []models.WorkoutExercise{
+			{ExerciseID: "Squat"},
+			{ExerciseID: "Leg Press"},
+		},
+		Version: 1,
+	})
+	assert.NoError(t, err)
+
+	var puts, deletes, records []string
+	awsx.Db = &mockDynamo{
+		UpdateItemFn: func(ctx context.Context, p *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			assert.Equal(t, types.ReturnValueAllOld, p.ReturnValues)
+			return &dynamodb.UpdateItemOutput{Attributes: previous}, nil
+		},
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			records = append(records, p.Key["SK"].(*types.AttributeValueMemberS).Value)
+			return &dynamodb.GetItemOutput{}, nil
+		},
+		BatchWriteItemFn: func(ctx context.Context, p *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			for _, r := range p.RequestItems["test-table"] {
+				if r.PutRequest != nil {
+					puts = append(puts, r.PutRequest.Item["SK"].(*types.AttributeValueMemberS).Value)
+				} else {
+					deletes = append(deletes, r.DeleteRequest.Key["SK"].(*types.AttributeValueMemberS).Value)
+				}
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	saved, err := SaveWorkout(context.Background(), models.Workout{
+		PK: "USER#u1",
+		SK: "WORKOUT#w1",
+		Exercises: []models.WorkoutExercise{
+			{ExerciseID: "Squat"},
+			{ExerciseID: "Lunge"},
+		},
+	}, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, saved.Version)
+	assert.Equal(t, []string{"HIST#Squat#w1", "HIST#Lunge#w1"}, puts)
+	assert.Equal(t, []string{"HIST#Leg%20Press#w1"}, deletes)
+	assert.Equal(t, []string{"PR#Leg%20Press"}, records) // only what was taken out is looked at
+}
+
+func TestDeleteWorkout_DropsHistory(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	existing, err := attributevalue.MarshalMap(models.Workout{
+		PK:        "USER#u1",
+		SK:        "WORKOUT#w1",
+		Exercises: []models.WorkoutExercise{{ExerciseID: "Squat"}},
+	})
+	assert.NoError(t, err)
+
+	var deletes []string
+	awsx.Db = &mockDynamo{
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: existing}, nil
+		},
+		TransactWriteItemsFn: func(ctx context.Context, p *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+		BatchWriteItemFn: func(ctx context.Context, p *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			for _, r := range p.RequestItems["test-table"] {
+				deletes = append(deletes, r.DeleteRequest.Key["SK"].(*types.AttributeValueMemberS).Value)
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	assert.NoError(t, DeleteWorkout(context.Background(), "u1", "w1"))
+	assert.Equal(t, []string{"HIST#Squat#w1"}, deletes)
+}
+
+func TestGetExerciseHistory_QueriesOneExercise(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	entry, err := attributevalue.MarshalMap(models.HistoryEntry{
+		PK:        "USER#u1",
+		SK:        "HIST#Bench%20Press#w2",
+		Exercise:  "Bench Press",
+		WorkoutID: "w2",
+	})
+	assert.NoError(t, err)
+
+	awsx.Db = &mockDynamo{
+		GetItemFn: historyBuilt,
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "HIST#Bench%20Press#"}, p.ExpressionAttributeValues[":PREFIX"])
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "HIST#Bench%20Press#w3"}, p.ExclusiveStartKey["SK"])
+			assert.False(t, *p.ScanIndexForward)
+			return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{entry}, LastEvaluatedKey: entry}, nil
+		},
+	}
+
+	entries, next, err := GetExerciseHistory(context.Background(), "u1", "Bench Press", 1, "w3")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "w2", entries[0].WorkoutID)
+	assert.Equal(t, "w2", next)
+}
+
+func TestGetWorkoutsBetween_BoundsByIdAndPages(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	item, err := attributevalue.MarshalMap(models.Workout{PK: "USER#u1", SK: "WORKOUT#2025-03-03T12:00:00.000000Z"})
+	assert.NoError(t, err)
+
+	calls := 0
+	awsx.Db = &mockDynamo{
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "WORKOUT#2025-03-01T05:00:00"}, p.ExpressionAttributeValues[":SK_MIN"])
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "WORKOUT#2025-04-01T03:59:59~"}, p.ExpressionAttributeValues[":SK_MAX"])
+			calls++
+			if calls == 1 {
+				return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{item}, LastEvaluatedKey: item}, nil
+			}
+			return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{item}}, nil
+		},
+	}
+
+	toronto, _ := time.LoadLocation("America/Toronto")
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, toronto)
+	to := time.Date(2025, 4, 1, 0, 0, 0, 0, toronto).Add(-time.Nanosecond)
+
+	workouts, err := GetWorkoutsBetween(context.Background(), "u1", from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Len(t, workouts, 2)
+}
+
+func TestGetWorkoutsBetween_NoneWhenFromIsAfterTo(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	awsx.Db = &mockDynamo{} // no query to make
+
+	now := time.Now()
+	workouts, err := GetWorkoutsBetween(context.Background(), "u1", now.Add(time.Hour), now)
+	assert.NoError(t, err)
+	assert.Empty(t, workouts)
+}
+
+func TestGetWorkouts_NameFilterFillsThePage(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	page := func(names ...string) []map[string]types.AttributeValue {
+		var items []map[string]types.AttributeValue
+		for _, n := range names {
+			item, err := attributevalue.MarshalMap(models.Workout{PK: "USER#u1", SK: "WORKOUT#" + n, Name: n})
+			assert.NoError(t, err)
+			items = append(items, item)
+		}
+		return items
+	}
+
+	calls := 0
+	awsx.Db = &mockDynamo{
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "WORKOUT#2025-03-01T00:00:00"}, p.ExpressionAttributeValues[":SK_MIN"])
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "WORKOUT#~"}, p.ExpressionAttributeValues[":SK_MAX"])
+			calls++
+			if calls == 1 {
+				items := page("Legs", "Push A")
+				return &dynamodb.QueryOutput{Items: items, LastEvaluatedKey: items[1]}, nil
+			}
+			return &dynamodb.QueryOutput{Items: page("Pull", "push B", "Upper Push")}, nil
+		},
+	}
+
+	filter := models.WorkoutFilter{From: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Name: "PUSH"}
+	workouts, cursor, err := GetWorkouts(context.Background(), "u1", 2, "", filter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Len(t, workouts, 2)
+	assert.Equal(t, "push B", workouts[1].Name)
+	assert.Equal(t, "push B", cursor) // Upper Push is still to come
+}
+
+func TestGetWorkouts_ExerciseFilterGoesThroughHistory(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var entries []map[string]types.AttributeValue
+	for _, id := range []string{"w3", "w2", "w1"} {
+		item, err := attributevalue.MarshalMap(models.HistoryEntry{PK: "USER#u1", SK: "HIST#Squat#" + id, WorkoutID: id})
+		assert.NoError(t, err)
+		entries = append(entries, item)
+	}
+	w1, err := attributevalue.MarshalMap(models.Workout{PK: "USER#u1", SK: "WORKOUT#w1", Name: "Legs"})
+	assert.NoError(t, err)
+	w3, err := attributevalue.MarshalMap(models.Workout{PK: "USER#u1", SK: "WORKOUT#w3", Name: "Legs again"})
+	assert.NoError(t, err)
+
+	awsx.Db = &mockDynamo{
+		GetItemFn: historyBuilt,
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "HIST#Squat#"}, p.ExpressionAttributeValues[":SK_MIN"])
+			assert.Equal(t, &types.AttributeValueMemberS{Value: "HIST#Squat#w4"}, p.ExclusiveStartKey["SK"])
+			return &dynamodb.QueryOutput{Items: entries}, nil
+		},
+		BatchGetItemFn: func(ctx context.Context, p *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+			assert.Len(t, p.RequestItems["test-table"].Keys, 3)
+			// w2 is gone; the rest come back out of order
+			return &dynamodb.BatchGetItemOutput{
+				Responses: map[string][]map[string]types.AttributeValue{"test-table": {w1, w3}},
+			}, nil
+		},
+	}
+
+	workouts, cursor, err := GetWorkouts(context.Background(), "u1", 10, "w4", models.WorkoutFilter{Exercise: "Squat"})
+
+	assert.NoError(t, err)
+	assert.Empty(t, cursor)
+	assert.Len(t, workouts, 2)
+	assert.Equal(t, "w3", workouts[0].ID())
+	assert.Equal(t, "w1", workouts[1].ID())
+}
+
+func TestImportWorkouts_WritesWorkoutsWithHistory(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var puts []string
+	var versions []string
+	awsx.Db = &mockDynamo{
+		BatchWriteItemFn: func(ctx context.Context, p *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			for _, r := range p.RequestItems["test-table"] {
+				puts = append(puts, r.PutRequest.Item["SK"].(*types.AttributeValueMemberS).Value)
+				if v, ok := r.PutRequest.Item["version"].(*types.AttributeValueMemberN); ok {
+					versions = append(versions, v.Value)
+				}
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+
+	count, err := ImportWorkouts(context.Background(), []models.Workout{
+		{PK: "USER#u1", SK: "WORKOUT#w1", Exercises: []models.WorkoutExercise{{ExerciseID: "Squat"}}},
+		{PK: "USER#u1", SK: "WORKOUT#w2", Exercises: []models.WorkoutExercise{{ExerciseID: "Squat"}, {ExerciseID: "Lunge"}}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{"WORKOUT#w1", "HIST#Squat#w1", "WORKOUT#w2", "HIST#Squat#w2", "HIST#Lunge#w2"}, puts)
+	assert.Equal(t, []string{"1", "1"}, versions)
+}
+
+func TestRemoveWorkoutImage_IsAChangeToSync(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	awsx.Db = &mockDynamo{
+		TransactWriteItemsFn: func(ctx context.Context, p *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+			update := p.TransactItems[0].Update
+			assert.Equal(t, "SET #updated_at = :updated_at, "+bumpVersion+" DELETE #images :imageset", *update.UpdateExpression)
+			assert.Contains(t, update.ExpressionAttributeValues, ":updated_at")
+			assert.Equal(t, &types.AttributeValueMemberN{Value: "1"}, update.ExpressionAttributeValues[":one"])
+			assert.Equal(t, "PROGRESS#w1#workouts/abc/1.jpg", p.TransactItems[1].Delete.Key["SK"].(*types.AttributeValueMemberS).Value)
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+
+	assert.NoError(t, RemoveWorkoutImage(context.Background(), "u1", "w1", "/workouts/abc/1.jpg"))
+}
+
+func TestEnsureHistory_BuildsItOnceForOlderWorkouts(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	// saved before history was kept
+	legacy, err := attributevalue.MarshalMap(models.Workout{
+		PK:        "USER#u1",
+		SK:        "WORKOUT#w1",
+		Exercises: []models.WorkoutExercise{{ExerciseID: "Squat", Sets: []models.Set{{ID: "s1", Reps: 5}}}},
+	})
+	assert.NoError(t, err)
+
+	var written []string
+	marked := false
+	awsx.Db = &mockDynamo{
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			assert.Equal(t, &types.AttributeValueMemberS{Value: models.HistoryBuiltKey}, p.Key["SK"])
+			if marked {
+				return historyBuilt(ctx, p)
+			}
+			return &dynamodb.GetItemOutput{}, nil
+		},
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{legacy}}, nil
+		},
+		BatchWriteItemFn: func(ctx context.Context, p *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			for _, r := range p.RequestItems["test-table"] {
+				written = append(written, r.PutRequest.Item["SK"].(*types.AttributeValueMemberS).Value)
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+		PutItemFn: func(ctx context.Context, p *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+			assert.Equal(t, &types.AttributeValueMemberS{Value: models.HistoryBuiltKey}, p.Item["SK"])
+			marked = true
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+
+	assert.NoError(t, EnsureHistory(context.Background(), "u1"))
+	assert.Equal(t, []string{"HIST#Squat#w1"}, written)
+	assert.True(t, marked)
+
+	// once marked, it is not built again
+	assert.NoError(t, EnsureHistory(context.Background(), "u1"))
+	assert.Len(t, written, 1)
+}
+
+func TestGetExerciseHistory_HasTheRebuildStartedOnceWhileHistoryIsMissing(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	var marker map[string]types.AttributeValue
+	awsx.Db = &mockDynamo{
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: marker}, nil
+		},
+		PutItemFn: func(ctx context.Context, p *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+			assert.Equal(t, "attribute_not_exists(PK)", *p.ConditionExpression)
+			assert.NotContains(t, p.Item, "built_at")
+			marker = p.Item
+			return &dynamodb.PutItemOutput{}, nil
+		},
+		QueryFn: func(ctx context.Context, p *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			t.Fatal("history is not read before it is complete")
+			return nil, nil
+		},
+	}
+
+	var pending *models.HistoryPendingError
+	_, _, err := GetExerciseHistory(context.Background(), "u1", "Squat", 10, "")
+	assert.ErrorAs(t, err, &pending)
+	assert.True(t, pending.Start, "the first read starts the rebuild")
+	assert.Contains(t, marker, "rebuild_started_at")
+
+	_, _, err = GetExerciseHistory(context.Background(), "u1", "Squat", 10, "")
+	assert.ErrorAs(t, err, &pending)
+	assert.False(t, pending.Start, "the reads after it wait for it")
+}
+
+func TestGetExerciseHistory_TakesALostRebuildOver(t *testing.T) {
+	teardown := setupTest(t)
+	defer teardown()
+
+	stale, err := attributevalue.MarshalMap(models.NewHistoryRebuild("u1", time.Now().Add(-time.Hour)))
+	assert.NoError(t, err)
+
+	taken := false
+	awsx.Db = &mockDynamo{
+		GetItemFn: func(ctx context.Context, p *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: stale}, nil
+		},
+		PutItemFn: func(ctx context.Context, p *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+			assert.Equal(t, "rebuild_started_at = :seen", *p.ConditionExpression)
+			assert.Equal(t, stale["rebuild_started_at"], p.ExpressionAttributeValues[":seen"])
+			if taken {
+				return nil, &types.ConditionalCheckFailedException{}
+			}
+			taken = true
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+
+	var pending *models.HistoryPendingError
+	_, _, err = GetExerciseHistory(context.Background(), "u1", "Squat", 10, "")
+	assert.ErrorAs(t, err, &pending)
+	assert.True(t, pending.Start)
+
+	// another read got there first
+	_, _, err = GetExerciseHistory(context.Background(), "u1", "Squat", 10, "")
+	assert.ErrorAs(t, err, &pending)
+	assert.False(t, pending.Start)
+}
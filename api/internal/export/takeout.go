@@ -0,0 +1,237 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"heart/internal/models"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Takeout is everything we hold about a user, gathered for a personal data export.
+// Account and Export are the records behind the profile and the export itself, as stored.
+type Takeout struct {
+	Profile   *models.User
+	Account   *models.UserInternal
+	Export    *models.DataExport
+	Workouts  []models.WorkoutOut
+	Templates []models.TemplateOut
+	Programs  []models.ProgramOut
+	Exercises []models.ExerciseOut
+	Progress  []models.ImageOut
+}
+
+// WriteArchive writes the takeout to w as a zip with a JSON file per kind of record,
+// plus CSV copies of the tabular ones for spreadsheet users.
+func WriteArchive(w io.Writer, t *Takeout) error {
+	archive := zip.NewWriter(w)
+
+	jsons := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", t.Profile},
+		{"account.json", t.Account},
+		{"export.json", t.Export},
+		{"workouts.json", t.Workouts},
+		{"templates.json", t.Templates},
+		{"programs.json", t.Programs},
+		{"exercises.json", t.Exercises},
+		{"progress.json", t.Progress},
+	}
+
+	for _, f := range jsons {
+		if err := writeJSON(archive, f.name, f.data); err != nil {
+			return err
+		}
+	}
+
+	csvs := []struct {
+		name string
+		rows [][]string
+	}{
+		{"workouts.csv", workoutRows(t.Workouts)},
+		{"templates.csv", templateRows(t.Templates)},
+		{"exercises.csv", exerciseRows(t.Exercises)},
+		{"progress.csv", progressRows(t.Progress)},
+	}
+
+	for _, f := range csvs {
+		if err := writeCSV(archive, f.name, f.rows); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	return nil
+}
+
+func writeJSON(archive *zip.Writer, name string, data any) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func writeCSV(archive *zip.Writer, name string, rows [][]string) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+
+	writer := csv.NewWriter(f)
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// workoutHeader heads the CSV of workouts, which has a row per set.
+// Columns added later go last, so that the earlier ones keep their place.
+var workoutHeader = []string{
+	"workout_id", "workout_name", "start", "end", "exercise", "set_id", "completed", "weight_kg", "reps", "duration_s", "distance_km",
+	"set_type", "rpe", "rir", "tempo", "rest_s", "note", "group", "group_type",
+}
+
+// workoutRows flattens workouts into one row per set, header first.
+func workoutRows(workouts []models.WorkoutOut) [][]string {
+	rows := [][]string{workoutHeader}
+	for i := range workouts {
+		rows = append(rows, setRows(&workouts[i])...)
+	}
+	return rows
+}
+
+func setRows(w *models.WorkoutOut) [][]string {
+	end := ""
+	if w.End != nil {
+		end = formatTime(*w.End)
+	}
+
+	var rows [][]string
+	for _, e := range w.Exercises {
+		exercise := ""
+		if e.Exercise != nil {
+			exercise = *e.Exercise
+		}
+
+		for _, s := range e.Sets {
+			row := []string{
+				w.ID,
+				w.Name,
+				formatTime(w.Start),
+				end,
+				exercise,
+				s.ID,
+				strconv.FormatBool(s.Completed),
+				formatFloat(s.Weight),
+				strconv.Itoa(s.Reps),
+				formatFloat(s.Duration),
+				formatFloat(s.Distance),
+			}
+			set := models.Set{Type: s.Type, RPE: s.RPE, RIR: s.RIR, Tempo: s.Tempo, Rest: s.Rest, Note: s.Note}
+			row = append(row, detailColumns(&set)...)
+			rows = append(rows, append(row, e.Group, string(e.GroupType)))
+		}
+	}
+
+	return rows
+}
+
+func templateRows(templates []models.TemplateOut) [][]string {
+	rows := [][]string{
+		{
+			"template_id", "template_name", "exercise", "set_id", "weight_kg", "reps", "duration_s", "distance_km",
+			"set_type", "rpe", "rir", "tempo", "rest_s", "note", "group", "group_type",
+		},
+	}
+
+	for _, t := range templates {
+		for _, e := range t.Exercises {
+			for _, s := range e.Sets {
+				row := []string{
+					t.ID,
+					t.Name,
+					e.ExerciseID,
+					s.ID,
+					formatFloat(s.Weight),
+					strconv.Itoa(s.Reps),
+					formatFloat(s.Duration),
+					formatFloat(s.Distance),
+				}
+				row = append(row, detailColumns(&s)...)
+				rows = append(rows, append(row, e.Group, string(e.GroupType)))
+			}
+		}
+	}
+
+	return rows
+}
+
+// detailColumns are the columns of what a set can note besides what was done:
+// its type, effort, tempo, rest and note. Those not noted are left empty.
+func detailColumns(s *models.Set) []string {
+	rpe, rir, rest := "", "", ""
+	if s.RPE != 0 {
+		rpe = formatFloat(s.RPE)
+	}
+	if s.RIR != nil {
+		rir = strconv.Itoa(*s.RIR)
+	}
+	if s.Rest != 0 {
+		rest = strconv.Itoa(s.Rest)
+	}
+	return []string{string(s.Type), rpe, rir, s.Tempo, rest, s.Note}
+}
+
+func exerciseRows(exercises []models.ExerciseOut) [][]string {
+	rows := [][]string{
+		{"name", "category", "target", "instructions", "archived"},
+	}
+
+	for _, e := range exercises {
+		instructions := ""
+		if e.Instructions != nil {
+			instructions = *e.Instructions
+		}
+		archived := e.Archived != nil && *e.Archived
+		rows = append(rows, []string{e.Name, e.Category, e.Target, instructions, strconv.FormatBool(archived)})
+	}
+
+	return rows
+}
+
+func progressRows(images []models.ImageOut) [][]string {
+	rows := [][]string{
+		{"workout_id", "image_id", "url"},
+	}
+
+	for _, i := range images {
+		rows = append(rows, []string{i.WorkoutId, i.ID, i.URL})
+	}
+
+	return rows
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
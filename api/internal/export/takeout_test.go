@@ -0,0 +1,157 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"heart/internal/models"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleTakeout() *Takeout {
+	username := "jane_doe"
+	exercise := "Push Up"
+	start := time.Date(2025, 7, 25, 18, 20, 1, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	rir := 2
+	user := models.User{}
+	user.FirebaseUID = "u1"
+	user.Username = &username
+
+	account := models.NewUserInternal(&user)
+	requested := models.NewDataExport("u1", start)
+
+	return &Takeout{
+		Profile: &user,
+		Account: &account,
+		Export:  &requested,
+		Workouts: []models.WorkoutOut{
+			{
+				ID:    "w1",
+				Name:  "Chest",
+				Start: start,
+				End:   &end,
+				Exercises: []models.WorkoutExerciseOut{
+					{
+						ID:        "e1",
+						Exercise:  &exercise,
+						Group:     "a",
+						GroupType: models.Superset,
+						Sets: []models.SetOut{
+							{ID: "s1", Completed: true, Weight: 20.5, Reps: 10, Type: models.WorkingSet, RPE: 8.5, RIR: &rir, Tempo: "3-1-1-0", Rest: 90, Note: "Felt easy"},
+							{ID: "s2", Completed: false, Reps: 8},
+						},
+					},
+				},
+			},
+		},
+		Templates: []models.TemplateOut{
+			{
+				ID:   "t1",
+				Name: "Push",
+				Exercises: []models.TemplateExercise{
+					{ID: "e1", ExerciseID: exercise, Group: "a", GroupType: models.Superset, Sets: []models.Set{{ID: "s1", Reps: 12, Type: models.AMRAPSet, Rest: 60}}},
+				},
+			},
+		},
+		Exercises: []models.ExerciseOut{{Name: "Cable Fly", Category: "Machine", Target: "Chest"}},
+		Progress:  []models.ImageOut{{WorkoutId: "w1", ID: "img", URL: "https://media.example.test/workouts/abc/img.png"}},
+	}
+}
+
+func readArchive(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string][]byte)
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		files[f.Name] = content
+	}
+	return files
+}
+
+func TestWriteArchive_ContainsEveryFile(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteArchive(&buf, sampleTakeout()))
+
+	files := readArchive(t, buf.Bytes())
+	for _, name := range []string{
+		"profile.json", "account.json", "export.json", "workouts.json", "templates.json", "programs.json", "exercises.json", "progress.json",
+		"workouts.csv", "templates.csv", "exercises.csv", "progress.csv",
+	} {
+		assert.Contains(t, files, name)
+	}
+
+	var profile map[string]any
+	require.NoError(t, json.Unmarshal(files["profile.json"], &profile))
+	assert.Equal(t, "u1", profile["id"])
+
+	var account map[string]any
+	require.NoError(t, json.Unmarshal(files["account.json"], &account))
+	assert.Equal(t, "USER#u1", account["pk"])
+	assert.Equal(t, "u1", account["firebase_uid"])
+
+	var requested map[string]any
+	require.NoError(t, json.Unmarshal(files["export.json"], &requested))
+	assert.Equal(t, models.ExportPending, requested["status"])
+
+	var workouts []models.WorkoutOut
+	require.NoError(t, json.Unmarshal(files["workouts.json"], &workouts))
+	require.Len(t, workouts, 1)
+	assert.Equal(t, "Chest", workouts[0].Name)
+}
+
+func TestWriteArchive_WorkoutsCSVHasRowPerSet(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteArchive(&buf, sampleTakeout()))
+
+	files := readArchive(t, buf.Bytes())
+	rows, err := csv.NewReader(bytes.NewReader(files["workouts.csv"])).ReadAll()
+	require.NoError(t, err)
+
+	require.Len(t, rows, 3) // header + 2 sets
+	assert.Equal(t, "workout_id", rows[0][0])
+	assert.Equal(t, []string{
+		"w1", "Chest", "2025-07-25T18:20:01Z", "2025-07-25T19:20:01Z", "Push Up", "s1", "true", "20.5", "10", "0", "0",
+		"working", "8.5", "2", "3-1-1-0", "90", "Felt easy", "a", "superset",
+	}, rows[1])
+	assert.Equal(t, "false", rows[2][6])
+	assert.Equal(t, "", rows[2][12]) // no RPE noted
+}
+
+func TestWriteArchive_TemplatesCSVHasSetDetailsAndGroups(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteArchive(&buf, sampleTakeout()))
+
+	files := readArchive(t, buf.Bytes())
+	rows, err := csv.NewReader(bytes.NewReader(files["templates.csv"])).ReadAll()
+	require.NoError(t, err)
+
+	require.Len(t, rows, 2)
+	assert.Equal(t, []string{"t1", "Push", "Push Up", "s1", "0", "12", "0", "0", "amrap", "", "", "", "60", "", "a", "superset"}, rows[1])
+}
+
+func TestWriteArchive_EmptyTakeout(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteArchive(&buf, &Takeout{}))
+
+	files := readArchive(t, buf.Bytes())
+	assert.Equal(t, "null\n", string(files["workouts.json"]))
+
+	rows, err := csv.NewReader(bytes.NewReader(files["exercises.csv"])).ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, rows, 1) // header only
+}
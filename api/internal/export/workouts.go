@@ -0,0 +1,250 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"heart/internal/models"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// WorkoutWriter streams workouts to a file, one at a time, so that long histories
+// never have to be held in memory.
+type WorkoutWriter interface {
+	Write(w *models.WorkoutOut) error
+	// Close finishes the file; it does not close the underlying writer.
+	Close() error
+}
+
+type WorkoutFormat struct {
+	ContentType string
+	Extension   string
+	New         func(io.Writer) WorkoutWriter
+}
+
+// WorkoutFormats are the formats workouts can be exported in, by name.
+var WorkoutFormats = map[string]WorkoutFormat{
+	"csv":   {ContentType: "text/csv", Extension: "csv", New: NewCSVWriter},
+	"jsonl": {ContentType: "application/jsonl", Extension: "jsonl", New: NewJSONLWriter},
+	"tcx":   {ContentType: "application/vnd.garmin.tcx+xml", Extension: "tcx", New: NewTCXWriter},
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	header bool
+}
+
+// NewCSVWriter writes a row per set, the same as the workouts.csv of a personal data export.
+func NewCSVWriter(w io.Writer) WorkoutWriter {
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Write(w *models.WorkoutOut) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	if err := c.writer.WriteAll(setRows(w)); err != nil {
+		return fmt.Errorf("failed to write workout %s: %w", w.ID, err)
+	}
+
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// writeHeader writes the header once, even if there are no workouts to follow it.
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+
+	if err := c.writer.Write(workoutHeader); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	return nil
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+// NewJSONLWriter writes a workout, as the API returns it, per line.
+func NewJSONLWriter(w io.Writer) WorkoutWriter {
+	return &jsonlWriter{encoder: json.NewEncoder(w)}
+}
+
+func (j *jsonlWriter) Write(w *models.WorkoutOut) error {
+	if err := j.encoder.Encode(w); err != nil {
+		return fmt.Errorf("failed to write workout %s: %w", w.ID, err)
+	}
+
+	return nil
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
+
+// tcxActivity is the part of Garmin's Training Center schema we fill in:
+// an activity per workout with cardio in it, and a lap per cardio set.
+type tcxActivity struct {
+	XMLName xml.Name `xml:"Activity"`
+	Sport   string   `xml:"Sport,attr"`
+	ID      string   `xml:"Id"`
+	Laps    []tcxLap `xml:"Lap"`
+	Notes   string   `xml:"Notes,omitempty"`
+}
+
+type tcxLap struct {
+	StartTime        string  `xml:"StartTime,attr"`
+	TotalTimeSeconds float64 `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64 `xml:"DistanceMeters"`
+	Calories         int     `xml:"Calories"`
+	Intensity        string  `xml:"Intensity"`
+	TriggerMethod    string  `xml:"TriggerMethod"`
+	Notes            string  `xml:"Notes,omitempty"`
+}
+
+const (
+	tcxHeader = xml.Header + `<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">` + "\n<Activities>\n"
+	tcxFooter = "</Activities>\n</TrainingCenterDatabase>\n"
+)
+
+type tcxWriter struct {
+	writer  io.Writer
+	encoder *xml.Encoder
+	header  bool
+}
+
+// NewTCXWriter writes the completed cardio sets, the ones with a duration or a distance,
+// as Training Center activities that Garmin Connect, Strava and the like can read.
+// Workouts without cardio are left out.
+func NewTCXWriter(w io.Writer) WorkoutWriter {
+	return &tcxWriter{writer: w, encoder: xml.NewEncoder(w)}
+}
+
+func (t *tcxWriter) Write(w *models.WorkoutOut) error {
+	if err := t.writeHeader(); err != nil {
+		return err
+	}
+
+	activity, ok := newTCXActivity(w)
+	if !ok {
+		return nil
+	}
+
+	if err := t.encoder.Encode(activity); err != nil {
+		return fmt.Errorf("failed to write workout %s: %w", w.ID, err)
+	}
+	if _, err := io.WriteString(t.writer, "\n"); err != nil {
+		return fmt.Errorf("failed to write workout %s: %w", w.ID, err)
+	}
+
+	return nil
+}
+
+func (t *tcxWriter) Close() error {
+	if err := t.writeHeader(); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(t.writer, tcxFooter); err != nil {
+		return fmt.Errorf("failed to write footer: %w", err)
+	}
+
+	return nil
+}
+
+func (t *tcxWriter) writeHeader() error {
+	if t.header {
+		return nil
+	}
+	t.header = true
+
+	if _, err := io.WriteString(t.writer, tcxHeader); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	return nil
+}
+
+// newTCXActivity lays the cardio sets of a workout end to end from its start.
+func newTCXActivity(w *models.WorkoutOut) (tcxActivity, bool) {
+	activity := tcxActivity{ID: formatTime(w.Start), Notes: w.Name}
+
+	sports := map[string]bool{}
+	at := w.Start
+	for _, e := range w.Exercises {
+		exercise := ""
+		if e.Exercise != nil {
+			exercise = *e.Exercise
+		}
+
+		for _, s := range e.Sets {
+			if !s.Completed || (s.Duration <= 0 && s.Distance <= 0) {
+				continue
+			}
+
+			activity.Laps = append(activity.Laps, tcxLap{
+				StartTime:        formatTime(at),
+				TotalTimeSeconds: s.Duration,
+				DistanceMeters:   s.Distance * 1000,
+				Intensity:        "Active",
+				TriggerMethod:    "Manual",
+				Notes:            exercise,
+			})
+			sports[tcxSport(exercise)] = true
+			at = at.Add(time.Duration(s.Duration * float64(time.Second)))
+		}
+	}
+
+	// one sport per activity in TCX, so mixed sessions are "Other"
+	activity.Sport = "Other"
+	if len(sports) == 1 {
+		for sport := range sports {
+			activity.Sport = sport
+		}
+	}
+
+	return activity, len(activity.Laps) > 0
+}
+
+// tcxSports maps the words of an exercise name to the TCX sport they give away.
+// Names are matched word by word, so a "Crunch" is no run.
+var tcxSports = map[string]string{
+	"run":     "Running",
+	"runs":    "Running",
+	"running": "Running",
+	"jog":     "Running",
+	"jogging": "Running",
+	"bike":    "Biking",
+	"biking":  "Biking",
+	"cycle":   "Biking",
+	"cycling": "Biking",
+}
+
+func tcxSport(exercise string) string {
+	words := strings.FieldsFunc(strings.ToLower(exercise), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		if sport, ok := tcxSports[word]; ok {
+			return sport
+		}
+	}
+	return "Other"
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"heart/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeWorkouts(t *testing.T, format string, workouts []models.WorkoutOut) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := WorkoutFormats[format].New(&buf)
+	for i := range workouts {
+		require.NoError(t, writer.Write(&workouts[i]))
+	}
+	require.NoError(t, writer.Close())
+	return buf.String()
+}
+
+func cardioWorkout() models.WorkoutOut {
+	run, squat := "Treadmill Run", "Squat"
+	return models.WorkoutOut{
+		ID:    "w2",
+		Name:  "Mixed",
+		Start: time.Date(2025, 8, 1, 7, 0, 0, 0, time.UTC),
+		Exercises: []models.WorkoutExerciseOut{
+			{ID: "e1", Exercise: &squat, Sets: []models.SetOut{{ID: "s1", Completed: true, Weight: 100, Reps: 5}}},
+			{ID: "e2", Exercise: &run, Sets: []models.SetOut{
+				{ID: "s2", Completed: true, Duration: 600, Distance: 2},
+				{ID: "s3", Completed: false, Duration: 600, Distance: 2},
+				{ID: "s4", Completed: true, Duration: 300, Distance: 1.2},
+			}},
+		},
+	}
+}
+
+func TestCSVWriter_RowPerSetAcrossWorkouts(t *testing.T) {
+	out := writeWorkouts(t, "csv", append(sampleTakeout().Workouts, cardioWorkout()))
+
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	require.NoError(t, err)
+
+	require.Len(t, rows, 7) // header + 2 + 4 sets
+	assert.Equal(t, workoutHeader, rows[0])
+	assert.Equal(t, "w2", rows[3][0])
+	assert.Equal(t, "600", rows[4][9])
+	assert.Equal(t, []string{"working", "8.5", "2", "3-1-1-0", "90", "Felt easy", "a", "superset"}, rows[1][11:])
+}
+
+func TestCSVWriter_HeaderOnlyWithoutWorkouts(t *testing.T) {
+	assert.Equal(t, strings.Join(workoutHeader, ",")+"\n", writeWorkouts(t, "csv", nil))
+}
+
+func TestJSONLWriter_WorkoutPerLine(t *testing.T) {
+	out := writeWorkouts(t, "jsonl", append(sampleTakeout().Workouts, cardioWorkout()))
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+
+	var w models.WorkoutOut
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &w))
+	assert.Equal(t, "Mixed", w.Name)
+	assert.Len(t, w.Exercises, 2)
+}
+
+func TestTCXWriter_LapPerCompletedCardioSet(t *testing.T) {
+	out := writeWorkouts(t, "tcx", append(sampleTakeout().Workouts, cardioWorkout()))
+
+	var doc struct {
+		Activities []tcxActivity `xml:"Activities>Activity"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(out), &doc))
+
+	require.Len(t, doc.Activities, 1) // the strength workout has no cardio
+	activity := doc.Activities[0]
+	assert.Equal(t, "Running", activity.Sport)
+	assert.Equal(t, "2025-08-01T07:00:00Z", activity.ID)
+	require.Len(t, activity.Laps, 2)
+	assert.Equal(t, 2000.0, activity.Laps[0].DistanceMeters)
+	assert.Equal(t, "2025-08-01T07:10:00Z", activity.Laps[1].StartTime)
+	assert.Equal(t, "Treadmill Run", activity.Laps[1].Notes)
+}
+
+func TestTCXSport_MatchesWholeWords(t *testing.T) {
+	assert.Equal(t, "Running", tcxSport("Treadmill Run"))
+	assert.Equal(t, "Running", tcxSport("Jogging"))
+	assert.Equal(t, "Biking", tcxSport("Stationary Bike"))
+	assert.Equal(t, "Other", tcxSport("Crunch"))
+	assert.Equal(t, "Other", tcxSport("Bicycle Crunch"))
+	assert.Equal(t, "Other", tcxSport("Prune Juice"))
+}
+
+func TestTCXWriter_EmptyDocument(t *testing.T) {
+	out := writeWorkouts(t, "tcx", nil)
+
+	assert.True(t, strings.HasPrefix(out, "<?xml"))
+	assert.True(t, strings.HasSuffix(out, "</TrainingCenterDatabase>\n"))
+}
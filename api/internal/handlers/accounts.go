@@ -3,14 +3,16 @@ package handlers
 import (
 	"errors"
 	"fmt"
-	"heart/internal/awsx"
 	"heart/internal/config"
-	"heart/internal/dbx"
 	"heart/internal/models"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// how long a download link for a data export stays valid
+const exportLinkExpiry = time.Hour
+
 // GetAccount godoc
 //
 //	@Summary		Get user account
@@ -30,7 +32,7 @@ import (
 func GetAccount(c *gin.Context, userId string) (any, error) {
 	accountId := c.Param("accountId")
 
-	user, err := dbx.GetAccount(c.Request.Context(), accountId)
+	user, err := accountStore(c).GetAccount(c.Request.Context(), accountId)
 
 	if err != nil {
 		return nil, models.NewServerError(err)
@@ -72,7 +74,7 @@ func RegisterAccount(c *gin.Context, userId string) (any, error) {
 		return nil, models.NewValidationError(fmt.Errorf("user id mismatch"))
 	}
 
-	account, err := dbx.SaveAccount(c.Request.Context(), userId, user)
+	account, err := accountStore(c).SaveAccount(c.Request.Context(), userId, user)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +109,7 @@ func EditAccount(c *gin.Context, userId string) (any, error) {
 
 	switch request.Action {
 	case "undoAccountDeletion":
-		user, err := dbx.GetAccount(c.Request.Context(), userId)
+		user, err := accountStore(c).GetAccount(c.Request.Context(), userId)
 
 		if err != nil {
 			return nil, models.NewServerError(err)
@@ -118,14 +120,14 @@ func EditAccount(c *gin.Context, userId string) (any, error) {
 		}
 
 		if user.AccountDeletionSchedule != nil {
-			err := awsx.DeleteAccountDeletionSchedule(c.Request.Context(), user.AccountDeletionSchedule)
+			err := jobs(c).DeleteAccountDeletionSchedule(c.Request.Context(), user.AccountDeletionSchedule)
 
 			if err != nil {
 				return nil, models.NewServerError(err)
 			}
 		}
 
-		err = dbx.UndoAccountDeletion(c.Request.Context(), userId)
+		err = accountStore(c).UndoAccountDeletion(c.Request.Context(), userId)
 
 		if err != nil {
 			return nil, models.NewServerError(err)
@@ -134,7 +136,7 @@ func EditAccount(c *gin.Context, userId string) (any, error) {
 		return models.NoContent, nil
 
 	case "removeAvatar":
-		_, err := awsx.DeleteObject(
+		_, err := objects(c).DeleteObject(
 			c.Request.Context(),
 			config.App.MediaBucket,
 			config.App.AvatarKey(userId),
@@ -144,7 +146,7 @@ func EditAccount(c *gin.Context, userId string) (any, error) {
 			return nil, models.NewServerError(err)
 		}
 
-		err = dbx.RemoveAvatar(c.Request.Context(), userId)
+		err = accountStore(c).RemoveAvatar(c.Request.Context(), userId)
 
 		if err != nil {
 			return nil, models.NewServerError(err)
@@ -162,7 +164,7 @@ func EditAccount(c *gin.Context, userId string) (any, error) {
 
 		tag := config.App.UploadDestinationTag()
 
-		response, err := awsx.GeneratePresignedPostURL(
+		response, err := objects(c).GeneratePresignedPostURL(
 			c.Request.Context(),
 			config.App.UploadBucket,
 			config.App.AvatarKey(userId),
@@ -199,14 +201,14 @@ func EditAccount(c *gin.Context, userId string) (any, error) {
 //	@Router			/accounts [delete]
 //	@Security		BearerAuth
 func DeleteAccount(c *gin.Context, userId string) (any, error) {
-	when, schedule, err := awsx.CreateAccountDeletionSchedule(c.Request.Context(), userId)
+	when, schedule, err := jobs(c).CreateAccountDeletionSchedule(c.Request.Context(), userId)
 
 	if err != nil {
 		return nil, models.NewServerError(err)
 	}
 
 	if when != nil && schedule != nil {
-		err := dbx.ScheduleAccountForDeletion(c.Request.Context(), userId, *schedule, when.Unix())
+		err := accountStore(c).ScheduleAccountForDeletion(c.Request.Context(), userId, *schedule, when.Unix())
 		if err != nil {
 			return nil, err
 		}
@@ -214,3 +216,79 @@ func DeleteAccount(c *gin.Context, userId string) (any, error) {
 
 	return models.NoContent, nil
 }
+
+// ExportAccountData godoc
+//
+//	@Summary		Request a personal data export
+//	@Description	Starts building an archive of everything the user has stored: profile, workouts, templates, own exercises and progress images
+//	@Tags			accounts
+//	@Accept			json
+//	@Produce		json
+//	@ID				exportAccountData
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Success		200				{object}	DataExport
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/accounts/export [post]
+//	@Security		BearerAuth
+func ExportAccountData(c *gin.Context, userId string) (any, error) {
+	export := models.NewDataExport(userId, time.Now().UTC())
+
+	// keep the link to the previous archive so the background job can clean it up
+	previous, err := accountStore(c).GetDataExport(c.Request.Context(), userId)
+	var notFound *models.NotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return nil, err
+	}
+	if previous != nil {
+		export.ObjectKey = previous.ObjectKey
+	}
+
+	saved, err := accountStore(c).SaveDataExport(c.Request.Context(), export)
+	if err != nil {
+		return nil, err
+	}
+
+	err = jobs(c).InvokeBackground(c.Request.Context(), "DataExport", map[string]string{"user_id": userId})
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	return models.NewDataExportOut(saved), nil
+}
+
+// GetAccountDataExport godoc
+//
+//	@Summary		Get personal data export
+//	@Description	Returns the status of the latest data export and, once it is ready, a time-limited download link
+//	@Tags			accounts
+//	@Accept			json
+//	@Produce		json
+//	@ID				getAccountDataExport
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Success		200				{object}	DataExport
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/accounts/export [get]
+//	@Security		BearerAuth
+func GetAccountDataExport(c *gin.Context, userId string) (any, error) {
+	export, err := accountStore(c).GetDataExport(c.Request.Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	out := models.NewDataExportOut(export)
+
+	if export.Status == models.ExportReady && export.ObjectKey != nil {
+		url, err := objects(c).GeneratePresignedGetURL(c.Request.Context(), config.App.MediaBucket, *export.ObjectKey, exportLinkExpiry)
+		if err != nil {
+			return nil, models.NewServerError(err)
+		}
+		expiresAt := time.Now().UTC().Add(exportLinkExpiry)
+		out.URL = &url
+		out.ExpiresAt = &expiresAt
+	}
+
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"heart/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportAccountData_SavesPendingAndInvokesBackground(t *testing.T) {
+	db := newFakeStores()
+	previousKey := "exports/u1/old.zip"
+	db.getDataExport = func(ctx context.Context, userId string) (*models.DataExport, error) {
+		return &models.DataExport{Status: models.ExportReady, ObjectKey: &previousKey}, nil
+	}
+	var saved models.DataExport
+	db.saveDataExport = func(ctx context.Context, in models.DataExport) (*models.DataExport, error) {
+		saved = in
+		return &in, nil
+	}
+	var gotEvent string
+	var gotPayload any
+	db.invokeBackground = func(ctx context.Context, event string, payload any) error {
+		gotEvent, gotPayload = event, payload
+		return nil
+	}
+
+	res, err := ExportAccountData(db.attach(newCtx()), "u1")
+	require.NoError(t, err)
+
+	out, ok := res.(models.DataExportOut)
+	require.True(t, ok)
+	assert.Equal(t, models.ExportPending, out.Status)
+	assert.Nil(t, out.URL)
+
+	assert.Equal(t, models.UserKey+"u1", saved.PK)
+	assert.Equal(t, models.DataExportKey, saved.SK)
+	require.NotNil(t, saved.ObjectKey)
+	assert.Equal(t, previousKey, *saved.ObjectKey)
+
+	assert.Equal(t, "DataExport", gotEvent)
+	assert.Equal(t, map[string]string{"user_id": "u1"}, gotPayload)
+}
+
+func TestExportAccountData_FirstExport(t *testing.T) {
+	db := newFakeStores()
+	db.getDataExport = func(ctx context.Context, userId string) (*models.DataExport, error) {
+		return nil, models.NewNotFoundError("Export not found", nil)
+	}
+	db.saveDataExport = func(ctx context.Context, in models.DataExport) (*models.DataExport, error) {
+		assert.Nil(t, in.ObjectKey)
+		return &in, nil
+	}
+	db.invokeBackground = func(ctx context.Context, event string, payload any) error { return nil }
+
+	_, err := ExportAccountData(db.attach(newCtx()), "u1")
+	assert.NoError(t, err)
+}
+
+func TestGetAccountDataExport_ReadyHasLink(t *testing.T) {
+	db := newFakeStores()
+	key := "exports/u1/new.zip"
+	completed := time.Now().UTC()
+	db.getDataExport = func(ctx context.Context, userId string) (*models.DataExport, error) {
+		return &models.DataExport{Status: models.ExportReady, ObjectKey: &key, CompletedAt: &completed}, nil
+	}
+	var gotKey string
+	var gotExpiry time.Duration
+	db.presignGetURL = func(ctx context.Context, bucket, key string, expires time.Duration) (string, error) {
+		gotKey, gotExpiry = key, expires
+		return "https://signed.example.test/" + key, nil
+	}
+
+	res, err := GetAccountDataExport(db.attach(newCtx()), "u1")
+	require.NoError(t, err)
+
+	out := res.(models.DataExportOut)
+	require.NotNil(t, out.URL)
+	assert.Equal(t, "https://signed.example.test/exports/u1/new.zip", *out.URL)
+	assert.NotNil(t, out.ExpiresAt)
+	assert.Equal(t, key, gotKey)
+	assert.Equal(t, exportLinkExpiry, gotExpiry)
+}
+
+func TestGetAccountDataExport_PendingHasNoLink(t *testing.T) {
+	db := newFakeStores()
+	db.getDataExport = func(ctx context.Context, userId string) (*models.DataExport, error) {
+		return &models.DataExport{Status: models.ExportPending}, nil
+	}
+	db.presignGetURL = func(ctx context.Context, bucket, key string, expires time.Duration) (string, error) {
+		t.Fatal("pending export should not be signed")
+		return "", nil
+	}
+
+	res, err := GetAccountDataExport(db.attach(newCtx()), "u1")
+	require.NoError(t, err)
+	assert.Nil(t, res.(models.DataExportOut).URL)
+}
+
+func TestGetAccountDataExport_NotFoundPassthrough(t *testing.T) {
+	db := newFakeStores()
+
+	db.getDataExport = func(ctx context.Context, userId string) (*models.DataExport, error) {
+		return nil, models.NewNotFoundError("Export not found", errors.New("nf"))
+	}
+
+	res, err := GetAccountDataExport(db.attach(newCtx()), "u1")
+	assert.Nil(t, res)
+	var nf *models.NotFoundError
+	assert.ErrorAs(t, err, &nf)
+}
+
+func TestDeleteAccount_SchedulesTheDeletionThroughJobs(t *testing.T) {
+	db := newFakeStores()
+	when, arn := time.Date(2025, 8, 24, 0, 0, 0, 0, time.UTC), "arn:aws:scheduler:ca-central-1:123:schedule/account-deletions/account-deletion-u1"
+	db.createSchedule = func(ctx context.Context, userId string) (*time.Time, *string, error) {
+		assert.Equal(t, "u1", userId)
+		return &when, &arn, nil
+	}
+	var scheduled string
+	var at int64
+	db.scheduleAccountForDeletion = func(ctx context.Context, userId string, scheduleArn string, w int64) error {
+		scheduled, at = scheduleArn, w
+		return nil
+	}
+
+	res, err := DeleteAccount(db.attach(newCtx()), "u1")
+	require.NoError(t, err)
+	assert.Equal(t, models.NoContent, res)
+	assert.Equal(t, arn, scheduled)
+	assert.Equal(t, when.Unix(), at)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"heart/internal/dbx"
+	"heart/internal/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CatalogTTL is how long the catalog is served from memory before it is read again.
+// It almost never changes, and a change reaching clients a few minutes late is harmless.
+const CatalogTTL = 15 * time.Minute
+
+const catalogKey = "catalog"
+
+// catalogCache keeps the global exercise catalog warm in memory, along with an ETag
+// that changes whenever its contents do. A zero ttl reads it afresh every time.
+type catalogCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	exercises []models.Exercise
+	etag      string
+	fetchedAt time.Time
+}
+
+// Catalog keeps the exercise catalog in memory for the handlers down the chain, for ttl at a time.
+func Catalog(ttl time.Duration) gin.HandlerFunc {
+	cache := &catalogCache{ttl: ttl}
+	return func(c *gin.Context) {
+		c.Set(catalogKey, cache)
+		c.Next()
+	}
+}
+
+// exerciseCatalog returns the catalog cache Catalog put on the context.
+func exerciseCatalog(c *gin.Context) *catalogCache {
+	return c.MustGet(catalogKey).(*catalogCache)
+}
+
+// get returns the catalog and its ETag, reading it anew from the store once the cached copy is older than ttl.
+// The slice is shared between requests: callers must not modify its elements.
+func (cc *catalogCache) get(ctx context.Context, store dbx.ExerciseStore) ([]models.Exercise, string, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.ttl > 0 && cc.exercises != nil && time.Since(cc.fetchedAt) < cc.ttl {
+		return cc.exercises, cc.etag, nil
+	}
+
+	exercises, err := store.GetExercises(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if exercises == nil {
+		exercises = []models.Exercise{}
+	}
+
+	etag, err := contentTag(exercises)
+	if err != nil {
+		return nil, "", models.NewServerError(err)
+	}
+
+	// clipped, so that appending to it copies rather than writes into the shared array
+	cc.exercises, cc.etag, cc.fetchedAt = slices.Clip(exercises), etag, time.Now()
+	return cc.exercises, cc.etag, nil
+}
+
+// contentTag names the JSON encoding of v by a short hash of it.
+func contentTag(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// noneMatch tells whether the If-None-Match header lacks the ETag, so the response must be sent in full.
+func noneMatch(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return false
+		}
+	}
+	return true
+}
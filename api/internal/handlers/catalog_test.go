@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"heart/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogCache_ServesFromMemory(t *testing.T) {
+	db := newFakeStores()
+	reads := 0
+	catalog := []models.Exercise{{Name: "Push Up"}}
+	db.getExercises = func(ctx context.Context) ([]models.Exercise, error) {
+		reads++
+		return catalog, nil
+	}
+
+	cache := &catalogCache{ttl: time.Hour}
+	first, etag, err := cache.get(context.Background(), db)
+	require.NoError(t, err)
+	second, again, err := cache.get(context.Background(), db)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, reads)
+	assert.Equal(t, first, second)
+	assert.Equal(t, etag, again)
+
+	// once stale, it is read again, and a change shows in the ETag
+	cache.fetchedAt = time.Now().Add(-2 * time.Hour)
+	catalog = append(catalog, models.Exercise{Name: "Pull Up"})
+	third, changed, err := cache.get(context.Background(), db)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, reads)
+	assert.Len(t, third, 2)
+	assert.NotEqual(t, etag, changed)
+}
+
+func TestNoneMatch(t *testing.T) {
+	assert.True(t, noneMatch("", `"abc"`))
+	assert.True(t, noneMatch(`"abd"`, `"abc"`))
+	assert.False(t, noneMatch(`"abc"`, `"abc"`))
+	assert.False(t, noneMatch(`W/"abc"`, `"abc"`))
+	assert.False(t, noneMatch(`"x", "abc"`, `"abc"`))
+	assert.False(t, noneMatch("*", `"abc"`))
+}
+
+func TestNegotiateLocale(t *testing.T) {
+	available := map[string]bool{"de": true, "pt-br": true}
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"de", "de"},
+		{"de-AT,de;q=0.9", "de"},
+		{"PT-BR", "pt-br"},
+		{"pt", "en"},
+		{"fr-FR,fr;q=0.9,de;q=0.5", "de"},
+		{"en-US,en;q=0.9,de;q=0.8", "en"},
+		{"de;q=0.2,pt-BR;q=0.8", "pt-br"},
+		{"de;q=0", "en"},
+		{"*", "en"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, negotiateLocale(tt.header, available), tt.header)
+	}
+}
@@ -1,61 +1,119 @@
 package handlers
 
 import (
-	"heart/internal/dbx"
+	"errors"
+	"fmt"
+	"heart/internal/config"
 	"heart/internal/models"
+	"heart/internal/validation"
+	"log"
+	"maps"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-)
-
-// test seams for dbx dependencies
-var (
-	dbGetExercises    = dbx.GetExercises
-	dbGetOwnExercises = dbx.GetOwnExercises
-	dbMakeExercise    = dbx.MakeExercise
-	dbEditExercise    = dbx.EditExercise
+	"github.com/google/uuid"
 )
 
 // GetExercises godoc
 //
 //	@Summary		List all exercises
-//	@Description	Returns all exercises in a single page
+//	@Description	Returns all exercises in a single page, each with the names the user has merged into it.
+//	@Description	The catalog alone carries an ETag; sending it back in If-None-Match gets 304 while it stands.
+//	@Description	Catalog exercises come localized in the language negotiated from Accept-Language, if there is a translation.
 //	@Tags			workouts
 //	@Accept			json
 //	@Produce		json
 //	@ID				getExercises
 //	@Param			X-App-Version	header		string	false	"Client app version (e.g., 2.8.0)"
+//	@Param			If-None-Match	header		string	false	"ETag of the catalog the client holds"
+//	@Param			Accept-Language	header		string	false	"Preferred languages (e.g., de-DE, de;q=0.9); English by default"
 //	@Param			owned			query		boolean	false	"Filter exercises created by the authenticated user"
+//	@Param			merged			query		boolean	false	"Catalog and own exercises together, told apart by own; ignored with owned"
+//	@Param			category		query		string	false	"Only exercises in this category, regardless of case"
+//	@Param			target			query		string	false	"Only exercises with this target, regardless of case"
+//	@Param			q				query		string	false	"Only exercises whose name, or a name merged into it, contains this, regardless of case"
 //	@Success		200				{object}	ExercisesResponse
+//	@Success		304				"Not Modified"
 //	@Failure		401				{object}	ErrorResponse	"Unauthorized"
 //	@Failure		500				{object}	ErrorResponse	"Server error"
 //	@Router			/exercises [get]
 //	@Security		BearerAuth
 func GetExercises(c *gin.Context, userId string) (any, error) {
+	ctx := c.Request.Context()
 	owned := c.Query("owned") == "true"
-	var exercises []models.Exercise
+	merged := !owned && c.Query("merged") == "true"
+
+	var catalog, own []models.Exercise
+	var etag string
 	var err error
-	if owned {
-		exercises, err = dbGetOwnExercises(c.Request.Context(), userId)
-	} else {
-		exercises, err = dbGetExercises(c.Request.Context())
+	if !owned {
+		catalog, etag, err = exerciseCatalog(c).get(ctx, exerciseStore(c))
+		if err != nil {
+			return nil, models.NewServerError(err)
+		}
+	}
+	if owned || merged {
+		own, err = exerciseStore(c).GetOwnExercises(ctx, userId)
+		if err != nil {
+			return nil, models.NewServerError(err)
+		}
 	}
 
+	aliases, err := exerciseStore(c).GetAliases(ctx, userId)
 	if err != nil {
-		return nil, models.NewServerError(err)
+		return nil, err
+	}
+
+	locale := negotiateLocale(c.GetHeader("Accept-Language"), models.ExerciseLocales(catalog))
+	c.Header("Content-Language", locale)
+	c.Header("Vary", "Accept-Language")
+
+	// the user's merges show in the catalog too, so they are part of what the client holds
+	if !owned && !merged {
+		if len(aliases) > 0 {
+			tag, err := contentTag(aliases)
+			if err != nil {
+				return nil, models.NewServerError(err)
+			}
+			etag += "-" + tag
+		}
+		if locale != defaultLocale {
+			etag += "-" + locale
+		}
+		etag = strconv.Quote(etag)
+
+		c.Header("ETag", etag)
+		if !noneMatch(c.GetHeader("If-None-Match"), etag) {
+			return models.NotModified, nil
+		}
+	}
+
+	byTarget := models.NewAliases(aliases)
+	filter := models.ExerciseFilter{
+		Category: c.Query("category"),
+		Target:   c.Query("target"),
+		Search:   strings.TrimSpace(c.Query("q")),
 	}
 
 	out := models.ExercisesResponse{
-		Exercises: make([]models.ExerciseOut, len(exercises)),
-	}
-	for i, e := range exercises {
-		ex := models.NewExerciseOut(&e)
-		if owned {
-			ex.Own = boolPtr(true)
-		} else {
-			ex.Own = boolPtr(false)
+		Exercises: make([]models.ExerciseOut, 0, len(catalog)+len(own)),
+	}
+	add := func(exercises []models.Exercise, isOwn bool) {
+		for _, e := range exercises {
+			ex := models.NewExerciseOut(&e)
+			ex.Own = boolPtr(isOwn)
+			ex.Aliases = byTarget.Of(e.Name)
+			if locale != defaultLocale {
+				ex.Localized = e.Localize(locale)
+			}
+			if filter.Matches(&ex) {
+				out.Exercises = append(out.Exercises, ex)
+			}
 		}
-		out.Exercises[i] = ex
 	}
+	add(catalog, false)
+	add(own, true)
 
 	return out, nil
 }
@@ -81,8 +139,11 @@ func MakeExercise(c *gin.Context, userId string) (any, error) {
 	if err := c.BindJSON(&exercise); err != nil {
 		return nil, models.NewValidationError(err)
 	}
+	if err := validation.UserExercise(&exercise); err != nil {
+		return nil, models.NewValidationError(err)
+	}
 
-	made, err := dbMakeExercise(c, exercise, userId)
+	made, err := exerciseStore(c).MakeExercise(c, exercise, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +180,7 @@ func EditExercise(c *gin.Context, userId string) (any, error) {
 		return nil, models.NewValidationError(err)
 	}
 
-	updated, err := dbEditExercise(c.Request.Context(), userId, exerciseName, in)
+	updated, err := exerciseStore(c).EditExercise(c.Request.Context(), userId, exerciseName, in)
 	if err != nil {
 		return nil, err
 	}
@@ -128,4 +189,343 @@ func EditExercise(c *gin.Context, userId string) (any, error) {
 	return out, nil
 }
 
+// RenameExercise godoc
+//
+//	@Summary		Rename an exercise
+//	@Description	Renames an exercise created by the authenticated user, along with every workout, template
+//	@Description	and program that refers to it, its history and its personal records. The new name must not
+//	@Description	be taken by another exercise, regardless of case.
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@ID				renameExercise
+//	@Param			X-App-Version	header		string				false	"Client app version (e.g., 2.8.0)"
+//	@Param			exerciseName	path		string				true	"Name of the exercise to rename"
+//	@Param			input			body		RenameExerciseIn	true	"New name"
+//	@Success		200				{object}	Exercise
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/exercises/{exerciseName}/rename [post]
+//	@Security		BearerAuth
+func RenameExercise(c *gin.Context, userId string) (any, error) {
+	from := c.Param("exerciseName")
+	var in models.RenameExerciseIn
+	if err := c.BindJSON(&in); err != nil {
+		return nil, models.NewValidationError(err)
+	}
+	to := strings.TrimSpace(in.Name)
+	if to == "" {
+		return nil, models.NewValidationError(fmt.Errorf("exercise name cannot be blank"))
+	}
+
+	names, err := knownExercises(c, userId)
+	if err != nil {
+		return nil, err
+	}
+	if name, ok := names[strings.ToLower(to)]; ok && name != from {
+		return nil, models.NewValidationError(fmt.Errorf("exercise with name '%s' already exists", name))
+	}
+	if to == from {
+		return nil, models.NewValidationError(fmt.Errorf("exercise is already named '%s'", to))
+	}
+
+	renamed, err := exerciseStore(c).RenameExercise(c.Request.Context(), userId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	out := models.NewExerciseOut(renamed)
+	out.Own = boolPtr(true)
+	return out, nil
+}
+
+// DeleteExercise godoc
+//
+//	@Summary		Delete an exercise
+//	@Description	Deletes an exercise created by the authenticated user. While workouts, templates or programs
+//	@Description	still refer to it, the delete is refused with a count of them, unless cascade is set: then
+//	@Description	it is taken out of all of them, and its history and personal records are deleted too.
+//	@Description	Its images are deleted with it.
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@ID				deleteExercise
+//	@Param			X-App-Version	header	string	false	"Client app version (e.g., 2.8.0)"
+//	@Param			exerciseName	path	string	true	"Name of the exercise to delete"
+//	@Param			cascade			query	boolean	false	"Take the exercise out of whatever uses it"
+//	@Success		204				"No Content"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		409				{object}	ErrorResponse	"Still in use"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/exercises/{exerciseName} [delete]
+//	@Security		BearerAuth
+func DeleteExercise(c *gin.Context, userId string) (any, error) {
+	cascade, _ := strconv.ParseBool(c.Query("cascade"))
+
+	deleted, err := exerciseStore(c).DeleteExercise(c.Request.Context(), userId, c.Param("exerciseName"), cascade)
+	if err != nil {
+		return nil, err
+	}
+
+	deleteExerciseImages(c, deleted)
+	return models.NoContent, nil
+}
+
+// MergeExercise godoc
+//
+//	@Summary		Merge an exercise into another
+//	@Description	Merges an exercise created by the authenticated user into another exercise, own or from the catalog.
+//	@Description	Workouts, templates and programs move over to the target, personal records are folded into its own,
+//	@Description	and the merged name stays on as an alias of the target, as do names merged into it before.
+//	@Description	Its images are deleted; those of the target stay.
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@ID				mergeExercise
+//	@Param			X-App-Version	header	string			false	"Client app version (e.g., 2.8.0)"
+//	@Param			exerciseName	path	string			true	"Name of the exercise to merge"
+//	@Param			input			body	MergeExerciseIn	true	"Exercise to merge into"
+//	@Success		204				"No Content"
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/exercises/{exerciseName}/merge [post]
+//	@Security		BearerAuth
+func MergeExercise(c *gin.Context, userId string) (any, error) {
+	from := c.Param("exerciseName")
+	var in models.MergeExerciseIn
+	if err := c.BindJSON(&in); err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	names, err := knownExercises(c, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	to, ok := names[strings.ToLower(strings.TrimSpace(in.Target))]
+	if !ok {
+		return nil, models.NewValidationError(fmt.Errorf("no exercise named '%s'", in.Target))
+	}
+	if to == from {
+		return nil, models.NewValidationError(fmt.Errorf("cannot merge '%s' into itself", from))
+	}
+
+	merged, err := exerciseStore(c).MergeExercise(c.Request.Context(), userId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	deleteExerciseImages(c, merged)
+	return models.NoContent, nil
+}
+
+// MakeExercisePresignedUrl godoc
+//
+//	@Summary		Generates presigned URL for exercise image upload
+//	@Description	Generates presigned URL and form fields for uploading the demo image or the thumbnail of an exercise
+//	@Description	created by the authenticated user. Once processed, the image replaces the one before it, along with its size.
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@ID				makeExercisePresignedUrl
+//	@Param			X-App-Version	header		string			false	"Client app version (e.g., 2.8.0)"
+//	@Param			exerciseName	path		string			true	"Name of the exercise"
+//	@Param			input			body		ExerciseImageIn	true	"Upload request"
+//	@Success		200				{object}	PresignedUrlResponse
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/exercises/{exerciseName}/images [put]
+//	@Security		BearerAuth
+func MakeExercisePresignedUrl(c *gin.Context, userId string) (any, error) {
+	var request models.ExerciseImageIn
+	if err := c.BindJSON(&request); err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	kind := request.Kind
+	if kind == "" {
+		kind = models.ExerciseAsset
+	}
+	if kind != models.ExerciseAsset && kind != models.ExerciseThumbnail {
+		return nil, models.NewValidationError(fmt.Errorf("kind must be %s or %s", models.ExerciseAsset, models.ExerciseThumbnail))
+	}
+
+	exercise, err := exerciseStore(c).GetOwnExercise(c.Request.Context(), userId, c.Param("exerciseName"))
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType := models.DefaultMimeType
+	if request.MimeType != nil && *request.MimeType != "" {
+		mimeType = *request.MimeType
+	}
+
+	extension, err := models.Extension(mimeType)
+	if err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+	key := fmt.Sprintf("%s%s%s", config.App.ExerciseImagePrefix(userId), id, extension)
+
+	// the media pipeline attaches the processed image to the exercise these name
+	tag := config.App.UploadDestinationTag()
+	maps.Copy(tag, map[string]string{"userId": userId, "exercise": exercise.Name, "image": kind})
+
+	response, err := objects(c).GeneratePresignedPostURL(
+		c.Request.Context(),
+		config.App.UploadBucket,
+		key,
+		mimeType,
+		&tag,
+	)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	destinationUrl := fmt.Sprintf("%s/%s", config.App.MediaDistributionAlias, key)
+	return models.PresignedUrlResponse{
+		URL:            response.URL,
+		Fields:         response.Values,
+		DestinationUrl: &destinationUrl,
+	}, nil
+}
+
+// deleteExerciseImages deletes the uploaded images of an exercise that is gone. The exercise no longer
+// points to them, so a failure only leaves them behind until the account is purged; it is logged, not returned.
+func deleteExerciseImages(c *gin.Context, exercise *models.Exercise) {
+	for _, image := range []*models.ImageDescription{exercise.Asset, exercise.Thumbnail} {
+		if image == nil || image.Key == nil {
+			continue
+		}
+		if _, err := objects(c).DeleteObject(c.Request.Context(), config.App.MediaBucket, *image.Key); err != nil {
+			log.Printf("[ERROR] deleting image %s of exercise %s: %v", *image.Key, exercise.Name, err)
+		}
+	}
+}
+
+// resolveExercise follows a name merged into another exercise to that exercise.
+func resolveExercise(c *gin.Context, userId string, name string) (string, error) {
+	aliases, err := exerciseStore(c).GetAliases(c.Request.Context(), userId)
+	if err != nil {
+		return "", err
+	}
+
+	return models.NewAliases(aliases).Resolve(name), nil
+}
+
+// GetExerciseRecords godoc
+//
+//	@Summary		Personal records for an exercise
+//	@Description	Returns the heaviest weight, best estimated 1RM, most reps at each weight,
+//	@Description	longest duration and longest distance the user has logged for the exercise.
+//	@Description	A name merged into another exercise gives the records of that exercise.
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@ID				getExerciseRecords
+//	@Param			X-App-Version	header		string	false	"Client app version (e.g., 2.8.0)"
+//	@Param			exerciseName	path		string	true	"Name of the exercise"
+//	@Success		200				{object}	PersonalRecords
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/exercises/{exerciseName}/records [get]
+//	@Security		BearerAuth
+func GetExerciseRecords(c *gin.Context, userId string) (any, error) {
+	exerciseName, err := resolveExercise(c, userId, c.Param("exerciseName"))
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := workoutStore(c).GetRecords(c.Request.Context(), userId, exerciseName)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPersonalRecordsOut(records), nil
+}
+
+// GetExerciseHistory godoc
+//
+//	@Summary		Exercise history
+//	@Description	Returns the sets logged for the exercise across all workouts, newest first.
+//	@Description	A name merged into another exercise gives the history of that exercise.
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@ID				getExerciseHistory
+//	@Param			X-App-Version	header		string	false	"Client app version (e.g., 2.8.0)"
+//	@Param			exerciseName	path		string	true	"Name of the exercise"
+//	@Param			pageSize		query		integer	false	"Page size for pagination"
+//	@Param			cursor			query		string	false	"Cursor for pagination"
+//	@Success		200				{object}	ExerciseHistoryResponse
+//	@Success		202				"History is still being built; ask again shortly"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/exercises/{exerciseName}/history [get]
+//	@Security		BearerAuth
+func GetExerciseHistory(c *gin.Context, userId string) (any, error) {
+	exerciseName, err := resolveExercise(c, userId, c.Param("exerciseName"))
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := 20
+	if size := c.Query("pageSize"); size != "" {
+		if parsed, err := strconv.Atoi(size); err == nil && parsed > 0 {
+			pageSize = parsed
+		}
+	}
+
+	cursor := c.Query("cursor")
+
+	entries, next, err := workoutStore(c).GetExerciseHistory(c.Request.Context(), userId, exerciseName, pageSize, cursor)
+	pending, err := historyPending(c, userId, err)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+	if pending {
+		return models.Accepted, nil
+	}
+
+	history := make([]models.ExerciseHistoryOut, len(entries))
+	for i, e := range entries {
+		history[i] = models.NewExerciseHistoryOut(&e)
+	}
+
+	return models.ExerciseHistoryResponse{
+		Exercise: exerciseName,
+		History:  history,
+		Cursor:   next,
+	}, nil
+}
+
+// historyPending tells whether the store could not read history because the user's history is
+// still being built, and starts building it in the background when that falls to this request.
+// Any other error is handed back.
+func historyPending(c *gin.Context, userId string, err error) (bool, error) {
+	var pending *models.HistoryPendingError
+	if !errors.As(err, &pending) {
+		return false, err
+	}
+
+	if pending.Start {
+		err := jobs(c).InvokeBackground(c.Request.Context(), "RebuildHistory", map[string]string{"user_id": userId})
+		if err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
 func boolPtr(b bool) *bool { return &b }
@@ -9,27 +9,31 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/ptr"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func newCtx() *gin.Context {
 	gin.SetMode(gin.TestMode)
 	rec := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(rec)
+	newFakeStores().attach(c) // tests that stub the stores attach their own
 	// Attach a dummy request so c.Request.Context() is valid in handlers
 	c.Request = httptest.NewRequest("GET", "/exercises", nil)
 	return c
 }
 
 func TestGetExercises_Success(t *testing.T) {
-	orig := dbGetExercises
-	dbGetExercises = func(ctx context.Context) ([]models.Exercise, error) {
+	db := newFakeStores()
+	stubAliases(db)
+	db.getExercises = func(ctx context.Context) ([]models.Exercise, error) {
 		return []models.Exercise{{Name: "Push Up", Category: "Body", Target: "Chest"}}, nil
 	}
-	t.Cleanup(func() { dbGetExercises = orig })
 
-	c := newCtx()
+	c := db.attach(newCtx())
 	res, err := GetExercises(c, "user")
 	assert.NoError(t, err)
 	out, ok := res.(models.ExercisesResponse)
@@ -39,11 +43,10 @@ func TestGetExercises_Success(t *testing.T) {
 }
 
 func TestGetExercises_ServerError(t *testing.T) {
-	orig := dbGetExercises
-	dbGetExercises = func(ctx context.Context) ([]models.Exercise, error) { return nil, errors.New("boom") }
-	t.Cleanup(func() { dbGetExercises = orig })
+	db := newFakeStores()
+	db.getExercises = func(ctx context.Context) ([]models.Exercise, error) { return nil, errors.New("boom") }
 
-	c := newCtx()
+	c := db.attach(newCtx())
 	res, err := GetExercises(c, "user")
 	assert.Nil(t, res)
 	assert.Error(t, err)
@@ -51,16 +54,129 @@ func TestGetExercises_ServerError(t *testing.T) {
 	assert.True(t, isHTTP)
 }
 
+func TestGetExercises_Filters(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db, models.NewExerciseAlias("user", "DB Bench", "Dumbbell Bench Press"))
+	stubKnownExercises(db, []models.Exercise{
+		{Name: "Dumbbell Bench Press", Category: "Dumbbell", Target: "Chest"},
+		{Name: "Push Up", Category: "Body weight", Target: "Chest"},
+		{Name: "Pull Up", Category: "Body weight", Target: "Back"},
+	}, nil)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"category=body%20weight", []string{"Push Up", "Pull Up"}},
+		{"target=CHEST", []string{"Dumbbell Bench Press", "Push Up"}},
+		{"category=Body%20weight&target=Back", []string{"Pull Up"}},
+		{"q=up", []string{"Push Up", "Pull Up"}},
+		{"q=db", []string{"Dumbbell Bench Press"}},
+		{"q=squat", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c := db.attach(newCtx())
+			c.Request = httptest.NewRequest("GET", "/exercises?"+tt.query, nil)
+			res, err := GetExercises(c, "user")
+
+			require.NoError(t, err)
+			names := []string{}
+			for _, e := range res.(models.ExercisesResponse).Exercises {
+				names = append(names, e.Name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestGetExercises_Merged(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	stubKnownExercises(db, []models.Exercise{{Name: "Push Up"}}, []models.Exercise{{Name: "Band Pull Apart"}})
+
+	c := db.attach(newCtx())
+	c.Request = httptest.NewRequest("GET", "/exercises?merged=true", nil)
+	res, err := GetExercises(c, "user")
+
+	require.NoError(t, err)
+	out := res.(models.ExercisesResponse).Exercises
+	require.Len(t, out, 2)
+	assert.Equal(t, "Push Up", out[0].Name)
+	assert.False(t, *out[0].Own)
+	assert.Equal(t, "Band Pull Apart", out[1].Name)
+	assert.True(t, *out[1].Own)
+	assert.Empty(t, c.Writer.Header().Get("ETag"), "own exercises change too often to tag")
+}
+
+func TestGetExercises_NotModified(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	stubKnownExercises(db, []models.Exercise{{Name: "Push Up"}}, nil)
+
+	c := db.attach(newCtx())
+	_, err := GetExercises(c, "user")
+	require.NoError(t, err)
+	etag := c.Writer.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	c = db.attach(newCtx())
+	c.Request.Header.Set("If-None-Match", etag)
+	res, err := GetExercises(c, "user")
+	require.NoError(t, err)
+	assert.Equal(t, models.NotModified, res)
+
+	// a merge changes what the client holds, even though the catalog stands
+	stubAliases(db, models.NewExerciseAlias("user", "Pushup", "Push Up"))
+	c = db.attach(newCtx())
+	c.Request.Header.Set("If-None-Match", etag)
+	res, err = GetExercises(c, "user")
+	require.NoError(t, err)
+	assert.IsType(t, models.ExercisesResponse{}, res)
+	assert.NotEqual(t, etag, c.Writer.Header().Get("ETag"))
+}
+
+func TestGetExercises_Localized(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	stubKnownExercises(db, []models.Exercise{
+		{Name: "Push Up", Category: "Body weight", Target: "Chest", Translations: map[string]models.ExerciseTranslation{
+			"de": {Name: "Liegestütz", Category: "Körpergewicht", Target: "Brust"},
+		}},
+		{Name: "Pull Up", Category: "Body weight", Target: "Back"},
+	}, nil)
+
+	c := db.attach(newCtx())
+	_, err := GetExercises(c, "user")
+	require.NoError(t, err)
+	english := c.Writer.Header().Get("ETag")
+
+	c = db.attach(newCtx())
+	c.Request = httptest.NewRequest("GET", "/exercises?q=liege", nil)
+	c.Request.Header.Set("Accept-Language", "de-DE,de;q=0.9,en;q=0.8")
+	res, err := GetExercises(c, "user")
+
+	require.NoError(t, err)
+	out := res.(models.ExercisesResponse).Exercises
+	require.Len(t, out, 1)
+	assert.Equal(t, "Push Up", out[0].Name, "still referred to by its English name")
+	assert.Equal(t, "Liegestütz", out[0].Localized.Name)
+	assert.Equal(t, "Brust", out[0].Localized.Target)
+	assert.Equal(t, "de", c.Writer.Header().Get("Content-Language"))
+	assert.NotEqual(t, english, c.Writer.Header().Get("ETag"), "a change of language is a change of content")
+}
+
 func TestMakeExercise_Success(t *testing.T) {
-	orig := dbMakeExercise
-	dbMakeExercise = func(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
+	db := newFakeStores()
+	db.makeExercise = func(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
 		return &in, nil
 	}
-	t.Cleanup(func() { dbMakeExercise = orig })
 
 	gin.SetMode(gin.TestMode)
 	rec := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(rec)
+	db.attach(c)
 	body := models.UserExerciseIn{Name: "Push Up", Category: "Body", Target: "Chest"}
 	b, _ := json.Marshal(body)
 	c.Request = httptest.NewRequest("POST", "/exercises", bytes.NewReader(b))
@@ -76,15 +192,15 @@ func TestMakeExercise_Success(t *testing.T) {
 }
 
 func TestMakeExercise_BadJSON(t *testing.T) {
-	orig := dbMakeExercise
-	dbMakeExercise = func(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
+	db := newFakeStores()
+	db.makeExercise = func(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
 		return &in, nil
 	}
-	t.Cleanup(func() { dbMakeExercise = orig })
 
 	gin.SetMode(gin.TestMode)
 	rec := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(rec)
+	db.attach(c)
 	c.Request = httptest.NewRequest("POST", "/exercises", bytes.NewBufferString("{bad json"))
 	c.Request.Header.Set("Content-Type", "application/json")
 
@@ -95,16 +211,36 @@ func TestMakeExercise_BadJSON(t *testing.T) {
 	assert.True(t, isHTTP)
 }
 
+func TestMakeExercise_NamesTheFieldsAtFault(t *testing.T) {
+	db := newFakeStores()
+	db.makeExercise = func(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
+		t.Fatal("should not make")
+		return nil, nil
+	}
+
+	c := db.attach(newGinContextWithBody("POST", "/exercises", `{"name":"Push/Up","category":" ","target":"Chest"}`))
+	res, err := MakeExercise(c, "user-1")
+
+	assert.Nil(t, res)
+	var validation *models.ValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.JSONEq(t, `{
+		"error": "category: must not be blank; name: can only contain letters, numbers and spaces",
+		"code": "ValidationError",
+		"details": {"category": ["must not be blank"], "name": ["can only contain letters, numbers and spaces"]}
+	}`, string(validation.JSON()))
+}
+
 func TestMakeExercise_PropagatesError(t *testing.T) {
-	orig := dbMakeExercise
-	dbMakeExercise = func(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
+	db := newFakeStores()
+	db.makeExercise = func(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
 		return nil, errors.New("db fail")
 	}
-	t.Cleanup(func() { dbMakeExercise = orig })
 
 	gin.SetMode(gin.TestMode)
 	rec := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(rec)
+	db.attach(c)
 	body := models.UserExerciseIn{Name: "Push Up", Category: "Body", Target: "Chest"}
 	b, _ := json.Marshal(body)
 	c.Request = httptest.NewRequest("POST", "/exercises", bytes.NewReader(b))
@@ -114,3 +250,243 @@ func TestMakeExercise_PropagatesError(t *testing.T) {
 	assert.Nil(t, res)
 	assert.Error(t, err)
 }
+
+func TestGetExerciseRecords(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	db.getRecords = func(ctx context.Context, userId, exercise string) (*models.PersonalRecords, error) {
+		records := models.NewPersonalRecords(userId, exercise)
+		records.Weight = &models.Record{Value: 100, WorkoutID: "w1", SetID: "s1"}
+		return &records, nil
+	}
+
+	c := db.attach(newCtx())
+	c.Params = gin.Params{{Key: "exerciseName", Value: "Bench Press"}}
+	res, err := GetExerciseRecords(c, "u1")
+
+	assert.NoError(t, err)
+	out := res.(models.PersonalRecordsOut)
+	assert.Equal(t, "Bench Press", out.Exercise)
+	assert.Equal(t, 100.0, out.Weight.Value)
+	assert.NotNil(t, out.RepsAtWeight)
+}
+
+func TestGetExerciseHistory_Paginates(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	var gotLimit int
+	var gotCursor string
+	db.getExerciseHistory = func(ctx context.Context, userId, exercise string, limit int, cursor string) ([]models.HistoryEntry, string, error) {
+		gotLimit, gotCursor = limit, cursor
+		return []models.HistoryEntry{{Exercise: exercise, WorkoutID: "w1"}}, "w1", nil
+	}
+
+	c := db.attach(newCtx())
+	c.Request = httptest.NewRequest("GET", "/exercises/Squat/history?pageSize=5&cursor=w2", nil)
+	c.Params = gin.Params{{Key: "exerciseName", Value: "Squat"}}
+	res, err := GetExerciseHistory(c, "u1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, gotLimit)
+	assert.Equal(t, "w2", gotCursor)
+	out := res.(models.ExerciseHistoryResponse)
+	assert.Equal(t, "Squat", out.Exercise)
+	assert.Equal(t, "w1", out.Cursor)
+	assert.Len(t, out.History, 1)
+}
+
+func TestGetExerciseHistory_StartsBuildingHistoryInTheBackground(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	db.getExerciseHistory = func(ctx context.Context, userId, exercise string, limit int, cursor string) ([]models.HistoryEntry, string, error) {
+		return nil, "", &models.HistoryPendingError{Start: true}
+	}
+	var invoked string
+	var payload any
+	db.invokeBackground = func(ctx context.Context, event string, p any) error {
+		invoked, payload = event, p
+		return nil
+	}
+
+	c := db.attach(newCtx())
+	c.Request = httptest.NewRequest("GET", "/exercises/Squat/history", nil)
+	c.Params = gin.Params{{Key: "exerciseName", Value: "Squat"}}
+	res, err := GetExerciseHistory(c, "u1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.Accepted, res)
+	assert.Equal(t, "RebuildHistory", invoked)
+	assert.Equal(t, map[string]string{"user_id": "u1"}, payload)
+
+	// while it is being built, later reads wait for it
+	db.getExerciseHistory = func(ctx context.Context, userId, exercise string, limit int, cursor string) ([]models.HistoryEntry, string, error) {
+		return nil, "", &models.HistoryPendingError{}
+	}
+	db.invokeBackground = nil
+	res, err = GetExerciseHistory(c, "u1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.Accepted, res)
+}
+
+func stubKnownExercises(db *fakeStores, catalog, own []models.Exercise) {
+	db.getExercises = func(ctx context.Context) ([]models.Exercise, error) { return catalog, nil }
+	db.getOwnExercises = func(ctx context.Context, userId string) ([]models.Exercise, error) { return own, nil }
+}
+
+func TestRenameExercise_NameTaken(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	stubKnownExercises(db, []models.Exercise{{Name: "Bench Press"}}, []models.Exercise{{Name: "Bench"}})
+	db.renameExercise = func(ctx context.Context, userId, from, to string) (*models.Exercise, error) {
+		t.Fatal("should not rename")
+		return nil, nil
+	}
+
+	c := db.attach(newGinContextWithBody("POST", "/exercises/Bench/rename", `{"name":"bench press"}`))
+	c.Params = gin.Params{{Key: "exerciseName", Value: "Bench"}}
+	res, err := RenameExercise(c, "u1")
+
+	assert.Nil(t, res)
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
+
+func TestRenameExercise_ChangesCase(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	stubKnownExercises(db, nil, []models.Exercise{{Name: "bench"}})
+	db.renameExercise = func(ctx context.Context, userId, from, to string) (*models.Exercise, error) {
+		return &models.Exercise{Name: to}, nil
+	}
+
+	c := db.attach(newGinContextWithBody("POST", "/exercises/bench/rename", `{"name":" Bench "}`))
+	c.Params = gin.Params{{Key: "exerciseName", Value: "bench"}}
+	res, err := RenameExercise(c, "u1")
+
+	require.NoError(t, err)
+	out := res.(models.ExerciseOut)
+	assert.Equal(t, "Bench", out.Name)
+	assert.True(t, *out.Own)
+}
+
+func TestDeleteExercise_PassesCascade(t *testing.T) {
+	db := newFakeStores()
+	var cascaded bool
+	db.deleteExercise = func(ctx context.Context, userId, name string, cascade bool) (*models.Exercise, error) {
+		cascaded = cascade
+		return &models.Exercise{Name: name}, nil
+	}
+
+	c := db.attach(newGinContextWithBody("DELETE", "/exercises/Bench?cascade=true", ""))
+	c.Params = gin.Params{{Key: "exerciseName", Value: "Bench"}}
+	res, err := DeleteExercise(c, "u1")
+
+	require.NoError(t, err)
+	assert.Equal(t, models.NoContent, res)
+	assert.True(t, cascaded)
+}
+
+func TestDeleteExercise_DeletesImages(t *testing.T) {
+	db := newFakeStores()
+	db.deleteExercise = func(ctx context.Context, userId, name string, cascade bool) (*models.Exercise, error) {
+		return &models.Exercise{
+			Name:      name,
+			Asset:     &models.ImageDescription{Key: ptr.String("custom-exercises/abc/1.png")},
+			Thumbnail: &models.ImageDescription{Link: ptr.String("https://media.example.test/legacy.png")},
+		}, nil
+	}
+	var deleted []string
+	db.deleteObject = func(ctx context.Context, bucket, key string) (*s3.DeleteObjectOutput, error) {
+		deleted = append(deleted, key)
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	c := db.attach(newGinContextWithBody("DELETE", "/exercises/Bench", ""))
+	c.Params = gin.Params{{Key: "exerciseName", Value: "Bench"}}
+	res, err := DeleteExercise(c, "u1")
+
+	require.NoError(t, err)
+	assert.Equal(t, models.NoContent, res)
+	assert.Equal(t, []string{"custom-exercises/abc/1.png"}, deleted, "only images that were uploaded")
+}
+
+func TestMakeExercisePresignedUrl_BadKind(t *testing.T) {
+	c := newGinContextWithBody("PUT", "/exercises/Bench/images", `{"kind":"poster"}`)
+	c.Params = gin.Params{{Key: "exerciseName", Value: "Bench"}}
+	res, err := MakeExercisePresignedUrl(c, "u1")
+
+	assert.Nil(t, res)
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
+
+func TestMakeExercisePresignedUrl_NotOwn(t *testing.T) {
+	db := newFakeStores()
+	db.getOwnExercise = func(ctx context.Context, userId, name string) (*models.Exercise, error) {
+		return nil, models.NewNotFoundError("Exercise not found", nil)
+	}
+
+	c := db.attach(newGinContextWithBody("PUT", "/exercises/Push%20Up/images", `{"kind":"thumbnail"}`))
+	c.Params = gin.Params{{Key: "exerciseName", Value: "Push Up"}}
+	res, err := MakeExercisePresignedUrl(c, "u1")
+
+	assert.Nil(t, res)
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func stubAliases(db *fakeStores, aliases ...models.ExerciseAlias) {
+	db.getAliases = func(ctx context.Context, userId string) ([]models.ExerciseAlias, error) { return aliases, nil }
+}
+
+func TestMergeExercise_ResolvesTarget(t *testing.T) {
+	db := newFakeStores()
+	stubKnownExercises(db, []models.Exercise{{Name: "Dumbbell Bench Press"}}, []models.Exercise{{Name: "DB Bench"}})
+	stubAliases(db)
+	var merged string
+	db.mergeExercise = func(ctx context.Context, userId, from, to string) (*models.Exercise, error) {
+		merged = from + " -> " + to
+		return &models.Exercise{Name: from}, nil
+	}
+
+	c := db.attach(newGinContextWithBody("POST", "/exercises/DB%20Bench/merge", `{"target":"dumbbell bench press"}`))
+	c.Params = gin.Params{{Key: "exerciseName", Value: "DB Bench"}}
+	res, err := MergeExercise(c, "u1")
+
+	require.NoError(t, err)
+	assert.Equal(t, models.NoContent, res)
+	assert.Equal(t, "DB Bench -> Dumbbell Bench Press", merged)
+}
+
+func TestMergeExercise_UnknownTarget(t *testing.T) {
+	db := newFakeStores()
+	stubKnownExercises(db, nil, []models.Exercise{{Name: "DB Bench"}})
+	stubAliases(db)
+
+	c := db.attach(newGinContextWithBody("POST", "/exercises/DB%20Bench/merge", `{"target":"Nope"}`))
+	c.Params = gin.Params{{Key: "exerciseName", Value: "DB Bench"}}
+	res, err := MergeExercise(c, "u1")
+
+	assert.Nil(t, res)
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
+
+func TestGetExerciseRecords_FollowsAlias(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db, models.NewExerciseAlias("u1", "DB Bench", "Dumbbell Bench Press"))
+	var asked string
+	db.getRecords = func(ctx context.Context, userId, exercise string) (*models.PersonalRecords, error) {
+		asked = exercise
+		records := models.NewPersonalRecords(userId, exercise)
+		return &records, nil
+	}
+
+	c := db.attach(newCtx())
+	c.Params = gin.Params{{Key: "exerciseName", Value: "DB Bench"}}
+	_, err := GetExerciseRecords(c, "u1")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Dumbbell Bench Press", asked)
+}
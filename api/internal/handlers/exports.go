@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"heart/internal/config"
+	"heart/internal/export"
+	"heart/internal/models"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const exportPageSize = 100
+
+// ExportWorkouts godoc
+//
+//	@Summary		Exports workouts
+//	@Description	Streams the user's workouts as a file, newest first: CSV with a row per set,
+//	@Description	JSON Lines with a workout per line, or TCX with the cardio sets of each workout as an activity.
+//	@Description	Takes the same filters as the list of workouts.
+//	@Tags			workouts
+//	@Produce		text/csv
+//	@Produce		application/jsonl
+//	@Produce		application/vnd.garmin.tcx+xml
+//	@ID				exportWorkouts
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			X-Timezone		header		string	false	"IANA time zone dates are taken in, UTC by default"
+//	@Param			format			query		string	false	"File format, csv by default"	Enums(csv, jsonl, tcx)
+//	@Param			from			query		string	false	"Started on or after, 2006-01-02 or RFC 3339"
+//	@Param			to				query		string	false	"Started on or before, 2006-01-02 or RFC 3339"
+//	@Param			exercise		query		string	false	"Has this exercise"
+//	@Param			name			query		string	false	"Name contains, case-insensitive"
+//	@Success		200				{file}		file
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/workouts/export [get]
+//	@Security		BearerAuth
+func ExportWorkouts(c *gin.Context, userId string) (any, error) {
+	name := c.DefaultQuery("format", "csv")
+	format, ok := export.WorkoutFormats[name]
+	if !ok {
+		formats := slices.Sorted(maps.Keys(export.WorkoutFormats))
+		return nil, models.NewValidationError(fmt.Errorf("format must be one of: %s", strings.Join(formats, ", ")))
+	}
+
+	filter, err := workoutFilter(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first page is read before anything is sent, so that errors still get a proper response
+	workouts, cursor, err := workoutStore(c).GetWorkouts(c.Request.Context(), userId, exportPageSize, "", filter)
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	c.Header("Content-Type", format.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="workouts.%s"`, format.Extension))
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+
+	writer := format.New(c.Writer)
+	for {
+		for i := range workouts {
+			out := models.NewWorkoutOut(&workouts[i], config.App.MediaDistributionAlias)
+			if err := writer.Write(&out); err != nil {
+				return nil, err
+			}
+		}
+		c.Writer.Flush()
+
+		if cursor == "" {
+			break
+		}
+
+		workouts, cursor, err = workoutStore(c).GetWorkouts(c.Request.Context(), userId, exportPageSize, cursor, filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, writer.Close()
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"heart/internal/models"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newExportCtx(query string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	newFakeStores().attach(c) // tests that stub the stores attach their own
+	c.Request = httptest.NewRequest("GET", "/workouts/export"+query, nil)
+	return c, rec
+}
+
+func TestExportWorkouts_PagesThroughAllWorkouts(t *testing.T) {
+	db := newFakeStores()
+
+	var cursors []string
+	db.getWorkouts = func(ctx context.Context, userId string, limit int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error) {
+		cursors = append(cursors, cursor)
+		start := time.Date(2025, 8, 1, 7, 0, 0, 0, time.UTC)
+		w := models.Workout{
+			PK:        "USER#" + userId,
+			SK:        "WORKOUT#w" + cursor,
+			Name:      "Legs",
+			Start:     start,
+			Exercises: []models.WorkoutExercise{{ExerciseID: "Squat", Sets: []models.Set{{ID: "s1", Completed: true, Reps: 5}}}},
+		}
+		if cursor == "" {
+			return []models.Workout{w}, "1", nil
+		}
+		return []models.Workout{w}, "", nil
+	}
+
+	c, rec := newExportCtx("?format=jsonl")
+	db.attach(c)
+	res, err := ExportWorkouts(c, "u1")
+	require.NoError(t, err)
+	assert.Nil(t, res)
+
+	assert.Equal(t, []string{"", "1"}, cursors)
+	assert.Equal(t, "application/jsonl", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), `filename="workouts.jsonl"`)
+	assert.Len(t, strings.Split(strings.TrimSpace(rec.Body.String()), "\n"), 2)
+}
+
+func TestExportWorkouts_ErrorBeforeStreaming(t *testing.T) {
+	db := newFakeStores()
+	db.getWorkouts = func(ctx context.Context, userId string, limit int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error) {
+		return nil, "", errors.New("boom")
+	}
+
+	c, _ := newExportCtx("")
+	db.attach(c)
+	_, err := ExportWorkouts(c, "u1")
+
+	var server *models.ServerError
+	assert.ErrorAs(t, err, &server)
+	assert.False(t, c.Writer.Written())
+}
+
+func TestExportWorkouts_UnknownFormat(t *testing.T) {
+	c, _ := newExportCtx("?format=xlsx")
+	_, err := ExportWorkouts(c, "u1")
+
+	var validation *models.ValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.Contains(t, err.Error(), "csv, jsonl, tcx")
+}
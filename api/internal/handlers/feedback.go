@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"fmt"
-	"heart/internal/awsx"
 	"heart/internal/config"
 	"heart/internal/models"
 	"time"
@@ -34,7 +33,7 @@ func LeaveFeedback(c *gin.Context, userId string) (any, error) {
 
 	key := fmt.Sprintf("feedback/%s/%s", userId, time.Now().Format("2006-01-02T15:04:05.999999-07:00"))
 
-	link, err := awsx.GeneratePresignedPostURL(
+	link, err := objects(c).GeneratePresignedPostURL(
 		c.Request.Context(),
 		config.App.MediaBucket,
 		key,
@@ -54,7 +53,7 @@ func LeaveFeedback(c *gin.Context, userId string) (any, error) {
 		"screenshot": screenshotUrl,
 	}
 
-	err = awsx.SendToMonitoring(c.Request.Context(), body)
+	err = jobs(c).SendToMonitoring(c.Request.Context(), body)
 
 	if err != nil {
 		return nil, err
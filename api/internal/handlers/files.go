@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"heart/internal/awsx"
+	"heart/internal/models"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadFile takes a form upload through a link the API handed out, the way S3 would,
+// when objects are kept on disk. It is not part of the API proper, so not in its docs.
+func UploadFile(c *gin.Context) (any, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, models.NewValidationError(err)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+	defer file.Close()
+
+	fields := make(map[string]string, len(form.Value))
+	for name, values := range form.Value {
+		if len(values) > 0 {
+			fields[name] = values[0]
+		}
+	}
+
+	_, err = awsx.LocalFiles().Save(c.Param("bucket"), fields, file)
+	if errors.Is(err, awsx.ErrBadUpload) || errors.Is(err, fs.ErrInvalid) {
+		return nil, models.NewForbiddenError("Upload not allowed", err)
+	}
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+
+	return models.NoContent, nil
+}
+
+// GetFile serves an object kept on disk, like the media distribution does for S3.
+func GetFile(c *gin.Context) (any, error) {
+	path, err := awsx.LocalFiles().Path(c.Param("bucket"), strings.TrimPrefix(c.Param("key"), "/"))
+	if err != nil {
+		return nil, models.NewNotFoundError("File not found", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return nil, models.NewNotFoundError("File not found", err)
+	}
+
+	c.File(path)
+	return nil, nil
+}
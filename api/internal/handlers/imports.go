@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"heart/internal/importer"
+	"heart/internal/models"
+	"heart/internal/validation"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportSize  = 10 << 20 // years of history fit in a few megabytes
+	maxImportSkips = 50
+)
+
+// ImportWorkouts godoc
+//
+//	@Summary		Imports workout history
+//	@Description	Imports workouts from the CSV export of another app, Strong or Hevy, detected from the header
+//	@Description	unless given. Exercises we do not know are created as custom ones. Workouts already imported
+//	@Description	are left alone, so the same file can be sent again.
+//	@Tags			workouts
+//	@Accept			mpfd
+//	@Produce		json
+//	@ID				importWorkouts
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			X-Timezone		header		string	false	"IANA time zone of times in the export, UTC by default"
+//	@Param			file			formData	file	true	"CSV export"
+//	@Param			format			query		string	false	"Export format"	Enums(strong, hevy)
+//	@Param			units			query		string	false	"Units of exports that do not say, metric by default"	Enums(metric, imperial)
+//	@Success		200				{object}	ImportReport
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/workouts/import [post]
+//	@Security		BearerAuth
+func ImportWorkouts(c *gin.Context, userId string) (any, error) {
+	loc, err := timezone(c)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := importer.Options{Location: loc}
+	switch c.DefaultQuery("units", "metric") {
+	case "metric":
+	case "imperial":
+		opts.Imperial = true
+	default:
+		return nil, models.NewValidationError(errors.New("units must be metric or imperial"))
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	upload, err := c.FormFile("file")
+	if err != nil {
+		return nil, models.NewValidationError(fmt.Errorf("missing CSV file: %w", err))
+	}
+
+	file, err := upload.Open()
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+	defer file.Close()
+
+	result, err := importer.Read(file, c.Query("format"), opts)
+	if err != nil {
+		return nil, models.NewValidationError(fmt.Errorf("%w, expected one of: %s", err, strings.Join(importer.Formats(), ", ")))
+	}
+
+	report := models.ImportReport{
+		Format:    result.Format,
+		Skipped:   len(result.Skipped),
+		Exercises: []string{},
+		Skips:     append([]models.ImportSkip{}, result.Skipped[:min(len(result.Skipped), maxImportSkips)]...),
+	}
+
+	if len(result.Workouts) == 0 {
+		return report, nil
+	}
+
+	ctx := c.Request.Context()
+
+	names, err := knownExercises(c, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := workoutStore(c).GetWorkoutsBetween(ctx, userId, result.Workouts[0].In.Start, result.Workouts[len(result.Workouts)-1].In.Start)
+	if err != nil {
+		return nil, err
+	}
+	imported := make(map[string]bool, len(existing))
+	for _, w := range existing {
+		imported[w.ID()] = true
+	}
+
+	var workouts []models.Workout
+	for _, w := range result.Workouts {
+		if imported[w.In.ID] {
+			report.Duplicate += w.Rows
+			continue
+		}
+
+		for i, e := range w.In.Exercises {
+			name, ok := names[strings.ToLower(e.Exercise)]
+			if !ok {
+				made, err := exerciseStore(c).MakeExercise(ctx, models.UserExerciseIn{Name: e.Exercise, Category: models.OtherGroup, Target: models.OtherGroup}, userId)
+				if err != nil {
+					return nil, err
+				}
+				name = made.Name
+				names[strings.ToLower(name)] = name
+				report.Exercises = append(report.Exercises, name)
+			}
+			w.In.Exercises[i].Exercise = name
+		}
+
+		workouts = append(workouts, models.NewWorkout(&w.In, userId))
+		report.Imported += w.Rows
+	}
+
+	if report.Workouts, err = workoutStore(c).ImportWorkouts(ctx, workouts); err != nil {
+		return nil, err
+	}
+
+	// the workouts are in either way; records catch up on the next save
+	if err := workoutStore(c).ApplyRecords(ctx, userId, workouts); err != nil {
+		log.Printf("[ERROR] updating personal records after import: %v", err)
+	}
+
+	return report, nil
+}
+
+// knownNames checks names against the exercises knownExercises lists, looking those up only
+// when there are names to check.
+func knownNames(c *gin.Context, userId string, names int) (validation.Known, error) {
+	if names == 0 {
+		return nil, nil
+	}
+
+	known, err := knownExercises(c, userId)
+	if err != nil {
+		return nil, err
+	}
+	return func(name string) bool {
+		_, ok := known[strings.ToLower(name)]
+		return ok
+	}, nil
+}
+
+// knownExercises maps the lowercased names of catalog and own exercises to how they are spelled,
+// and those of exercises merged away to the exercises they were merged into.
+func knownExercises(c *gin.Context, userId string) (map[string]string, error) {
+	catalog, _, err := exerciseCatalog(c).get(c.Request.Context(), exerciseStore(c))
+	if err != nil {
+		return nil, err
+	}
+
+	own, err := exerciseStore(c).GetOwnExercises(c.Request.Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := exerciseStore(c).GetAliases(c.Request.Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(catalog)+len(own)+len(aliases))
+	for _, e := range append(catalog, own...) {
+		names[strings.ToLower(e.Name)] = e.Name
+	}
+	for _, a := range aliases {
+		if _, ok := names[strings.ToLower(a.Name)]; !ok {
+			names[strings.ToLower(a.Name)] = a.Target
+		}
+	}
+
+	return names, nil
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"heart/internal/models"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const strongExport = "Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE\n" +
+	"2024-04-28 09:00:00,Push,45m,Bench Press (Barbell),1,60,8,0,0,,,\n" +
+	"2024-05-01 18:30:00,Legs,1h,Squat (Barbell),1,100,5,0,0,,,\n" +
+	"2024-05-01 18:30:00,Legs,1h,Nordic Curl,1,0,6,0,0,,,\n" +
+	"2024-05-01 18:30:00,Legs,1h,Squat (Barbell),Rest Timer,0,0,0,90,,,\n"
+
+func newImportCtx(t *testing.T, query, csv string) *gin.Context {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "export.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(csv))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	c := newCtx()
+	c.Request = httptest.NewRequest("POST", "/workouts/import"+query, &body)
+	c.Request.Header.Set("Content-Type", form.FormDataContentType())
+	return c
+}
+
+func stubImport(db *fakeStores, existing []models.Workout) (imported *[]models.Workout, made *[]string) {
+
+	imported, made = &[]models.Workout{}, &[]string{}
+
+	db.getWorkoutsBetween = func(ctx context.Context, userId string, from, to time.Time) ([]models.Workout, error) {
+		return existing, nil
+	}
+	db.getExercises = func(ctx context.Context) ([]models.Exercise, error) {
+		return []models.Exercise{{Name: "Squat Barbell"}, {Name: "Bench Press Barbell"}}, nil
+	}
+	db.getOwnExercises = func(ctx context.Context, userId string) ([]models.Exercise, error) {
+		return nil, nil
+	}
+	db.makeExercise = func(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
+		*made = append(*made, in.Name)
+		return &in, nil
+	}
+	db.importWorkouts = func(ctx context.Context, workouts []models.Workout) (int, error) {
+		*imported = workouts
+		return len(workouts), nil
+	}
+	db.applyRecords = func(ctx context.Context, userId string, workouts []models.Workout) error {
+		return nil
+	}
+
+	return imported, made
+}
+
+func TestImportWorkouts_CreatesMissingExercises(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	imported, made := stubImport(db, nil)
+
+	res, err := ImportWorkouts(db.attach(newImportCtx(t, "", strongExport)), "u1")
+	require.NoError(t, err)
+
+	report := res.(models.ImportReport)
+	assert.Equal(t, "strong", report.Format)
+	assert.Equal(t, 2, report.Workouts)
+	assert.Equal(t, 3, report.Imported)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, []string{"Nordic Curl"}, report.Exercises)
+	assert.Equal(t, []string{"Nordic Curl"}, *made)
+
+	require.Len(t, *imported, 2)
+	assert.Equal(t, "USER#u1", (*imported)[1].PK)
+	assert.Equal(t, "Squat Barbell", (*imported)[1].Exercises[0].ExerciseID)
+}
+
+func TestImportWorkouts_SkipsWorkoutsAlreadyImported(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	pushStart := time.Date(2024, 4, 28, 9, 0, 0, 0, time.UTC)
+	existing := models.NewWorkout(&models.WorkoutIn{ID: models.Timestamp(pushStart), Start: pushStart}, "u1")
+	imported, _ := stubImport(db, []models.Workout{existing})
+
+	res, err := ImportWorkouts(db.attach(newImportCtx(t, "", strongExport)), "u1")
+	require.NoError(t, err)
+
+	report := res.(models.ImportReport)
+	assert.Equal(t, 1, report.Workouts)
+	assert.Equal(t, 1, report.Duplicate)
+	require.Len(t, *imported, 1)
+	assert.Equal(t, "Legs", (*imported)[0].Name)
+}
+
+func TestImportWorkouts_UnknownFormat(t *testing.T) {
+	db := newFakeStores()
+	stubImport(db, nil)
+
+	_, err := ImportWorkouts(db.attach(newImportCtx(t, "", "a,b\n1,2\n")), "u1")
+
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
+
+func TestImportWorkouts_BadUnits(t *testing.T) {
+	db := newFakeStores()
+	stubImport(db, nil)
+
+	_, err := ImportWorkouts(db.attach(newImportCtx(t, "?units=stone", strongExport)), "u1")
+
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
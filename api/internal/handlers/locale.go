@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultLocale is the language exercises are named in, and what everything falls back to.
+const defaultLocale = "en"
+
+// negotiateLocale picks the language to answer in from an Accept-Language header, out of those
+// available: the most preferred one there is, matching "pt-BR" to "pt" if need be.
+func negotiateLocale(header string, available map[string]bool) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var wanted []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			wanted = append(wanted, weighted{tag, q})
+		}
+	}
+	// stable, so that equally weighted languages keep the order they were listed in
+	slices.SortStableFunc(wanted, func(a, b weighted) int { return cmp.Compare(b.q, a.q) })
+
+	for _, w := range wanted {
+		primary, _, _ := strings.Cut(w.tag, "-")
+		if primary == defaultLocale {
+			return defaultLocale
+		}
+		if available[w.tag] {
+			return w.tag
+		}
+		if available[primary] {
+			return primary
+		}
+	}
+
+	return defaultLocale
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"heart/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetErrorCatalog godoc
+//
+//	@Summary		List error codes
+//	@Description	Lists the codes error responses come with, each with its status, title and problem type.
+//	@Description	Errors are RFC 7807 problem details, except for apps older than PROBLEM_DETAILS_APP_VERSION,
+//	@Description	which get the bodies they were built to read. No sign-in needed.
+//	@Tags			problems
+//	@Produce		json
+//	@ID				getErrorCatalog
+//	@Success		200	{array}	ErrorKind
+//	@Router			/problems [get]
+func GetErrorCatalog(c *gin.Context) (any, error) {
+	return models.ErrorCatalog, nil
+}
+
+// GetErrorKind godoc
+//
+//	@Summary		Describe an error code
+//	@Description	Describes the problem type of an error code, which is what the type of a problem links to.
+//	@Tags			problems
+//	@Produce		json
+//	@ID				getErrorKind
+//	@Param			code	path		string	true	"Error code"
+//	@Success		200		{object}	ErrorKind
+//	@Failure		404		{object}	ErrorResponse	"Not Found"
+//	@Router			/problems/{code} [get]
+func GetErrorKind(c *gin.Context) (any, error) {
+	kind, ok := models.LookUpErrorKind(models.ErrorCode(c.Param("code")))
+	if !ok {
+		return nil, models.NewNotFoundError("No such error code", errors.New("unknown error code"))
+	}
+	return kind, nil
+}
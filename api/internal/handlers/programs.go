@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"heart/internal/models"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPrograms godoc
+//
+//	@Summary		Lists training programs
+//	@Description	Returns all training programs of the authenticated user
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@ID				getPrograms
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Success		200				{object}	ProgramResponse
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/programs [get]
+//	@Security		BearerAuth
+func GetPrograms(c *gin.Context, userId string) (any, error) {
+	programs, err := templateStore(c).GetPrograms(c.Request.Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.ProgramResponse{
+		Programs: models.NewProgramArray(programs),
+	}, nil
+}
+
+// GetProgram godoc
+//
+//	@Summary		Get training program
+//	@Description	Returns a training program by ID
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@ID				getProgram
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			programId		path		string	true	"Program ID"
+//	@Success		200				{object}	Program
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/programs/{programId} [get]
+//	@Security		BearerAuth
+func GetProgram(c *gin.Context, userId string) (any, error) {
+	program, err := templateStore(c).GetProgram(c.Request.Context(), userId, c.Param("programId"))
+	if err != nil {
+		return nil, err
+	}
+
+	setETag(c, program.Version)
+	return models.NewProgramOut(program), nil
+}
+
+// MakeProgram godoc
+//
+//	@Summary		Creates a training program
+//	@Description	Validates, saves and returns a training program. Its slots must refer to templates of the user.
+//	@Description	Passing the version the edit is based on, in the body or as If-Match, rejects the save
+//	@Description	if the program has changed since.
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@ID				makeProgram
+//	@Param			X-App-Version	header		string		false	"Client app version"
+//	@Param			If-Match		header		string		false	"Version the edit is based on"
+//	@Param			input			body		ProgramIn	true	"Program request"
+//	@Success		200				{object}	Program
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		409				{object}	ErrorResponse	"Changed since the given version"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/programs [post]
+//	@Security		BearerAuth
+func MakeProgram(c *gin.Context, userId string) (any, error) {
+	var program models.ProgramIn
+	if err := c.BindJSON(&program); err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	return saveProgram(c, userId, &program, false)
+}
+
+// UpdateProgram godoc
+//
+//	@Summary		Updates a training program
+//	@Description	Validates, saves and returns an existing training program. Passing the version the edit
+//	@Description	is based on, in the body or as If-Match, rejects the save if the program has changed since.
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@ID				updateProgram
+//	@Param			X-App-Version	header		string		false	"Client app version"
+//	@Param			If-Match		header		string		false	"Version the edit is based on"
+//	@Param			programId		path		string		true	"Program ID"
+//	@Param			input			body		ProgramIn	true	"Program request"
+//	@Success		200				{object}	Program
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		409				{object}	ErrorResponse	"Changed since the given version"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/programs/{programId} [put]
+//	@Security		BearerAuth
+func UpdateProgram(c *gin.Context, userId string) (any, error) {
+	var program models.ProgramIn
+	program.ID = c.Param("programId") // the body may leave it out
+	if err := c.BindJSON(&program); err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	if program.ID != c.Param("programId") {
+		return nil, models.NewValidationError(errors.New("program id in the body does not match the path"))
+	}
+
+	return saveProgram(c, userId, &program, true)
+}
+
+func saveProgram(c *gin.Context, userId string, in *models.ProgramIn, mustExist bool) (any, error) {
+	if err := in.Validate(); err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	expected, err := expectedVersion(c, in.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := templateStore(c).GetTemplates(c.Request.Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	program := models.NewProgram(in, userId, time.Now())
+
+	var missing []string
+	for _, id := range program.Templates() {
+		if !slices.ContainsFunc(templates, func(t models.Template) bool { return t.ID() == id }) {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, models.NewValidationError(fmt.Errorf("no such templates: %s", strings.Join(missing, ", ")))
+	}
+
+	saved, err := templateStore(c).SaveProgram(c.Request.Context(), program, expected, mustExist)
+	if err != nil {
+		return nil, err
+	}
+
+	setETag(c, saved.Version)
+	return models.NewProgramOut(saved), nil
+}
+
+// DeleteProgram godoc
+//
+//	@Summary		Delete training program
+//	@Description	Deletes a training program by ID. Its templates are left alone.
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@ID				deleteProgram
+//	@Param			X-App-Version	header	string	false	"Client app version"
+//	@Param			programId		path	string	true	"Program ID"
+//	@Success		204				"No Content"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/programs/{programId} [delete]
+//	@Security		BearerAuth
+func DeleteProgram(c *gin.Context, userId string) (any, error) {
+	if err := templateStore(c).DeleteProgram(c.Request.Context(), userId, c.Param("programId")); err != nil {
+		return nil, err
+	}
+
+	return models.NoContent, nil
+}
+
+// GetNextSession godoc
+//
+//	@Summary		Next session of a training program
+//	@Description	Works out which template comes next in the program, from the workouts started from its templates
+//	@Description	since the program started, taken in order. Returns it laid out as a new, unsaved workout,
+//	@Description	with the week's targets. A program that does not repeat reports when it is finished.
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@ID				getNextSession
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			programId		path		string	true	"Program ID"
+//	@Success		200				{object}	ProgramSession
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/programs/{programId}/next [get]
+//	@Security		BearerAuth
+func GetNextSession(c *gin.Context, userId string) (any, error) {
+	ctx := c.Request.Context()
+
+	program, err := templateStore(c).GetProgram(ctx, userId, c.Param("programId"))
+	if err != nil {
+		return nil, err
+	}
+
+	// a program set to start later has no sessions done yet
+	now := time.Now()
+	var workouts []models.Workout
+	if !program.Start.After(now) {
+		workouts, err = workoutStore(c).GetWorkoutsBetween(ctx, userId, program.Start, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	session := models.SessionOut{Program: program.ID(), Done: program.Sessions(workouts)}
+
+	slot, week, ok := program.Next(session.Done)
+	if !ok {
+		session.Finished = true
+		return session, nil
+	}
+	session.Week, session.Day = week, slot.Day
+
+	template, err := templateStore(c).GetTemplate(ctx, userId, slot.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	workout := program.Targets(template, week).StartWorkout(nil, now)
+	session.Workout = &workout
+
+	return session, nil
+}
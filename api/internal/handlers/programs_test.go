@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"heart/internal/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const programBody = `{"id":"p1","name":"PPL","weeks":1,"slots":[{"week":1,"day":1,"templateId":"push"},{"week":1,"day":2,"templateId":"pull"}]}`
+
+func stubProgramTemplates(db *fakeStores, ids ...string) {
+
+	db.getTemplates = func(ctx context.Context, userId string) ([]models.Template, error) {
+		var templates []models.Template
+		for _, id := range ids {
+			templates = append(templates, models.Template{SK: models.TemplateKey + id})
+		}
+		return templates, nil
+	}
+}
+
+func TestMakeProgram_Saves(t *testing.T) {
+	db := newFakeStores()
+	stubProgramTemplates(db, "push", "pull", "legs")
+
+	var mustExist bool
+	db.saveProgram = func(ctx context.Context, in models.Program, expected *int, exist bool) (*models.Program, error) {
+		mustExist = exist
+		in.Version = 1
+		return &in, nil
+	}
+
+	res, err := MakeProgram(db.attach(newGinContextWithBody("POST", "/programs", programBody)), "u1")
+
+	require.NoError(t, err)
+	assert.False(t, mustExist)
+	out := res.(models.ProgramOut)
+	assert.Equal(t, "p1", out.ID)
+	assert.Len(t, out.Slots, 2)
+	assert.WithinDuration(t, time.Now(), out.Start, time.Minute)
+}
+
+func TestMakeProgram_UnknownTemplate(t *testing.T) {
+	db := newFakeStores()
+	stubProgramTemplates(db, "push")
+
+	_, err := MakeProgram(db.attach(newGinContextWithBody("POST", "/programs", programBody)), "u1")
+
+	var validation *models.ValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.Contains(t, string(validation.JSON()), "pull")
+}
+
+func TestMakeProgram_OverlappingSlots(t *testing.T) {
+	body := `{"id":"p1","name":"PPL","weeks":1,"slots":[{"week":1,"day":1,"templateId":"push"},{"week":1,"day":1,"templateId":"pull"}]}`
+	_, err := MakeProgram(newGinContextWithBody("POST", "/programs", body), "u1")
+
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
+
+func TestUpdateProgram_OnlyOverAnExistingOne(t *testing.T) {
+	db := newFakeStores()
+	stubProgramTemplates(db, "push", "pull")
+
+	var mustExist bool
+	db.saveProgram = func(ctx context.Context, in models.Program, expected *int, exist bool) (*models.Program, error) {
+		mustExist = exist
+		return &in, nil
+	}
+
+	body := `{"name":"PPL","weeks":1,"slots":[{"week":1,"day":1,"templateId":"push"}]}`
+	c := db.attach(newGinContextWithBody("PUT", "/programs/p1", body))
+	c.Params = gin.Params{{Key: "programId", Value: "p1"}}
+	res, err := UpdateProgram(c, "u1")
+
+	require.NoError(t, err)
+	assert.True(t, mustExist)
+	assert.Equal(t, "p1", res.(models.ProgramOut).ID)
+}
+
+func stubNextSession(t *testing.T, db *fakeStores, program models.Program, workouts []models.Workout) {
+
+	db.getProgram = func(ctx context.Context, userId, programId string) (*models.Program, error) {
+		return &program, nil
+	}
+	db.getWorkoutsBetween = func(ctx context.Context, userId string, from, to time.Time) ([]models.Workout, error) {
+		assert.True(t, from.Equal(program.Start))
+		return workouts, nil
+	}
+	db.getTemplate = func(ctx context.Context, userId, templateId string) (*models.Template, error) {
+		return &models.Template{
+			SK:        models.TemplateKey + templateId,
+			Name:      templateId,
+			Exercises: []models.TemplateExercise{{ExerciseID: "Squat", Sets: []models.Set{{Weight: 100, Reps: 5}}}},
+		}, nil
+	}
+}
+
+func TestGetNextSession_AppliesTheWeeksProgression(t *testing.T) {
+	db := newFakeStores()
+	start := time.Date(2025, 7, 21, 0, 0, 0, 0, time.UTC)
+	stubNextSession(t, db, models.Program{
+		SK:          models.ProgramKey + "p1",
+		Weeks:       1,
+		Start:       start,
+		Repeat:      true,
+		Slots:       []models.ProgramSlot{{Week: 1, Day: 1, Template: "legs"}},
+		Progression: []models.Progression{{Weight: 2.5}},
+	}, []models.Workout{
+		{Start: start.Add(time.Hour), Template: "legs"},
+		{Start: start.Add(24 * time.Hour), Template: "legs"},
+	})
+
+	c := db.attach(newCtx())
+	c.Params = gin.Params{{Key: "programId", Value: "p1"}}
+	res, err := GetNextSession(c, "u1")
+
+	require.NoError(t, err)
+	session := res.(models.SessionOut)
+	assert.Equal(t, 2, session.Done)
+	assert.Equal(t, 3, session.Week)
+	require.NotNil(t, session.Workout)
+	assert.Equal(t, "legs", *session.Workout.Template)
+	assert.Equal(t, 105.0, session.Workout.Exercises[0].Sets[0].Weight)
+}
+
+func TestGetNextSession_Finished(t *testing.T) {
+	db := newFakeStores()
+	start := time.Date(2025, 7, 21, 0, 0, 0, 0, time.UTC)
+	stubNextSession(t, db, models.Program{
+		Weeks: 1,
+		Start: start,
+		Slots: []models.ProgramSlot{{Week: 1, Day: 1, Template: "legs"}},
+	}, []models.Workout{{Start: start, Template: "legs"}})
+
+	res, err := GetNextSession(db.attach(newCtx()), "u1")
+
+	require.NoError(t, err)
+	session := res.(models.SessionOut)
+	assert.True(t, session.Finished)
+	assert.Nil(t, session.Workout)
+}
+
+func TestGetNextSession_ProgramStartingLater(t *testing.T) {
+	db := newFakeStores()
+	stubNextSession(t, db, models.Program{
+		SK:    models.ProgramKey + "p1",
+		Weeks: 1,
+		Start: time.Now().AddDate(0, 0, 7),
+		Slots: []models.ProgramSlot{{Week: 1, Day: 1, Template: "legs"}},
+	}, nil)
+	db.getWorkoutsBetween = func(ctx context.Context, userId string, from, to time.Time) ([]models.Workout, error) {
+		t.Fatal("nothing to read before the program starts")
+		return nil, nil
+	}
+
+	c := db.attach(newCtx())
+	c.Params = gin.Params{{Key: "programId", Value: "p1"}}
+	res, err := GetNextSession(c, "u1")
+
+	require.NoError(t, err)
+	session := res.(models.SessionOut)
+	assert.Zero(t, session.Done)
+	assert.Equal(t, 1, session.Week)
+	require.NotNil(t, session.Workout)
+	assert.Equal(t, "legs", *session.Workout.Template)
+}
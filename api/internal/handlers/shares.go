@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"heart/internal/models"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ShareTemplate godoc
+//
+//	@Summary		Shares a template
+//	@Description	Publishes a snapshot of the template under a new code, for anyone holding it to read and copy.
+//	@Description	Later edits to the template do not reach the snapshot; share it again to publish them.
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@ID				shareTemplate
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			templateId		path		string	true	"Template ID"
+//	@Success		200				{object}	Share
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/templates/{templateId}/share [post]
+//	@Security		BearerAuth
+func ShareTemplate(c *gin.Context, userId string) (any, error) {
+	ctx := c.Request.Context()
+
+	template, err := templateStore(c).GetTemplate(ctx, userId, c.Param("templateId"))
+	if err != nil {
+		return nil, err
+	}
+
+	own, err := exerciseStore(c).GetOwnExercises(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	custom := make([]models.UserExerciseIn, len(own))
+	for i, e := range own {
+		custom[i] = models.UserExerciseIn{Name: e.Name, Category: e.Category, Target: e.Target, Instructions: e.Instructions}
+	}
+
+	shared, err := templateStore(c).ShareTemplate(ctx, template, custom)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewShareOut(shared), nil
+}
+
+// GetShares godoc
+//
+//	@Summary		Lists shared templates
+//	@Description	Returns the templates the authenticated user has shared, newest first, with how often each was copied
+//	@Tags			shared
+//	@Accept			json
+//	@Produce		json
+//	@ID				getShares
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Success		200				{object}	ShareResponse
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/shared [get]
+//	@Security		BearerAuth
+func GetShares(c *gin.Context, userId string) (any, error) {
+	shared, err := templateStore(c).GetShares(c.Request.Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.ShareResponse{
+		Shares: models.NewShareArray(shared),
+	}, nil
+}
+
+// GetSharedTemplate godoc
+//
+//	@Summary		Get shared template
+//	@Description	Returns a shared template by its code. No sign-in needed.
+//	@Tags			shared
+//	@Accept			json
+//	@Produce		json
+//	@ID				getSharedTemplate
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			code			path		string	true	"Share code"
+//	@Success		200				{object}	SharedTemplate
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/shared/{code} [get]
+func GetSharedTemplate(c *gin.Context) (any, error) {
+	shared, err := templateStore(c).GetSharedTemplate(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewSharedTemplateOut(shared), nil
+}
+
+// ImportSharedTemplate godoc
+//
+//	@Summary		Copies a shared template
+//	@Description	Copies a shared template into the templates of the authenticated user, last in the list.
+//	@Description	Custom exercises it uses that the user lacks, by name regardless of case, are made for them.
+//	@Tags			shared
+//	@Accept			json
+//	@Produce		json
+//	@ID				importSharedTemplate
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			code			path		string	true	"Share code"
+//	@Success		200				{object}	SharedImport
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/shared/{code}/import [post]
+//	@Security		BearerAuth
+func ImportSharedTemplate(c *gin.Context, userId string) (any, error) {
+	ctx := c.Request.Context()
+
+	shared, err := templateStore(c).GetSharedTemplate(ctx, c.Param("code"))
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := knownExercises(c, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	out := models.SharedImportOut{Exercises: []string{}}
+	copied := shared.Copy(userId, models.Timestamp(time.Now()), 0)
+
+	for i, e := range copied.Exercises {
+		name, ok := names[strings.ToLower(e.ExerciseID)]
+		if !ok {
+			exercise := models.UserExerciseIn{Name: e.ExerciseID, Category: models.OtherGroup, Target: models.OtherGroup}
+			for _, custom := range shared.Custom {
+				if custom.Name == e.ExerciseID {
+					exercise = custom
+				}
+			}
+
+			made, err := exerciseStore(c).MakeExercise(ctx, exercise, userId)
+			if err != nil {
+				return nil, err
+			}
+			name = made.Name
+			names[strings.ToLower(name)] = name
+			out.Exercises = append(out.Exercises, name)
+		}
+		copied.Exercises[i].ExerciseID = name
+	}
+
+	templates, err := templateStore(c).GetTemplates(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	last := -1
+	for _, t := range templates {
+		last = max(last, t.OrderInParent)
+	}
+	copied.OrderInParent = last + 1
+
+	never := 0 // the new ID must not be taken
+	saved, err := templateStore(c).SaveTemplate(ctx, copied, &never)
+	if err != nil {
+		return nil, err
+	}
+
+	// the copy is made either way; a missed count is not worth failing it over
+	if err := templateStore(c).CountSharedImport(ctx, shared.Code()); err != nil {
+		log.Printf("[ERROR] counting import of shared template %s: %v", shared.Code(), err)
+	}
+
+	setETag(c, saved.Version)
+	out.Template = models.NewTemplateOut(saved)
+	return out, nil
+}
+
+// RevokeShare godoc
+//
+//	@Summary		Revoke shared template
+//	@Description	Takes a shared template of the authenticated user down. Copies already made are left alone.
+//	@Tags			shared
+//	@Accept			json
+//	@Produce		json
+//	@ID				revokeShare
+//	@Param			X-App-Version	header	string	false	"Client app version"
+//	@Param			code			path	string	true	"Share code"
+//	@Success		204				"No Content"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/shared/{code} [delete]
+//	@Security		BearerAuth
+func RevokeShare(c *gin.Context, userId string) (any, error) {
+	if err := templateStore(c).RevokeShare(c.Request.Context(), userId, c.Param("code")); err != nil {
+		return nil, err
+	}
+
+	return models.NoContent, nil
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"heart/internal/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareTemplate_SnapshotsOwnExercises(t *testing.T) {
+	db := newFakeStores()
+
+	db.getTemplate = func(ctx context.Context, userId, id string) (*models.Template, error) {
+		return &models.Template{PK: "USER#" + userId, SK: "TEMPLATE#" + id, Name: "Upper body"}, nil
+	}
+	db.getOwnExercises = func(ctx context.Context, userId string) ([]models.Exercise, error) {
+		return []models.Exercise{{Name: "Band Pull Apart", Category: "Bands", Target: "Back"}}, nil
+	}
+	var custom []models.UserExerciseIn
+	db.shareTemplate = func(ctx context.Context, template *models.Template, own []models.UserExerciseIn) (*models.SharedTemplate, error) {
+		custom = own
+		shared, _ := models.NewSharedTemplate(template, own, "abc123", time.Now())
+		return &shared, nil
+	}
+
+	c := db.attach(newCtx())
+	c.Params = gin.Params{{Key: "templateId", Value: "t1"}}
+	res, err := ShareTemplate(c, "coach")
+
+	require.NoError(t, err)
+	out := res.(models.ShareOut)
+	assert.Equal(t, "abc123", out.Code)
+	assert.Equal(t, "t1", out.TemplateID)
+	assert.Equal(t, []models.UserExerciseIn{{Name: "Band Pull Apart", Category: "Bands", Target: "Back"}}, custom)
+}
+
+func TestGetSharedTemplate_NotFound(t *testing.T) {
+	db := newFakeStores()
+	db.getSharedTemplate = func(ctx context.Context, code string) (*models.SharedTemplate, error) {
+		return nil, models.NewNotFoundError("Shared template not found", nil)
+	}
+
+	c := db.attach(newCtx())
+	c.Params = gin.Params{{Key: "code", Value: "nope"}}
+	res, err := GetSharedTemplate(c)
+
+	assert.Nil(t, res)
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestImportSharedTemplate_MakesMissingExercises(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+
+	db.getSharedTemplate = func(ctx context.Context, code string) (*models.SharedTemplate, error) {
+		return &models.SharedTemplate{
+			PK:    "SHARED#" + code,
+			Owner: "coach",
+			Name:  "Upper body",
+			Exercises: []models.TemplateExercise{
+				{ID: "1", ExerciseID: "Bench Press"},
+				{ID: "2", ExerciseID: "Band Pull Apart"},
+			},
+			Custom: []models.UserExerciseIn{{Name: "Band Pull Apart", Category: "Bands", Target: "Back"}},
+		}, nil
+	}
+	db.getExercises = func(ctx context.Context) ([]models.Exercise, error) {
+		return []models.Exercise{{Name: "Bench press"}}, nil
+	}
+	db.getOwnExercises = func(ctx context.Context, userId string) ([]models.Exercise, error) { return nil, nil }
+	var made []models.UserExerciseIn
+	db.makeExercise = func(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
+		made = append(made, in)
+		return &in, nil
+	}
+	db.getTemplates = func(ctx context.Context, userId string) ([]models.Template, error) {
+		return []models.Template{{OrderInParent: 0}, {OrderInParent: 2}}, nil
+	}
+	var expected *int
+	db.saveTemplate = func(ctx context.Context, in models.Template, exp *int) (*models.Template, error) {
+		expected = exp
+		in.Version = 1
+		return &in, nil
+	}
+	var counted string
+	db.countSharedImport = func(ctx context.Context, code string) error {
+		counted = code
+		return nil
+	}
+
+	c := db.attach(newCtx())
+	c.Params = gin.Params{{Key: "code", Value: "abc123"}}
+	res, err := ImportSharedTemplate(c, "client")
+
+	require.NoError(t, err)
+	out := res.(models.SharedImportOut)
+	assert.Equal(t, []string{"Band Pull Apart"}, out.Exercises)
+	assert.Equal(t, []models.UserExerciseIn{{Name: "Band Pull Apart", Category: "Bands", Target: "Back"}}, made)
+	assert.Equal(t, "Bench press", out.Template.Exercises[0].ExerciseID, "spelled the way the client knows it")
+	assert.Equal(t, 3, out.Template.Order)
+	assert.Equal(t, 0, *expected)
+	assert.Equal(t, "abc123", counted)
+}
+
+func TestRevokeShare_Success(t *testing.T) {
+	db := newFakeStores()
+	var revoked string
+	db.revokeShare = func(ctx context.Context, userId, code string) error {
+		revoked = userId + "/" + code
+		return nil
+	}
+
+	c := db.attach(newCtx())
+	c.Params = gin.Params{{Key: "code", Value: "abc123"}}
+	res, err := RevokeShare(c, "coach")
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.NoContent, res)
+	assert.Equal(t, "coach/abc123", revoked)
+}
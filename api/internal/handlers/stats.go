@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"heart/internal/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultStatsWeeks is how far back stats look when not given a start.
+const defaultStatsWeeks = 12
+
+// maxStatsYears caps the range stats are asked for, as every workout in it is read at once.
+const maxStatsYears = 2
+
+// GetStats godoc
+//
+//	@Summary		Training stats
+//	@Description	Aggregates completed sets, warm-ups aside, over a date range: tonnage, set counts, duration, distance and rest,
+//	@Description	by exercise target and category, by superset, circuit or giant set, and by week. Defaults to the last 12 weeks, and spans two years at most.
+//	@Tags			stats
+//	@Accept			json
+//	@Produce		json
+//	@ID				getStats
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			X-Timezone		header		string	false	"IANA time zone the weeks are counted in, UTC by default"
+//	@Param			from			query		string	false	"Start date, 2006-01-02 or RFC 3339"
+//	@Param			to				query		string	false	"End date, inclusive, 2006-01-02 or RFC 3339"
+//	@Success		200				{object}	Stats
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/stats [get]
+//	@Security		BearerAuth
+func GetStats(c *gin.Context, userId string) (any, error) {
+	loc, err := timezone(c)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := dateParam(c, "to", loc, true)
+	if err != nil {
+		return nil, err
+	}
+	if to.IsZero() {
+		to = time.Now().In(loc)
+	}
+
+	from, err := dateParam(c, "from", loc, false)
+	if err != nil {
+		return nil, err
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -7*defaultStatsWeeks)
+	}
+
+	if from.After(to) {
+		return nil, models.NewValidationError(errors.New("from must not be after to"))
+	}
+	if !to.Before(from.AddDate(maxStatsYears, 0, 1)) { // to runs to the end of its day
+		return nil, models.NewValidationError(fmt.Errorf("from and to must be at most %d years apart", maxStatsYears))
+	}
+
+	ctx := c.Request.Context()
+
+	workouts, err := workoutStore(c).GetWorkoutsBetween(ctx, userId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog, _, err := exerciseCatalog(c).get(ctx, exerciseStore(c))
+	if err != nil {
+		return nil, err
+	}
+
+	own, err := exerciseStore(c).GetOwnExercises(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	exercises := make(map[string]models.Exercise, len(catalog)+len(own))
+	for _, e := range catalog {
+		exercises[e.Name] = e
+	}
+	for _, e := range own {
+		exercises[e.Name] = e
+	}
+
+	aliases, err := exerciseStore(c).GetAliases(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	byName := models.NewAliases(aliases)
+	for i := range workouts {
+		byName.Apply(&workouts[i])
+	}
+
+	return models.NewStats(workouts, exercises, from, to, loc), nil
+}
+
+// timezone reads the client's zone from X-Timezone.
+func timezone(c *gin.Context) (*time.Location, error) {
+	name := c.GetHeader("X-Timezone")
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, models.NewValidationError(fmt.Errorf("unknown time zone %q", name))
+	}
+
+	return loc, nil
+}
+
+// dateParam reads a query parameter given either as a date or as an RFC 3339 timestamp.
+// A date is taken in loc, at its first instant or, for the end of a range, its last.
+// Returns the zero time when the parameter is absent.
+func dateParam(c *gin.Context, name string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	day, err := time.ParseInLocation(time.DateOnly, value, loc)
+	if err != nil {
+		return time.Time{}, models.NewValidationError(fmt.Errorf("%s must be a date or an RFC 3339 timestamp", name))
+	}
+
+	if endOfDay {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return day, nil
+}
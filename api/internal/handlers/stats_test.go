@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"heart/internal/models"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubStats(db *fakeStores, workouts func(from, to time.Time) []models.Workout) {
+
+	db.getWorkoutsBetween = func(ctx context.Context, userId string, from, to time.Time) ([]models.Workout, error) {
+		return workouts(from, to), nil
+	}
+	db.getExercises = func(ctx context.Context) ([]models.Exercise, error) {
+		return []models.Exercise{{Name: "Squat", Target: "Legs", Category: "Barbell"}}, nil
+	}
+	db.getOwnExercises = func(ctx context.Context, userId string) ([]models.Exercise, error) {
+		// own exercises win over the catalog
+		return []models.Exercise{{Name: "Squat", Target: "Quads", Category: "Barbell"}}, nil
+	}
+}
+
+func TestGetStats_DateRangeInClientZone(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	var gotFrom, gotTo time.Time
+	stubStats(db, func(from, to time.Time) []models.Workout {
+		gotFrom, gotTo = from, to
+		return []models.Workout{{
+			Start:     time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC),
+			Exercises: []models.WorkoutExercise{{ExerciseID: "Squat", Sets: []models.Set{{Completed: true, Weight: 100, Reps: 5}}}},
+		}}
+	})
+
+	c := db.attach(newCtx())
+	c.Request = httptest.NewRequest("GET", "/stats?from=2025-03-01&to=2025-03-31", nil)
+	c.Request.Header.Set("X-Timezone", "America/Toronto")
+
+	res, err := GetStats(c, "u1")
+	require.NoError(t, err)
+
+	toronto, _ := time.LoadLocation("America/Toronto")
+	assert.True(t, gotFrom.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, toronto)))
+	assert.True(t, gotTo.Equal(time.Date(2025, 4, 1, 0, 0, 0, 0, toronto).Add(-time.Nanosecond)))
+
+	stats := res.(models.StatsResponse)
+	assert.Equal(t, 500.0, stats.Totals.Tonnage)
+	require.Len(t, stats.ByTarget, 1)
+	assert.Equal(t, "Quads", stats.ByTarget[0].Name)
+}
+
+func TestGetStats_DefaultsToRecentWeeks(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	var gotFrom, gotTo time.Time
+	stubStats(db, func(from, to time.Time) []models.Workout {
+		gotFrom, gotTo = from, to
+		return nil
+	})
+
+	c := db.attach(newCtx())
+	c.Request = httptest.NewRequest("GET", "/stats", nil)
+
+	_, err := GetStats(c, "u1")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), gotTo, time.Minute)
+	assert.Equal(t, 12*7*24*time.Hour, gotTo.Sub(gotFrom))
+}
+
+func TestGetStats_AllowsTwoYearsAtMost(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	stubStats(db, func(from, to time.Time) []models.Workout { return nil })
+
+	c := db.attach(newCtx())
+	c.Request = httptest.NewRequest("GET", "/stats?from=2023-01-01&to=2025-01-01", nil)
+	_, err := GetStats(c, "u1")
+	require.NoError(t, err)
+
+	c = db.attach(newCtx())
+	c.Request = httptest.NewRequest("GET", "/stats?from=2023-01-01&to=2025-01-02", nil)
+	_, err = GetStats(c, "u1")
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
+
+func TestGetStats_Validation(t *testing.T) {
+	for _, target := range []string{"/stats?from=March", "/stats?from=2025-04-01&to=2025-03-01", "/stats?from=2020-01-01&to=2025-01-01"} {
+		c := newCtx()
+		c.Request = httptest.NewRequest("GET", target, nil)
+		_, err := GetStats(c, "u1")
+		var validation *models.ValidationError
+		assert.ErrorAs(t, err, &validation, target)
+	}
+
+	c := newCtx()
+	c.Request = httptest.NewRequest("GET", "/stats", nil)
+	c.Request.Header.Set("X-Timezone", "Mars/Olympus")
+	_, err := GetStats(c, "u1")
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"heart/internal/awsx"
+	"heart/internal/dbx"
+	"heart/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the stores and services the router hands down with every request
+
+func workoutStore(c *gin.Context) dbx.WorkoutStore {
+	return middleware.StoresFrom(c).Workouts
+}
+
+func exerciseStore(c *gin.Context) dbx.ExerciseStore {
+	return middleware.StoresFrom(c).Exercises
+}
+
+func templateStore(c *gin.Context) dbx.TemplateStore {
+	return middleware.StoresFrom(c).Templates
+}
+
+func accountStore(c *gin.Context) dbx.AccountStore {
+	return middleware.StoresFrom(c).Accounts
+}
+
+func objects(c *gin.Context) awsx.Objects {
+	return middleware.ServicesFrom(c).Objects
+}
+
+func jobs(c *gin.Context) awsx.Jobs {
+	return middleware.ServicesFrom(c).Jobs
+}
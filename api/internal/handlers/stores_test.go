@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"heart/internal/awsx"
+	"heart/internal/dbx"
+	"heart/internal/localdb"
+	"heart/internal/middleware"
+	"heart/internal/models"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeStores stands in for the data stores, and the services, of a test. Methods the test sets
+// a function for call it; the rest go to an empty in-memory store, or fail for services.
+type fakeStores struct {
+	*localdb.Store
+
+	// WorkoutStore
+	getWorkout         func(ctx context.Context, userId string, workoutId string) (*models.Workout, error)
+	saveWorkout        func(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error)
+	importWorkouts     func(ctx context.Context, workouts []models.Workout) (int, error)
+	deleteWorkout      func(ctx context.Context, userId string, workoutId string) error
+	getWorkouts        func(ctx context.Context, userId string, limit int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error)
+	getWorkoutsBetween func(ctx context.Context, userId string, from, to time.Time) ([]models.Workout, error)
+	removeWorkoutImage func(ctx context.Context, userId, workoutId, imageId string) error
+	getWorkoutGallery  func(ctx context.Context, userId string, limit int, cursor string) ([]models.ImageOut, *string, error)
+	getExerciseHistory func(ctx context.Context, userId string, exercise string, limit int, cursor string) ([]models.HistoryEntry, string, error)
+	getRecords         func(ctx context.Context, userId string, exercise string) (*models.PersonalRecords, error)
+	updateRecords      func(ctx context.Context, userId string, workout *models.Workout) (map[string][]models.RecordKind, error)
+	applyRecords       func(ctx context.Context, userId string, workouts []models.Workout) error
+
+	// ExerciseStore
+	getExercises    func(ctx context.Context) ([]models.Exercise, error)
+	makeExercise    func(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error)
+	getOwnExercises func(ctx context.Context, userId string) ([]models.Exercise, error)
+	getOwnExercise  func(ctx context.Context, userId string, name string) (*models.Exercise, error)
+	editExercise    func(ctx context.Context, userId string, exerciseName string, in models.EditExerciseIn) (*models.Exercise, error)
+	renameExercise  func(ctx context.Context, userId string, from string, to string) (*models.Exercise, error)
+	deleteExercise  func(ctx context.Context, userId string, name string, cascade bool) (*models.Exercise, error)
+	mergeExercise   func(ctx context.Context, userId string, from string, to string) (*models.Exercise, error)
+	getAliases      func(ctx context.Context, userId string) ([]models.ExerciseAlias, error)
+
+	// TemplateStore
+	getTemplates      func(ctx context.Context, userId string) ([]models.Template, error)
+	getTemplate       func(ctx context.Context, userId string, templateId string) (*models.Template, error)
+	saveTemplate      func(ctx context.Context, in models.Template, expected *int) (*models.Template, error)
+	updateTemplate    func(ctx context.Context, in models.Template, expected *int) (*models.Template, error)
+	recordTemplateUse func(ctx context.Context, template *models.Template, workout *models.Workout) error
+	reorderTemplates  func(ctx context.Context, userId string, templateIds []string) error
+	deleteTemplate    func(ctx context.Context, userId string, templateId string) error
+	getPrograms       func(ctx context.Context, userId string) ([]models.Program, error)
+	getProgram        func(ctx context.Context, userId string, programId string) (*models.Program, error)
+	saveProgram       func(ctx context.Context, in models.Program, expected *int, mustExist bool) (*models.Program, error)
+	deleteProgram     func(ctx context.Context, userId string, programId string) error
+	shareTemplate     func(ctx context.Context, template *models.Template, custom []models.UserExerciseIn) (*models.SharedTemplate, error)
+	getSharedTemplate func(ctx context.Context, code string) (*models.SharedTemplate, error)
+	getShares         func(ctx context.Context, userId string) ([]models.SharedTemplate, error)
+	countSharedImport func(ctx context.Context, code string) error
+	revokeShare       func(ctx context.Context, userId string, code string) error
+
+	// AccountStore
+	saveAccount                func(ctx context.Context, userId string, in models.User) (*models.User, error)
+	getAccount                 func(ctx context.Context, userId string) (*models.User, error)
+	scheduleAccountForDeletion func(ctx context.Context, userId string, scheduleArn string, when int64) error
+	undoAccountDeletion        func(ctx context.Context, userId string) error
+	removeAvatar               func(ctx context.Context, userId string) error
+	getAccountItemKeys         func(ctx context.Context, userId string) ([]models.ItemKey, error)
+	deleteItems                func(ctx context.Context, keys []models.ItemKey) (int, error)
+	getDataExport              func(ctx context.Context, userId string) (*models.DataExport, error)
+	saveDataExport             func(ctx context.Context, in models.DataExport) (*models.DataExport, error)
+	getChanges                 func(ctx context.Context, userId string, since *models.SyncToken, until time.Time, limit int) (*models.Changes, *models.SyncToken, bool, error)
+
+	// awsx.Services
+	presignPostURL   func(ctx context.Context, bucket string, key string, contentType string, tagging *map[string]string) (*s3.PresignedPostRequest, error)
+	presignGetURL    func(ctx context.Context, bucket string, key string, expires time.Duration) (string, error)
+	deleteObject     func(ctx context.Context, bucket string, key string) (*s3.DeleteObjectOutput, error)
+	invokeBackground func(ctx context.Context, event string, payload any) error
+	createSchedule   func(ctx context.Context, userId string) (*time.Time, *string, error)
+	deleteSchedule   func(ctx context.Context, scheduleArn *string) error
+	sendToMonitoring func(ctx context.Context, message any) error
+}
+
+func newFakeStores() *fakeStores {
+	return &fakeStores{Store: localdb.NewMemory()}
+}
+
+// attach hands the stores and services to the handlers run with the context, as the router would,
+// along with a catalog read afresh every time, since tests stub it one by one.
+func (f *fakeStores) attach(c *gin.Context) *gin.Context {
+	middleware.SetStores(c, dbx.NewStores(f))
+	middleware.SetServices(c, awsx.Services{Objects: f, Jobs: f})
+	c.Set(catalogKey, &catalogCache{})
+	return c
+}
+
+// errNotStubbed fails the services a test calls without setting a function for them.
+var errNotStubbed = errors.New("not stubbed in this test")
+
+func (f *fakeStores) GeneratePresignedPostURL(ctx context.Context, bucket string, key string, contentType string, tagging *map[string]string) (*s3.PresignedPostRequest, error) {
+	if f.presignPostURL != nil {
+		return f.presignPostURL(ctx, bucket, key, contentType, tagging)
+	}
+	return nil, errNotStubbed
+}
+
+func (f *fakeStores) GeneratePresignedGetURL(ctx context.Context, bucket string, key string, expires time.Duration) (string, error) {
+	if f.presignGetURL != nil {
+		return f.presignGetURL(ctx, bucket, key, expires)
+	}
+	return "", errNotStubbed
+}
+
+func (f *fakeStores) DeleteObject(ctx context.Context, bucket string, key string) (*s3.DeleteObjectOutput, error) {
+	if f.deleteObject != nil {
+		return f.deleteObject(ctx, bucket, key)
+	}
+	return nil, errNotStubbed
+}
+
+func (f *fakeStores) InvokeBackground(ctx context.Context, event string, payload any) error {
+	if f.invokeBackground != nil {
+		return f.invokeBackground(ctx, event, payload)
+	}
+	return errNotStubbed
+}
+
+func (f *fakeStores) CreateAccountDeletionSchedule(ctx context.Context, userId string) (*time.Time, *string, error) {
+	if f.createSchedule != nil {
+		return f.createSchedule(ctx, userId)
+	}
+	return nil, nil, errNotStubbed
+}
+
+func (f *fakeStores) DeleteAccountDeletionSchedule(ctx context.Context, scheduleArn *string) error {
+	if f.deleteSchedule != nil {
+		return f.deleteSchedule(ctx, scheduleArn)
+	}
+	return errNotStubbed
+}
+
+func (f *fakeStores) SendToMonitoring(ctx context.Context, message any) error {
+	if f.sendToMonitoring != nil {
+		return f.sendToMonitoring(ctx, message)
+	}
+	return errNotStubbed
+}
+
+func (f *fakeStores) GetWorkout(ctx context.Context, userId string, workoutId string) (*models.Workout, error) {
+	if f.getWorkout != nil {
+		return f.getWorkout(ctx, userId, workoutId)
+	}
+	return f.Store.GetWorkout(ctx, userId, workoutId)
+}
+
+func (f *fakeStores) SaveWorkout(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error) {
+	if f.saveWorkout != nil {
+		return f.saveWorkout(ctx, in, expected)
+	}
+	return f.Store.SaveWorkout(ctx, in, expected)
+}
+
+func (f *fakeStores) ImportWorkouts(ctx context.Context, workouts []models.Workout) (int, error) {
+	if f.importWorkouts != nil {
+		return f.importWorkouts(ctx, workouts)
+	}
+	return f.Store.ImportWorkouts(ctx, workouts)
+}
+
+func (f *fakeStores) DeleteWorkout(ctx context.Context, userId string, workoutId string) error {
+	if f.deleteWorkout != nil {
+		return f.deleteWorkout(ctx, userId, workoutId)
+	}
+	return f.Store.DeleteWorkout(ctx, userId, workoutId)
+}
+
+func (f *fakeStores) GetWorkouts(ctx context.Context, userId string, limit int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error) {
+	if f.getWorkouts != nil {
+		return f.getWorkouts(ctx, userId, limit, cursor, filter)
+	}
+	return f.Store.GetWorkouts(ctx, userId, limit, cursor, filter)
+}
+
+func (f *fakeStores) GetWorkoutsBetween(ctx context.Context, userId string, from, to time.Time) ([]models.Workout, error) {
+	if f.getWorkoutsBetween != nil {
+		return f.getWorkoutsBetween(ctx, userId, from, to)
+	}
+	return f.Store.GetWorkoutsBetween(ctx, userId, from, to)
+}
+
+func (f *fakeStores) RemoveWorkoutImage(ctx context.Context, userId, workoutId, imageId string) error {
+	if f.removeWorkoutImage != nil {
+		return f.removeWorkoutImage(ctx, userId, workoutId, imageId)
+	}
+	return f.Store.RemoveWorkoutImage(ctx, userId, workoutId, imageId)
+}
+
+func (f *fakeStores) GetWorkoutGallery(ctx context.Context, userId string, limit int, cursor string) ([]models.ImageOut, *string, error) {
+	if f.getWorkoutGallery != nil {
+		return f.getWorkoutGallery(ctx, userId, limit, cursor)
+	}
+	return f.Store.GetWorkoutGallery(ctx, userId, limit, cursor)
+}
+
+func (f *fakeStores) GetExerciseHistory(ctx context.Context, userId string, exercise string, limit int, cursor string) ([]models.HistoryEntry, string, error) {
+	if f.getExerciseHistory != nil {
+		return f.getExerciseHistory(ctx, userId, exercise, limit, cursor)
+	}
+	return f.Store.GetExerciseHistory(ctx, userId, exercise, limit, cursor)
+}
+
+func (f *fakeStores) GetRecords(ctx context.Context, userId string, exercise string) (*models.PersonalRecords, error) {
+	if f.getRecords != nil {
+		return f.getRecords(ctx, userId, exercise)
+	}
+	return f.Store.GetRecords(ctx, userId, exercise)
+}
+
+func (f *fakeStores) UpdateRecords(ctx context.Context, userId string, workout *models.Workout) (map[string][]models.RecordKind, error) {
+	if f.updateRecords != nil {
+		return f.updateRecords(ctx, userId, workout)
+	}
+	return f.Store.UpdateRecords(ctx, userId, workout)
+}
+
+func (f *fakeStores) ApplyRecords(ctx context.Context, userId string, workouts []models.Workout) error {
+	if f.applyRecords != nil {
+		return f.applyRecords(ctx, userId, workouts)
+	}
+	return f.Store.ApplyRecords(ctx, userId, workouts)
+}
+
+func (f *fakeStores) GetExercises(ctx context.Context) ([]models.Exercise, error) {
+	if f.getExercises != nil {
+		return f.getExercises(ctx)
+	}
+	return f.Store.GetExercises(ctx)
+}
+
+func (f *fakeStores) MakeExercise(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
+	if f.makeExercise != nil {
+		return f.makeExercise(ctx, in, userId)
+	}
+	return f.Store.MakeExercise(ctx, in, userId)
+}
+
+func (f *fakeStores) GetOwnExercises(ctx context.Context, userId string) ([]models.Exercise, error) {
+	if f.getOwnExercises != nil {
+		return f.getOwnExercises(ctx, userId)
+	}
+	return f.Store.GetOwnExercises(ctx, userId)
+}
+
+func (f *fakeStores) GetOwnExercise(ctx context.Context, userId string, name string) (*models.Exercise, error) {
+	if f.getOwnExercise != nil {
+		return f.getOwnExercise(ctx, userId, name)
+	}
+	return f.Store.GetOwnExercise(ctx, userId, name)
+}
+
+func (f *fakeStores) EditExercise(ctx context.Context, userId string, exerciseName string, in models.EditExerciseIn) (*models.Exercise, error) {
+	if f.editExercise != nil {
+		return f.editExercise(ctx, userId, exerciseName, in)
+	}
+	return f.Store.EditExercise(ctx, userId, exerciseName, in)
+}
+
+func (f *fakeStores) RenameExercise(ctx context.Context, userId string, from string, to string) (*models.Exercise, error) {
+	if f.renameExercise != nil {
+		return f.renameExercise(ctx, userId, from, to)
+	}
+	return f.Store.RenameExercise(ctx, userId, from, to)
+}
+
+func (f *fakeStores) DeleteExercise(ctx context.Context, userId string, name string, cascade bool) (*models.Exercise, error) {
+	if f.deleteExercise != nil {
+		return f.deleteExercise(ctx, userId, name, cascade)
+	}
+	return f.Store.DeleteExercise(ctx, userId, name, cascade)
+}
+
+func (f *fakeStores) MergeExercise(ctx context.Context, userId string, from string, to string) (*models.Exercise, error) {
+	if f.mergeExercise != nil {
+		return f.mergeExercise(ctx, userId, from, to)
+	}
+	return f.Store.MergeExercise(ctx, userId, from, to)
+}
+
+func (f *fakeStores) GetAliases(ctx context.Context, userId string) ([]models.ExerciseAlias, error) {
+	if f.getAliases != nil {
+		return f.getAliases(ctx, userId)
+	}
+	return f.Store.GetAliases(ctx, userId)
+}
+
+func (f *fakeStores) GetTemplates(ctx context.Context, userId string) ([]models.Template, error) {
+	if f.getTemplates != nil {
+		return f.getTemplates(ctx, userId)
+	}
+	return f.Store.GetTemplates(ctx, userId)
+}
+
+func (f *fakeStores) GetTemplate(ctx context.Context, userId string, templateId string) (*models.Template, error) {
+	if f.getTemplate != nil {
+		return f.getTemplate(ctx, userId, templateId)
+	}
+	return f.Store.GetTemplate(ctx, userId, templateId)
+}
+
+func (f *fakeStores) SaveTemplate(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+	if f.saveTemplate != nil {
+		return f.saveTemplate(ctx, in, expected)
+	}
+	return f.Store.SaveTemplate(ctx, in, expected)
+}
+
+func (f *fakeStores) UpdateTemplate(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+	if f.updateTemplate != nil {
+		return f.updateTemplate(ctx, in, expected)
+	}
+	return f.Store.UpdateTemplate(ctx, in, expected)
+}
+
+func (f *fakeStores) RecordTemplateUse(ctx context.Context, template *models.Template, workout *models.Workout) error {
+	if f.recordTemplateUse != nil {
+		return f.recordTemplateUse(ctx, template, workout)
+	}
+	return f.Store.RecordTemplateUse(ctx, template, workout)
+}
+
+func (f *fakeStores) ReorderTemplates(ctx context.Context, userId string, templateIds []string) error {
+	if f.reorderTemplates != nil {
+		return f.reorderTemplates(ctx, userId, templateIds)
+	}
+	return f.Store.ReorderTemplates(ctx, userId, templateIds)
+}
+
+func (f *fakeStores) DeleteTemplate(ctx context.Context, userId string, templateId string) error {
+	if f.deleteTemplate != nil {
+		return f.deleteTemplate(ctx, userId, templateId)
+	}
+	return f.Store.DeleteTemplate(ctx, userId, templateId)
+}
+
+func (f *fakeStores) GetPrograms(ctx context.Context, userId string) ([]models.Program, error) {
+	if f.getPrograms != nil {
+		return f.getPrograms(ctx, userId)
+	}
+	return f.Store.GetPrograms(ctx, userId)
+}
+
+func (f *fakeStores) GetProgram(ctx context.Context, userId string, programId string) (*models.Program, error) {
+	if f.getProgram != nil {
+		return f.getProgram(ctx, userId, programId)
+	}
+	return f.Store.GetProgram(ctx, userId, programId)
+}
+
+func (f *fakeStores) SaveProgram(ctx context.Context, in models.Program, expected *int, mustExist bool) (*models.Program, error) {
+	if f.saveProgram != nil {
+		return f.saveProgram(ctx, in, expected, mustExist)
+	}
+	return f.Store.SaveProgram(ctx, in, expected, mustExist)
+}
+
+func (f *fakeStores) DeleteProgram(ctx context.Context, userId string, programId string) error {
+	if f.deleteProgram != nil {
+		return f.deleteProgram(ctx, userId, programId)
+	}
+	return f.Store.DeleteProgram(ctx, userId, programId)
+}
+
+func (f *fakeStores) ShareTemplate(ctx context.Context, template *models.Template, custom []models.UserExerciseIn) (*models.SharedTemplate, error) {
+	if f.shareTemplate != nil {
+		return f.shareTemplate(ctx, template, custom)
+	}
+	return f.Store.ShareTemplate(ctx, template, custom)
+}
+
+func (f *fakeStores) GetSharedTemplate(ctx context.Context, code string) (*models.SharedTemplate, error) {
+	if f.getSharedTemplate != nil {
+		return f.getSharedTemplate(ctx, code)
+	}
+	return f.Store.GetSharedTemplate(ctx, code)
+}
+
+func (f *fakeStores) GetShares(ctx context.Context, userId string) ([]models.SharedTemplate, error) {
+	if f.getShares != nil {
+		return f.getShares(ctx, userId)
+	}
+	return f.Store.GetShares(ctx, userId)
+}
+
+func (f *fakeStores) CountSharedImport(ctx context.Context, code string) error {
+	if f.countSharedImport != nil {
+		return f.countSharedImport(ctx, code)
+	}
+	return f.Store.CountSharedImport(ctx, code)
+}
+
+func (f *fakeStores) RevokeShare(ctx context.Context, userId string, code string) error {
+	if f.revokeShare != nil {
+		return f.revokeShare(ctx, userId, code)
+	}
+	return f.Store.RevokeShare(ctx, userId, code)
+}
+
+func (f *fakeStores) SaveAccount(ctx context.Context, userId string, in models.User) (*models.User, error) {
+	if f.saveAccount != nil {
+		return f.saveAccount(ctx, userId, in)
+	}
+	return f.Store.SaveAccount(ctx, userId, in)
+}
+
+func (f *fakeStores) GetAccount(ctx context.Context, userId string) (*models.User, error) {
+	if f.getAccount != nil {
+		return f.getAccount(ctx, userId)
+	}
+	return f.Store.GetAccount(ctx, userId)
+}
+
+func (f *fakeStores) ScheduleAccountForDeletion(ctx context.Context, userId string, scheduleArn string, when int64) error {
+	if f.scheduleAccountForDeletion != nil {
+		return f.scheduleAccountForDeletion(ctx, userId, scheduleArn, when)
+	}
+	return f.Store.ScheduleAccountForDeletion(ctx, userId, scheduleArn, when)
+}
+
+func (f *fakeStores) UndoAccountDeletion(ctx context.Context, userId string) error {
+	if f.undoAccountDeletion != nil {
+		return f.undoAccountDeletion(ctx, userId)
+	}
+	return f.Store.UndoAccountDeletion(ctx, userId)
+}
+
+func (f *fakeStores) RemoveAvatar(ctx context.Context, userId string) error {
+	if f.removeAvatar != nil {
+		return f.removeAvatar(ctx, userId)
+	}
+	return f.Store.RemoveAvatar(ctx, userId)
+}
+
+func (f *fakeStores) GetAccountItemKeys(ctx context.Context, userId string) ([]models.ItemKey, error) {
+	if f.getAccountItemKeys != nil {
+		return f.getAccountItemKeys(ctx, userId)
+	}
+	return f.Store.GetAccountItemKeys(ctx, userId)
+}
+
+func (f *fakeStores) DeleteItems(ctx context.Context, keys []models.ItemKey) (int, error) {
+	if f.deleteItems != nil {
+		return f.deleteItems(ctx, keys)
+	}
+	return f.Store.DeleteItems(ctx, keys)
+}
+
+func (f *fakeStores) GetDataExport(ctx context.Context, userId string) (*models.DataExport, error) {
+	if f.getDataExport != nil {
+		return f.getDataExport(ctx, userId)
+	}
+	return f.Store.GetDataExport(ctx, userId)
+}
+
+func (f *fakeStores) SaveDataExport(ctx context.Context, in models.DataExport) (*models.DataExport, error) {
+	if f.saveDataExport != nil {
+		return f.saveDataExport(ctx, in)
+	}
+	return f.Store.SaveDataExport(ctx, in)
+}
+
+func (f *fakeStores) GetChanges(ctx context.Context, userId string, since *models.SyncToken, until time.Time, limit int) (*models.Changes, *models.SyncToken, bool, error) {
+	if f.getChanges != nil {
+		return f.getChanges(ctx, userId, since, until, limit)
+	}
+	return f.Store.GetChanges(ctx, userId, since, until, limit)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"heart/internal/config"
+	"heart/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// syncLag holds back the most recent changes so that writes still settling in the index
+// are picked up by the next sync instead of being skipped over.
+const syncLag = 2 * time.Second
+
+// GetChanges godoc
+//
+//	@Summary		Returns changes since the last sync
+//	@Description	Returns workouts, templates and own exercises saved since the token, and those deleted, oldest first.
+//	@Description	Omit the token to download everything. Keep calling with the returned token while hasMore is true.
+//	@Description	When reset is true, the token was too old and the client must drop its local copy before applying the page.
+//	@Tags			sync
+//	@Accept			json
+//	@Produce		json
+//	@ID				getChanges
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			since			query		string	false	"Token returned by the previous sync"
+//	@Param			pageSize		query		integer	false	"Page size for pagination"
+//	@Success		200				{object}	SyncResponse
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/sync [get]
+//	@Security		BearerAuth
+func GetChanges(c *gin.Context, userId string) (any, error) {
+	pageSize := 100
+	if size := c.Query("pageSize"); size != "" {
+		if parsed, err := strconv.Atoi(size); err == nil && parsed > 0 {
+			pageSize = parsed
+		}
+	}
+
+	now := time.Now().UTC()
+	until := now.Add(-syncLag)
+
+	var since *models.SyncToken
+	reset := false
+
+	if raw := c.Query("since"); raw != "" {
+		token, err := models.DecodeSyncToken(raw)
+		if err != nil {
+			return nil, models.NewValidationError(err)
+		}
+
+		at, _ := token.Time()
+		switch {
+		case now.Sub(at) > models.TombstoneRetention:
+			// deletions this old are forgotten, start over
+			reset = true
+		case at.After(until):
+			return emptySync(token), nil
+		default:
+			since = token
+		}
+	} else {
+		reset = true
+	}
+
+	changes, next, more, err := accountStore(c).GetChanges(c.Request.Context(), userId, since, until, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	out := models.NewSyncResponse(changes, config.App.MediaDistributionAlias)
+	out.Token = next.Encode()
+	out.HasMore = more
+	out.Reset = reset
+
+	return out, nil
+}
+
+func emptySync(token *models.SyncToken) models.SyncResponse {
+	out := models.NewSyncResponse(&models.Changes{}, "")
+	out.Token = token.Encode()
+	return out
+}
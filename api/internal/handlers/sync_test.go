@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"heart/internal/models"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSyncCtx(target string) *gin.Context {
+	c := newCtx()
+	c.Request = httptest.NewRequest("GET", target, nil)
+	return c
+}
+
+func TestGetChanges_FirstSyncReadsEverything(t *testing.T) {
+	db := newFakeStores()
+
+	var gotSince *models.SyncToken
+	var gotLimit int
+	db.getChanges = func(ctx context.Context, userId string, since *models.SyncToken, until time.Time, limit int) (*models.Changes, *models.SyncToken, bool, error) {
+		gotSince, gotLimit = since, limit
+		return &models.Changes{Workouts: []models.Workout{{SK: models.WorkoutKey + "w1"}}}, &models.SyncToken{UpdatedAt: models.Timestamp(until)}, true, nil
+	}
+
+	res, err := GetChanges(db.attach(newSyncCtx("/sync")), "u1")
+	require.NoError(t, err)
+
+	out := res.(models.SyncResponse)
+	assert.Nil(t, gotSince)
+	assert.Equal(t, 100, gotLimit)
+	assert.True(t, out.Reset)
+	assert.True(t, out.HasMore)
+	assert.Len(t, out.Workouts, 1)
+	assert.NotEmpty(t, out.Token)
+}
+
+func TestGetChanges_ContinuesFromToken(t *testing.T) {
+	db := newFakeStores()
+
+	token := models.SyncToken{UpdatedAt: models.Timestamp(time.Now().Add(-time.Hour)), SK: "WORKOUT#w1"}
+	var gotSince *models.SyncToken
+	db.getChanges = func(ctx context.Context, userId string, since *models.SyncToken, until time.Time, limit int) (*models.Changes, *models.SyncToken, bool, error) {
+		gotSince = since
+		return &models.Changes{}, since, false, nil
+	}
+
+	res, err := GetChanges(db.attach(newSyncCtx("/sync?pageSize=5&since="+token.Encode())), "u1")
+	require.NoError(t, err)
+
+	out := res.(models.SyncResponse)
+	require.NotNil(t, gotSince)
+	assert.Equal(t, token, *gotSince)
+	assert.False(t, out.Reset)
+	assert.Equal(t, token.Encode(), out.Token)
+}
+
+func TestGetChanges_ExpiredTokenResets(t *testing.T) {
+	db := newFakeStores()
+
+	token := models.SyncToken{UpdatedAt: models.Timestamp(time.Now().Add(-models.TombstoneRetention - time.Hour))}
+	db.getChanges = func(ctx context.Context, userId string, since *models.SyncToken, until time.Time, limit int) (*models.Changes, *models.SyncToken, bool, error) {
+		assert.Nil(t, since)
+		return &models.Changes{}, &models.SyncToken{UpdatedAt: models.Timestamp(until)}, false, nil
+	}
+
+	res, err := GetChanges(db.attach(newSyncCtx("/sync?since="+token.Encode())), "u1")
+	require.NoError(t, err)
+	assert.True(t, res.(models.SyncResponse).Reset)
+}
+
+func TestGetChanges_MalformedToken(t *testing.T) {
+	res, err := GetChanges(newSyncCtx("/sync?since=garbage!"), "u1")
+	assert.Nil(t, res)
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
@@ -2,20 +2,13 @@ package handlers
 
 import (
 	"errors"
-	"heart/internal/dbx"
 	"heart/internal/models"
+	"heart/internal/validation"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// test seams for dbx dependencies
-var (
-	dbGetTemplates   = dbx.GetTemplates
-	dbGetTemplate    = dbx.GetTemplate
-	dbSaveTemplate   = dbx.SaveTemplate
-	dbDeleteTemplate = dbx.DeleteTemplate
-)
-
 // GetTemplates godoc
 //
 //	@Summary		Lists workout templates
@@ -31,7 +24,7 @@ var (
 //	@Router			/templates [get]
 //	@Security		BearerAuth
 func GetTemplates(c *gin.Context, userId string) (any, error) {
-	templates, err := dbGetTemplates(c.Request.Context(), userId)
+	templates, err := templateStore(c).GetTemplates(c.Request.Context(), userId)
 
 	if err != nil {
 		return nil, models.NewServerError(err)
@@ -61,7 +54,7 @@ func GetTemplates(c *gin.Context, userId string) (any, error) {
 func GetTemplate(c *gin.Context, userId string) (any, error) {
 	templateId := c.Param("templateId")
 
-	template, err := dbGetTemplate(c.Request.Context(), userId, templateId)
+	template, err := templateStore(c).GetTemplate(c.Request.Context(), userId, templateId)
 
 	if err != nil {
 		return nil, models.NewServerError(err)
@@ -71,21 +64,28 @@ func GetTemplate(c *gin.Context, userId string) (any, error) {
 		return nil, models.NewNotFoundError("Template not found", errors.New("template not found"))
 	}
 
+	setETag(c, template.Version)
 	return models.NewTemplateOut(template), nil
 }
 
 // MakeTemplate godoc
 //
 //	@Summary		Creates a workout template
-//	@Description	Validates, saves and returns a workout template
+//	@Description	Validates, saves and returns a workout template. Passing the version the edit is based on,
+//	@Description	in the body or as If-Match, rejects the save if the template has changed since.
+//	@Description	A rejected one comes back with what is wrong with each field under details, by its JSON path.
 //	@Tags			templates
 //	@Accept			json
 //	@Produce		json
 //	@ID				makeTemplate
 //	@Param			X-App-Version	header		string		false	"Client app version"
+//	@Param			If-Match		header		string		false	"Version the edit is based on"
 //	@Param			input			body		TemplateIn	true	"Template request"
 //	@Success		200				{object}	Template
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
 //	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		409				{object}	ErrorResponse	"Changed since the given version"
 //	@Failure		500				{object}	ErrorResponse	"Server error"
 //	@Router			/templates [post]
 //	@Security		BearerAuth
@@ -95,13 +95,164 @@ func MakeTemplate(c *gin.Context, userId string) (any, error) {
 		return nil, models.NewValidationError(err)
 	}
 
+	known, err := knownNames(c, userId, len(template.Exercises))
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Template(&template, known); err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	expected, err := expectedVersion(c, template.Version)
+	if err != nil {
+		return nil, err
+	}
+
 	created := models.NewTemplate(&template, userId)
 
-	saved, err := dbSaveTemplate(c.Request.Context(), created)
+	saved, err := templateStore(c).SaveTemplate(c.Request.Context(), created, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	setETag(c, saved.Version)
+	return models.NewTemplateOut(saved), nil
+}
+
+// UpdateTemplate godoc
+//
+//	@Summary		Updates a workout template
+//	@Description	Saves and returns an existing workout template. Passing the version the edit is based on,
+//	@Description	in the body or as If-Match, rejects the save if the template has changed since.
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@ID				updateTemplate
+//	@Param			X-App-Version	header		string		false	"Client app version"
+//	@Param			If-Match		header		string		false	"Version the edit is based on"
+//	@Param			templateId		path		string		true	"Template ID"
+//	@Param			input			body		TemplateIn	true	"Template request"
+//	@Success		200				{object}	Template
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		409				{object}	ErrorResponse	"Changed since the given version"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/templates/{templateId} [put]
+//	@Security		BearerAuth
+func UpdateTemplate(c *gin.Context, userId string) (any, error) {
+	var template models.TemplateIn
+	if err := c.BindJSON(&template); err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	known, err := knownNames(c, userId, len(template.Exercises))
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Template(&template, known); err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	templateId := c.Param("templateId")
+	if template.ID != "" && template.ID != templateId {
+		return nil, models.NewValidationError(errors.New("template id in the body does not match the path"))
+	}
+	template.ID = templateId
+
+	expected, err := expectedVersion(c, template.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := templateStore(c).UpdateTemplate(c.Request.Context(), models.NewTemplate(&template, userId), expected)
+	if err != nil {
+		return nil, err
+	}
+
+	setETag(c, saved.Version)
+	return models.NewTemplateOut(saved), nil
+}
+
+// ReorderTemplates godoc
+//
+//	@Summary		Reorders workout templates
+//	@Description	Puts the templates in the given order, all of them or, if any is missing, none.
+//	@Description	Returns all templates, with their new versions.
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@ID				reorderTemplates
+//	@Param			X-App-Version	header		string			false	"Client app version"
+//	@Param			input			body		TemplateOrderIn	true	"Template IDs in order"
+//	@Success		200				{object}	TemplateResponse
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/templates/order [patch]
+//	@Security		BearerAuth
+func ReorderTemplates(c *gin.Context, userId string) (any, error) {
+	var order models.TemplateOrderIn
+	if err := c.BindJSON(&order); err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	if err := templateStore(c).ReorderTemplates(c.Request.Context(), userId, order.IDs); err != nil {
+		return nil, err
+	}
+
+	templates, err := templateStore(c).GetTemplates(c.Request.Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.TemplateResponse{
+		Templates: models.NewTemplateArray(templates),
+	}, nil
+}
+
+// DuplicateTemplate godoc
+//
+//	@Summary		Duplicates a workout template
+//	@Description	Saves a copy of the template, at the end of the list, and returns it
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@ID				duplicateTemplate
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			templateId		path		string	true	"Template ID"
+//	@Success		200				{object}	Template
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/templates/{templateId}/duplicate [post]
+//	@Security		BearerAuth
+func DuplicateTemplate(c *gin.Context, userId string) (any, error) {
+	template, err := templateStore(c).GetTemplate(c.Request.Context(), userId, c.Param("templateId"))
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := templateStore(c).GetTemplates(c.Request.Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	last := -1
+	for _, t := range templates {
+		last = max(last, t.OrderInParent)
+	}
+
+	copied := template.Duplicate(models.Timestamp(time.Now()), last+1)
+
+	never := 0 // the new ID must not be taken
+	saved, err := templateStore(c).SaveTemplate(c.Request.Context(), copied, &never)
 	if err != nil {
 		return nil, err
 	}
 
+	setETag(c, saved.Version)
 	return models.NewTemplateOut(saved), nil
 }
 
@@ -124,7 +275,7 @@ func MakeTemplate(c *gin.Context, userId string) (any, error) {
 func DeleteTemplate(c *gin.Context, userId string) (any, error) {
 	templateId := c.Param("templateId")
 
-	err := dbDeleteTemplate(c.Request.Context(), userId, templateId)
+	err := templateStore(c).DeleteTemplate(c.Request.Context(), userId, templateId)
 
 	if err != nil {
 		return nil, models.NewServerError(err)
@@ -132,3 +283,103 @@ func DeleteTemplate(c *gin.Context, userId string) (any, error) {
 
 	return models.NoContent, nil
 }
+
+// StartWorkout godoc
+//
+//	@Summary		Starts a workout from a template
+//	@Description	Lays out a new workout from the template, without saving it. Sets are prefilled
+//	@Description	with the weights, reps, durations and distances of the last time each exercise was done,
+//	@Description	or as the template has them while the user's history is still being built.
+//	@Description	Saving the workout with its templateId counts it towards the template's usage.
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@ID				startWorkout
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			templateId		path		string	true	"Template ID"
+//	@Success		200				{object}	WorkoutIn
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/templates/{templateId}/start [post]
+//	@Security		BearerAuth
+func StartWorkout(c *gin.Context, userId string) (any, error) {
+	template, err := templateStore(c).GetTemplate(c.Request.Context(), userId, c.Param("templateId"))
+	if err != nil {
+		return nil, err
+	}
+
+	last := map[string][]models.Set{}
+	for _, e := range template.Exercises {
+		if _, ok := last[e.ExerciseID]; ok {
+			continue
+		}
+
+		entries, _, err := workoutStore(c).GetExerciseHistory(c.Request.Context(), userId, e.ExerciseID, 1, "")
+		pending, err := historyPending(c, userId, err)
+		if err != nil {
+			return nil, err
+		}
+		if pending {
+			break // the template's own sets, until there is history to go by
+		}
+
+		last[e.ExerciseID] = nil
+		if len(entries) > 0 {
+			last[e.ExerciseID] = entries[0].Sets
+		}
+	}
+
+	return template.StartWorkout(last, time.Now()), nil
+}
+
+// UpdateTemplateFromWorkout godoc
+//
+//	@Summary		Updates a template from a workout
+//	@Description	Replaces the template's exercises with the sets completed in a workout,
+//	@Description	so that next time starts from what was done this time. Passing the version
+//	@Description	the edit is based on as If-Match rejects the save if the template has changed since.
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@ID				updateTemplateFromWorkout
+//	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			If-Match		header		string	false	"Version the edit is based on"
+//	@Param			templateId		path		string	true	"Template ID"
+//	@Param			workoutId		path		string	true	"Workout ID"
+//	@Success		200				{object}	Template
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
+//	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		409				{object}	ErrorResponse	"Changed since the given version"
+//	@Failure		500				{object}	ErrorResponse	"Server error"
+//	@Router			/templates/{templateId}/from/{workoutId} [put]
+//	@Security		BearerAuth
+func UpdateTemplateFromWorkout(c *gin.Context, userId string) (any, error) {
+	expected, err := expectedVersion(c, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := templateStore(c).GetTemplate(c.Request.Context(), userId, c.Param("templateId"))
+	if err != nil {
+		return nil, err
+	}
+
+	workout, err := workoutStore(c).GetWorkout(c.Request.Context(), userId, c.Param("workoutId"))
+	if err != nil {
+		return nil, err
+	}
+
+	if !template.UpdateFrom(workout) {
+		return nil, models.NewValidationError(errors.New("the workout has no completed sets"))
+	}
+
+	saved, err := templateStore(c).SaveTemplate(c.Request.Context(), *template, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	setETag(c, saved.Version)
+	return models.NewTemplateOut(saved), nil
+}
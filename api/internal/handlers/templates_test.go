@@ -9,25 +9,26 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func newGinContextWithBody(method, path, body string) *gin.Context {
 	gin.SetMode(gin.TestMode)
 	rec := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(rec)
+	newFakeStores().attach(c) // tests that stub the stores attach their own
 	c.Request = httptest.NewRequest(method, path, strings.NewReader(body))
 	c.Request.Header.Set("Content-Type", "application/json")
 	return c
 }
 
 func TestGetTemplates_Success(t *testing.T) {
-	orig := dbGetTemplates
-	dbGetTemplates = func(ctx context.Context, userId string) ([]models.Template, error) {
+	db := newFakeStores()
+	db.getTemplates = func(ctx context.Context, userId string) ([]models.Template, error) {
 		return []models.Template{{Name: "T1", PK: "USER#" + userId, SK: "TEMPLATE#tid"}}, nil
 	}
-	t.Cleanup(func() { dbGetTemplates = orig })
 
-	c := newCtx()
+	c := db.attach(newCtx())
 	res, err := GetTemplates(c, "u1")
 	assert.NoError(t, err)
 	out, ok := res.(models.TemplateResponse)
@@ -37,11 +38,10 @@ func TestGetTemplates_Success(t *testing.T) {
 }
 
 func TestGetTemplate_NotFound(t *testing.T) {
-	orig := dbGetTemplate
-	dbGetTemplate = func(ctx context.Context, userId, id string) (*models.Template, error) { return nil, nil }
-	t.Cleanup(func() { dbGetTemplate = orig })
+	db := newFakeStores()
+	db.getTemplate = func(ctx context.Context, userId, id string) (*models.Template, error) { return nil, nil }
 
-	c := newCtx()
+	c := db.attach(newCtx())
 	c.Params = gin.Params{{Key: "templateId", Value: "tid"}}
 	res, err := GetTemplate(c, "u1")
 	assert.Nil(t, res)
@@ -57,12 +57,30 @@ func TestMakeTemplate_ValidationError(t *testing.T) {
 	assert.True(t, isHTTP)
 }
 
+func TestMakeTemplate_NamesTheFieldsAtFault(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	stubKnownExercises(db, []models.Exercise{{Name: "Squat"}, {Name: "Lunge"}}, nil)
+
+	body := `{"name":"Legs","exercises":[` +
+		`{"id":"t1","exercise":"Squat","order":0,"sets":[{"id":"s1","reps":5}]},` +
+		`{"id":"t2","exercise":"Lunge","order":0,"sets":[{"id":"s1","reps":-8}]}]}`
+	res, err := MakeTemplate(db.attach(newGinContextWithBody("POST", "/templates", body)), "u1")
+
+	assert.Nil(t, res)
+	var validation *models.ValidationError
+	require.ErrorAs(t, err, &validation)
+	body = string(validation.JSON())
+	assert.Contains(t, body, `"exercises[1].order":["is the same as exercises[0].order"]`)
+	assert.Contains(t, body, `"exercises[1].sets[0].id":["is the same as exercises[0].sets[0].id"]`)
+	assert.Contains(t, body, `"exercises[1].sets[0].reps":["must not be negative"]`)
+}
+
 func TestDeleteTemplate_Success(t *testing.T) {
-	orig := dbDeleteTemplate
-	dbDeleteTemplate = func(ctx context.Context, userId, id string) error { return nil }
-	t.Cleanup(func() { dbDeleteTemplate = orig })
+	db := newFakeStores()
+	db.deleteTemplate = func(ctx context.Context, userId, id string) error { return nil }
 
-	c := newCtx()
+	c := db.attach(newCtx())
 	c.Params = gin.Params{{Key: "templateId", Value: "tid"}}
 	res, err := DeleteTemplate(c, "u1")
 	assert.NoError(t, err)
@@ -70,17 +88,248 @@ func TestDeleteTemplate_Success(t *testing.T) {
 }
 
 func TestMakeTemplate_Saves(t *testing.T) {
-	orig := dbSaveTemplate
-	dbSaveTemplate = func(ctx context.Context, in models.Template) (*models.Template, error) {
+	db := newFakeStores()
+	db.saveTemplate = func(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
 		return &in, nil
 	}
-	t.Cleanup(func() { dbSaveTemplate = orig })
 
 	body := `{"name":"Plan A","rounds":[]}`
-	c := newGinContextWithBody("POST", "/templates", body)
+	c := db.attach(newGinContextWithBody("POST", "/templates", body))
 	res, err := MakeTemplate(c, "uX")
 	assert.NoError(t, err)
 	out, ok := res.(models.TemplateOut)
 	assert.True(t, ok)
 	assert.Equal(t, "Plan A", out.Name)
 }
+
+func TestMakeTemplate_IfMatchIsTheExpectedVersion(t *testing.T) {
+	db := newFakeStores()
+	var got *int
+	db.saveTemplate = func(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+		got = expected
+		in.Version = *expected + 1
+		return &in, nil
+	}
+
+	c := db.attach(newGinContextWithBody("POST", "/templates", `{"id":"t1","name":"Plan A"}`))
+	c.Request.Header.Set("If-Match", `W/"3"`)
+	res, err := MakeTemplate(c, "uX")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, *got)
+	assert.Equal(t, 4, res.(models.TemplateOut).Version)
+	assert.Equal(t, `"4"`, c.Writer.Header().Get("ETag"))
+}
+
+func TestStartWorkout_PrefillsFromHistory(t *testing.T) {
+	db := newFakeStores()
+
+	db.getTemplate = func(ctx context.Context, userId, templateId string) (*models.Template, error) {
+		return &models.Template{
+			PK:   "USER#" + userId,
+			SK:   "TEMPLATE#" + templateId,
+			Name: "Legs",
+			Exercises: []models.TemplateExercise{
+				{ID: "e1", ExerciseID: "Squat", Sets: []models.Set{{ID: "s1", Reps: 5}}},
+				{ID: "e2", ExerciseID: "Squat", Sets: []models.Set{{ID: "s2", Reps: 5}}},
+			},
+		}, nil
+	}
+	var asked []string
+	db.getExerciseHistory = func(ctx context.Context, userId, exercise string, limit int, cursor string) ([]models.HistoryEntry, string, error) {
+		asked = append(asked, exercise)
+		assert.Equal(t, 1, limit)
+		return []models.HistoryEntry{{Sets: []models.Set{{Completed: true, Weight: 120, Reps: 5}}}}, "", nil
+	}
+
+	c := db.attach(newGinContextWithBody("POST", "/templates/t1/start", ""))
+	c.Params = gin.Params{{Key: "templateId", Value: "t1"}}
+	res, err := StartWorkout(c, "u1")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Squat"}, asked) // once per exercise
+	in := res.(models.WorkoutIn)
+	assert.Equal(t, "t1", *in.Template)
+	assert.Equal(t, 120.0, in.Exercises[0].Sets[0].Weight)
+	assert.Equal(t, 120.0, in.Exercises[1].Sets[0].Weight)
+}
+
+func TestStartWorkout_GoesWithoutHistoryWhileItIsBuilt(t *testing.T) {
+	db := newFakeStores()
+	db.getTemplate = func(ctx context.Context, userId, templateId string) (*models.Template, error) {
+		return &models.Template{
+			PK:        "USER#" + userId,
+			SK:        "TEMPLATE#" + templateId,
+			Name:      "Legs",
+			Exercises: []models.TemplateExercise{{ID: "e1", ExerciseID: "Squat", Sets: []models.Set{{ID: "s1", Weight: 100, Reps: 5}}}},
+		}, nil
+	}
+	db.getExerciseHistory = func(ctx context.Context, userId, exercise string, limit int, cursor string) ([]models.HistoryEntry, string, error) {
+		return nil, "", &models.HistoryPendingError{Start: true}
+	}
+	invoked := false
+	db.invokeBackground = func(ctx context.Context, event string, payload any) error {
+		invoked = event == "RebuildHistory"
+		return nil
+	}
+
+	c := db.attach(newGinContextWithBody("POST", "/templates/t1/start", ""))
+	c.Params = gin.Params{{Key: "templateId", Value: "t1"}}
+	res, err := StartWorkout(c, "u1")
+
+	require.NoError(t, err)
+	assert.True(t, invoked)
+	assert.Equal(t, 100.0, res.(models.WorkoutIn).Exercises[0].Sets[0].Weight)
+}
+
+func TestStartWorkout_TemplateNotFound(t *testing.T) {
+	db := newFakeStores()
+	db.getTemplate = func(ctx context.Context, userId, templateId string) (*models.Template, error) {
+		return nil, models.NewNotFoundError("Template not found", nil)
+	}
+
+	c := db.attach(newGinContextWithBody("POST", "/templates/t1/start", ""))
+	_, err := StartWorkout(c, "u1")
+
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestUpdateTemplateFromWorkout(t *testing.T) {
+	db := newFakeStores()
+
+	db.getTemplate = func(ctx context.Context, userId, templateId string) (*models.Template, error) {
+		return &models.Template{PK: "USER#u1", SK: "TEMPLATE#t1", Name: "Legs", Version: 3}, nil
+	}
+	completed := []models.WorkoutExercise{{ID: "e1", ExerciseID: "Squat", Sets: []models.Set{{ID: "s1", Completed: true, Weight: 125, Reps: 5}}}}
+	db.getWorkout = func(ctx context.Context, userId, workoutId string) (*models.Workout, error) {
+		if workoutId == "empty" {
+			return &models.Workout{}, nil
+		}
+		return &models.Workout{Exercises: completed}, nil
+	}
+	var got *int
+	db.saveTemplate = func(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+		got = expected
+		in.Version++
+		return &in, nil
+	}
+
+	c := db.attach(newGinContextWithBody("PUT", "/templates/t1/from/w1", ""))
+	c.Params = gin.Params{{Key: "templateId", Value: "t1"}, {Key: "workoutId", Value: "w1"}}
+	c.Request.Header.Set("If-Match", `"3"`)
+	res, err := UpdateTemplateFromWorkout(c, "u1")
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, *got)
+	out := res.(models.TemplateOut)
+	assert.Equal(t, "Legs", out.Name)
+	require.Len(t, out.Exercises, 1)
+	assert.Equal(t, 125.0, out.Exercises[0].Sets[0].Weight)
+	assert.False(t, out.Exercises[0].Sets[0].Completed)
+
+	c = db.attach(newGinContextWithBody("PUT", "/templates/t1/from/empty", ""))
+	c.Params = gin.Params{{Key: "templateId", Value: "t1"}, {Key: "workoutId", Value: "empty"}}
+	_, err = UpdateTemplateFromWorkout(c, "u1")
+
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
+
+func TestUpdateTemplate_SavesUnderThePathID(t *testing.T) {
+	db := newFakeStores()
+
+	var got models.Template
+	db.updateTemplate = func(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+		got = in
+		in.Version = 2
+		return &in, nil
+	}
+
+	c := db.attach(newGinContextWithBody("PUT", "/templates/t1", `{"name":"Plan B","version":1}`))
+	c.Params = gin.Params{{Key: "templateId", Value: "t1"}}
+	res, err := UpdateTemplate(c, "u1")
+
+	require.NoError(t, err)
+	assert.Equal(t, "TEMPLATE#t1", got.SK)
+	assert.Equal(t, "Plan B", res.(models.TemplateOut).Name)
+	assert.Equal(t, `"2"`, c.Writer.Header().Get("ETag"))
+}
+
+func TestUpdateTemplate_BodyIDMustMatchPath(t *testing.T) {
+	c := newGinContextWithBody("PUT", "/templates/t1", `{"id":"t2","name":"Plan B"}`)
+	c.Params = gin.Params{{Key: "templateId", Value: "t1"}}
+	_, err := UpdateTemplate(c, "u1")
+
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
+
+func TestUpdateTemplate_MissingTemplate(t *testing.T) {
+	db := newFakeStores()
+	db.updateTemplate = func(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+		return nil, models.NewNotFoundError("Template not found", nil)
+	}
+
+	c := db.attach(newGinContextWithBody("PUT", "/templates/t1", `{"name":"Plan B"}`))
+	c.Params = gin.Params{{Key: "templateId", Value: "t1"}}
+	_, err := UpdateTemplate(c, "u1")
+
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestReorderTemplates(t *testing.T) {
+	db := newFakeStores()
+
+	var got []string
+	db.reorderTemplates = func(ctx context.Context, userId string, ids []string) error {
+		got = ids
+		return nil
+	}
+	db.getTemplates = func(ctx context.Context, userId string) ([]models.Template, error) {
+		return []models.Template{{SK: "TEMPLATE#t2"}, {SK: "TEMPLATE#t1", OrderInParent: 1}}, nil
+	}
+
+	res, err := ReorderTemplates(db.attach(newGinContextWithBody("PATCH", "/templates/order", `{"ids":["t2","t1"]}`)), "u1")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t2", "t1"}, got)
+	assert.Len(t, res.(models.TemplateResponse).Templates, 2)
+
+	for _, body := range []string{`{"ids":[]}`, `{"ids":["t1","t1"]}`} {
+		_, err := ReorderTemplates(db.attach(newGinContextWithBody("PATCH", "/templates/order", body)), "u1")
+
+		var validation *models.ValidationError
+		assert.ErrorAs(t, err, &validation, body)
+	}
+}
+
+func TestDuplicateTemplate_GoesLast(t *testing.T) {
+	db := newFakeStores()
+
+	source := models.Template{PK: "USER#u1", SK: "TEMPLATE#t1", Name: "Legs", Exercises: []models.TemplateExercise{{ExerciseID: "Squat"}}}
+	db.getTemplate = func(ctx context.Context, userId, templateId string) (*models.Template, error) {
+		return &source, nil
+	}
+	db.getTemplates = func(ctx context.Context, userId string) ([]models.Template, error) {
+		return []models.Template{source, {OrderInParent: 4}}, nil
+	}
+	var expected *int
+	db.saveTemplate = func(ctx context.Context, in models.Template, v *int) (*models.Template, error) {
+		expected = v
+		in.Version = 1
+		return &in, nil
+	}
+
+	c := db.attach(newGinContextWithBody("POST", "/templates/t1/duplicate", ""))
+	c.Params = gin.Params{{Key: "templateId", Value: "t1"}}
+	res, err := DuplicateTemplate(c, "u1")
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, *expected)
+	out := res.(models.TemplateOut)
+	assert.NotEqual(t, "t1", out.ID)
+	assert.Equal(t, "Legs (copy)", out.Name)
+	assert.Equal(t, 5, out.Order)
+	assert.Equal(t, source.Exercises, out.Exercises)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"heart/internal/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// expectedVersion returns the version a client based its edit on, taken from
+// the If-Match header or else from the body. Nil means the client did not say,
+// in which case the save is unconditional.
+func expectedVersion(c *gin.Context, body *int) (*int, error) {
+	match := strings.TrimSpace(c.GetHeader("If-Match"))
+	if match == "" || match == "*" {
+		return body, nil
+	}
+
+	match = strings.Trim(strings.TrimPrefix(match, "W/"), `"`)
+	version, err := strconv.Atoi(match)
+	if err != nil || version < 0 {
+		return nil, models.NewValidationError(errors.New("If-Match must be a version number"))
+	}
+
+	if body != nil && *body != version {
+		return nil, models.NewValidationError(errors.New("If-Match and body version disagree"))
+	}
+
+	return &version, nil
+}
+
+// setETag lets clients echo the version back in If-Match on their next save.
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
@@ -1,14 +1,12 @@
 package handlers
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"fmt"
-	"heart/internal/awsx"
 	"heart/internal/config"
-	"heart/internal/dbx"
 	"heart/internal/models"
+	"heart/internal/validation"
+	"log"
 	"maps"
 	"strconv"
 	"strings"
@@ -17,27 +15,26 @@ import (
 	"github.com/google/uuid"
 )
 
-// test seams for dbx dependencies
-var (
-	dbGetWorkouts       = dbx.GetWorkouts
-	dbGetWorkout        = dbx.GetWorkout
-	dbDeleteWorkout     = dbx.DeleteWorkout
-	removeWorkoutImage  = dbx.RemoveWorkoutImage
-	dbGetWorkoutGallery = dbx.GetWorkoutGallery
-)
-
 // GetWorkouts godoc
 //
 //	@Summary		Returns user workouts
-//	@Description	Returns paginated list of user workouts with exercises and sets
+//	@Description	Returns paginated list of user workouts with exercises and sets, newest first,
+//	@Description	optionally only those started within a date range, with an exercise or with a name
 //	@Tags			workouts
 //	@Accept			json
 //	@Produce		json
 //	@ID				getWorkouts
 //	@Param			X-App-Version	header		string	false	"Client app version"
+//	@Param			X-Timezone		header		string	false	"IANA time zone dates are taken in, UTC by default"
 //	@Param			pageSize		query		integer	false	"Page size for pagination"
 //	@Param			cursor			query		string	false	"Cursor for pagination"
+//	@Param			from			query		string	false	"Started on or after, 2006-01-02 or RFC 3339"
+//	@Param			to				query		string	false	"Started on or before, 2006-01-02 or RFC 3339"
+//	@Param			exercise		query		string	false	"Has this exercise"
+//	@Param			name			query		string	false	"Name contains, case-insensitive"
 //	@Success		200				{object}	WorkoutResponse
+//	@Success		202				"Filtering by exercise waits for history to be built; ask again shortly"
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
 //	@Failure		401				{object}	ErrorResponse	"Unauthorized"
 //	@Failure		500				{object}	ErrorResponse	"Server error"
 //	@Router			/workouts [get]
@@ -52,11 +49,24 @@ func GetWorkouts(c *gin.Context, userId string) (any, error) {
 
 	cursor := c.Query("cursor")
 
-	workouts, last, err := dbGetWorkouts(c.Request.Context(), userId, pageSize, cursor)
+	filter, err := workoutFilter(c)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Exercise != "" {
+		if filter.Exercise, err = resolveExercise(c, userId, filter.Exercise); err != nil {
+			return nil, err
+		}
+	}
 
+	workouts, last, err := workoutStore(c).GetWorkouts(c.Request.Context(), userId, pageSize, cursor, filter)
+	pending, err := historyPending(c, userId, err)
 	if err != nil {
 		return nil, models.NewServerError(err)
 	}
+	if pending {
+		return models.Accepted, nil
+	}
 
 	return models.WorkoutResponse{
 		Workouts: models.NewWorkoutsArray(workouts, config.App.MediaDistributionAlias),
@@ -64,6 +74,35 @@ func GetWorkouts(c *gin.Context, userId string) (any, error) {
 	}, nil
 }
 
+func workoutFilter(c *gin.Context) (models.WorkoutFilter, error) {
+	filter := models.WorkoutFilter{
+		Exercise: strings.TrimSpace(c.Query("exercise")),
+		Name:     strings.TrimSpace(c.Query("name")),
+	}
+
+	if c.Query("from") == "" && c.Query("to") == "" {
+		return filter, nil
+	}
+
+	loc, err := timezone(c)
+	if err != nil {
+		return filter, err
+	}
+
+	if filter.From, err = dateParam(c, "from", loc, false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = dateParam(c, "to", loc, true); err != nil {
+		return filter, err
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return filter, models.NewValidationError(errors.New("from must not be after to"))
+	}
+
+	return filter, nil
+}
+
 // GetWorkout godoc
 //
 //	@Summary		Returns a workout
@@ -83,27 +122,35 @@ func GetWorkouts(c *gin.Context, userId string) (any, error) {
 func GetWorkout(c *gin.Context, userId string) (any, error) {
 	workoutId := c.Param("workoutId")
 
-	workout, err := dbGetWorkout(c.Request.Context(), userId, workoutId)
+	workout, err := workoutStore(c).GetWorkout(c.Request.Context(), userId, workoutId)
 
 	if err != nil {
 		return nil, models.NewServerError(err)
 	}
 
+	setETag(c, workout.Version)
 	return models.NewWorkoutOut(workout, config.App.MediaDistributionAlias), nil
 }
 
 // MakeWorkout godoc
 //
 //	@Summary		Creates a workout
-//	@Description	Validates, saves and returns a workout
+//	@Description	Validates, saves and returns a workout. Passing the version the edit is based on,
+//	@Description	in the body or as If-Match, rejects the save if the workout has changed since.
+//	@Description	Sets that have just set a personal record come back flagged with it.
+//	@Description	A rejected one comes back with what is wrong with each field under details, by its JSON path.
 //	@Tags			workouts
 //	@Accept			json
 //	@Produce		json
 //	@ID				makeWorkout
 //	@Param			X-App-Version	header		string		false	"Client app version"
+//	@Param			If-Match		header		string		false	"Version the edit is based on"
 //	@Param			input			body		WorkoutIn	true	"Workout request"
 //	@Success		200				{object}	Workout
+//	@Failure		400				{object}	ErrorResponse	"Validation error"
 //	@Failure		401				{object}	ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	ErrorResponse	"Not Found"
+//	@Failure		409				{object}	ErrorResponse	"Changed since the given version"
 //	@Failure		500				{object}	ErrorResponse	"Server error"
 //	@Router			/workouts [post]
 //	@Security		BearerAuth
@@ -113,14 +160,66 @@ func MakeWorkout(c *gin.Context, userID string) (any, error) {
 		return nil, models.NewValidationError(err)
 	}
 
+	known, err := knownNames(c, userID, len(workoutIn.Exercises))
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Workout(&workoutIn, known); err != nil {
+		return nil, models.NewValidationError(err)
+	}
+
+	expected, err := expectedVersion(c, workoutIn.Version)
+	if err != nil {
+		return nil, err
+	}
+
 	workout := models.NewWorkout(&workoutIn, userID)
 
-	saved, err := dbx.SaveWorkout(c.Request.Context(), workout)
+	// a client that has not synced since a merge may still log the old name
+	aliases, err := exerciseStore(c).GetAliases(c.Request.Context(), userID)
 	if err != nil {
 		return nil, err
 	}
+	models.NewAliases(aliases).Apply(&workout)
+
+	saved, err := workoutStore(c).SaveWorkout(c.Request.Context(), workout, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	out := models.NewWorkoutOut(saved, config.App.MediaDistributionAlias)
+
+	// the workout is saved either way, so a failure here only costs the flags
+	if broken, err := workoutStore(c).UpdateRecords(c.Request.Context(), userID, saved); err != nil {
+		log.Printf("[ERROR] updating personal records of workout %s: %v", saved.ID(), err)
+	} else {
+		out.FlagRecords(broken)
+	}
+
+	if saved.Version == 1 && saved.Template != "" {
+		recordTemplateUse(c, userID, saved)
+	}
+
+	setETag(c, saved.Version)
+	return out, nil
+}
+
+// recordTemplateUse counts a new workout towards the usage of the template it was started from.
+// It does not fail the save, which has already happened.
+func recordTemplateUse(c *gin.Context, userID string, workout *models.Workout) {
+	template, err := templateStore(c).GetTemplate(c.Request.Context(), userID, workout.Template)
 
-	return models.NewWorkoutOut(saved, config.App.MediaDistributionAlias), nil
+	var notFound *models.NotFoundError
+	if errors.As(err, &notFound) {
+		return
+	}
+
+	if err == nil {
+		err = templateStore(c).RecordTemplateUse(c.Request.Context(), template, workout)
+	}
+	if err != nil {
+		log.Printf("[ERROR] recording use of template %s by workout %s: %v", workout.Template, workout.ID(), err)
+	}
 }
 
 // DeleteWorkout godoc
@@ -142,7 +241,7 @@ func MakeWorkout(c *gin.Context, userID string) (any, error) {
 func DeleteWorkout(c *gin.Context, userId string) (any, error) {
 	workoutId := c.Param("workoutId")
 
-	err := dbDeleteWorkout(c.Request.Context(), userId, workoutId)
+	err := workoutStore(c).DeleteWorkout(c.Request.Context(), userId, workoutId)
 
 	var notFound *models.NotFoundError
 	if ok := errors.As(err, &notFound); ok {
@@ -203,7 +302,7 @@ func MakeWorkoutPresignedUrl(c *gin.Context, userId string) (any, error) {
 		return nil, models.NewServerError(err)
 	}
 
-	response, err := awsx.GeneratePresignedPostURL(
+	response, err := objects(c).GeneratePresignedPostURL(
 		c.Request.Context(),
 		config.App.UploadBucket,
 		key,
@@ -224,15 +323,13 @@ func MakeWorkoutPresignedUrl(c *gin.Context, userId string) (any, error) {
 }
 
 func workoutImageKey(userId, workoutId, extension string) (string, error) {
-	h := sha256.Sum256([]byte(userId + ":" + workoutId))
-	hash := hex.EncodeToString(h[:])[:16] // 16 hex chars = 64 bits, plenty unique
 	id, err := uuid.NewV7()
 
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("workouts/%s/%s%s", hash, id, extension), err
+	return fmt.Sprintf("%s%s%s", config.App.WorkoutImagePrefix(userId, workoutId), id, extension), err
 }
 
 // DeleteWorkoutImage godoc
@@ -264,7 +361,7 @@ func DeleteWorkoutImage(c *gin.Context, userId string) (any, error) {
 		return nil, models.NewValidationError(errors.New("missing query param: key"))
 	}
 
-	workout, err := dbGetWorkout(c.Request.Context(), userId, workoutId)
+	workout, err := workoutStore(c).GetWorkout(c.Request.Context(), userId, workoutId)
 	if err != nil {
 		return nil, err
 	}
@@ -287,12 +384,12 @@ func DeleteWorkoutImage(c *gin.Context, userId string) (any, error) {
 		return models.NoContent, nil
 	}
 
-	_, err = awsx.DeleteObject(c.Request.Context(), config.App.MediaBucket, key)
+	_, err = objects(c).DeleteObject(c.Request.Context(), config.App.MediaBucket, key)
 	if err != nil {
 		return nil, models.NewServerError(err)
 	}
 
-	if err = removeWorkoutImage(c.Request.Context(), userId, workoutId, key); err != nil {
+	if err = workoutStore(c).RemoveWorkoutImage(c.Request.Context(), userId, workoutId, key); err != nil {
 		return nil, err
 	}
 
@@ -325,7 +422,7 @@ func GetWorkoutGallery(c *gin.Context, userId string) (any, error) {
 
 	cursor := c.Query("cursor")
 
-	items, next, err := dbGetWorkoutGallery(c.Request.Context(), userId, pageSize, cursor)
+	items, next, err := workoutStore(c).GetWorkoutGallery(c.Request.Context(), userId, pageSize, cursor)
 	if err != nil {
 		return nil, models.NewServerError(err)
 	}
@@ -2,15 +2,18 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"heart/internal/config"
 	"heart/internal/models"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMain(m *testing.M) {
@@ -22,23 +25,21 @@ func TestMain(m *testing.M) {
 			},
 		},
 	}
-
 	os.Exit(m.Run())
 }
 
 func TestGetWorkouts_DefaultsAndHappyPath(t *testing.T) {
-	orig := dbGetWorkouts
+	db := newFakeStores()
 	var gotUser string
 	var gotPage int
 	var gotCursor string
-	dbGetWorkouts = func(ctx context.Context, userId string, pageSize int, cursor string) ([]models.Workout, string, error) {
+	db.getWorkouts = func(ctx context.Context, userId string, pageSize int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error) {
 		gotUser, gotPage, gotCursor = userId, pageSize, cursor
 		ws := []models.Workout{{PK: models.UserKey + userId, SK: models.WorkoutKey + "w1", Name: "W"}}
 		return ws, "next", nil
 	}
-	t.Cleanup(func() { dbGetWorkouts = orig })
 
-	c := newCtx()
+	c := db.attach(newCtx())
 	// no pageSize, no cursor
 	res, err := GetWorkouts(c, "u1")
 	assert.NoError(t, err)
@@ -52,17 +53,17 @@ func TestGetWorkouts_DefaultsAndHappyPath(t *testing.T) {
 }
 
 func TestGetWorkouts_CustomPageAndCursor(t *testing.T) {
-	orig := dbGetWorkouts
+	db := newFakeStores()
 	var gotPage int
 	var gotCursor string
-	dbGetWorkouts = func(ctx context.Context, userId string, pageSize int, cursor string) ([]models.Workout, string, error) {
+	db.getWorkouts = func(ctx context.Context, userId string, pageSize int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error) {
 		gotPage, gotCursor = pageSize, cursor
 		return nil, "", nil
 	}
-	t.Cleanup(func() { dbGetWorkouts = orig })
 
 	rec := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(rec)
+	db.attach(c)
 	c.Request = httptest.NewRequest("GET", "/workouts?pageSize=25&cursor=abc", nil)
 
 	res, err := GetWorkouts(c, "u1")
@@ -73,11 +74,10 @@ func TestGetWorkouts_CustomPageAndCursor(t *testing.T) {
 }
 
 func TestGetWorkout_ErrorMapping(t *testing.T) {
-	orig := dbGetWorkout
-	dbGetWorkout = func(ctx context.Context, userId, id string) (*models.Workout, error) { return nil, errors.New("boom") }
-	t.Cleanup(func() { dbGetWorkout = orig })
+	db := newFakeStores()
+	db.getWorkout = func(ctx context.Context, userId, id string) (*models.Workout, error) { return nil, errors.New("boom") }
 
-	c := newCtx()
+	c := db.attach(newCtx())
 	c.Params = gin.Params{{Key: "workoutId", Value: "w1"}}
 	res, err := GetWorkout(c, "u1")
 	assert.Nil(t, res)
@@ -95,14 +95,72 @@ func TestMakeWorkout_ValidationError(t *testing.T) {
 	assert.True(t, isHTTP)
 }
 
+func TestMakeWorkout_SetOutOfRange(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	stubKnownExercises(db, []models.Exercise{{Name: "Squat"}}, nil)
+	body := `{"id":"w1","start":"2025-07-18T05:40:48Z","exercises":[{"id":"e1","exercise":"Squat","sets":[{"id":"s1","completed":true,"rpe":11}]}]}`
+	c := db.attach(newGinContextWithBody("POST", "/workouts", body))
+	res, err := MakeWorkout(c, "u1")
+
+	assert.Nil(t, res)
+	var validation *models.ValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.Contains(t, string(validation.JSON()), `"exercises[0].sets[0].rpe"`)
+}
+
+func TestMakeWorkout_SplitSuperset(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	stubKnownExercises(db, []models.Exercise{{Name: "Bench Press"}, {Name: "Squat"}, {Name: "Row"}}, nil)
+	body := `{"id":"w1","start":"2025-07-18T05:40:48Z","exercises":[` +
+		`{"id":"e1","exercise":"Bench Press","order":0,"group":"a","groupType":"superset"},` +
+		`{"id":"e2","exercise":"Squat","order":1},` +
+		`{"id":"e3","exercise":"Row","order":2,"group":"a","groupType":"superset"}]}`
+	res, err := MakeWorkout(db.attach(newGinContextWithBody("POST", "/workouts", body)), "u1")
+
+	assert.Nil(t, res)
+	var validation *models.ValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.Contains(t, string(validation.JSON()), `"exercises[2].group"`)
+}
+
+func TestMakeWorkout_UnknownExercise(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db, models.NewExerciseAlias("u1", "DB Bench", "Dumbbell Bench Press"))
+	stubKnownExercises(db, []models.Exercise{{Name: "Dumbbell Bench Press"}}, []models.Exercise{{Name: "Sled Push"}})
+	db.saveWorkout = func(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error) {
+		t.Fatal("should not save")
+		return nil, nil
+	}
+
+	body := `{"id":"w1","start":"2025-07-18T05:40:48Z","end":"2025-07-18T04:40:48Z","exercises":[` +
+		`{"id":"e1","exercise":"db bench","order":0,"sets":[]},` +
+		`{"id":"e2","exercise":"Sled Push","order":1,"sets":[]},` +
+		`{"id":"e3","exercise":"Moon Press","order":2,"sets":[{"id":"s1","completed":true,"weight":-20,"reps":5}]}]}`
+	res, err := MakeWorkout(db.attach(newGinContextWithBody("POST", "/workouts", body)), "u1")
+
+	assert.Nil(t, res)
+	var validation *models.ValidationError
+	require.ErrorAs(t, err, &validation)
+	var response struct {
+		Details map[string][]string `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(validation.JSON(), &response))
+	assert.Equal(t, map[string][]string{
+		"end":                         {"must not be before the start"},
+		"exercises[2].exercise":       {"is not a known exercise"},
+		"exercises[2].sets[0].weight": {"must not be negative"},
+	}, response.Details)
+}
+
 func TestDeleteWorkout_NotFoundPassthrough(t *testing.T) {
-	orig := dbDeleteWorkout
-	dbDeleteWorkout = func(ctx context.Context, userId, id string) error {
+	db := newFakeStores()
+	db.deleteWorkout = func(ctx context.Context, userId, id string) error {
 		return models.NewNotFoundError("not found", errors.New("nf"))
 	}
-	t.Cleanup(func() { dbDeleteWorkout = orig })
 
-	c := newCtx()
+	c := db.attach(newCtx())
 	c.Params = gin.Params{{Key: "workoutId", Value: "w1"}}
 	res, err := DeleteWorkout(c, "u1")
 	assert.Nil(t, res)
@@ -111,12 +169,12 @@ func TestDeleteWorkout_NotFoundPassthrough(t *testing.T) {
 }
 
 func TestGetWorkoutGallery_DefaultsAndHappyPath(t *testing.T) {
-	orig := dbGetWorkoutGallery
+	db := newFakeStores()
 	var gotUser string
 	var gotPage int
 	var gotCursor string
 
-	dbGetWorkoutGallery = func(ctx context.Context, userId string, pageSize int, cursor string) ([]models.ImageOut, *string, error) {
+	db.getWorkoutGallery = func(ctx context.Context, userId string, pageSize int, cursor string) ([]models.ImageOut, *string, error) {
 		gotUser, gotPage, gotCursor = userId, pageSize, cursor
 		next := "2025-07-01T00:00:00Z#2025-12-01T00:00:00Z~aaaa"
 		items := []models.ImageOut{
@@ -127,9 +185,8 @@ func TestGetWorkoutGallery_DefaultsAndHappyPath(t *testing.T) {
 		}
 		return items, &next, nil
 	}
-	t.Cleanup(func() { dbGetWorkoutGallery = orig })
 
-	c := newCtx() // no pageSize, no cursor
+	c := db.attach(newCtx()) // no pageSize, no cursor
 	res, err := GetWorkoutGallery(c, "u1")
 	assert.NoError(t, err)
 
@@ -145,18 +202,18 @@ func TestGetWorkoutGallery_DefaultsAndHappyPath(t *testing.T) {
 }
 
 func TestGetWorkoutGallery_CustomPageAndCursor(t *testing.T) {
-	orig := dbGetWorkoutGallery
+	db := newFakeStores()
 	var gotPage int
 	var gotCursor string
 
-	dbGetWorkoutGallery = func(ctx context.Context, userId string, pageSize int, cursor string) ([]models.ImageOut, *string, error) {
+	db.getWorkoutGallery = func(ctx context.Context, userId string, pageSize int, cursor string) ([]models.ImageOut, *string, error) {
 		gotPage, gotCursor = pageSize, cursor
 		return nil, nil, nil
 	}
-	t.Cleanup(func() { dbGetWorkoutGallery = orig })
 
 	rec := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(rec)
+	db.attach(c)
 	c.Request = httptest.NewRequest("GET", "/workouts/images?pageSize=25&cursor=abc", nil)
 
 	res, err := GetWorkoutGallery(c, "u1")
@@ -167,13 +224,12 @@ func TestGetWorkoutGallery_CustomPageAndCursor(t *testing.T) {
 }
 
 func TestGetWorkoutGallery_DBErrorMapping(t *testing.T) {
-	orig := dbGetWorkoutGallery
-	dbGetWorkoutGallery = func(ctx context.Context, userId string, pageSize int, cursor string) ([]models.ImageOut, *string, error) {
+	db := newFakeStores()
+	db.getWorkoutGallery = func(ctx context.Context, userId string, pageSize int, cursor string) ([]models.ImageOut, *string, error) {
 		return nil, nil, errors.New("boom")
 	}
-	t.Cleanup(func() { dbGetWorkoutGallery = orig })
 
-	c := newCtx()
+	c := db.attach(newCtx())
 	res, err := GetWorkoutGallery(c, "u1")
 
 	assert.Nil(t, res)
@@ -181,3 +237,157 @@ func TestGetWorkoutGallery_DBErrorMapping(t *testing.T) {
 	isHTTP := errors.As(err, &HTTPError)
 	assert.True(t, isHTTP)
 }
+
+func TestMakeWorkout_PassesConflictThrough(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	var got *int
+	db.saveWorkout = func(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error) {
+		got = expected
+		return nil, models.NewConflictError("Workout was changed on another device", models.WorkoutOut{Version: 3}, errors.New("stale"))
+	}
+
+	body := `{"id":"w1","start":"2025-07-18T05:40:48Z","exercises":[],"version":2}`
+	c := db.attach(newGinContextWithBody("POST", "/workouts", body))
+	res, err := MakeWorkout(c, "u1")
+
+	assert.Nil(t, res)
+	assert.Equal(t, 2, *got)
+	var conflict *models.ConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, 409, conflict.Status())
+	assert.JSONEq(t, `{"error":"Workout was changed on another device","code":"Conflict","details":{"current":{"id":"","name":"","start":"0001-01-01T00:00:00Z","end":null,"exercises":null,"version":3}}}`, string(conflict.JSON()))
+}
+
+func TestMakeWorkout_IfMatchDisagreesWithBody(t *testing.T) {
+	body := `{"id":"w1","start":"2025-07-18T05:40:48Z","exercises":[],"version":2}`
+	c := newGinContextWithBody("POST", "/workouts", body)
+	c.Request.Header.Set("If-Match", `"5"`)
+
+	res, err := MakeWorkout(c, "u1")
+
+	assert.Nil(t, res)
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
+
+func TestMakeWorkout_FlagsNewRecords(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	stubKnownExercises(db, []models.Exercise{{Name: "Bench Press"}}, nil)
+	db.saveWorkout = func(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error) {
+		return &in, nil
+	}
+	db.updateRecords = func(ctx context.Context, userId string, w *models.Workout) (map[string][]models.RecordKind, error) {
+		return map[string][]models.RecordKind{"s1": {models.RecordWeight}}, nil
+	}
+
+	body := `{"id":"w1","start":"2025-07-18T05:40:48Z","exercises":[{"id":"e1","exercise":"Bench Press","sets":[{"id":"s1","completed":true,"weight":100,"reps":1}]}]}`
+	c := db.attach(newGinContextWithBody("POST", "/workouts", body))
+	res, err := MakeWorkout(c, "u1")
+
+	assert.NoError(t, err)
+	out := res.(models.WorkoutOut)
+	assert.Equal(t, []models.RecordKind{models.RecordWeight}, out.Exercises[0].Sets[0].Records)
+}
+
+func TestMakeWorkout_SavesAliasUnderItsExercise(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db, models.NewExerciseAlias("u1", "DB Bench", "Dumbbell Bench Press"))
+	stubKnownExercises(db, []models.Exercise{{Name: "Dumbbell Bench Press"}}, nil)
+	var saved models.Workout
+	db.saveWorkout = func(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error) {
+		saved = in
+		return &in, nil
+	}
+	db.updateRecords = func(ctx context.Context, userId string, w *models.Workout) (map[string][]models.RecordKind, error) {
+		return nil, nil
+	}
+
+	body := `{"id":"w1","start":"2025-07-18T05:40:48Z","exercises":[{"id":"e1","exercise":"DB Bench","sets":[]}]}`
+	c := db.attach(newGinContextWithBody("POST", "/workouts", body))
+	_, err := MakeWorkout(c, "u1")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Dumbbell Bench Press", saved.Exercises[0].ExerciseID)
+}
+
+func TestMakeWorkout_RecordsFailureKeepsTheSave(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	db.saveWorkout = func(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error) {
+		return &in, nil
+	}
+	db.updateRecords = func(ctx context.Context, userId string, w *models.Workout) (map[string][]models.RecordKind, error) {
+		return nil, errors.New("boom")
+	}
+
+	c := db.attach(newGinContextWithBody("POST", "/workouts", `{"id":"w1","start":"2025-07-18T05:40:48Z","exercises":[]}`))
+	res, err := MakeWorkout(c, "u1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "w1", res.(models.WorkoutOut).ID)
+}
+
+func TestGetWorkouts_Filters(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+	var got models.WorkoutFilter
+	db.getWorkouts = func(ctx context.Context, userId string, pageSize int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error) {
+		got = filter
+		return nil, "", nil
+	}
+
+	c := db.attach(newCtx())
+	c.Request = httptest.NewRequest("GET", "/workouts?from=2025-03-01&to=2025-03-31&exercise=Bench%20Press&name=push", nil)
+	_, err := GetWorkouts(c, "u1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), got.From)
+	assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond), got.To)
+	assert.Equal(t, "Bench Press", got.Exercise)
+	assert.Equal(t, "push", got.Name)
+}
+
+func TestGetWorkouts_BadRange(t *testing.T) {
+	c := newCtx()
+	c.Request = httptest.NewRequest("GET", "/workouts?from=2025-04-01&to=2025-03-01", nil)
+	res, err := GetWorkouts(c, "u1")
+
+	assert.Nil(t, res)
+	var validation *models.ValidationError
+	assert.ErrorAs(t, err, &validation)
+}
+
+func TestMakeWorkout_CountsNewWorkoutTowardsTemplate(t *testing.T) {
+	db := newFakeStores()
+	stubAliases(db)
+
+	version := 1
+	db.saveWorkout = func(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error) {
+		in.Version = version
+		return &in, nil
+	}
+	db.updateRecords = func(ctx context.Context, userId string, w *models.Workout) (map[string][]models.RecordKind, error) {
+		return nil, nil
+	}
+	db.getTemplate = func(ctx context.Context, userId, templateId string) (*models.Template, error) {
+		return &models.Template{SK: "TEMPLATE#" + templateId}, nil
+	}
+	var recorded []string
+	db.recordTemplateUse = func(ctx context.Context, template *models.Template, workout *models.Workout) error {
+		recorded = append(recorded, template.ID()+"/"+workout.ID())
+		return nil
+	}
+
+	body := `{"id":"w1","start":"2025-07-18T05:40:48Z","templateId":"t1","exercises":[]}`
+	res, err := MakeWorkout(db.attach(newGinContextWithBody("POST", "/workouts", body)), "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, "t1", *res.(models.WorkoutOut).Template)
+
+	version = 2 // edits of the same workout do not count again
+	_, err = MakeWorkout(db.attach(newGinContextWithBody("POST", "/workouts", body)), "u1")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"t1/w1"}, recorded)
+}
@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"heart/internal/models"
+	"slices"
+	"time"
+)
+
+// hevy reads the CSV export of the Hevy app:
+// title,start_time,end_time,description,exercise_title,superset_id,exercise_notes,set_index,set_type,weight_kg,reps,distance_km,duration_seconds,rpe
+// Exports made with imperial units have weight_lbs and distance_miles instead.
+type hevy struct{}
+
+func init() {
+	Register(hevy{})
+}
+
+// hevyLayout is how Hevy writes times, e.g. "15 Jan 2023, 08:30".
+const hevyLayout = "2 Jan 2006, 15:04"
+
+func (hevy) Name() string {
+	return "hevy"
+}
+
+func (hevy) Matches(header []string) bool {
+	return slices.Contains(header, "exercise_title") && slices.Contains(header, "start_time")
+}
+
+func (hevy) Row(r Record, opts Options) (*Row, error) {
+	start, err := time.ParseInLocation(hevyLayout, r.Get("start_time"), opts.Location)
+	if err != nil {
+		return nil, fmt.Errorf("start_time is not a date: %q", r.Get("start_time"))
+	}
+
+	exercise := r.Get("exercise_title")
+	if ExerciseName(exercise) == "" {
+		return nil, errors.New("no exercise")
+	}
+
+	row := &Row{
+		Workout:  r.Get("title"),
+		Start:    start,
+		Exercise: exercise,
+	}
+
+	if end, err := time.ParseInLocation(hevyLayout, r.Get("end_time"), opts.Location); err == nil {
+		row.End = &end
+	}
+
+	weight, err := number("weight", r.Get("weight_kg", "weight_lbs"))
+	if err != nil {
+		return nil, err
+	}
+	reps, err := number("reps", r.Get("reps"))
+	if err != nil {
+		return nil, err
+	}
+	distance, err := number("distance", r.Get("distance_km", "distance_miles"))
+	if err != nil {
+		return nil, err
+	}
+	seconds, err := number("duration_seconds", r.Get("duration_seconds"))
+	if err != nil {
+		return nil, err
+	}
+
+	row.Set.Completed = true
+	row.Set.Weight = metric(weight, !r.Has("weight_kg") && r.Has("weight_lbs"), kgPerLb)
+	row.Set.Reps = int(reps)
+	row.Set.Distance = metric(distance, !r.Has("distance_km") && r.Has("distance_miles"), kmPerMile)
+	row.Set.Duration = seconds
+	row.Set.Type = hevySetTypes[r.Get("set_type")]
+	row.Set.RPE = rpe(r.Get("rpe"))
+
+	return row, nil
+}
+
+// hevySetTypes maps Hevy's set types to ours; "normal" sets are working sets.
+var hevySetTypes = map[string]models.SetType{
+	"warmup":  models.WarmupSet,
+	"dropset": models.DropSet,
+	"failure": models.FailureSet,
+}
@@ -0,0 +1,262 @@
+// Package importer reads workout history exported by other apps.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"heart/internal/models"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Format reads the CSV export of one app. Formats register themselves with Register.
+type Format interface {
+	Name() string
+	// Matches reports whether a header row is this format's.
+	Matches(header []string) bool
+	// Row reads one record into a set, or returns why the record has to be skipped.
+	Row(r Record, opts Options) (*Row, error)
+}
+
+// Row is one logged set, along with the workout and exercise it belongs to.
+type Row struct {
+	Workout  string
+	Start    time.Time
+	End      *time.Time
+	Exercise string
+	Set      models.Set
+}
+
+type Options struct {
+	Location *time.Location // for timestamps without a zone
+	Imperial bool           // weights in pounds and distances in miles, unless the export says
+}
+
+var formats []Format
+
+func Register(f Format) {
+	formats = append(formats, f)
+}
+
+// Formats lists the names of the registered formats.
+func Formats() []string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = f.Name()
+	}
+	return names
+}
+
+// Workout is an imported workout and how many rows went into it.
+type Workout struct {
+	In   models.WorkoutIn
+	Rows int
+}
+
+type Result struct {
+	Format   string
+	Workouts []Workout // oldest first
+	Skipped  []models.ImportSkip
+}
+
+// ErrUnknownFormat means the header matched none of the registered formats.
+var ErrUnknownFormat = errors.New("unrecognized export format")
+
+// Read parses an export, in the named format or, if empty, whichever one matches its header,
+// and groups its rows into workouts.
+func Read(r io.Reader, format string, opts Options) (*Result, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	buffered := bufio.NewReader(r)
+	reader := csv.NewReader(buffered)
+	reader.Comma = delimiter(buffered)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	f, err := find(format, header)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	result := &Result{Format: f.Name()}
+	var g grouper
+
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			result.Skipped = append(result.Skipped, models.ImportSkip{Line: line, Reason: err.Error()})
+			continue
+		}
+
+		row, err := f.Row(Record{columns: columns, values: values}, opts)
+		if err != nil {
+			result.Skipped = append(result.Skipped, models.ImportSkip{Line: line, Reason: err.Error()})
+			continue
+		}
+
+		g.add(row)
+	}
+
+	result.Workouts = g.workouts()
+	return result, nil
+}
+
+func find(name string, header []string) (Format, error) {
+	for _, f := range formats {
+		if name != "" && strings.EqualFold(name, f.Name()) {
+			return f, nil
+		}
+		if name == "" && f.Matches(header) {
+			return f, nil
+		}
+	}
+	return nil, ErrUnknownFormat
+}
+
+// delimiter sniffs the first line: some exports use semicolons, depending on locale.
+func delimiter(r *bufio.Reader) rune {
+	line, _ := r.Peek(4096)
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	if bytes.Count(line, []byte{';'}) > bytes.Count(line, []byte{','}) {
+		return ';'
+	}
+	return ','
+}
+
+// Record is a CSV record with its values looked up by column name.
+type Record struct {
+	columns map[string]int
+	values  []string
+}
+
+// Get returns the value in the first of the named columns present, trimmed.
+func (r Record) Get(names ...string) string {
+	for _, name := range names {
+		if i, ok := r.columns[name]; ok && i < len(r.values) {
+			return strings.TrimSpace(r.values[i])
+		}
+	}
+	return ""
+}
+
+// Has reports whether the export has the column.
+func (r Record) Has(name string) bool {
+	_, ok := r.columns[name]
+	return ok
+}
+
+// ExerciseName turns a name from another app into one ours accepts,
+// with letters, numbers and single spaces only: "Pull-Up (Weighted)" becomes "Pull Up Weighted".
+func ExerciseName(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, name)
+	return strings.Join(strings.Fields(cleaned), " ")
+}
+
+type grouper struct {
+	byKey   map[string]*group
+	byStart map[time.Time]int // groups so far starting at the same time
+}
+
+type group struct {
+	workout   Workout
+	base      time.Time      // the start, offset so that IDs differ from those of workouts starting alongside
+	exercises map[string]int // index in workout.In.Exercises
+}
+
+func (g *grouper) add(row *Row) {
+	if g.byKey == nil {
+		g.byKey = map[string]*group{}
+		g.byStart = map[time.Time]int{}
+	}
+
+	key := row.Start.UTC().Format(time.RFC3339) + "|" + row.Workout
+	w, ok := g.byKey[key]
+	if !ok {
+		start := row.Start.UTC()
+		base := start.Add(time.Duration(g.byStart[start]) * groupSpacing)
+		g.byStart[start]++
+
+		w = &group{
+			base: base,
+			workout: Workout{In: models.WorkoutIn{
+				ID:        models.Timestamp(base),
+				Name:      row.Workout,
+				Start:     row.Start,
+				End:       row.End,
+				Exercises: []models.WorkoutExerciseIn{},
+			}},
+			exercises: map[string]int{},
+		}
+		g.byKey[key] = w
+	}
+
+	in := &w.workout.In
+	name := ExerciseName(row.Exercise)
+	index, ok := w.exercises[name]
+	if !ok {
+		index = len(in.Exercises)
+		w.exercises[name] = index
+		in.Exercises = append(in.Exercises, models.WorkoutExerciseIn{
+			ID:       sequenceID(w.base, w.workout.Rows),
+			Exercise: name,
+			Order:    index,
+			Sets:     []models.Set{},
+		})
+	}
+
+	set := row.Set
+	set.ID = sequenceID(w.base, w.workout.Rows)
+	in.Exercises[index].Sets = append(in.Exercises[index].Sets, set)
+	w.workout.Rows++
+}
+
+func (g *grouper) workouts() []Workout {
+	workouts := make([]Workout, 0, len(g.byKey))
+	for _, w := range g.byKey {
+		workouts = append(workouts, w.workout)
+	}
+	sort.Slice(workouts, func(i, j int) bool {
+		return workouts[i].In.Start.Before(workouts[j].In.Start)
+	})
+	return workouts
+}
+
+// groupSpacing sets apart the IDs of workouts starting at the same time, each later one in the
+// export a millisecond on, clear of the microsecond steps of sequenceID within a workout.
+const groupSpacing = time.Millisecond
+
+// sequenceID makes IDs in the style of the app's, timestamps, that stay put on a re-import.
+func sequenceID(start time.Time, n int) string {
+	return models.Timestamp(start.Add(time.Duration(n+1) * time.Microsecond))
+}
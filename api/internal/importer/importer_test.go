@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"heart/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const strongExport = "\ufeffDate;Workout Name;Duration;Exercise Name;Set Order;Weight;Reps;Distance;Seconds;Notes;Workout Notes;RPE\n" +
+	"2024-05-01 18:30:00;Legs;1h 5m;Squat (Barbell);1;225;5;0;0;;;\n" +
+	"2024-05-01 18:30:00;Legs;1h 5m;Squat (Barbell);Rest Timer;0;0;0;90;;;\n" +
+	"2024-05-01 18:30:00;Legs;1h 5m;Squat (Barbell);2;225;5;0;0;Knees in;;8.4\n" +
+	"2024-05-01 18:30:00;Legs;1h 5m;Squat (Barbell);W;135;5;0;0;;;\n" +
+	"2024-05-01 18:30:00;Legs;1h 5m;Running;1;0;0;1;600;;;\n" +
+	"yesterday;Legs;1h;Squat (Barbell);1;225;5;0;0;;;\n" +
+	"2024-04-28 09:00:00;Push;45m;Bench Press (Barbell);1;135;8;0;0;;;\n"
+
+func TestRead_StrongImperialSemicolons(t *testing.T) {
+	toronto, _ := time.LoadLocation("America/Toronto")
+
+	result, err := Read(strings.NewReader(strongExport), "", Options{Location: toronto, Imperial: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, "strong", result.Format)
+	require.Len(t, result.Workouts, 2)
+
+	push := result.Workouts[0].In // oldest first
+	assert.Equal(t, "Push", push.Name)
+	assert.Equal(t, "Bench Press Barbell", push.Exercises[0].Exercise)
+
+	legs := result.Workouts[1]
+	assert.Equal(t, 4, legs.Rows)
+	assert.True(t, legs.In.Start.Equal(time.Date(2024, 5, 1, 18, 30, 0, 0, toronto)))
+	require.NotNil(t, legs.In.End)
+	assert.Equal(t, 65*time.Minute, legs.In.End.Sub(legs.In.Start))
+
+	require.Len(t, legs.In.Exercises, 2)
+	squat := legs.In.Exercises[0]
+	assert.Equal(t, "Squat Barbell", squat.Exercise)
+	require.Len(t, squat.Sets, 3)
+	assert.Equal(t, 102.06, squat.Sets[0].Weight)
+	assert.Equal(t, 5, squat.Sets[0].Reps)
+	assert.NotEqual(t, squat.Sets[0].ID, squat.Sets[1].ID)
+	assert.Equal(t, "Knees in", squat.Sets[1].Note)
+	assert.Equal(t, 8.5, squat.Sets[1].RPE)
+	assert.Equal(t, models.WarmupSet, squat.Sets[2].Type)
+	assert.Equal(t, 1.61, legs.In.Exercises[1].Sets[0].Distance)
+
+	require.Len(t, result.Skipped, 2)
+	assert.Equal(t, 3, result.Skipped[0].Line)
+	assert.Equal(t, "rest timer", result.Skipped[0].Reason)
+	assert.Equal(t, 7, result.Skipped[1].Line)
+}
+
+func TestRead_SameExportSameIDs(t *testing.T) {
+	first, err := Read(strings.NewReader(strongExport), "", Options{})
+	require.NoError(t, err)
+	second, err := Read(strings.NewReader(strongExport), "", Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, first.Workouts, second.Workouts)
+}
+
+func TestRead_Hevy(t *testing.T) {
+	export := "title,start_time,end_time,description,exercise_title,superset_id,exercise_notes,set_index,set_type,weight_lbs,reps,distance_miles,duration_seconds,rpe\n" +
+		"Morning,\"15 Jan 2023, 08:30\",\"15 Jan 2023, 09:15\",,Pull Up (Weighted),,,0,warmup,20,8,,,\n" +
+		"Morning,\"15 Jan 2023, 08:30\",\"15 Jan 2023, 09:15\",,Pull Up (Weighted),,,1,normal,20,many,,,\n"
+
+	result, err := Read(strings.NewReader(export), "", Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "hevy", result.Format)
+	require.Len(t, result.Workouts, 1)
+
+	w := result.Workouts[0].In
+	assert.Equal(t, "Morning", w.Name)
+	require.NotNil(t, w.End)
+	assert.Equal(t, 45*time.Minute, w.End.Sub(w.Start))
+	require.Len(t, w.Exercises, 1)
+	assert.Equal(t, "Pull Up Weighted", w.Exercises[0].Exercise)
+	assert.Equal(t, 9.07, w.Exercises[0].Sets[0].Weight)
+	assert.Equal(t, models.WarmupSet, w.Exercises[0].Sets[0].Type)
+
+	require.Len(t, result.Skipped, 1)
+	assert.Contains(t, result.Skipped[0].Reason, "reps")
+}
+
+func TestRead_UnknownFormat(t *testing.T) {
+	_, err := Read(strings.NewReader("a,b,c\n1,2,3\n"), "", Options{})
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = Read(strings.NewReader(strongExport), "fitbod", Options{})
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestRead_WorkoutsStartingTogetherGetTheirOwnIDs(t *testing.T) {
+	export := "Date;Workout Name;Duration;Exercise Name;Set Order;Weight;Reps;Distance;Seconds;Notes;Workout Notes;RPE\n" +
+		"2024-05-01 18:30:00;Legs;1h;Squat (Barbell);1;100;5;0;0;;;\n" +
+		"2024-05-01 18:30:00;Arms;1h;Curl (Dumbbell);1;10;12;0;0;;;\n" +
+		"2024-05-01 18:30:00;Legs;1h;Squat (Barbell);2;100;5;0;0;;;\n"
+
+	result, err := Read(strings.NewReader(export), "", Options{})
+	require.NoError(t, err)
+	require.Len(t, result.Workouts, 2)
+
+	workouts, sets := map[string]bool{}, map[string]bool{}
+	for _, w := range result.Workouts {
+		workouts[w.In.ID] = true
+		for _, e := range w.In.Exercises {
+			for _, s := range e.Sets {
+				sets[s.ID] = true
+			}
+		}
+	}
+	assert.Len(t, workouts, 2)
+	assert.Len(t, sets, 3)
+
+	again, err := Read(strings.NewReader(export), "", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, result.Workouts, again.Workouts)
+}
@@ -0,0 +1,119 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"heart/internal/models"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// strong reads the CSV export of the Strong app:
+// Date;Workout Name;Duration;Exercise Name;Set Order;Weight;Reps;Distance;Seconds;Notes;Workout Notes;RPE
+// Newer versions add Weight Unit and Distance Unit columns; older ones use the units set in the app.
+type strong struct{}
+
+func init() {
+	Register(strong{})
+}
+
+func (strong) Name() string {
+	return "strong"
+}
+
+func (strong) Matches(header []string) bool {
+	return slices.Contains(header, "Workout Name") && slices.Contains(header, "Exercise Name") && slices.Contains(header, "Set Order")
+}
+
+func (strong) Row(r Record, opts Options) (*Row, error) {
+	order := r.Get("Set Order")
+	if strings.EqualFold(order, "Rest Timer") {
+		return nil, errors.New("rest timer")
+	}
+
+	start, err := time.ParseInLocation(time.DateTime, r.Get("Date"), opts.Location)
+	if err != nil {
+		return nil, fmt.Errorf("Date is not a date: %q", r.Get("Date"))
+	}
+
+	exercise := r.Get("Exercise Name")
+	if ExerciseName(exercise) == "" {
+		return nil, errors.New("no exercise")
+	}
+
+	row := &Row{
+		Workout:  r.Get("Workout Name"),
+		Start:    start,
+		Exercise: exercise,
+	}
+
+	if duration, ok := strongDuration(r.Get("Duration")); ok {
+		end := start.Add(duration)
+		row.End = &end
+	}
+
+	weight, err := number("Weight", r.Get("Weight"))
+	if err != nil {
+		return nil, err
+	}
+	reps, err := number("Reps", r.Get("Reps"))
+	if err != nil {
+		return nil, err
+	}
+	distance, err := number("Distance", r.Get("Distance"))
+	if err != nil {
+		return nil, err
+	}
+	seconds, err := number("Seconds", r.Get("Seconds"))
+	if err != nil {
+		return nil, err
+	}
+
+	pounds, miles := opts.Imperial, opts.Imperial
+	if r.Has("Weight Unit") {
+		pounds = strings.HasPrefix(strings.ToLower(r.Get("Weight Unit")), "lb")
+	}
+	if r.Has("Distance Unit") {
+		miles = strings.HasPrefix(strings.ToLower(r.Get("Distance Unit")), "mi")
+	}
+
+	row.Set.Completed = true
+	row.Set.Weight = metric(weight, pounds, kgPerLb)
+	row.Set.Reps = int(reps)
+	row.Set.Distance = metric(distance, miles, kmPerMile)
+	row.Set.Duration = seconds
+	row.Set.Type = strongSetTypes[strings.ToUpper(order)]
+	row.Set.RPE = rpe(r.Get("RPE"))
+	row.Set.Note = note(r.Get("Notes"))
+
+	return row, nil
+}
+
+// strongSetTypes maps the letters Strong puts in place of the set number; numbered sets are working sets.
+var strongSetTypes = map[string]models.SetType{
+	"W": models.WarmupSet,
+	"D": models.DropSet,
+	"F": models.FailureSet,
+}
+
+var strongDurationPart = regexp.MustCompile(`(\d+)\s*([hms])`)
+
+// strongDuration reads durations like "1h 5m" or "45m".
+func strongDuration(value string) (time.Duration, bool) {
+	parts := strongDurationPart.FindAllStringSubmatch(value, -1)
+	if len(parts) == 0 {
+		return 0, false
+	}
+
+	units := map[string]time.Duration{"h": time.Hour, "m": time.Minute, "s": time.Second}
+
+	var d time.Duration
+	for _, p := range parts {
+		n, _ := strconv.Atoi(p[1])
+		d += time.Duration(n) * units[p[2]]
+	}
+	return d, true
+}
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"fmt"
+	"heart/internal/models"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	kgPerLb   = 0.45359237
+	kmPerMile = 1.609344
+)
+
+// number parses a decimal that may be empty, which reads as zero, or use a decimal comma.
+func number(column, value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a number: %q", column, value)
+	}
+	return n, nil
+}
+
+func round(n float64) float64 {
+	return math.Round(n*100) / 100
+}
+
+// metric converts a value to kilograms or kilometers.
+func metric(n float64, imperial bool, factor float64) float64 {
+	if imperial {
+		return round(n * factor)
+	}
+	return n
+}
+
+// rpe reads an RPE, rounded to the half. One that is not a number or out of range is dropped
+// rather than failing the set over it.
+func rpe(value string) float64 {
+	n, err := number("RPE", value)
+	if err != nil || n < 1 || n > models.MaxRPE {
+		return 0
+	}
+	return math.Round(n*2) / 2
+}
+
+// note cuts a note down to the size a set can hold.
+func note(value string) string {
+	if runes := []rune(value); len(runes) > models.MaxNoteSize {
+		return string(runes[:models.MaxNoteSize])
+	}
+	return value
+}
@@ -0,0 +1,187 @@
+package localdb
+
+import (
+	"context"
+	"fmt"
+	"heart/internal/models"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func (s *Store) SaveAccount(ctx context.Context, userId string, in models.User) (*models.User, error) {
+	in.FirebaseUID = userId
+	internal := models.NewUserInternal(&in)
+
+	var out models.UserInternal
+	err := s.update(ctx, func(t tx) error {
+		stored, err := t.get(internal.PK, internal.PK)
+		if err != nil {
+			return err
+		}
+
+		next := edit(stored, internal.PK, internal.PK)
+		next["username"] = optional(internal.Username)
+		next["email"] = &types.AttributeValueMemberS{Value: internal.Email}
+		next["avatar"] = optional(internal.AvatarUrl)
+		next["firebase_uid"] = &types.AttributeValueMemberS{Value: internal.FirebaseUID}
+		if err := t.put(next); err != nil {
+			return err
+		}
+
+		return attributevalue.UnmarshalMap(next, &out)
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	user := models.NewUser(&out)
+
+	return &user, nil
+}
+
+// optional stores a missing value as NULL, the way SaveAccount always has.
+func optional(v *string) types.AttributeValue {
+	if v == nil {
+		return &types.AttributeValueMemberNULL{Value: true}
+	}
+	return &types.AttributeValueMemberS{Value: *v}
+}
+
+func (s *Store) GetAccount(ctx context.Context, userId string) (*models.User, error) {
+	pk := models.UserKey + userId
+
+	var out *models.UserInternal
+	err := s.view(ctx, func(t tx) (err error) {
+		out, err = load[models.UserInternal](t, pk, pk)
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	if out == nil {
+		return nil, nil // handled down the line
+	}
+	user := models.NewUser(out)
+
+	return &user, nil
+}
+
+func (s *Store) ScheduleAccountForDeletion(ctx context.Context, userId string, scheduleArn string, when int64) error {
+	err := s.editAccount(ctx, userId, func(next item) {
+		next["account_deletion_schedule"] = &types.AttributeValueMemberS{Value: scheduleArn}
+		next["scheduled_for_deletion_at"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(when, 10)}
+	})
+	if err != nil {
+		return models.NewServerError(fmt.Errorf("failed to schedule account deletion: %w", err))
+	}
+
+	return nil
+}
+
+func (s *Store) UndoAccountDeletion(ctx context.Context, userId string) error {
+	err := s.editAccount(ctx, userId, func(next item) {
+		delete(next, "account_deletion_schedule")
+		delete(next, "scheduled_for_deletion_at")
+	})
+	if err != nil {
+		return models.NewServerError(fmt.Errorf("failed to undo account deletion: %w", err))
+	}
+	return nil
+}
+
+func (s *Store) RemoveAvatar(ctx context.Context, userId string) error {
+	err := s.editAccount(ctx, userId, func(next item) {
+		delete(next, "avatar")
+	})
+	if err != nil {
+		return models.NewServerError(fmt.Errorf("failed to remove avatar: %w", err))
+	}
+	return nil
+}
+
+// editAccount applies change to the user's account, which must exist.
+func (s *Store) editAccount(ctx context.Context, userId string, change func(item)) error {
+	pk := models.UserKey + userId
+
+	return s.update(ctx, func(t tx) error {
+		stored, err := t.get(pk, pk)
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			return errConditionFailed
+		}
+
+		next := edit(stored, pk, pk)
+		change(next)
+		return t.put(next)
+	})
+}
+
+// GetAccountItemKeys returns the keys of every item in the user's partition.
+func (s *Store) GetAccountItemKeys(ctx context.Context, userId string) ([]models.ItemKey, error) {
+	var keys []models.ItemKey
+	err := s.view(ctx, func(t tx) error {
+		items, err := t.scan(models.UserKey+userId, span{}, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, it := range items {
+			keys = append(keys, models.ItemKey{PK: partitionKey(it), SK: sortKey(it)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return keys, nil
+}
+
+// DeleteItems deletes the given items and returns how many were deleted.
+func (s *Store) DeleteItems(ctx context.Context, keys []models.ItemKey) (int, error) {
+	err := s.update(ctx, func(t tx) error {
+		for _, k := range keys {
+			if err := t.delete(k.PK, k.SK); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, failed(err)
+	}
+
+	return len(keys), nil
+}
+
+func (s *Store) GetDataExport(ctx context.Context, userId string) (*models.DataExport, error) {
+	var export *models.DataExport
+	err := s.view(ctx, func(t tx) (err error) {
+		export, err = load[models.DataExport](t, models.UserKey+userId, models.DataExportKey)
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	if export == nil {
+		return nil, models.NewNotFoundError("Export not found", nil)
+	}
+	return export, nil
+}
+
+func (s *Store) SaveDataExport(ctx context.Context, in models.DataExport) (*models.DataExport, error) {
+	err := s.update(ctx, func(t tx) error {
+		return save(t, in)
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return &in, nil
+}
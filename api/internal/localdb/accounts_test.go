@@ -0,0 +1,56 @@
+package localdb
+
+import (
+	"context"
+	"testing"
+
+	"heart/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleAccountForDeletion_NeedsTheAccount(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	err := s.ScheduleAccountForDeletion(ctx, "u1", "arn:schedule", 1750000000)
+	var serverErr *models.ServerError
+	require.ErrorAs(t, err, &serverErr)
+
+	_, err = s.SaveAccount(ctx, "u1", models.User{})
+	require.NoError(t, err)
+	require.NoError(t, s.ScheduleAccountForDeletion(ctx, "u1", "arn:schedule", 1750000000))
+
+	user, err := s.GetAccount(ctx, "u1")
+	require.NoError(t, err)
+	require.NotNil(t, user.ScheduledForDeletionAt)
+	assert.Equal(t, int64(1750000000), user.ScheduledForDeletionAt.Unix())
+
+	require.NoError(t, s.UndoAccountDeletion(ctx, "u1"))
+	user, err = s.GetAccount(ctx, "u1")
+	require.NoError(t, err)
+	assert.Nil(t, user.ScheduledForDeletionAt)
+}
+
+func TestDeleteItems_EmptiesThePartition(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	_, err := s.SaveAccount(ctx, "u1", models.User{})
+	require.NoError(t, err)
+	_, err = s.SaveWorkout(ctx, newWorkout("u1", "2025-07-01T18:00:00Z", "Squat"), nil)
+	require.NoError(t, err)
+
+	keys, err := s.GetAccountItemKeys(ctx, "u1")
+	require.NoError(t, err)
+	assert.Len(t, keys, 3) // account, workout and its history entry
+
+	deleted, err := s.DeleteItems(ctx, keys)
+	require.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+
+	user, err := s.GetAccount(ctx, "u1")
+	require.NoError(t, err)
+	assert.Nil(t, user)
+}
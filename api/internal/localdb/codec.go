@@ -0,0 +1,161 @@
+package localdb
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// encodeItem writes the item in DynamoDB's JSON, each attribute tagged with its type,
+// so that it reads back exactly as it was put.
+func encodeItem(it item) ([]byte, error) {
+	wire := make(map[string]any, len(it))
+	for name, av := range it {
+		v, err := encodeValue(av)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+		wire[name] = v
+	}
+	return json.Marshal(wire)
+}
+
+func encodeValue(av types.AttributeValue) (map[string]any, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return map[string]any{"S": v.Value}, nil
+	case *types.AttributeValueMemberN:
+		return map[string]any{"N": v.Value}, nil
+	case *types.AttributeValueMemberB:
+		return map[string]any{"B": v.Value}, nil
+	case *types.AttributeValueMemberBOOL:
+		return map[string]any{"BOOL": v.Value}, nil
+	case *types.AttributeValueMemberNULL:
+		return map[string]any{"NULL": true}, nil
+	case *types.AttributeValueMemberSS:
+		return map[string]any{"SS": v.Value}, nil
+	case *types.AttributeValueMemberNS:
+		return map[string]any{"NS": v.Value}, nil
+	case *types.AttributeValueMemberBS:
+		return map[string]any{"BS": v.Value}, nil
+	case *types.AttributeValueMemberL:
+		list := make([]any, len(v.Value))
+		for i, el := range v.Value {
+			enc, err := encodeValue(el)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = enc
+		}
+		return map[string]any{"L": list}, nil
+	case *types.AttributeValueMemberM:
+		m := make(map[string]any, len(v.Value))
+		for name, el := range v.Value {
+			enc, err := encodeValue(el)
+			if err != nil {
+				return nil, err
+			}
+			m[name] = enc
+		}
+		return map[string]any{"M": m}, nil
+	default:
+		return nil, fmt.Errorf("unsupported attribute value %T", av)
+	}
+}
+
+// decodeItem reads back an item written by encodeItem.
+func decodeItem(raw []byte) (item, error) {
+	var wire map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &wire); err != nil {
+		return nil, err
+	}
+
+	it := make(item, len(wire))
+	for name, enc := range wire {
+		av, err := decodeValue(enc)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+		it[name] = av
+	}
+	return it, nil
+}
+
+func decodeValue(raw json.RawMessage) (types.AttributeValue, error) {
+	var tagged map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &tagged); err != nil {
+		return nil, err
+	}
+	if len(tagged) != 1 {
+		return nil, fmt.Errorf("want one type per value, got %d", len(tagged))
+	}
+
+	var kind string
+	var v json.RawMessage
+	for kind, v = range tagged { // the only pair
+	}
+
+	switch kind {
+	case "S":
+		var s string
+		err := json.Unmarshal(v, &s)
+		return &types.AttributeValueMemberS{Value: s}, err
+	case "N":
+		var n string
+		err := json.Unmarshal(v, &n)
+		return &types.AttributeValueMemberN{Value: n}, err
+	case "B":
+		var b []byte
+		err := json.Unmarshal(v, &b)
+		return &types.AttributeValueMemberB{Value: b}, err
+	case "BOOL":
+		var b bool
+		err := json.Unmarshal(v, &b)
+		return &types.AttributeValueMemberBOOL{Value: b}, err
+	case "NULL":
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case "SS":
+		var ss []string
+		err := json.Unmarshal(v, &ss)
+		return &types.AttributeValueMemberSS{Value: ss}, err
+	case "NS":
+		var ns []string
+		err := json.Unmarshal(v, &ns)
+		return &types.AttributeValueMemberNS{Value: ns}, err
+	case "BS":
+		var bs [][]byte
+		err := json.Unmarshal(v, &bs)
+		return &types.AttributeValueMemberBS{Value: bs}, err
+	case "L":
+		var list []json.RawMessage
+		if err := json.Unmarshal(v, &list); err != nil {
+			return nil, err
+		}
+		out := make([]types.AttributeValue, len(list))
+		for i, el := range list {
+			av, err := decodeValue(el)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = av
+		}
+		return &types.AttributeValueMemberL{Value: out}, nil
+	case "M":
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(v, &m); err != nil {
+			return nil, err
+		}
+		out := make(map[string]types.AttributeValue, len(m))
+		for name, el := range m {
+			av, err := decodeValue(el)
+			if err != nil {
+				return nil, err
+			}
+			out[name] = av
+		}
+		return &types.AttributeValueMemberM{Value: out}, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", kind)
+	}
+}
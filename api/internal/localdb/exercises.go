@@ -0,0 +1,464 @@
+package localdb
+
+import (
+	"context"
+	"fmt"
+	"heart/internal/models"
+	"heart/internal/validation"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// catalogKey is the partition of the exercise catalog.
+const catalogKey = "EXERCISE"
+
+func (s *Store) GetExercises(ctx context.Context) ([]models.Exercise, error) {
+	var exercises []models.Exercise
+	err := s.view(ctx, func(t tx) (err error) {
+		exercises, err = all[models.Exercise](t, catalogKey, span{})
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return exercises, nil
+}
+
+// PutCatalog adds the exercises to the catalog, over any of the same name.
+// Offline, there is no other way for the catalog to fill.
+func (s *Store) PutCatalog(ctx context.Context, exercises []models.Exercise) error {
+	err := s.update(ctx, func(t tx) error {
+		for _, e := range exercises {
+			raw, err := attributevalue.MarshalMap(e)
+			if err != nil {
+				return err
+			}
+			// the catalog is read by the keys DynamoDB gives it, whatever the model calls them
+			delete(raw, "pk")
+			delete(raw, "sk")
+			raw["PK"] = &types.AttributeValueMemberS{Value: catalogKey}
+			raw["SK"] = &types.AttributeValueMemberS{Value: e.Name}
+
+			if err := t.put(raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return failed(err)
+}
+
+func (s *Store) MakeExercise(ctx context.Context, in models.UserExerciseIn, userId string) (*models.UserExerciseIn, error) {
+	if !validation.IsValidName(in.Name) {
+		return nil, models.NewValidationError(fmt.Errorf("exercise name can only contain letters, numbers and spaces"))
+	}
+
+	exercise := models.NewUserExercise(&in, userId)
+	exercise.UpdatedAt = models.Timestamp(time.Now())
+
+	err := s.update(ctx, func(t tx) error {
+		taken, err := t.get(exercise.PK, exercise.SK)
+		if err != nil {
+			return err
+		}
+		if taken != nil {
+			return models.NewValidationError(fmt.Errorf("exercise with name '%s' already exists", in.Name))
+		}
+
+		return save(t, exercise)
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return &in, nil
+}
+
+func (s *Store) GetOwnExercises(ctx context.Context, userId string) ([]models.Exercise, error) {
+	var exercises []models.Exercise
+	err := s.view(ctx, func(t tx) (err error) {
+		exercises, err = all[models.Exercise](t, models.UserKey+userId, prefixed(models.ExerciseKey))
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	for i := range exercises {
+		name, err := url.QueryUnescape(strings.TrimPrefix(exercises[i].Name, models.ExerciseKey))
+		if err != nil {
+			return nil, models.NewServerError(err)
+		}
+		exercises[i].Name = name
+	}
+
+	return exercises, nil
+}
+
+// GetOwnExercise returns an exercise the user made, by name.
+func (s *Store) GetOwnExercise(ctx context.Context, userId string, name string) (*models.Exercise, error) {
+	var exercise *models.Exercise
+	err := s.view(ctx, func(t tx) (err error) {
+		exercise, err = getOwnExercise(t, userId, name)
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return exercise, nil
+}
+
+func getOwnExercise(t tx, userId string, name string) (*models.Exercise, error) {
+	exercise, err := load[models.Exercise](t, models.UserKey+userId, ownExerciseKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if exercise == nil {
+		return nil, models.NewNotFoundError("Exercise not found", nil)
+	}
+
+	exercise.Name = name
+	return exercise, nil
+}
+
+func ownExerciseKey(name string) string {
+	return models.ExerciseKey + url.PathEscape(name)
+}
+
+func (s *Store) EditExercise(ctx context.Context, userId string, exerciseName string, in models.EditExerciseIn) (*models.Exercise, error) {
+	if in.Category == nil && in.Target == nil && in.Instructions == nil && in.Archived == nil {
+		return nil, models.NewValidationError(fmt.Errorf("no fields to update"))
+	}
+
+	pk, sk := models.UserKey+userId, ownExerciseKey(exerciseName)
+
+	var updated models.Exercise
+	err := s.update(ctx, func(t tx) error {
+		stored, err := t.get(pk, sk)
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			return models.NewValidationError(fmt.Errorf("exercise with name '%s' does not exist", exerciseName))
+		}
+
+		next := edit(stored, pk, sk)
+		if in.Category != nil {
+			next["category"] = &types.AttributeValueMemberS{Value: *in.Category}
+		}
+		if in.Target != nil {
+			next["target"] = &types.AttributeValueMemberS{Value: *in.Target}
+		}
+		if in.Instructions != nil {
+			if *in.Instructions == "" {
+				delete(next, "instructions")
+			} else {
+				next["instructions"] = &types.AttributeValueMemberS{Value: *in.Instructions}
+			}
+		}
+		if in.Archived != nil {
+			next["archived"] = &types.AttributeValueMemberBOOL{Value: *in.Archived}
+		}
+		next["updated_at"] = &types.AttributeValueMemberS{Value: models.Timestamp(time.Now())}
+
+		if err := t.put(next); err != nil {
+			return err
+		}
+		return attributevalue.UnmarshalMap(next, &updated)
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	name, err := url.PathUnescape(strings.TrimPrefix(updated.Name, models.ExerciseKey))
+	if err != nil {
+		return nil, models.NewServerError(err)
+	}
+	updated.Name = name
+
+	return &updated, nil
+}
+
+// RenameExercise gives an own exercise a new name, and with it the workouts, templates and
+// programs that refer to it, its history and its personal records, all at once.
+func (s *Store) RenameExercise(ctx context.Context, userId string, from string, to string) (*models.Exercise, error) {
+	to = strings.TrimSpace(to)
+	if !validation.IsValidName(to) {
+		return nil, models.NewValidationError(fmt.Errorf("exercise name can only contain letters, numbers and spaces"))
+	}
+
+	pk := models.UserKey + userId
+
+	var renamed models.Exercise
+	err := s.update(ctx, func(t tx) error {
+		stored, err := t.get(pk, ownExerciseKey(from))
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			return models.NewNotFoundError("Exercise not found", nil)
+		}
+
+		taken, err := t.get(pk, ownExerciseKey(to))
+		if err != nil {
+			return err
+		}
+		if taken != nil {
+			return models.NewValidationError(fmt.Errorf("exercise with name '%s' already exists", to))
+		}
+
+		uses, err := getExerciseUses(t, userId, from)
+		if err != nil {
+			return err
+		}
+		if err := uses.rewrite(t, from, to); err != nil {
+			return err
+		}
+		if err := moveRecords(t, userId, from, to); err != nil {
+			return err
+		}
+
+		next := edit(stored, pk, ownExerciseKey(to))
+		next["SK"] = &types.AttributeValueMemberS{Value: ownExerciseKey(to)}
+		next["name"] = &types.AttributeValueMemberS{Value: to}
+		next["updated_at"] = &types.AttributeValueMemberS{Value: models.Timestamp(time.Now())}
+		if err := t.put(next); err != nil {
+			return err
+		}
+		if err := deleteWithTombstone(t, userId, models.KindExercise, ownExerciseKey(from), from); err != nil {
+			return err
+		}
+
+		return attributevalue.UnmarshalMap(next, &renamed)
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	renamed.Name = to
+	return &renamed, nil
+}
+
+// DeleteExercise deletes an own exercise. One still in use is refused with a conflict that
+// counts what uses it, unless cascade is set: then it is taken out of the workouts, templates
+// and programs first, and its history and personal records go with it.
+// It returns the exercise as it was, so that its images can go too.
+func (s *Store) DeleteExercise(ctx context.Context, userId string, name string, cascade bool) (*models.Exercise, error) {
+	var exercise *models.Exercise
+	err := s.update(ctx, func(t tx) (err error) {
+		exercise, err = getOwnExercise(t, userId, name)
+		if err != nil {
+			return err
+		}
+
+		uses, err := getExerciseUses(t, userId, name)
+		if err != nil {
+			return err
+		}
+		if usage := uses.usage(); usage.InUse() && !cascade {
+			return models.NewConflictError("Exercise is still in use", usage, nil)
+		}
+
+		if err := uses.rewrite(t, name, ""); err != nil {
+			return err
+		}
+		if err := t.delete(models.UserKey+userId, models.RecordsSK(name)); err != nil {
+			return err
+		}
+
+		return deleteWithTombstone(t, userId, models.KindExercise, ownExerciseKey(name), name)
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return exercise, nil
+}
+
+// MergeExercise folds an own exercise into another one, the target, which may be from the catalog.
+// What was logged or planned under it moves to the target, its personal records are folded into
+// the target's, and its name, along with any names merged into it before, becomes an alias of the target.
+// It returns the exercise merged away, as it was.
+func (s *Store) MergeExercise(ctx context.Context, userId string, from string, to string) (*models.Exercise, error) {
+	var exercise *models.Exercise
+	err := s.update(ctx, func(t tx) (err error) {
+		exercise, err = getOwnExercise(t, userId, from)
+		if err != nil {
+			return err
+		}
+
+		uses, err := getExerciseUses(t, userId, from)
+		if err != nil {
+			return err
+		}
+		if err := uses.rewrite(t, from, to); err != nil {
+			return err
+		}
+		if _, err := updateRecords(t, userId, uses.workouts); err != nil {
+			return err
+		}
+
+		aliases, err := all[models.ExerciseAlias](t, models.UserKey+userId, prefixed(models.AliasKey))
+		if err != nil {
+			return err
+		}
+
+		// the name merged now, and the names merged into it before, all lead to the target from here on
+		aliases = append(aliases, models.NewExerciseAlias(userId, from, from))
+		for _, alias := range aliases {
+			if alias.Target != from {
+				continue
+			}
+			alias.Target = to
+			if err := save(t, alias); err != nil {
+				return err
+			}
+		}
+
+		if err := t.delete(models.UserKey+userId, models.RecordsSK(from)); err != nil {
+			return err
+		}
+		return deleteWithTombstone(t, userId, models.KindExercise, ownExerciseKey(from), from)
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return exercise, nil
+}
+
+// GetAliases returns the names the user has merged into other exercises.
+func (s *Store) GetAliases(ctx context.Context, userId string) ([]models.ExerciseAlias, error) {
+	var aliases []models.ExerciseAlias
+	err := s.view(ctx, func(t tx) (err error) {
+		aliases, err = all[models.ExerciseAlias](t, models.UserKey+userId, prefixed(models.AliasKey))
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return aliases, nil
+}
+
+// exerciseUses is what refers to an exercise by name.
+type exerciseUses struct {
+	workouts  []models.Workout
+	templates []models.Template
+	programs  []models.Program
+}
+
+// getExerciseUses finds the workouts the exercise was logged in through its history,
+// written first for workouts that have none, and the templates and programs that plan it.
+func getExerciseUses(t tx, userId string, name string) (*exerciseUses, error) {
+	if err := buildHistory(t, userId); err != nil {
+		return nil, err
+	}
+
+	pk := models.UserKey + userId
+
+	entries, err := all[models.HistoryEntry](t, pk, prefixed(models.HistoryPrefix(name)))
+	if err != nil {
+		return nil, err
+	}
+
+	uses := &exerciseUses{}
+	for _, e := range entries {
+		w, err := load[models.Workout](t, pk, models.WorkoutKey+e.WorkoutID)
+		if err != nil {
+			return nil, err
+		}
+		if w != nil {
+			uses.workouts = append(uses.workouts, *w)
+		}
+	}
+
+	templates, err := all[models.Template](t, pk, prefixed(models.TemplateKey))
+	if err != nil {
+		return nil, err
+	}
+	for _, tmpl := range templates {
+		if slices.ContainsFunc(tmpl.Exercises, func(e models.TemplateExercise) bool { return e.ExerciseID == name }) {
+			uses.templates = append(uses.templates, tmpl)
+		}
+	}
+
+	programs, err := all[models.Program](t, pk, prefixed(models.ProgramKey))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range programs {
+		if slices.ContainsFunc(p.Progression, func(r models.Progression) bool { return r.Exercise == name }) {
+			uses.programs = append(uses.programs, p)
+		}
+	}
+
+	return uses, nil
+}
+
+func (u *exerciseUses) usage() models.ExerciseUsageOut {
+	return models.ExerciseUsageOut{
+		Workouts:  len(u.workouts),
+		Templates: len(u.templates),
+		Programs:  len(u.programs),
+	}
+}
+
+// rewrite renames the exercise everywhere it is used, or takes it out when to is empty,
+// and writes each item back as its next version, the history of the workouts included.
+// The uses are left as written.
+func (u *exerciseUses) rewrite(t tx, from string, to string) error {
+	updatedAt := models.Timestamp(time.Now())
+
+	change := func(e interface {
+		RenameExercise(from, to string) bool
+		RemoveExercise(name string) bool
+	}) {
+		if to == "" {
+			e.RemoveExercise(from)
+		} else {
+			e.RenameExercise(from, to)
+		}
+	}
+
+	for i := range u.workouts {
+		w := &u.workouts[i]
+		previous := models.NewHistoryEntries(w)
+		change(w)
+		w.UpdatedAt, w.Version = updatedAt, w.Version+1
+		if err := save(t, w); err != nil {
+			return err
+		}
+		if err := replaceHistory(t, models.NewHistoryEntries(w), previous); err != nil {
+			return err
+		}
+	}
+
+	for i := range u.templates {
+		tmpl := &u.templates[i]
+		change(tmpl)
+		tmpl.UpdatedAt, tmpl.Version = updatedAt, tmpl.Version+1
+		if err := save(t, tmpl); err != nil {
+			return err
+		}
+	}
+
+	for i := range u.programs {
+		p := &u.programs[i]
+		change(p)
+		p.UpdatedAt, p.Version = updatedAt, p.Version+1
+		if err := save(t, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package localdb
+
+import (
+	"context"
+	"testing"
+
+	"heart/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteExercise_InUseByAWorkoutWithoutHistory(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	_, err := s.MakeExercise(ctx, models.UserExerciseIn{Name: "Zercher Squat", Category: "Barbell", Target: "Legs"}, "u1")
+	require.NoError(t, err)
+
+	// saved before history was kept
+	legacy := newWorkout("u1", "2025-06-01T18:00:00Z", "Zercher Squat")
+	require.NoError(t, s.update(ctx, func(t tx) error { return save(t, legacy) }))
+
+	_, err = s.DeleteExercise(ctx, "u1", "Zercher Squat", false)
+	var conflict *models.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, models.ExerciseUsageOut{Workouts: 1}, conflict.Current)
+
+	_, err = s.RenameExercise(ctx, "u1", "Zercher Squat", "Zercher")
+	require.NoError(t, err)
+
+	renamed, err := s.GetWorkout(ctx, "u1", legacy.ID())
+	require.NoError(t, err)
+	assert.Equal(t, "Zercher", renamed.Exercises[0].ExerciseID)
+	assert.Equal(t, 1, renamed.Version)
+}
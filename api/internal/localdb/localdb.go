@@ -0,0 +1,249 @@
+// Package localdb keeps the data of the API without AWS. It holds the very items the DynamoDB table
+// would, under the same keys, so that sort-key ordering, cursors and conditional writes behave alike.
+package localdb
+
+import (
+	"context"
+	"errors"
+	"heart/internal/dbx"
+	"heart/internal/models"
+	"io"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// item is a row of the table, as DynamoDB would hold it.
+type item = map[string]types.AttributeValue
+
+// table holds items by partition key and sort key.
+type table interface {
+	// view runs fn on a snapshot of the table.
+	view(ctx context.Context, fn func(tx) error) error
+	// update runs fn on the table; its writes land all together once fn returns nil, or not at all.
+	update(ctx context.Context, fn func(tx) error) error
+}
+
+type tx interface {
+	get(pk, sk string) (item, error) // nil when there is no such item
+	// scan returns up to limit items of the partition within the span, all of them for a zero limit.
+	// An empty to leaves the span open at the top.
+	scan(pk string, s span, limit int) ([]item, error)
+	put(it item) error
+	delete(pk, sk string) error
+}
+
+// errConditionFailed stands for the write DynamoDB would have refused.
+var errConditionFailed = errors.New("conditional check failed")
+
+// Store keeps every kind of data of the API in one table.
+type Store struct {
+	table table
+}
+
+var _ dbx.Store = (*Store)(nil)
+
+func (s *Store) view(ctx context.Context, fn func(tx) error) error {
+	return s.table.view(ctx, fn)
+}
+
+func (s *Store) update(ctx context.Context, fn func(tx) error) error {
+	return s.table.update(ctx, fn)
+}
+
+// Close lets go of the file behind the store, if there is one.
+func (s *Store) Close() error {
+	if c, ok := s.table.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// span is a range of sort keys to read through, like the key condition of a query.
+type span struct {
+	from, to string
+	desc     bool   // newest first, for sort keys that end in a timestamp
+	after    string // sort key to resume after, exclusive
+}
+
+// prefixed spans the sort keys that begin with prefix.
+func prefixed(prefix string) span {
+	return span{from: prefix, to: prefix + "\xff"}
+}
+
+// scanBatch is how many items page reads at a time when keep may turn some of them down.
+const scanBatch = 100
+
+// page reads the items of the partition within the span and returns up to limit of them that keep
+// accepts, with the sort key to resume after, empty once nothing is left beyond it.
+// A zero limit reads them all; a nil keep keeps every item.
+func page[T any](t tx, pk string, s span, limit int, keep func(*T) bool) ([]T, string, error) {
+	var out []T
+	for {
+		// one more than is needed tells whether anything is left beyond the page
+		batch := 0
+		if limit > 0 {
+			batch = limit - len(out) + 1
+			if keep != nil {
+				batch = max(batch, scanBatch)
+			}
+		}
+
+		items, err := t.scan(pk, s, batch)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for i, raw := range items {
+			s.after = sortKey(raw)
+
+			var v T
+			if err := attributevalue.UnmarshalMap(raw, &v); err != nil {
+				return nil, "", err
+			}
+			if keep != nil && !keep(&v) {
+				continue
+			}
+
+			out = append(out, v)
+			if len(out) == limit {
+				if i == len(items)-1 && len(items) < batch {
+					return out, "", nil
+				}
+				return out, s.after, nil
+			}
+		}
+
+		if batch == 0 || len(items) < batch {
+			return out, "", nil
+		}
+	}
+}
+
+// all reads every item of the partition within the span.
+func all[T any](t tx, pk string, s span) ([]T, error) {
+	out, _, err := page[T](t, pk, s, 0, nil)
+	return out, err
+}
+
+// load reads the item with the given key into a T, nil if there is none.
+func load[T any](t tx, pk, sk string) (*T, error) {
+	raw, err := t.get(pk, sk)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+
+	var v T
+	if err := attributevalue.UnmarshalMap(raw, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// save writes v whole, over any item with its key.
+func save(t tx, v any) error {
+	raw, err := attributevalue.MarshalMap(v)
+	if err != nil {
+		return err
+	}
+	return t.put(raw)
+}
+
+// edit returns a copy of the stored item to change, or a new one with the given key if there is none.
+// Attributes are replaced on the copy, never changed in place: the stored item may share them.
+func edit(stored item, pk, sk string) item {
+	if stored == nil {
+		return item{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+			"SK": &types.AttributeValueMemberS{Value: sk},
+		}
+	}
+	return maps.Clone(stored)
+}
+
+// setFrom copies the named attributes of v onto the item, removing those v leaves out.
+func setFrom(it item, v any, names ...string) error {
+	fresh, err := attributevalue.MarshalMap(v)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if value, ok := fresh[name]; ok {
+			it[name] = value
+		} else {
+			delete(it, name)
+		}
+	}
+	return nil
+}
+
+func partitionKey(it item) string {
+	return text(it, "PK")
+}
+
+func sortKey(it item) string {
+	return text(it, "SK")
+}
+
+func text(it item, name string) string {
+	if s, ok := it[name].(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+	return ""
+}
+
+func number(it item, name string) int {
+	if n, ok := it[name].(*types.AttributeValueMemberN); ok {
+		v, _ := strconv.Atoi(n.Value)
+		return v
+	}
+	return 0
+}
+
+func setNumber(it item, name string, v int) {
+	it[name] = &types.AttributeValueMemberN{Value: strconv.Itoa(v)}
+}
+
+// bumpVersion moves the item to its next version and returns it.
+func bumpVersion(it item) int {
+	version := number(it, "version") + 1
+	setNumber(it, "version", version)
+	return version
+}
+
+// onVersion tells whether the stored item is on the version the client based its edit on, if it said.
+// Version zero stands for a copy never saved before, as do items that predate versioning.
+func onVersion(stored item, expected *int) bool {
+	switch {
+	case expected == nil:
+		return true // last writer wins, as older clients expect
+	case *expected == 0:
+		_, versioned := stored["version"]
+		return !versioned
+	default:
+		_, versioned := stored["version"]
+		return versioned && number(stored, "version") == *expected
+	}
+}
+
+// deleteWithTombstone deletes an item and leaves a tombstone in its place,
+// so that syncing clients learn about the deletion.
+func deleteWithTombstone(t tx, userId, kind, sk, id string) error {
+	if err := t.delete(models.UserKey+userId, sk); err != nil {
+		return err
+	}
+	return save(t, models.NewTombstone(userId, kind, sk, id, time.Now()))
+}
+
+// failed passes the errors of the models through and turns any other into a server error.
+func failed(err error) error {
+	var known models.HTTPError
+	if err == nil || errors.As(err, &known) {
+		return err
+	}
+	return models.NewServerError(err)
+}
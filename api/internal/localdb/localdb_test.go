@@ -0,0 +1,13 @@
+package localdb
+
+import (
+	"os"
+	"testing"
+
+	"heart/internal/config"
+)
+
+func TestMain(m *testing.M) {
+	config.App = &config.AppConfig{AwsConfig: config.AwsConfig{CloudFrontConfig: config.CloudFrontConfig{MediaDistributionAlias: "https://cdn.example.com"}}}
+	os.Exit(m.Run())
+}
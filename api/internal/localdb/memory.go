@@ -0,0 +1,113 @@
+package localdb
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// NewMemory returns a store that keeps everything in memory, gone once the process ends.
+// It is meant for running the API offline and for tests.
+func NewMemory() *Store {
+	return &Store{table: &memoryTable{partitions: map[string]map[string]item{}}}
+}
+
+// memoryTable holds partitions as maps by sort key, and sorts them as they are scanned.
+type memoryTable struct {
+	mu         sync.RWMutex
+	partitions map[string]map[string]item
+}
+
+func (m *memoryTable) view(_ context.Context, fn func(tx) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return fn(&memoryTx{table: m})
+}
+
+func (m *memoryTable) update(_ context.Context, fn func(tx) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := &memoryTx{table: m, writes: map[[2]string]item{}}
+	if err := fn(t); err != nil {
+		return err
+	}
+
+	for key, it := range t.writes {
+		pk, sk := key[0], key[1]
+		if it == nil {
+			delete(m.partitions[pk], sk)
+			continue
+		}
+		if m.partitions[pk] == nil {
+			m.partitions[pk] = map[string]item{}
+		}
+		m.partitions[pk][sk] = it
+	}
+	return nil
+}
+
+// memoryTx reads through the writes it holds back, a nil item for a deletion, to the table.
+type memoryTx struct {
+	table  *memoryTable
+	writes map[[2]string]item
+}
+
+func (t *memoryTx) get(pk, sk string) (item, error) {
+	if it, ok := t.writes[[2]string{pk, sk}]; ok {
+		return it, nil
+	}
+	return t.table.partitions[pk][sk], nil
+}
+
+func (t *memoryTx) scan(pk string, s span, limit int) ([]item, error) {
+	inRange := func(sk string) bool {
+		if sk < s.from || s.to != "" && sk > s.to {
+			return false
+		}
+		return s.after == "" || !s.desc && sk > s.after || s.desc && sk < s.after
+	}
+
+	found := map[string]item{}
+	for sk, it := range t.table.partitions[pk] {
+		if inRange(sk) {
+			found[sk] = it
+		}
+	}
+	for key, it := range t.writes {
+		if key[0] != pk || !inRange(key[1]) {
+			continue
+		}
+		if it == nil {
+			delete(found, key[1])
+		} else {
+			found[key[1]] = it
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(found))
+	if s.desc {
+		slices.Reverse(keys)
+	}
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	items := make([]item, 0, len(keys))
+	for _, sk := range keys {
+		items = append(items, found[sk])
+	}
+	return items, nil
+}
+
+func (t *memoryTx) put(it item) error {
+	t.writes[[2]string{partitionKey(it), sortKey(it)}] = maps.Clone(it)
+	return nil
+}
+
+func (t *memoryTx) delete(pk, sk string) error {
+	t.writes[[2]string{pk, sk}] = nil
+	return nil
+}
@@ -0,0 +1,88 @@
+package localdb
+
+import (
+	"context"
+	"heart/internal/models"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+)
+
+func (s *Store) GetPrograms(ctx context.Context, userId string) ([]models.Program, error) {
+	var programs []models.Program
+	err := s.view(ctx, func(t tx) (err error) {
+		programs, err = all[models.Program](t, models.UserKey+userId, prefixed(models.ProgramKey))
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return programs, nil
+}
+
+func (s *Store) GetProgram(ctx context.Context, userId string, programId string) (*models.Program, error) {
+	var program *models.Program
+	err := s.view(ctx, func(t tx) (err error) {
+		program, err = load[models.Program](t, models.UserKey+userId, models.ProgramKey+programId)
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	if program == nil {
+		return nil, models.NewNotFoundError("Program not found", nil)
+	}
+	return program, nil
+}
+
+// SaveProgram upserts the program and bumps its version. A non-nil expected version makes the
+// save fail with a conflict, carrying the stored copy, if the program has moved on since.
+// With mustExist, it only saves over a program that is already there.
+func (s *Store) SaveProgram(ctx context.Context, in models.Program, expected *int, mustExist bool) (*models.Program, error) {
+	in.UpdatedAt = models.Timestamp(time.Now())
+
+	err := s.update(ctx, func(t tx) error {
+		stored, err := t.get(in.PK, in.SK)
+		if err != nil {
+			return err
+		}
+		if mustExist && stored == nil || !onVersion(stored, expected) {
+			return programConflict(stored)
+		}
+
+		next := edit(stored, in.PK, in.SK)
+		if err := setFrom(next, in, "name", "weeks", "start", "repeat", "slots", "progression", "updated_at"); err != nil {
+			return err
+		}
+		in.Version = bumpVersion(next)
+		return t.put(next)
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return &in, nil
+}
+
+func programConflict(stored item) error {
+	if stored == nil {
+		return models.NewNotFoundError("Program not found", errConditionFailed)
+	}
+
+	var current models.Program
+	if err := attributevalue.UnmarshalMap(stored, &current); err != nil {
+		return err
+	}
+
+	return models.NewConflictError("Program was changed on another device", models.NewProgramOut(&current), errConditionFailed)
+}
+
+func (s *Store) DeleteProgram(ctx context.Context, userId string, programId string) error {
+	err := s.update(ctx, func(t tx) error {
+		return deleteWithTombstone(t, userId, models.KindProgram, models.ProgramKey+programId, programId)
+	})
+
+	return failed(err)
+}
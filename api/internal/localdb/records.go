@@ -0,0 +1,203 @@
+package localdb
+
+import (
+	"context"
+	"heart/internal/models"
+)
+
+// GetRecords returns the personal records of the user for an exercise,
+// empty if they have not logged it yet.
+// Records not kept yet, for workouts logged before they were, are worked out from the history first.
+func (s *Store) GetRecords(ctx context.Context, userId string, exercise string) (*models.PersonalRecords, error) {
+	var records *models.PersonalRecords
+	err := s.view(ctx, func(t tx) (err error) {
+		records, err = storedRecords(t, userId, exercise)
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+	if records != nil {
+		return records, nil
+	}
+
+	err = s.update(ctx, func(t tx) (err error) {
+		records, err = getRecords(t, userId, exercise, nil)
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return records, nil
+}
+
+// UpdateRecords folds a saved workout into the personal records of every exercise in it
+// and returns, by set ID, the records its sets have just set.
+func (s *Store) UpdateRecords(ctx context.Context, userId string, workout *models.Workout) (map[string][]models.RecordKind, error) {
+	var broken map[string][]models.RecordKind
+	err := s.update(ctx, func(t tx) (err error) {
+		broken, err = updateRecords(t, userId, []models.Workout{*workout})
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return broken, nil
+}
+
+// ApplyRecords folds many workouts at once, oldest first.
+func (s *Store) ApplyRecords(ctx context.Context, userId string, workouts []models.Workout) error {
+	err := s.update(ctx, func(t tx) error {
+		_, err := updateRecords(t, userId, workouts)
+		return err
+	})
+
+	return failed(err)
+}
+
+// getRecords returns the records of an exercise, worked out from the history without the given
+// workouts when they are not kept yet, for those to be folded in after.
+func getRecords(t tx, userId string, exercise string, without []models.Workout) (*models.PersonalRecords, error) {
+	records, err := storedRecords(t, userId, exercise)
+	if err != nil || records != nil {
+		return records, err
+	}
+
+	built := models.NewPersonalRecords(userId, exercise)
+	if err := rebuildRecords(t, userId, &built, without); err != nil {
+		return nil, err
+	}
+	return &built, nil
+}
+
+// storedRecords reads the records kept of an exercise, nil if there are none yet.
+func storedRecords(t tx, userId string, exercise string) (*models.PersonalRecords, error) {
+	return load[models.PersonalRecords](t, models.UserKey+userId, models.RecordsSK(exercise))
+}
+
+func updateRecords(t tx, userId string, workouts []models.Workout) (map[string][]models.RecordKind, error) {
+	broken := map[string][]models.RecordKind{}
+	seen := map[string]bool{}
+
+	for _, workout := range workouts {
+		for _, exercise := range workout.Exercises {
+			if seen[exercise.ExerciseID] {
+				continue
+			}
+			seen[exercise.ExerciseID] = true
+
+			records, err := getRecords(t, userId, exercise.ExerciseID, workouts)
+			if err != nil {
+				return nil, err
+			}
+
+			// a workout saved again may have been edited down, so records it holds are
+			// recomputed from the history rather than only ever raised
+			if heldBy(records, workouts) {
+				before := records.Clone()
+				if err := rebuildRecords(t, userId, records, nil); err != nil {
+					return nil, err
+				}
+				for _, w := range workouts {
+					for setId, kinds := range records.Broken(&before, w.ID()) {
+						broken[setId] = append(broken[setId], kinds...)
+					}
+				}
+				continue
+			}
+
+			changed := false
+			for _, w := range workouts {
+				set := records.Apply(&w)
+				for setId, kinds := range set {
+					broken[setId] = append(broken[setId], kinds...)
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+
+			if err := save(t, records); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return broken, nil
+}
+
+// dropRecords recomputes the records a workout held of exercises it no longer has:
+// those taken out of it since, given what it is now, or all of them once it is deleted.
+func dropRecords(t tx, userId string, previous *models.Workout, now *models.Workout) error {
+	kept := map[string]bool{}
+	if now != nil {
+		for _, exercise := range now.Exercises {
+			kept[exercise.ExerciseID] = true
+		}
+	}
+
+	for _, exercise := range previous.Exercises {
+		if kept[exercise.ExerciseID] {
+			continue
+		}
+		kept[exercise.ExerciseID] = true
+
+		// records not kept yet are worked out from the history, which is already up to date
+		records, err := storedRecords(t, userId, exercise.ExerciseID)
+		if err != nil {
+			return err
+		}
+		if records == nil || !records.HeldBy(previous.ID()) {
+			continue
+		}
+		if err := rebuildRecords(t, userId, records, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func heldBy(records *models.PersonalRecords, workouts []models.Workout) bool {
+	for i := range workouts {
+		if records.HeldBy(workouts[i].ID()) {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuildRecords recomputes the records of an exercise from its whole history, but for the given
+// workouts, and saves them.
+func rebuildRecords(t tx, userId string, records *models.PersonalRecords, without []models.Workout) error {
+	if err := buildHistory(t, userId); err != nil {
+		return err
+	}
+
+	history, err := all[models.HistoryEntry](t, records.PK, prefixed(models.HistoryPrefix(records.Exercise)))
+	if err != nil {
+		return err
+	}
+
+	records.Rebuild(models.LeaveOut(history, without))
+	return save(t, records)
+}
+
+// moveRecords files the personal records of an exercise under its new name.
+// Records not kept yet are left to be worked out from the history under the new name.
+func moveRecords(t tx, userId string, from string, to string) error {
+	records, err := storedRecords(t, userId, from)
+	if err != nil || records == nil {
+		return err
+	}
+
+	records.SK, records.Exercise = models.RecordsSK(to), to
+	if err := save(t, records); err != nil {
+		return err
+	}
+
+	return t.delete(models.UserKey+userId, models.RecordsSK(from))
+}
@@ -0,0 +1,70 @@
+package localdb
+
+import (
+	"context"
+	"time"
+)
+
+// ScheduleKey is the partition schedules are kept under.
+const ScheduleKey = "SCHEDULE"
+
+// Schedule is a background job to run for a user at a set time, standing in for the EventBridge
+// schedules of the API on AWS. It is kept with the data, so that it outlives a restart.
+// PK: SCHEDULE
+// SK: <name>
+type Schedule struct {
+	PK     string    `dynamodbav:"PK"`
+	SK     string    `dynamodbav:"SK"`
+	Event  string    `dynamodbav:"event"`
+	UserID string    `dynamodbav:"user_id"`
+	At     time.Time `dynamodbav:"at"`
+}
+
+// NewSchedule returns the schedule to run the event for the user at the given time.
+func NewSchedule(name, event, userId string, at time.Time) Schedule {
+	return Schedule{PK: ScheduleKey, SK: name, Event: event, UserID: userId, At: at}
+}
+
+// Name tells the schedule apart from the others.
+func (s *Schedule) Name() string {
+	return s.SK
+}
+
+// SaveSchedule keeps the schedule unless there is one of that name already, and tells whether it did.
+func (s *Store) SaveSchedule(ctx context.Context, in Schedule) (bool, error) {
+	saved := false
+	err := s.update(ctx, func(t tx) error {
+		existing, err := t.get(in.PK, in.SK)
+		if err != nil || existing != nil {
+			return err
+		}
+
+		saved = true
+		return save(t, in)
+	})
+
+	return saved, failed(err)
+}
+
+// DeleteSchedule drops the schedule of that name, if there is one.
+func (s *Store) DeleteSchedule(ctx context.Context, name string) error {
+	err := s.update(ctx, func(t tx) error {
+		return t.delete(ScheduleKey, name)
+	})
+
+	return failed(err)
+}
+
+// GetSchedules returns every schedule kept, in order of name.
+func (s *Store) GetSchedules(ctx context.Context) ([]Schedule, error) {
+	var schedules []Schedule
+	err := s.view(ctx, func(t tx) (err error) {
+		schedules, err = all[Schedule](t, ScheduleKey, span{})
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return schedules, nil
+}
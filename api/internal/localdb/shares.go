@@ -0,0 +1,132 @@
+package localdb
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"heart/internal/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+// shareAttempts bounds how many codes ShareTemplate draws before giving up on finding a free one.
+const shareAttempts = 3
+
+// ShareTemplate publishes a snapshot of the template under a new code, along with the
+// owner's pointer to it.
+func (s *Store) ShareTemplate(ctx context.Context, template *models.Template, custom []models.UserExerciseIn) (*models.SharedTemplate, error) {
+	var shared *models.SharedTemplate
+	err := s.update(ctx, func(t tx) error {
+		for range shareAttempts {
+			snapshot, share := models.NewSharedTemplate(template, custom, models.NewShareCode(), time.Now())
+
+			taken, err := t.get(snapshot.PK, snapshot.SK)
+			if err != nil {
+				return err
+			}
+			if taken != nil {
+				continue // the code is taken: draw another
+			}
+
+			if err := save(t, snapshot); err != nil {
+				return err
+			}
+			shared = &snapshot
+			return save(t, share)
+		}
+
+		return fmt.Errorf("no free share code after %d attempts", shareAttempts)
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return shared, nil
+}
+
+func (s *Store) GetSharedTemplate(ctx context.Context, code string) (*models.SharedTemplate, error) {
+	var shared *models.SharedTemplate
+	err := s.view(ctx, func(t tx) (err error) {
+		shared, err = load[models.SharedTemplate](t, models.SharedKey+code, models.SharedKey+code)
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	if shared == nil {
+		return nil, models.NewNotFoundError("Shared template not found", nil)
+	}
+	return shared, nil
+}
+
+// GetShares returns the templates the user has shared, newest first, with how often each was copied.
+func (s *Store) GetShares(ctx context.Context, userId string) ([]models.SharedTemplate, error) {
+	var shared []models.SharedTemplate
+	err := s.view(ctx, func(t tx) error {
+		shares, err := all[models.Share](t, models.UserKey+userId, prefixed(models.ShareKey))
+		if err != nil {
+			return err
+		}
+
+		for _, share := range shares {
+			code := strings.TrimPrefix(share.SK, models.ShareKey)
+			snapshot, err := load[models.SharedTemplate](t, models.SharedKey+code, models.SharedKey+code)
+			if err != nil {
+				return err
+			}
+			if snapshot != nil {
+				shared = append(shared, *snapshot)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	slices.SortFunc(shared, func(a, b models.SharedTemplate) int {
+		return cmp.Compare(b.CreatedAt.UnixNano(), a.CreatedAt.UnixNano())
+	})
+
+	return shared, nil
+}
+
+// CountSharedImport adds one to the times the shared template was copied.
+// A share revoked in the meantime is left alone.
+func (s *Store) CountSharedImport(ctx context.Context, code string) error {
+	err := s.update(ctx, func(t tx) error {
+		stored, err := t.get(models.SharedKey+code, models.SharedKey+code)
+		if err != nil || stored == nil {
+			return err
+		}
+
+		next := edit(stored, models.SharedKey+code, models.SharedKey+code)
+		setNumber(next, "imports", number(next, "imports")+1)
+		return t.put(next)
+	})
+
+	return failed(err)
+}
+
+// RevokeShare takes a shared template down, along with the owner's pointer to it.
+// Only its owner can revoke it; to anyone else it is not found.
+func (s *Store) RevokeShare(ctx context.Context, userId string, code string) error {
+	err := s.update(ctx, func(t tx) error {
+		stored, err := t.get(models.SharedKey+code, models.SharedKey+code)
+		if err != nil {
+			return err
+		}
+		if stored == nil || text(stored, "owner") != userId {
+			return models.NewNotFoundError("Shared template not found", errConditionFailed)
+		}
+
+		if err := t.delete(models.SharedKey+code, models.SharedKey+code); err != nil {
+			return err
+		}
+		return t.delete(models.UserKey+userId, models.ShareKey+code)
+	})
+
+	return failed(err)
+}
@@ -0,0 +1,179 @@
+package localdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+// migrations bring the schema of a SQLite file up to date, in order. The version a file is at is
+// kept in its user_version, so a migration never runs twice: add new ones at the end, never edit them.
+var migrations = []string{
+	// items holds what the DynamoDB table would, each item encoded whole. Sort keys compare
+	// byte by byte, as they do in DynamoDB, so scans come out in the same order.
+	`CREATE TABLE items (
+		pk   TEXT NOT NULL,
+		sk   TEXT NOT NULL,
+		item TEXT NOT NULL,
+		PRIMARY KEY (pk, sk)
+	) WITHOUT ROWID`,
+}
+
+// OpenSQLite returns a store that keeps everything in the SQLite file at path,
+// created if there is none, and brings its schema up to date.
+func OpenSQLite(ctx context.Context, path string) (*Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	// one connection at a time keeps writers from tripping over each other
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+
+	return &Store{table: &sqliteTable{db: db}}, nil
+}
+
+func migrate(ctx context.Context, db *sql.DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("schema version %d is newer than this build knows (%d)", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		// PRAGMA takes no parameters; the version is a number of our own
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteTable keeps items in a SQLite file, one row each.
+type sqliteTable struct {
+	db *sql.DB
+}
+
+func (s *sqliteTable) view(ctx context.Context, fn func(tx) error) error {
+	t, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer t.Rollback()
+
+	return fn(&sqliteTx{ctx: ctx, tx: t})
+}
+
+func (s *sqliteTable) update(ctx context.Context, fn func(tx) error) error {
+	t, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&sqliteTx{ctx: ctx, tx: t}); err != nil {
+		_ = t.Rollback()
+		return err
+	}
+	return t.Commit()
+}
+
+func (s *sqliteTable) Close() error {
+	return s.db.Close()
+}
+
+type sqliteTx struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func (t *sqliteTx) get(pk, sk string) (item, error) {
+	var raw []byte
+	err := t.tx.QueryRowContext(t.ctx, "SELECT item FROM items WHERE pk = ? AND sk = ?", pk, sk).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeItem(raw)
+}
+
+func (t *sqliteTx) scan(pk string, s span, limit int) ([]item, error) {
+	query, args := "SELECT item FROM items WHERE pk = ? AND sk >= ?", []any{pk, s.from}
+	if s.to != "" {
+		query, args = query+" AND sk <= ?", append(args, s.to)
+	}
+	switch {
+	case s.after != "" && s.desc:
+		query, args = query+" AND sk < ?", append(args, s.after)
+	case s.after != "":
+		query, args = query+" AND sk > ?", append(args, s.after)
+	}
+	if s.desc {
+		query += " ORDER BY sk DESC"
+	} else {
+		query += " ORDER BY sk ASC"
+	}
+	if limit > 0 {
+		query, args = query+" LIMIT ?", append(args, limit)
+	}
+
+	rows, err := t.tx.QueryContext(t.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []item
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		it, err := decodeItem(raw)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+func (t *sqliteTx) put(it item) error {
+	raw, err := encodeItem(it)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.tx.ExecContext(t.ctx,
+		"INSERT INTO items (pk, sk, item) VALUES (?, ?, ?) ON CONFLICT (pk, sk) DO UPDATE SET item = excluded.item",
+		partitionKey(it), sortKey(it), string(raw),
+	)
+	return err
+}
+
+func (t *sqliteTx) delete(pk, sk string) error {
+	_, err := t.tx.ExecContext(t.ctx, "DELETE FROM items WHERE pk = ? AND sk = ?", pk, sk)
+	return err
+}
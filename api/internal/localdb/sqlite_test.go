@@ -0,0 +1,168 @@
+package localdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"heart/internal/models"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openSQLite(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := OpenSQLite(context.Background(), path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestOpenSQLite_MigratesOnceAndKeepsData(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "heart.db")
+
+	s := openSQLite(t, path)
+	_, err := s.SaveWorkout(ctx, newWorkout("u1", "2025-07-01T18:00:00Z", "e1"), nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s = openSQLite(t, path)
+	w, err := s.GetWorkout(ctx, "u1", "2025-07-01T18:00:00Z")
+	require.NoError(t, err)
+	assert.Equal(t, "Workout 2025-07-01T18:00:00Z", w.Name)
+	require.Len(t, w.Exercises, 1)
+	assert.Equal(t, 100.0, w.Exercises[0].Sets[0].Weight)
+
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer db.Close()
+	var version int
+	require.NoError(t, db.QueryRow("PRAGMA user_version").Scan(&version))
+	assert.Equal(t, len(migrations), version)
+}
+
+func TestSQLite_PagesWorkoutsLikeMemory(t *testing.T) {
+	ctx := context.Background()
+	stores := map[string]*Store{
+		"memory": NewMemory(),
+		"sqlite": openSQLite(t, filepath.Join(t.TempDir(), "heart.db")),
+	}
+
+	type page struct {
+		IDs    []string
+		Cursor string
+	}
+	pages := map[string][]page{}
+	for name, s := range stores {
+		for _, id := range []string{"2025-07-03T18:00:00Z", "2025-07-01T18:00:00Z", "2025-07-02T18:00:00Z", "2025-07-02T07:30:00Z"} {
+			_, err := s.SaveWorkout(ctx, newWorkout("u1", id, "e1"), nil)
+			require.NoError(t, err)
+		}
+		_, err := s.SaveWorkout(ctx, newWorkout("u2", "2025-07-04T18:00:00Z"), nil)
+		require.NoError(t, err)
+
+		cursor := ""
+		for {
+			workouts, next, err := s.GetWorkouts(ctx, "u1", 3, cursor, models.WorkoutFilter{})
+			require.NoError(t, err)
+			p := page{Cursor: next}
+			for _, w := range workouts {
+				p.IDs = append(p.IDs, w.ID())
+			}
+			pages[name] = append(pages[name], p)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+	}
+
+	assert.Equal(t, []page{
+		{IDs: []string{"2025-07-03T18:00:00Z", "2025-07-02T18:00:00Z", "2025-07-02T07:30:00Z"}, Cursor: "2025-07-02T07:30:00Z"},
+		{IDs: []string{"2025-07-01T18:00:00Z"}},
+	}, pages["sqlite"])
+	assert.Equal(t, pages["memory"], pages["sqlite"])
+}
+
+func TestSQLite_UpdateIsAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLite(t, filepath.Join(t.TempDir(), "heart.db"))
+
+	boom := errors.New("boom")
+	err := s.update(ctx, func(t tx) error {
+		if err := t.put(item{"PK": &types.AttributeValueMemberS{Value: "USER#u1"}, "SK": &types.AttributeValueMemberS{Value: "A"}}); err != nil {
+			return err
+		}
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+
+	var items []item
+	err = s.view(ctx, func(t tx) (err error) {
+		items, err = t.scan("USER#u1", span{}, 0)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestSQLite_ScanPagesLikeMemory(t *testing.T) {
+	ctx := context.Background()
+	stores := map[string]*Store{
+		"memory": NewMemory(),
+		"sqlite": openSQLite(t, filepath.Join(t.TempDir(), "heart.db")),
+	}
+
+	sortKeys := map[string][]string{}
+	for name, s := range stores {
+		err := s.update(ctx, func(t tx) error {
+			for _, sk := range []string{"A1", "A2", "A3", "A4", "B1"} {
+				if err := t.put(item{"PK": &types.AttributeValueMemberS{Value: "USER#u1"}, "SK": &types.AttributeValueMemberS{Value: sk}}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = s.view(ctx, func(t tx) error {
+			s := prefixed("A")
+			s.desc, s.after = true, "A4"
+			items, err := t.scan("USER#u1", s, 2)
+			for _, it := range items {
+				sortKeys[name] = append(sortKeys[name], sortKey(it))
+			}
+			return err
+		})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"A3", "A2"}, sortKeys["sqlite"])
+	assert.Equal(t, sortKeys["memory"], sortKeys["sqlite"])
+}
+
+func TestCodec_RoundTripsEveryType(t *testing.T) {
+	it := item{
+		"PK":   &types.AttributeValueMemberS{Value: "USER#u1"},
+		"n":    &types.AttributeValueMemberN{Value: "1.5"},
+		"b":    &types.AttributeValueMemberB{Value: []byte{0, 1, 2}},
+		"ok":   &types.AttributeValueMemberBOOL{Value: true},
+		"none": &types.AttributeValueMemberNULL{Value: true},
+		"ss":   &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"ns":   &types.AttributeValueMemberNS{Value: []string{"1", "2"}},
+		"bs":   &types.AttributeValueMemberBS{Value: [][]byte{{1}, {2}}},
+		"l":    &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "x"}, &types.AttributeValueMemberN{Value: "3"}}},
+		"m":    &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"k": &types.AttributeValueMemberBOOL{Value: false}}},
+	}
+
+	raw, err := encodeItem(it)
+	require.NoError(t, err)
+	back, err := decodeItem(raw)
+	require.NoError(t, err)
+	assert.Equal(t, it, back)
+}
@@ -0,0 +1,129 @@
+package localdb
+
+import (
+	"context"
+	"heart/internal/dbx"
+	"heart/internal/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+// GetChanges returns, oldest first, the workouts, templates, programs and own exercises of the user
+// saved after the since token, along with tombstones of those deleted, up to the until instant.
+// It reads the partition in the order of the sync index: by updated_at, then by sort key.
+// A nil since starts a full download instead, see getEverything.
+func (s *Store) GetChanges(ctx context.Context, userId string, since *models.SyncToken, until time.Time, limit int) (*models.Changes, *models.SyncToken, bool, error) {
+	if since == nil || since.Full {
+		return s.getEverything(ctx, userId, since, until, limit)
+	}
+
+	upper := models.Timestamp(until)
+
+	var feed []item
+	err := s.view(ctx, func(t tx) error {
+		items, err := t.scan(models.UserKey+userId, span{}, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, it := range items {
+			_, indexed := it["updated_at"] // the index leaves out items without one
+			if indexed && text(it, "updated_at") <= upper && after(it, since) {
+				feed = append(feed, it)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, false, failed(err)
+	}
+
+	slices.SortFunc(feed, func(a, b item) int {
+		if c := strings.Compare(text(a, "updated_at"), text(b, "updated_at")); c != 0 {
+			return c
+		}
+		return strings.Compare(sortKey(a), sortKey(b))
+	})
+
+	more := len(feed) > limit
+	if more {
+		feed = feed[:limit]
+	}
+
+	changes := &models.Changes{}
+	next := since
+
+	for _, it := range feed {
+		sk := sortKey(it)
+		updatedAt, err := dbx.UnmarshalChange(it, sk, changes)
+		if err != nil {
+			return nil, nil, false, models.NewServerError(err)
+		}
+
+		next = &models.SyncToken{UpdatedAt: updatedAt, SK: sk}
+	}
+
+	return changes, next, more, nil
+}
+
+// getEverything reads the synced items of the user by sort key, whether they have an updated_at or not,
+// for a client that has nothing yet. Once done, the token it hands back has the feed pick up from
+// when the download started.
+func (s *Store) getEverything(ctx context.Context, userId string, since *models.SyncToken, until time.Time, limit int) (*models.Changes, *models.SyncToken, bool, error) {
+	started := models.Timestamp(until)
+	resume := ""
+	if since != nil {
+		started, resume = since.UpdatedAt, since.SK
+	}
+
+	var items []item
+	err := s.view(ctx, func(t tx) error {
+		all, err := t.scan(models.UserKey+userId, span{}, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, it := range all {
+			sk := sortKey(it)
+			synced := slices.ContainsFunc(models.SyncedKeys, func(prefix string) bool { return strings.HasPrefix(sk, prefix) })
+			if synced && sk > resume {
+				items = append(items, it)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, false, failed(err)
+	}
+
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+
+	changes := &models.Changes{}
+	for _, it := range items {
+		if _, err := dbx.UnmarshalChange(it, sortKey(it), changes); err != nil {
+			return nil, nil, false, models.NewServerError(err)
+		}
+	}
+
+	if !more {
+		return changes, &models.SyncToken{UpdatedAt: started}, false, nil
+	}
+	return changes, &models.SyncToken{UpdatedAt: started, SK: sortKey(items[len(items)-1]), Full: true}, true, nil
+}
+
+// after tells whether the item comes after the token in the feed.
+func after(it item, since *models.SyncToken) bool {
+	if since == nil {
+		return true
+	}
+
+	updatedAt := text(it, "updated_at")
+	if since.SK == "" {
+		return updatedAt > since.UpdatedAt
+	}
+	return updatedAt > since.UpdatedAt || updatedAt == since.UpdatedAt && sortKey(it) > since.SK
+}
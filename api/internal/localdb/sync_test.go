@@ -0,0 +1,81 @@
+package localdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"heart/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetChanges_ReadsTheFeedInPages(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	_, err := s.SaveWorkout(ctx, newWorkout("u1", "2025-07-01T18:00:00Z"), nil)
+	require.NoError(t, err)
+	_, err = s.SaveTemplate(ctx, newTemplate("u1", "a"), nil)
+	require.NoError(t, err)
+	_, err = s.SaveTemplate(ctx, newTemplate("u1", "b"), nil)
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteTemplate(ctx, "u1", "a"))
+	_, err = s.SaveAccount(ctx, "u1", models.User{}) // not in the feed
+	require.NoError(t, err)
+
+	until := time.Now().Add(time.Second)
+	since := &models.SyncToken{UpdatedAt: models.Timestamp(time.Now().Add(-time.Hour))}
+
+	first, token, more, err := s.GetChanges(ctx, "u1", since, until, 2)
+	require.NoError(t, err)
+	assert.True(t, more)
+	assert.NotEmpty(t, token.SK)
+
+	rest, token, more, err := s.GetChanges(ctx, "u1", token, until, 2)
+	require.NoError(t, err)
+	assert.False(t, more)
+
+	// every change once, whichever page it falls on
+	assert.Len(t, append(first.Workouts, rest.Workouts...), 1)
+	assert.Len(t, append(first.Templates, rest.Templates...), 1)
+	assert.Len(t, append(first.Tombstones, rest.Tombstones...), 1)
+
+	none, next, more, err := s.GetChanges(ctx, "u1", token, until, 2)
+	require.NoError(t, err)
+	assert.False(t, more)
+	assert.Empty(t, none.Tombstones)
+	assert.Equal(t, token, next)
+}
+
+func TestGetChanges_FirstSyncReadsEverythingByKey(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	// saved before the sync index, so it has no updated_at
+	legacy := newWorkout("u1", "2025-06-01T18:00:00Z")
+	require.NoError(t, s.update(ctx, func(t tx) error { return save(t, legacy) }))
+	_, err := s.SaveTemplate(ctx, newTemplate("u1", "a"), nil)
+	require.NoError(t, err)
+	_, err = s.SaveTemplate(ctx, newTemplate("u1", "b"), nil)
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteTemplate(ctx, "u1", "b")) // nothing to delete on a first sync
+
+	until := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	first, token, more, err := s.GetChanges(ctx, "u1", nil, until, 1)
+	require.NoError(t, err)
+	assert.True(t, more)
+	assert.True(t, token.Full)
+	assert.Equal(t, models.Timestamp(until), token.UpdatedAt)
+
+	rest, token, more, err := s.GetChanges(ctx, "u1", token, until.Add(time.Hour), 1)
+	require.NoError(t, err)
+	assert.False(t, more)
+	assert.Equal(t, &models.SyncToken{UpdatedAt: models.Timestamp(until)}, token)
+
+	assert.Len(t, append(first.Templates, rest.Templates...), 1)
+	assert.Len(t, append(first.Workouts, rest.Workouts...), 1)
+	assert.Empty(t, append(first.Tombstones, rest.Tombstones...))
+}
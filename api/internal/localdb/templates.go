@@ -0,0 +1,167 @@
+package localdb
+
+import (
+	"context"
+	"fmt"
+	"heart/internal/models"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func (s *Store) GetTemplates(ctx context.Context, userId string) ([]models.Template, error) {
+	var templates []models.Template
+	err := s.view(ctx, func(t tx) (err error) {
+		templates, err = all[models.Template](t, models.UserKey+userId, prefixed(models.TemplateKey))
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return templates, nil
+}
+
+func (s *Store) GetTemplate(ctx context.Context, userId string, templateId string) (*models.Template, error) {
+	var template *models.Template
+	err := s.view(ctx, func(t tx) (err error) {
+		template, err = load[models.Template](t, models.UserKey+userId, models.TemplateKey+templateId)
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	if template == nil {
+		return nil, models.NewNotFoundError("Template not found", nil)
+	}
+	return template, nil
+}
+
+// SaveTemplate upserts the template and bumps its version. A non-nil expected version makes the
+// save fail with a conflict, carrying the stored copy, if the template has moved on since.
+func (s *Store) SaveTemplate(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+	return s.saveTemplate(ctx, in, expected, false)
+}
+
+// UpdateTemplate saves the template like SaveTemplate, but only over one that already exists.
+func (s *Store) UpdateTemplate(ctx context.Context, in models.Template, expected *int) (*models.Template, error) {
+	return s.saveTemplate(ctx, in, expected, true)
+}
+
+func (s *Store) saveTemplate(ctx context.Context, in models.Template, expected *int, mustExist bool) (*models.Template, error) {
+	in.UpdatedAt = models.Timestamp(time.Now())
+
+	err := s.update(ctx, func(t tx) error {
+		stored, err := t.get(in.PK, in.SK)
+		if err != nil {
+			return err
+		}
+		if mustExist && stored == nil || !onVersion(stored, expected) {
+			return templateConflict(stored)
+		}
+
+		next := edit(stored, in.PK, in.SK)
+		if err := setFrom(next, in, "name", "order", "exercises", "updated_at"); err != nil {
+			return err
+		}
+		bumpVersion(next)
+		if err := t.put(next); err != nil {
+			return err
+		}
+
+		// usage is kept apart from edits
+		var saved models.Template
+		if err := attributevalue.UnmarshalMap(next, &saved); err != nil {
+			return err
+		}
+		in.Version = saved.Version
+		in.TemplateUse = saved.TemplateUse
+		return nil
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return &in, nil
+}
+
+func templateConflict(stored item) error {
+	if stored == nil {
+		return models.NewNotFoundError("Template not found", errConditionFailed)
+	}
+
+	var current models.Template
+	if err := attributevalue.UnmarshalMap(stored, &current); err != nil {
+		return err
+	}
+
+	return models.NewConflictError("Template was changed on another device", models.NewTemplateOut(&current), errConditionFailed)
+}
+
+// RecordTemplateUse counts a workout started from the template towards its usage.
+// Templates deleted in the meantime are left alone.
+func (s *Store) RecordTemplateUse(ctx context.Context, template *models.Template, workout *models.Workout) error {
+	planned, completed := template.Adherence(workout)
+
+	err := s.update(ctx, func(t tx) error {
+		stored, err := t.get(template.PK, template.SK)
+		if err != nil || stored == nil {
+			return err
+		}
+
+		next := edit(stored, template.PK, template.SK)
+		setNumber(next, "performed", number(next, "performed")+1)
+		setNumber(next, "planned_sets", number(next, "planned_sets")+planned)
+		setNumber(next, "completed_sets", number(next, "completed_sets")+completed)
+
+		// workouts can be logged out of order, so the latest start only ever moves forward
+		start := models.Timestamp(workout.Start)
+		if text(next, "last_performed") < start {
+			next["last_performed"] = &types.AttributeValueMemberS{Value: start}
+		}
+
+		return t.put(next)
+	})
+
+	return failed(err)
+}
+
+// ReorderTemplates gives the templates their place in the list, in the order given,
+// all or none of them. Each one moves to its next version.
+func (s *Store) ReorderTemplates(ctx context.Context, userId string, templateIds []string) error {
+	updatedAt := models.Timestamp(time.Now())
+	pk := models.UserKey + userId
+
+	err := s.update(ctx, func(t tx) error {
+		for i, id := range templateIds {
+			stored, err := t.get(pk, models.TemplateKey+id)
+			if err != nil {
+				return err
+			}
+			if stored == nil {
+				return models.NewNotFoundError(fmt.Sprintf("Template %s not found", id), errConditionFailed)
+			}
+
+			next := edit(stored, pk, models.TemplateKey+id)
+			setNumber(next, "order", i)
+			next["updated_at"] = &types.AttributeValueMemberS{Value: updatedAt}
+			bumpVersion(next)
+			if err := t.put(next); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return failed(err)
+}
+
+func (s *Store) DeleteTemplate(ctx context.Context, userId string, templateId string) error {
+	err := s.update(ctx, func(t tx) error {
+		return deleteWithTombstone(t, userId, models.KindTemplate, models.TemplateKey+templateId, templateId)
+	})
+
+	return failed(err)
+}
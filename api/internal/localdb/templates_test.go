@@ -0,0 +1,89 @@
+package localdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"heart/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTemplate(userId, id string) models.Template {
+	return models.Template{PK: models.UserKey + userId, SK: models.TemplateKey + id, Name: id}
+}
+
+func TestReorderTemplates_AllOrNone(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	for _, id := range []string{"a", "b"} {
+		_, err := s.SaveTemplate(ctx, newTemplate("u1", id), nil)
+		require.NoError(t, err)
+	}
+
+	err := s.ReorderTemplates(ctx, "u1", []string{"b", "missing", "a"})
+	var notFound *models.NotFoundError
+	require.ErrorAs(t, err, &notFound)
+
+	b, err := s.GetTemplate(ctx, "u1", "b")
+	require.NoError(t, err)
+	assert.Equal(t, 1, b.Version)
+	assert.Equal(t, 0, b.OrderInParent)
+
+	require.NoError(t, s.ReorderTemplates(ctx, "u1", []string{"b", "a"}))
+	a, err := s.GetTemplate(ctx, "u1", "a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, a.Version)
+	assert.Equal(t, 1, a.OrderInParent)
+}
+
+func TestUpdateTemplate_KeepsUsageAndNeedsTheTemplate(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	_, err := s.UpdateTemplate(ctx, newTemplate("u1", "a"), nil)
+	var notFound *models.NotFoundError
+	require.ErrorAs(t, err, &notFound)
+
+	template, err := s.SaveTemplate(ctx, newTemplate("u1", "a"), nil)
+	require.NoError(t, err)
+
+	workout := newWorkout("u1", "2025-07-01T18:00:00Z")
+	require.NoError(t, s.RecordTemplateUse(ctx, template, &workout))
+
+	renamed := newTemplate("u1", "a")
+	renamed.Name = "Push"
+	updated, err := s.UpdateTemplate(ctx, renamed, &template.Version)
+	require.NoError(t, err)
+	assert.Equal(t, "Push", updated.Name)
+	assert.Equal(t, 2, updated.Version)
+	assert.Equal(t, 1, updated.Performed)
+	assert.Equal(t, models.Timestamp(workout.Start), updated.LastPerformed)
+
+	_, err = s.UpdateTemplate(ctx, renamed, &template.Version)
+	var conflict *models.ConflictError
+	assert.ErrorAs(t, err, &conflict)
+}
+
+func TestRecordTemplateUse_LastPerformedOnlyMovesForward(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	template, err := s.SaveTemplate(ctx, newTemplate("u1", "a"), nil)
+	require.NoError(t, err)
+
+	later := newWorkout("u1", "2025-07-02T18:00:00Z")
+	later.Start = later.Start.Add(24 * time.Hour)
+	earlier := newWorkout("u1", "2025-07-01T18:00:00Z")
+	require.NoError(t, s.RecordTemplateUse(ctx, template, &later))
+	require.NoError(t, s.RecordTemplateUse(ctx, template, &earlier))
+
+	stored, err := s.GetTemplate(ctx, "u1", "a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, stored.Performed)
+	assert.Equal(t, models.Timestamp(later.Start), stored.LastPerformed)
+	assert.Equal(t, 1, stored.Version)
+}
@@ -0,0 +1,439 @@
+package localdb
+
+import (
+	"context"
+	"errors"
+	"heart/internal/config"
+	"heart/internal/models"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func (s *Store) GetWorkout(ctx context.Context, userId string, workoutId string) (*models.Workout, error) {
+	var workout *models.Workout
+	err := s.view(ctx, func(t tx) (err error) {
+		workout, err = load[models.Workout](t, models.UserKey+userId, models.WorkoutKey+workoutId)
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	if workout == nil {
+		return nil, models.NewNotFoundError("Workout not found", nil)
+	}
+	return workout, nil
+}
+
+// SaveWorkout upserts the workout, bumps its version and brings the exercise history in line.
+// A non-nil expected version makes the save fail with a conflict, carrying the stored copy,
+// if the workout has moved on since. Images stay as they were.
+func (s *Store) SaveWorkout(ctx context.Context, in models.Workout, expected *int) (*models.Workout, error) {
+	in.UpdatedAt = models.Timestamp(time.Now())
+
+	err := s.update(ctx, func(t tx) error {
+		stored, err := t.get(in.PK, in.SK)
+		if err != nil {
+			return err
+		}
+		if !onVersion(stored, expected) {
+			return workoutConflict(stored)
+		}
+
+		next := edit(stored, in.PK, in.SK)
+		if err := setFrom(next, in, "start", "end", "name", "exercises", "template_id", "updated_at"); err != nil {
+			return err
+		}
+		in.Version = bumpVersion(next)
+		if err := t.put(next); err != nil {
+			return err
+		}
+
+		var previous *models.Workout
+		if stored != nil {
+			previous = &models.Workout{}
+			if err := attributevalue.UnmarshalMap(stored, previous); err != nil {
+				return err
+			}
+		}
+		if err := saveHistory(t, &in, previous); err != nil || previous == nil {
+			return err
+		}
+		return dropRecords(t, strings.TrimPrefix(in.PK, models.UserKey), previous, &in)
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return &in, nil
+}
+
+func workoutConflict(stored item) error {
+	if stored == nil {
+		return models.NewNotFoundError("Workout not found", errConditionFailed)
+	}
+
+	var current models.Workout
+	if err := attributevalue.UnmarshalMap(stored, &current); err != nil {
+		return err
+	}
+
+	return models.NewConflictError(
+		"Workout was changed on another device",
+		models.NewWorkoutOut(&current, config.App.MediaDistributionAlias),
+		errConditionFailed,
+	)
+}
+
+// ImportWorkouts writes new workouts, along with their exercise history.
+// It does not check for workouts already there; those are overwritten.
+func (s *Store) ImportWorkouts(ctx context.Context, workouts []models.Workout) (int, error) {
+	updatedAt := models.Timestamp(time.Now())
+
+	err := s.update(ctx, func(t tx) error {
+		for _, w := range workouts {
+			w.UpdatedAt = updatedAt
+			w.Version = 1
+
+			if err := save(t, w); err != nil {
+				return err
+			}
+			if err := saveHistory(t, &w, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, failed(err)
+	}
+
+	return len(workouts), nil
+}
+
+func (s *Store) DeleteWorkout(ctx context.Context, userId string, workoutId string) error {
+	err := s.update(ctx, func(t tx) error {
+		existing, err := load[models.Workout](t, models.UserKey+userId, models.WorkoutKey+workoutId)
+		if err != nil {
+			return err
+		}
+
+		if err := deleteWithTombstone(t, userId, models.KindWorkout, models.WorkoutKey+workoutId, workoutId); err != nil {
+			return err
+		}
+
+		if existing == nil {
+			return nil
+		}
+		if err := deleteHistory(t, existing); err != nil {
+			return err
+		}
+		return dropRecords(t, userId, existing, nil)
+	})
+
+	return failed(err)
+}
+
+// GetWorkouts returns a page of the user's workouts that pass the filter, newest first.
+// The cursor is the ID of the last workout on the previous page.
+func (s *Store) GetWorkouts(ctx context.Context, userId string, limit int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error) {
+	if filter.Exercise != "" {
+		return s.getWorkoutsWithExercise(ctx, userId, limit, cursor, filter)
+	}
+
+	lower, upper := models.WorkoutKeyRange(models.WorkoutKey, filter.From, filter.To)
+	within := span{from: lower, to: upper, desc: true}
+	if cursor != "" {
+		within.after = models.WorkoutKey + cursor
+	}
+
+	var keep func(*models.Workout) bool
+	if filter.Name != "" {
+		keep = func(w *models.Workout) bool { return filter.MatchesName(w.Name) }
+	}
+
+	var workouts []models.Workout
+	var last string
+	err := s.view(ctx, func(t tx) (err error) {
+		workouts, last, err = page(t, models.UserKey+userId, within, limit, keep)
+		return err
+	})
+	if err != nil {
+		return nil, "", failed(err)
+	}
+
+	return workouts, strings.TrimPrefix(last, models.WorkoutKey), nil
+}
+
+// getWorkoutsWithExercise finds the workouts through the exercise's history rows,
+// which carry the workout name as well, and then reads them whole.
+func (s *Store) getWorkoutsWithExercise(ctx context.Context, userId string, limit int, cursor string, filter models.WorkoutFilter) ([]models.Workout, string, error) {
+	if err := s.ensureHistory(ctx, userId); err != nil {
+		return nil, "", err
+	}
+
+	pk := models.UserKey + userId
+	prefix := models.HistoryPrefix(filter.Exercise)
+	lower, upper := models.WorkoutKeyRange(prefix, filter.From, filter.To)
+	within := span{from: lower, to: upper, desc: true}
+	if cursor != "" {
+		within.after = prefix + cursor
+	}
+
+	var keep func(*models.HistoryEntry) bool
+	if filter.Name != "" {
+		keep = func(h *models.HistoryEntry) bool { return filter.MatchesName(h.WorkoutName) }
+	}
+
+	var workouts []models.Workout
+	var last string
+	err := s.view(ctx, func(t tx) error {
+		entries, next, err := page(t, pk, within, limit, keep)
+		if err != nil {
+			return err
+		}
+		last = next
+
+		// in history order, skipping any workout deleted while its history lingered
+		workouts = make([]models.Workout, 0, len(entries))
+		for _, e := range entries {
+			w, err := load[models.Workout](t, pk, models.WorkoutKey+e.WorkoutID)
+			if err != nil {
+				return err
+			}
+			if w != nil {
+				workouts = append(workouts, *w)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", failed(err)
+	}
+
+	return workouts, strings.TrimPrefix(last, prefix), nil
+}
+
+// GetWorkoutsBetween returns all workouts the user started between from and to, oldest first,
+// none if from is after to.
+func (s *Store) GetWorkoutsBetween(ctx context.Context, userId string, from, to time.Time) ([]models.Workout, error) {
+	if from.After(to) {
+		return nil, nil
+	}
+
+	lower, upper := models.WorkoutKeyRange(models.WorkoutKey, from, to)
+
+	var workouts []models.Workout
+	err := s.view(ctx, func(t tx) (err error) {
+		workouts, err = all[models.Workout](t, models.UserKey+userId, span{from: lower, to: upper})
+		return err
+	})
+	if err != nil {
+		return nil, failed(err)
+	}
+
+	return workouts, nil
+}
+
+// RemoveWorkoutImage takes the image off the workout and out of the progress gallery.
+func (s *Store) RemoveWorkoutImage(ctx context.Context, userId, workoutId, imageId string) error {
+	// imageId is actually the object key (e.g. "workouts/<hash>/<uuidv7>.png")
+	imageKey := strings.TrimPrefix(imageId, "/")
+
+	if imageKey == "" {
+		return models.NewValidationError(errors.New("missing image key"))
+	}
+
+	pk := models.UserKey + userId
+	err := s.update(ctx, func(t tx) error {
+		stored, err := t.get(pk, models.WorkoutKey+workoutId)
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			return models.NewNotFoundError("Workout not found", errConditionFailed)
+		}
+
+		next := edit(stored, pk, models.WorkoutKey+workoutId)
+		if images, ok := next["images"].(*types.AttributeValueMemberSS); ok {
+			kept := slices.DeleteFunc(slices.Clone(images.Value), func(key string) bool { return key == imageKey })
+			if len(kept) == 0 {
+				delete(next, "images") // DynamoDB does not keep empty sets
+			} else {
+				next["images"] = &types.AttributeValueMemberSS{Value: kept}
+			}
+		}
+		// a change like any other, for other devices to sync
+		next["updated_at"] = &types.AttributeValueMemberS{Value: models.Timestamp(time.Now())}
+		bumpVersion(next)
+		if err := t.put(next); err != nil {
+			return err
+		}
+
+		return t.delete(pk, models.ProgressKey+workoutId+"#"+imageKey)
+	})
+
+	return failed(err)
+}
+
+// GetWorkoutGallery returns a page of the user's progress images, those of the latest workouts first.
+func (s *Store) GetWorkoutGallery(ctx context.Context, userId string, limit int, cursor string) ([]models.ImageOut, *string, error) {
+	within := prefixed(models.ProgressKey)
+	within.desc = true
+	if cursor != "" {
+		within.after = models.ProgressKey + cursor
+	}
+
+	var items []models.ProgressImage
+	var last string
+	err := s.view(ctx, func(t tx) (err error) {
+		items, last, err = page[models.ProgressImage](t, models.UserKey+userId, within, limit, nil)
+		return err
+	})
+	if err != nil {
+		return nil, nil, failed(err)
+	}
+
+	var nextCursor *string
+	if last != "" {
+		cur := models.ProgressCursorFromSK(last)
+		nextCursor = &cur
+	}
+
+	outs := make([]models.ImageOut, len(items))
+	for index, item := range items {
+		outs[index] = models.NewImageOut(item)
+	}
+
+	return outs, nextCursor, nil
+}
+
+// GetExerciseHistory returns the sets logged for an exercise, one entry per workout, newest first.
+// The cursor is the ID of the last workout on the previous page.
+func (s *Store) GetExerciseHistory(ctx context.Context, userId string, exercise string, limit int, cursor string) ([]models.HistoryEntry, string, error) {
+	if err := s.ensureHistory(ctx, userId); err != nil {
+		return nil, "", err
+	}
+
+	prefix := models.HistoryPrefix(exercise)
+	within := prefixed(prefix)
+	within.desc = true
+	if cursor != "" {
+		within.after = prefix + cursor
+	}
+
+	var entries []models.HistoryEntry
+	var last string
+	err := s.view(ctx, func(t tx) (err error) {
+		entries, last, err = page[models.HistoryEntry](t, models.UserKey+userId, within, limit, nil)
+		return err
+	})
+	if err != nil {
+		return nil, "", failed(err)
+	}
+
+	return entries, strings.TrimPrefix(last, prefix), nil
+}
+
+// ensureHistory writes the history of the user's workouts unless it has been written before,
+// as dbx.EnsureHistory does. Going through every workout takes no time worth handing over to
+// a background job here, so reads build it as well.
+func (s *Store) ensureHistory(ctx context.Context, userId string) error {
+	var built bool
+	err := s.view(ctx, func(t tx) (err error) {
+		built, err = historyBuilt(t, userId)
+		return err
+	})
+	if err != nil || built {
+		return failed(err)
+	}
+
+	return failed(s.update(ctx, func(t tx) error { return buildHistory(t, userId) }))
+}
+
+// RebuildHistory writes the history of every workout the user has, and marks it as complete.
+// Returns how many workouts it went through.
+func (s *Store) RebuildHistory(ctx context.Context, userId string) (int, error) {
+	count := 0
+	err := s.update(ctx, func(t tx) (err error) {
+		count, err = rebuildHistory(t, userId)
+		return err
+	})
+
+	return count, failed(err)
+}
+
+func historyBuilt(t tx, userId string) (bool, error) {
+	pk := models.UserKey + userId
+	built, err := load[models.HistoryBuilt](t, pk, models.HistoryBuiltKey)
+	return built != nil && built.Built(), err
+}
+
+// buildHistory writes the history of every workout the user has, unless it is complete already.
+func buildHistory(t tx, userId string) error {
+	built, err := historyBuilt(t, userId)
+	if err != nil || built {
+		return err
+	}
+
+	_, err = rebuildHistory(t, userId)
+	return err
+}
+
+func rebuildHistory(t tx, userId string) (int, error) {
+	workouts, err := all[models.Workout](t, models.UserKey+userId, prefixed(models.WorkoutKey))
+	if err != nil {
+		return 0, err
+	}
+	for i := range workouts {
+		if err := saveHistory(t, &workouts[i], nil); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(workouts), save(t, models.NewHistoryBuilt(userId, time.Now()))
+}
+
+// saveHistory writes the history entries of a saved workout and, given the copy it replaced,
+// drops those of exercises taken out of it since.
+func saveHistory(t tx, workout *models.Workout, previous *models.Workout) error {
+	var before []models.HistoryEntry
+	if previous != nil {
+		before = models.NewHistoryEntries(previous)
+	}
+	return replaceHistory(t, models.NewHistoryEntries(workout), before)
+}
+
+// replaceHistory writes the entries and drops those of before it leaves out.
+func replaceHistory(t tx, entries []models.HistoryEntry, before []models.HistoryEntry) error {
+	kept := map[string]bool{}
+	for _, entry := range entries {
+		kept[entry.SK] = true
+		if err := save(t, entry); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range before {
+		if !kept[entry.SK] {
+			if err := t.delete(entry.PK, entry.SK); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteHistory drops the history entries of a workout.
+func deleteHistory(t tx, workout *models.Workout) error {
+	for _, entry := range models.NewHistoryEntries(workout) {
+		if err := t.delete(entry.PK, entry.SK); err != nil {
+			return err
+		}
+	}
+	return nil
+}
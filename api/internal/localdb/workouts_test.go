@@ -0,0 +1,242 @@
+package localdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"heart/internal/models"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWorkout(userId, id string, exercises ...string) models.Workout {
+	w := models.Workout{
+		PK:    models.UserKey + userId,
+		SK:    models.WorkoutKey + id,
+		Name:  "Workout " + id,
+		Start: time.Date(2025, 7, 1, 18, 0, 0, 0, time.UTC),
+	}
+	for i, e := range exercises {
+		w.Exercises = append(w.Exercises, models.WorkoutExercise{
+			ID:            e,
+			ExerciseID:    e,
+			ExerciseOrder: i,
+			Sets:          []models.Set{{ID: "s1", Completed: true, Weight: 100, Reps: 5}},
+		})
+	}
+	return w
+}
+
+func TestSaveWorkout_BumpsVersionAndRejectsStaleEdits(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	zero := 0
+	saved, err := s.SaveWorkout(ctx, newWorkout("u1", "2025-07-01T18:00:00Z"), &zero)
+	require.NoError(t, err)
+	assert.Equal(t, 1, saved.Version)
+
+	_, err = s.SaveWorkout(ctx, newWorkout("u1", "2025-07-01T18:00:00Z"), &zero)
+	var conflict *models.ConflictError
+	require.ErrorAs(t, err, &conflict)
+
+	one := 1
+	saved, err = s.SaveWorkout(ctx, newWorkout("u1", "2025-07-01T18:00:00Z"), &one)
+	require.NoError(t, err)
+	assert.Equal(t, 2, saved.Version)
+
+	missing := 3
+	_, err = s.SaveWorkout(ctx, newWorkout("u1", "2025-07-02T18:00:00Z"), &missing)
+	var notFound *models.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
+
+func TestGetWorkouts_PagesNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	ids := []string{"2025-07-01T18:00:00Z", "2025-07-02T18:00:00Z", "2025-07-03T18:00:00Z"}
+	for _, id := range ids {
+		_, err := s.SaveWorkout(ctx, newWorkout("u1", id), nil)
+		require.NoError(t, err)
+	}
+	_, err := s.SaveWorkout(ctx, newWorkout("u2", "2025-07-04T18:00:00Z"), nil)
+	require.NoError(t, err)
+
+	first, cursor, err := s.GetWorkouts(ctx, "u1", 2, "", models.WorkoutFilter{})
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	assert.Equal(t, ids[2], first[0].ID())
+	assert.Equal(t, ids[1], first[1].ID())
+	assert.Equal(t, ids[1], cursor)
+
+	rest, cursor, err := s.GetWorkouts(ctx, "u1", 2, cursor, models.WorkoutFilter{})
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	assert.Equal(t, ids[0], rest[0].ID())
+	assert.Empty(t, cursor)
+}
+
+func TestGetWorkoutsBetween_OldestFirstAndNoneWhenInverted(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	for _, id := range []string{"2025-07-02T18:00:00Z", "2025-07-01T18:00:00Z", "2025-07-05T18:00:00Z"} {
+		_, err := s.SaveWorkout(ctx, newWorkout("u1", id), nil)
+		require.NoError(t, err)
+	}
+
+	from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 3, 0, 0, 0, 0, time.UTC)
+
+	workouts, err := s.GetWorkoutsBetween(ctx, "u1", from, to)
+	require.NoError(t, err)
+	require.Len(t, workouts, 2)
+	assert.Equal(t, "2025-07-01T18:00:00Z", workouts[0].ID())
+
+	workouts, err = s.GetWorkoutsBetween(ctx, "u1", to, from)
+	require.NoError(t, err)
+	assert.Empty(t, workouts)
+}
+
+func TestSaveWorkout_KeepsHistoryInLine(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	id := "2025-07-01T18:00:00Z"
+	_, err := s.SaveWorkout(ctx, newWorkout("u1", id, "Squat", "Bench Press"), nil)
+	require.NoError(t, err)
+
+	history, _, err := s.GetExerciseHistory(ctx, "u1", "Bench Press", 10, "")
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	_, err = s.SaveWorkout(ctx, newWorkout("u1", id, "Squat"), nil)
+	require.NoError(t, err)
+
+	history, _, err = s.GetExerciseHistory(ctx, "u1", "Bench Press", 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	workouts, _, err := s.GetWorkouts(ctx, "u1", 10, "", models.WorkoutFilter{Exercise: "Squat"})
+	require.NoError(t, err)
+	assert.Len(t, workouts, 1)
+
+	require.NoError(t, s.DeleteWorkout(ctx, "u1", id))
+	history, _, err = s.GetExerciseHistory(ctx, "u1", "Squat", 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestRemoveWorkoutImage_IsAChangeToSync(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	saved, err := s.SaveWorkout(ctx, newWorkout("u1", "2025-07-01T18:00:00Z"), nil)
+	require.NoError(t, err)
+	require.NoError(t, s.update(ctx, func(t tx) error {
+		stored, err := t.get(saved.PK, saved.SK)
+		if err != nil {
+			return err
+		}
+		stored["images"] = &types.AttributeValueMemberSS{Value: []string{"workouts/abc/1.jpg", "workouts/abc/2.jpg"}}
+		stored["updated_at"] = &types.AttributeValueMemberS{Value: "2025-07-01T19:00:00.000000Z"}
+		return t.put(stored)
+	}))
+
+	require.NoError(t, s.RemoveWorkoutImage(ctx, "u1", "2025-07-01T18:00:00Z", "workouts/abc/1.jpg"))
+
+	w, err := s.GetWorkout(ctx, "u1", "2025-07-01T18:00:00Z")
+	require.NoError(t, err)
+	require.NotNil(t, w.ImageKeys)
+	assert.Equal(t, []string{"workouts/abc/2.jpg"}, *w.ImageKeys)
+	assert.Equal(t, saved.Version+1, w.Version)
+	assert.Greater(t, w.UpdatedAt, "2025-07-01T19:00:00.000000Z")
+}
+
+func TestGetExerciseHistory_BuildsItForOlderWorkouts(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	// saved before history was kept
+	legacy := newWorkout("u1", "2025-06-01T18:00:00Z", "Squat")
+	require.NoError(t, s.update(ctx, func(t tx) error { return save(t, legacy) }))
+	_, err := s.SaveWorkout(ctx, newWorkout("u1", "2025-07-01T18:00:00Z", "Squat"), nil)
+	require.NoError(t, err)
+
+	entries, _, err := s.GetExerciseHistory(ctx, "u1", "Squat", 10, "")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "2025-06-01T18:00:00Z", entries[1].WorkoutID)
+
+	workouts, _, err := s.GetWorkouts(ctx, "u1", 10, "", models.WorkoutFilter{Exercise: "Squat"})
+	require.NoError(t, err)
+	assert.Len(t, workouts, 2)
+}
+
+func TestDeleteWorkout_BringsItsRecordsDown(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	older := newWorkout("u1", "2025-07-01T18:00:00Z", "Squat")
+	newer := newWorkout("u1", "2025-07-02T18:00:00Z", "Squat", "Lunge")
+	newer.Start = newer.Start.Add(24 * time.Hour)
+	newer.Exercises[0].Sets[0].Weight = 140
+	for _, w := range []models.Workout{older, newer} {
+		saved, err := s.SaveWorkout(ctx, w, nil)
+		require.NoError(t, err)
+		_, err = s.UpdateRecords(ctx, "u1", saved)
+		require.NoError(t, err)
+	}
+
+	// the 140 was a typo: edited down, the record goes back to the older workout
+	newer.Exercises[0].Sets[0].Weight = 90
+	saved, err := s.SaveWorkout(ctx, newer, nil)
+	require.NoError(t, err)
+	broken, err := s.UpdateRecords(ctx, "u1", saved)
+	require.NoError(t, err)
+	assert.Equal(t, []models.RecordKind{models.RecordRepsAtWeight}, broken["s1"])
+
+	squat, err := s.GetRecords(ctx, "u1", "Squat")
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, squat.Weight.Value)
+	assert.Equal(t, older.ID(), squat.Weight.WorkoutID)
+
+	// deleted, it holds nothing any more
+	require.NoError(t, s.DeleteWorkout(ctx, "u1", newer.ID()))
+
+	squat, err = s.GetRecords(ctx, "u1", "Squat")
+	require.NoError(t, err)
+	assert.False(t, squat.HeldBy(newer.ID()))
+	assert.NotContains(t, squat.RepsAtWeight, "90")
+
+	lunge, err := s.GetRecords(ctx, "u1", "Lunge")
+	require.NoError(t, err)
+	assert.True(t, lunge.Empty())
+}
+
+func TestUpdateRecords_WorksOutRecordsNotKeptYetFromHistory(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	// logged before records were kept: the workout is there, its records are not
+	older := newWorkout("u1", "2025-07-01T18:00:00Z", "Squat")
+	_, err := s.SaveWorkout(ctx, older, nil)
+	require.NoError(t, err)
+
+	lighter := newWorkout("u1", "2025-07-02T18:00:00Z", "Squat")
+	lighter.Start = lighter.Start.Add(24 * time.Hour)
+	lighter.Exercises[0].Sets[0].Reps = 3
+	saved, err := s.SaveWorkout(ctx, lighter, nil)
+	require.NoError(t, err)
+	broken, err := s.UpdateRecords(ctx, "u1", saved)
+	require.NoError(t, err)
+	assert.Empty(t, broken)
+
+	squat, err := s.GetRecords(ctx, "u1", "Squat")
+	require.NoError(t, err)
+	assert.Equal(t, older.ID(), squat.Weight.WorkoutID)
+}
@@ -1,28 +1,39 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"heart/internal/firebasex"
-	"net/http"
+	"heart/internal/models"
 	"strings"
 
+	"firebase.google.com/go/v4/auth"
 	"github.com/gin-gonic/gin"
 )
 
 // test seam: allow stubbing in tests
 var verifyIDToken = firebasex.VerifyIDToken
 
+// TrustBearerTokens makes Authentication take bearer tokens for the IDs of the users themselves.
+// It is only meant for running offline, where there is no Firebase to verify them with.
+func TrustBearerTokens() {
+	verifyIDToken = func(_ context.Context, token string) (*auth.Token, error) {
+		return &auth.Token{UID: token}, nil
+	}
+}
+
 func Authentication() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
 		if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or invalid token"})
+			Abort(c, models.NewUnauthorizedError("Missing or invalid token", errors.New("no bearer token")))
 			return
 		}
 
 		bearer := strings.TrimPrefix(auth, "Bearer ")
 		token, err := verifyIDToken(c.Request.Context(), bearer)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			Abort(c, models.NewUnauthorizedError("Invalid token", err))
 			return
 		}
 
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"heart/internal/config"
+	"heart/internal/models"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gin-gonic/gin"
+)
+
+// Abort ends the request with err as problem details or, for apps older than
+// PROBLEM_DETAILS_APP_VERSION, as the body they were built to read. Errors that are not
+// HTTPErrors are server errors.
+func Abort(c *gin.Context, err error) {
+	var httpErr models.HTTPError
+	if !errors.As(err, &httpErr) {
+		httpErr = models.NewServerError(err)
+	}
+
+	if legacyErrors(c.GetHeader("X-App-Version")) {
+		c.Data(httpErr.Status(), "application/json", httpErr.JSON())
+	} else {
+		body, _ := json.Marshal(httpErr.Problem(c.Request.URL.Path))
+		c.Data(httpErr.Status(), models.ProblemContentType, body)
+	}
+	c.Abort()
+}
+
+// legacyErrors tells whether an app of that version predates problem details. Those that
+// do not say, or when no version is set to tell them apart, get problem details.
+func legacyErrors(appVersion string) bool {
+	if config.App == nil || config.App.ProblemDetailsSince == nil {
+		return false
+	}
+	since := config.App.ProblemDetailsSince
+
+	v, err := semver.NewVersion(strings.Split(appVersion, "+")[0])
+	if err != nil {
+		return false
+	}
+
+	return v.LessThan(since)
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"heart/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+// problemDetailsSince sets the app version problem details start at, none for an empty one.
+func problemDetailsSince(t *testing.T, version string) {
+	t.Helper()
+	previous := config.App
+	t.Cleanup(func() { config.App = previous })
+
+	config.App = &config.AppConfig{}
+	if version != "" {
+		config.App.ProblemDetailsSince = semver.MustParse(version)
+	}
+}
+
+func TestLegacyErrors(t *testing.T) {
+	problemDetailsSince(t, "2.9.0")
+
+	assert.True(t, legacyErrors("2.8.5"))
+	assert.True(t, legacyErrors("2.8.5+120"))
+	assert.False(t, legacyErrors("2.9.0"))
+	assert.False(t, legacyErrors("3.0.0"))
+	assert.False(t, legacyErrors(""), "apps that do not say get problem details")
+	assert.False(t, legacyErrors("bad.version"))
+
+	problemDetailsSince(t, "")
+	assert.False(t, legacyErrors("1.0.0"))
+}
+
+func TestVersion_ProblemDetails(t *testing.T) {
+	t.Setenv("MIN_APP_VERSION", "1.2.3")
+	problemDetailsSince(t, "1.0.0")
+	r := newVersionTestRouter()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set("X-App-Version", "1.2.2")
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, 426, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "/problems/UpgradeRequired",
+		"title": "The app needs updating",
+		"status": 426,
+		"detail": "Please update the app to continue.",
+		"instance": "/ok",
+		"code": "UpgradeRequired",
+		"details": {"minVersion": "1.2.3", "currentVersion": "1.2.2"}
+	}`, rec.Body.String())
+}
+
+func TestVersion_OlderAppsKeepTheirBody(t *testing.T) {
+	t.Setenv("MIN_APP_VERSION", "1.2.3")
+	problemDetailsSince(t, "2.0.0")
+	r := newVersionTestRouter()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set("X-App-Version", "1.2.2")
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, 426, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"message":"Please update the app to continue.","minVersion":"1.2.3","currentVersion":"1.2.2"}`, rec.Body.String())
+}
+
+func TestAuthentication_OlderAppsKeepTheirBody(t *testing.T) {
+	problemDetailsSince(t, "2.0.0")
+	r := newAuthTestRouter()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("X-App-Version", "1.9.0")
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.JSONEq(t, `{"error":"Missing or invalid token","code":"Unauthorized"}`, rec.Body.String())
+}
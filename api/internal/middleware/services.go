@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"heart/internal/awsx"
+
+	"github.com/gin-gonic/gin"
+)
+
+const servicesKey = "services"
+
+// Services hands the object storage and background jobs to the handlers down the chain.
+func Services(services awsx.Services) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		SetServices(c, services)
+		c.Next()
+	}
+}
+
+// SetServices puts the services on the context, the way Services does for every request.
+func SetServices(c *gin.Context, services awsx.Services) {
+	c.Set(servicesKey, services)
+}
+
+// ServicesFrom returns the services put on the context. Without any, it is a bug in how the router is set up.
+func ServicesFrom(c *gin.Context) awsx.Services {
+	return c.MustGet(servicesKey).(awsx.Services)
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"heart/internal/dbx"
+
+	"github.com/gin-gonic/gin"
+)
+
+const storesKey = "stores"
+
+// Stores hands the data stores to the handlers down the chain.
+func Stores(stores dbx.Stores) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		SetStores(c, stores)
+		c.Next()
+	}
+}
+
+// SetStores puts the stores on the context, the way Stores does for every request.
+func SetStores(c *gin.Context, stores dbx.Stores) {
+	c.Set(storesKey, stores)
+}
+
+// StoresFrom returns the stores put on the context. Without any, it is a bug in how the router is set up.
+func StoresFrom(c *gin.Context) dbx.Stores {
+	return c.MustGet(storesKey).(dbx.Stores)
+}
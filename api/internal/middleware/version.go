@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"heart/internal/models"
 	"os"
 	"strings"
 
@@ -8,17 +9,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const (
-	code     = 426
-	language = "Please update the app to continue."
-)
-
 func Version() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		appVersion := c.GetHeader("X-App-Version")
 
 		if appVersion == "" {
-			c.AbortWithStatusJSON(code, gin.H{"message": language})
+			Abort(c, models.NewUpgradeRequiredError("", ""))
 			return
 		}
 
@@ -27,7 +23,7 @@ func Version() gin.HandlerFunc {
 		v, err := semver.NewVersion(currentVersion)
 
 		if err != nil {
-			c.AbortWithStatusJSON(code, gin.H{"message": language})
+			Abort(c, models.NewUpgradeRequiredError("", ""))
 			return
 		}
 
@@ -38,11 +34,7 @@ func Version() gin.HandlerFunc {
 		}
 
 		if v.LessThan(minVersion) {
-			c.AbortWithStatusJSON(code, gin.H{
-				"message":        language,
-				"minVersion":     os.Getenv("MIN_APP_VERSION"),
-				"currentVersion": currentVersion,
-			})
+			Abort(c, models.NewUpgradeRequiredError(os.Getenv("MIN_APP_VERSION"), currentVersion))
 			return
 		}
 
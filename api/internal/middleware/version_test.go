@@ -25,7 +25,7 @@ func TestVersion_MissingHeader(t *testing.T) {
 	r.ServeHTTP(rec, req)
 
 	assert.Equal(t, 426, rec.Code)
-	assert.Contains(t, rec.Body.String(), language)
+	assert.Contains(t, rec.Body.String(), "Please update the app to continue.")
 }
 
 func TestVersion_MalformedSemver(t *testing.T) {
@@ -36,7 +36,7 @@ func TestVersion_MalformedSemver(t *testing.T) {
 	r.ServeHTTP(rec, req)
 
 	assert.Equal(t, 426, rec.Code)
-	assert.Contains(t, rec.Body.String(), language)
+	assert.Contains(t, rec.Body.String(), "Please update the app to continue.")
 }
 
 func TestVersion_MinVersionUnset_Allows(t *testing.T) {
@@ -1,3 +1,13 @@
 package models
 
 var NoContent = struct{}{}
+
+type notModified struct{}
+
+// NotModified tells the client that the copy it holds, named by If-None-Match, is still current.
+var NotModified = notModified{}
+
+type accepted struct{}
+
+// Accepted tells the client that what it asked for is on its way but not ready yet; it should ask again.
+var Accepted = accepted{}
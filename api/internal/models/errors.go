@@ -2,20 +2,23 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"maps"
 )
 
+// HTTPError is an error that knows how to answer the request it failed.
 type HTTPError interface {
 	error
 	Status() int
-	JSON() []byte
+	Problem(instance string) Problem
+	JSON() []byte // the body apps older than problem details expect
 }
 
 type baseError struct {
 	Err     error
-	status  int
 	message string
-	code    string
+	code    ErrorCode
 	details map[string]any
 }
 
@@ -24,19 +27,34 @@ func (e *baseError) Error() string {
 }
 
 func (e *baseError) Status() int {
-	return e.status
+	kind, _ := LookUpErrorKind(e.code)
+	return kind.Status
 }
 
-func (e *baseError) JSON() []byte {
-	message := e.message
-	if message == "" {
-		message = e.Err.Error()
+func (e *baseError) detail() string {
+	if e.message != "" {
+		return e.message
 	}
-	resp := map[string]any{
-		"error": message,
+	return e.Err.Error()
+}
+
+func (e *baseError) Problem(instance string) Problem {
+	kind, _ := LookUpErrorKind(e.code)
+	return Problem{
+		Type:     kind.Type,
+		Title:    kind.Title,
+		Status:   kind.Status,
+		Detail:   e.detail(),
+		Instance: instance,
+		Code:     e.code,
+		Details:  e.details,
 	}
-	if e.code != "" {
-		resp["code"] = e.code
+}
+
+func (e *baseError) JSON() []byte {
+	resp := map[string]any{
+		"error": e.detail(),
+		"code":  e.code,
 	}
 	if len(e.details) > 0 {
 		resp["details"] = e.details
@@ -52,9 +70,11 @@ type ServerError struct {
 type ValidationError struct {
 	*baseError
 }
+
 type UnauthorizedError struct {
 	*baseError
 }
+
 type ForbiddenError struct {
 	*baseError
 }
@@ -63,24 +83,57 @@ type NotFoundError struct {
 	*baseError
 }
 
+// UpgradeRequiredError turns away apps too old for the API.
+type UpgradeRequiredError struct {
+	*baseError
+}
+
+// ConflictError is returned when a write was based on a stale version;
+// it carries the server copy so the client can merge and retry.
+type ConflictError struct {
+	*baseError
+	Current any
+}
+
 func NewServerError(err error) *ServerError {
 	log.Printf("[ERROR] %v", err)
 	return &ServerError{
 		&baseError{
 			Err:     err,
-			status:  500,
-			code:    "ServerError",
+			code:    CodeServer,
 			message: "Internal server error",
 		},
 	}
 }
 
+// detailed is an error that can tell which fields of the request it is about.
+type detailed interface {
+	error
+	Details() map[string]any
+}
+
+// NewValidationError wraps a rejected request. When err tells which fields are at fault,
+// the response lists them under details.
 func NewValidationError(err error) *ValidationError {
-	return &ValidationError{
+	e := &ValidationError{
 		&baseError{
-			Err:    err,
-			status: 400,
-			code:   "ValidationError",
+			Err:  err,
+			code: CodeValidation,
+		},
+	}
+	var d detailed
+	if errors.As(err, &d) {
+		e.details = d.Details()
+	}
+	return e
+}
+
+func NewUnauthorizedError(msg string, err error) *UnauthorizedError {
+	return &UnauthorizedError{
+		&baseError{
+			Err:     err,
+			message: msg,
+			code:    CodeUnauthorized,
 		},
 	}
 }
@@ -89,9 +142,8 @@ func NewForbiddenError(msg string, err error) *ForbiddenError {
 	return &ForbiddenError{
 		&baseError{
 			Err:     err,
-			status:  403,
 			message: msg,
-			code:    "Forbidden",
+			code:    CodeForbidden,
 		},
 	}
 }
@@ -100,14 +152,49 @@ func NewNotFoundError(msg string, err error) *NotFoundError {
 	return &NotFoundError{
 		&baseError{
 			Err:     err,
-			status:  404,
 			message: msg,
-			code:    "NotFound",
+			code:    CodeNotFound,
+		},
+	}
+}
+
+func NewConflictError(msg string, current any, err error) *ConflictError {
+	return &ConflictError{
+		baseError: &baseError{
+			Err:     err,
+			message: msg,
+			code:    CodeConflict,
+			details: map[string]any{"current": current},
 		},
+		Current: current,
 	}
 }
 
-type ErrorResponse struct {
-	Error string `json:"error" example:"An unexpected error occurred"`
-	Code  string `json:"code" example:"InternalError"`
-} // @name ErrorResponse
+// NewUpgradeRequiredError turns away an app of currentVersion, older than minVersion.
+// Either is empty when not known, such as when the app did not say its version.
+func NewUpgradeRequiredError(minVersion, currentVersion string) *UpgradeRequiredError {
+	details := map[string]any{}
+	if minVersion != "" {
+		details["minVersion"] = minVersion
+	}
+	if currentVersion != "" {
+		details["currentVersion"] = currentVersion
+	}
+
+	return &UpgradeRequiredError{
+		&baseError{
+			Err:     errors.New("app version below the minimum"),
+			message: "Please update the app to continue.",
+			code:    CodeUpgradeRequired,
+			details: details,
+		},
+	}
+}
+
+// JSON keeps the body older apps look for, with the message at the top.
+func (e *UpgradeRequiredError) JSON() []byte {
+	resp := map[string]any{"message": e.message}
+	maps.Copy(resp, e.details)
+	bytes, _ := json.Marshal(resp)
+	return bytes
+}
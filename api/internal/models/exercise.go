@@ -1,8 +1,10 @@
 package models
 
 import (
+	"cmp"
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
 )
 
@@ -16,12 +18,55 @@ type Exercise struct {
 	Instructions *string           `dynamodbav:"instructions,omitempty"`
 	UserID       string            `dynamodbav:"userId,omitempty"`
 	Archived     *bool             `dynamodbav:"archived,omitempty"`
+	UpdatedAt    string            `dynamodbav:"updated_at,omitempty"`
+	// Translations of a catalog exercise, keyed by lowercase language tag such as "de" or "pt-br".
+	// Name stays the English one: it is what workouts refer to the exercise by.
+	Translations map[string]ExerciseTranslation `dynamodbav:"translations,omitempty"`
 }
 
 func (e *Exercise) String() string {
 	return e.Name
 }
 
+// ExerciseTranslation is how an exercise reads in one language; blank fields fall back to English.
+type ExerciseTranslation struct {
+	Name         string  `dynamodbav:"name,omitempty"`
+	Category     string  `dynamodbav:"category,omitempty"`
+	Target       string  `dynamodbav:"target,omitempty"`
+	Instructions *string `dynamodbav:"instructions,omitempty"`
+}
+
+// Localize returns how the exercise reads in the locale, or nil if it has no translation for it.
+func (e *Exercise) Localize(locale string) *LocalizedExerciseOut {
+	t, ok := e.Translations[locale]
+	if !ok {
+		return nil
+	}
+
+	out := LocalizedExerciseOut{
+		Locale:       locale,
+		Name:         cmp.Or(t.Name, e.Name),
+		Category:     cmp.Or(t.Category, e.Category),
+		Target:       cmp.Or(t.Target, e.Target),
+		Instructions: e.Instructions,
+	}
+	if t.Instructions != nil {
+		out.Instructions = t.Instructions
+	}
+	return &out
+}
+
+// ExerciseLocales lists the locales any of the exercises is translated to.
+func ExerciseLocales(exercises []Exercise) map[string]bool {
+	locales := map[string]bool{}
+	for _, e := range exercises {
+		for locale := range e.Translations {
+			locales[locale] = true
+		}
+	}
+	return locales
+}
+
 type ExerciseOut struct {
 	Name         string            `json:"name" example:"Push Up" binding:"required"`
 	Category     string            `json:"category" example:"Body weight" binding:"required"`
@@ -31,8 +76,20 @@ type ExerciseOut struct {
 	Instructions *string           `json:"instructions,omitempty" example:"Keep your body straight and lower yourself until your chest almost touches the ground."`
 	Own          *bool             `json:"own,omitempty"`
 	Archived     *bool             `json:"archived,omitempty"`
+	Aliases      []string          `json:"aliases,omitempty" example:"DB Bench"` // names merged into it
+	// Localized is how the exercise reads in the language negotiated from Accept-Language.
+	// Absent for English, and where there is no translation; name remains what to refer to it by.
+	Localized *LocalizedExerciseOut `json:"localized,omitempty"`
 } // @name Exercise
 
+type LocalizedExerciseOut struct {
+	Locale       string  `json:"locale" example:"de"`
+	Name         string  `json:"name" example:"Liegestütz"`
+	Category     string  `json:"category" example:"Körpergewicht"`
+	Target       string  `json:"target" example:"Brust"`
+	Instructions *string `json:"instructions,omitempty" example:"Halte den Körper gerade und senke dich ab, bis die Brust fast den Boden berührt."`
+} // @name LocalizedExercise
+
 func NewExerciseOut(e *Exercise) ExerciseOut {
 	return ExerciseOut{
 		Name:         e.Name,
@@ -49,6 +106,38 @@ type ExercisesResponse struct {
 	Exercises []ExerciseOut `json:"exercises"`
 } // @name ExercisesResponse
 
+// ExerciseFilter narrows down a listing of exercises; zero fields do not filter.
+type ExerciseFilter struct {
+	Category string // case-insensitive, the whole category, localized or not
+	Target   string // case-insensitive, the whole target, localized or not
+	Search   string // case-insensitive, any part of the name, localized or not, or of a name merged into it
+}
+
+func (f ExerciseFilter) Matches(e *ExerciseOut) bool {
+	if f.Category != "" && !strings.EqualFold(e.Category, f.Category) &&
+		(e.Localized == nil || !strings.EqualFold(e.Localized.Category, f.Category)) {
+		return false
+	}
+	if f.Target != "" && !strings.EqualFold(e.Target, f.Target) &&
+		(e.Localized == nil || !strings.EqualFold(e.Localized.Target, f.Target)) {
+		return false
+	}
+	if f.Search == "" {
+		return true
+	}
+
+	search := strings.ToLower(f.Search)
+	if strings.Contains(strings.ToLower(e.Name), search) {
+		return true
+	}
+	if e.Localized != nil && strings.Contains(strings.ToLower(e.Localized.Name), search) {
+		return true
+	}
+	return slices.ContainsFunc(e.Aliases, func(alias string) bool {
+		return strings.Contains(strings.ToLower(alias), search)
+	})
+}
+
 type EditExerciseIn struct {
 	Category     *string `json:"category,omitempty" example:"Body weight"`
 	Target       *string `json:"target,omitempty" example:"Chest"`
@@ -56,6 +145,25 @@ type EditExerciseIn struct {
 	Archived     *bool   `json:"archived,omitempty"`
 } // @name EditExerciseIn
 
+type MergeExerciseIn struct {
+	Target string `json:"target" example:"Dumbbell Bench Press" binding:"required"` // the exercise to merge into
+} // @name MergeExerciseIn
+
+type RenameExerciseIn struct {
+	Name string `json:"name" example:"Push Up" binding:"required"`
+} // @name RenameExerciseIn
+
+// ExerciseUsageOut counts what still refers to an exercise.
+type ExerciseUsageOut struct {
+	Workouts  int `json:"workouts" example:"12"`
+	Templates int `json:"templates" example:"2"`
+	Programs  int `json:"programs" example:"1"` // through their progression rules
+} // @name ExerciseUsage
+
+func (u ExerciseUsageOut) InUse() bool {
+	return u.Workouts+u.Templates+u.Programs > 0
+}
+
 type UserExerciseIn struct {
 	Name         string  `dynamodbav:"name" json:"name" example:"Push Up" binding:"required"`
 	Category     string  `dynamodbav:"category" json:"category" example:"Body weight" binding:"required"`
@@ -65,8 +173,9 @@ type UserExerciseIn struct {
 
 type UserExercise struct {
 	UserExerciseIn
-	PK string `json:"-" dynamodbav:"PK"`
-	SK string `json:"-" dynamodbav:"SK"`
+	PK        string `json:"-" dynamodbav:"PK"`
+	SK        string `json:"-" dynamodbav:"SK"`
+	UpdatedAt string `json:"-" dynamodbav:"updated_at,omitempty"`
 } // @name UserExercise
 
 func NewUserExercise(e *UserExerciseIn, userId string) UserExercise {
@@ -77,3 +186,62 @@ func NewUserExercise(e *UserExerciseIn, userId string) UserExercise {
 		UserExerciseIn: *e,
 	}
 }
+
+// ExerciseAlias keeps the name of an exercise that was merged into another one,
+// so that the old name still leads to the exercise it became.
+// PK: USER#<userId>
+// SK: ALIAS#<escaped name>
+type ExerciseAlias struct {
+	PK     string `dynamodbav:"PK"`
+	SK     string `dynamodbav:"SK"`
+	Name   string `dynamodbav:"name"`
+	Target string `dynamodbav:"target"`
+}
+
+func NewExerciseAlias(userId, name, target string) ExerciseAlias {
+	return ExerciseAlias{
+		PK:     UserKey + userId,
+		SK:     AliasKey + url.PathEscape(name),
+		Name:   name,
+		Target: target,
+	}
+}
+
+// Aliases maps the old names of exercises to the exercises they now stand for.
+type Aliases map[string]string
+
+func NewAliases(aliases []ExerciseAlias) Aliases {
+	out := make(Aliases, len(aliases))
+	for _, a := range aliases {
+		out[a.Name] = a.Target
+	}
+	return out
+}
+
+// Resolve returns the exercise a name stands for: the name itself unless it is an alias.
+func (a Aliases) Resolve(name string) string {
+	if target, ok := a[name]; ok {
+		return target
+	}
+	return name
+}
+
+// Of lists the aliases of an exercise, sorted.
+func (a Aliases) Of(target string) []string {
+	var names []string
+	for name, t := range a {
+		if t == target {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Apply renames the exercises of a workout logged under an alias to the exercise
+// it stands for, so that they count towards one and the same exercise.
+func (a Aliases) Apply(w *Workout) {
+	for name, target := range a {
+		w.RenameExercise(name, target)
+	}
+}
@@ -199,3 +199,58 @@ func strP(v string) *string {
 func intP(v int) *int {
 	return ptr.Int(v)
 }
+
+func TestAliases(t *testing.T) {
+	aliases := NewAliases([]ExerciseAlias{
+		NewExerciseAlias("u1", "DB Bench", "Dumbbell Bench Press"),
+		NewExerciseAlias("u1", "Bench DB", "Dumbbell Bench Press"),
+	})
+
+	assert.Equal(t, "Dumbbell Bench Press", aliases.Resolve("DB Bench"))
+	assert.Equal(t, "Squat", aliases.Resolve("Squat"))
+	assert.Equal(t, []string{"Bench DB", "DB Bench"}, aliases.Of("Dumbbell Bench Press"))
+	assert.Empty(t, aliases.Of("Squat"))
+
+	workout := Workout{Exercises: []WorkoutExercise{{ExerciseID: "DB Bench"}, {ExerciseID: "Squat"}}}
+	aliases.Apply(&workout)
+	assert.Equal(t, "Dumbbell Bench Press", workout.Exercises[0].ExerciseID)
+	assert.Equal(t, "Squat", workout.Exercises[1].ExerciseID)
+}
+
+func TestNewExerciseAlias_EscapesKey(t *testing.T) {
+	alias := NewExerciseAlias("u1", "DB Bench", "Dumbbell Bench Press")
+	assert.Equal(t, "USER#u1", alias.PK)
+	assert.Equal(t, "ALIAS#DB%20Bench", alias.SK)
+}
+
+func TestExercise_Localize(t *testing.T) {
+	e := Exercise{
+		Name:         "Push Up",
+		Category:     "Body weight",
+		Target:       "Chest",
+		Instructions: ptr.String("Keep your body straight."),
+		Translations: map[string]ExerciseTranslation{
+			"de": {Name: "Liegestütz", Category: "Körpergewicht"},
+		},
+	}
+
+	assert.Nil(t, e.Localize("fr"))
+
+	got := e.Localize("de")
+	require.NotNil(t, got)
+	assert.Equal(t, "de", got.Locale)
+	assert.Equal(t, "Liegestütz", got.Name)
+	assert.Equal(t, "Körpergewicht", got.Category)
+	assert.Equal(t, "Chest", got.Target, "falls back to English")
+	assert.Equal(t, "Keep your body straight.", *got.Instructions, "falls back to English")
+}
+
+func TestExerciseLocales(t *testing.T) {
+	locales := ExerciseLocales([]Exercise{
+		{Name: "Push Up", Translations: map[string]ExerciseTranslation{"de": {}, "pt-br": {}}},
+		{Name: "Pull Up", Translations: map[string]ExerciseTranslation{"de": {}}},
+		{Name: "Squat"},
+	})
+
+	assert.Equal(t, map[string]bool{"de": true, "pt-br": true}, locales)
+}
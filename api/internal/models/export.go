@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// DataExportKey is the sort key of the user's data export item; a user has at most one.
+const DataExportKey = "EXPORT"
+
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport tracks the latest personal data export a user asked for.
+// It goes out as JSON, as stored, only in the export itself.
+// PK: USER#<userId>
+// SK: EXPORT
+type DataExport struct {
+	PK          string     `dynamodbav:"PK" json:"pk"`
+	SK          string     `dynamodbav:"SK" json:"sk"`
+	Status      string     `dynamodbav:"status" json:"status"`
+	RequestedAt time.Time  `dynamodbav:"requested_at" json:"requested_at"`
+	CompletedAt *time.Time `dynamodbav:"completed_at,omitempty" json:"completed_at,omitempty"`
+	ObjectKey   *string    `dynamodbav:"object_key,omitempty" json:"object_key,omitempty"`
+}
+
+func NewDataExport(userId string, requestedAt time.Time) DataExport {
+	return DataExport{
+		PK:          UserKey + userId,
+		SK:          DataExportKey,
+		Status:      ExportPending,
+		RequestedAt: requestedAt,
+	}
+}
+
+type DataExportOut struct {
+	Status      string     `json:"status" example:"ready" enums:"pending,ready,failed"`
+	RequestedAt time.Time  `json:"requestedAt" example:"2025-07-25T18:20:01Z"`
+	CompletedAt *time.Time `json:"completedAt,omitempty" example:"2025-07-25T18:21:12Z"`
+	URL         *string    `json:"url,omitempty" example:"https://<bucket>.s3.amazonaws.com/exports/<user>/<uuidv7>.zip?X-Amz-Signature=..."`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty" example:"2025-07-25T19:21:12Z"`
+} // @name DataExport
+
+func NewDataExportOut(e *DataExport) DataExportOut {
+	return DataExportOut{
+		Status:      e.Status,
+		RequestedAt: e.RequestedAt,
+		CompletedAt: e.CompletedAt,
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+// GroupType tells how the exercises of a group are done: one set of each in turn,
+// resting only after the round. Exercises outside any group are done straight, set after set.
+type GroupType string
+
+const (
+	Superset GroupType = "superset" // two exercises
+	Circuit  GroupType = "circuit"  // a round of exercises, often timed
+	GiantSet GroupType = "giant"    // three or more exercises for one muscle group
+)
+
+// GroupTypes are the types a group can have.
+var GroupTypes = []GroupType{Superset, Circuit, GiantSet}
+
+// Straight names, in stats, the exercises outside any group.
+const Straight = "straight"
+
+// loners lists the groups left with a single exercise, such as after the others were removed.
+func loners(groups []string) map[string]bool {
+	sizes := map[string]int{}
+	for _, g := range groups {
+		if g != "" {
+			sizes[g]++
+		}
+	}
+
+	alone := map[string]bool{}
+	for g, size := range sizes {
+		if size == 1 {
+			alone[g] = true
+		}
+	}
+	return alone
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkout_RemoveExerciseUndoesLoneGroups(t *testing.T) {
+	workout := Workout{Exercises: []WorkoutExercise{
+		{ID: "1", ExerciseID: "Bench", Group: "a", GroupType: Superset},
+		{ID: "2", ExerciseID: "Row", Group: "a", GroupType: Superset},
+		{ID: "3", ExerciseID: "Squat", Group: "b", GroupType: Circuit},
+		{ID: "4", ExerciseID: "Lunge", Group: "b", GroupType: Circuit},
+		{ID: "5", ExerciseID: "Burpee", Group: "b", GroupType: Circuit},
+	}}
+
+	assert.True(t, workout.RemoveExercise("Row"))
+	assert.True(t, workout.RemoveExercise("Burpee"))
+
+	assert.Equal(t, []WorkoutExercise{
+		{ID: "1", ExerciseID: "Bench"},
+		{ID: "3", ExerciseID: "Squat", Group: "b", GroupType: Circuit},
+		{ID: "4", ExerciseID: "Lunge", Group: "b", GroupType: Circuit},
+	}, workout.Exercises)
+}
+
+func TestTemplate_UpdateFromUndoesLoneGroups(t *testing.T) {
+	template := Template{}
+	ok := template.UpdateFrom(&Workout{Exercises: []WorkoutExercise{
+		{ID: "w1", ExerciseID: "Bench", Group: "a", GroupType: Superset, Sets: []Set{{ID: "a", Completed: true}}},
+		{ID: "w2", ExerciseID: "Row", Group: "a", GroupType: Superset, Sets: []Set{{ID: "b"}}},
+		{ID: "w3", ExerciseID: "Squat", Group: "b", GroupType: GiantSet, Sets: []Set{{ID: "c", Completed: true}}},
+		{ID: "w4", ExerciseID: "Lunge", Group: "b", GroupType: GiantSet, Sets: []Set{{ID: "d", Completed: true}}},
+	}})
+
+	assert.True(t, ok)
+	assert.Equal(t, []TemplateExercise{
+		{ID: "w1", ExerciseID: "Bench", ExerciseOrder: 0, Sets: []Set{{ID: "a"}}},
+		{ID: "w3", ExerciseID: "Squat", ExerciseOrder: 1, Sets: []Set{{ID: "c"}}, Group: "b", GroupType: GiantSet},
+		{ID: "w4", ExerciseID: "Lunge", ExerciseOrder: 2, Sets: []Set{{ID: "d"}}, Group: "b", GroupType: GiantSet},
+	}, template.Exercises)
+}
+
+func TestGroups_RoundTrip(t *testing.T) {
+	in := WorkoutIn{ID: "w1", Exercises: []WorkoutExerciseIn{
+		{ID: "e1", Exercise: "Bench", Order: 0, Group: "a", GroupType: Superset},
+		{ID: "e2", Exercise: "Row", Order: 1, Group: "a", GroupType: Superset},
+		{ID: "e3", Exercise: "Plank", Order: 2},
+	}}
+	workout := NewWorkout(&in, "u1")
+	out := NewWorkoutOut(&workout, "")
+	assert.Equal(t, "a", out.Exercises[1].Group)
+	assert.Equal(t, Superset, out.Exercises[1].GroupType)
+	assert.Empty(t, out.Exercises[2].Group)
+
+	template := Template{Exercises: []TemplateExercise{
+		{ID: "t1", ExerciseID: "Bench", ExerciseOrder: 0, Group: "a", GroupType: Circuit},
+		{ID: "t2", ExerciseID: "Row", ExerciseOrder: 1, Group: "a", GroupType: Circuit},
+	}}
+	started := template.StartWorkout(nil, time.Now())
+	assert.Equal(t, "a", started.Exercises[0].Group)
+	assert.Equal(t, Circuit, started.Exercises[1].GroupType)
+}
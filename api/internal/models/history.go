@@ -0,0 +1,143 @@
+package models
+
+import (
+	"net/url"
+	"slices"
+	"time"
+)
+
+// HistoryEntry is a DynamoDB item copying the sets one workout has of one exercise,
+// so that the history of an exercise reads as a single range.
+// PK: USER#<userId>
+// SK: HIST#<exercise>#<workoutId>
+type HistoryEntry struct {
+	PK          string    `dynamodbav:"PK"`
+	SK          string    `dynamodbav:"SK"`
+	Exercise    string    `dynamodbav:"exercise"`
+	WorkoutID   string    `dynamodbav:"workout_id"`
+	WorkoutName string    `dynamodbav:"workout_name,omitempty"`
+	Start       time.Time `dynamodbav:"start"`
+	Sets        []Set     `dynamodbav:"sets"`
+}
+
+// HistoryBuiltKey is the sort key of the item marking that the history of every workout
+// of the user has been written, those saved before history was kept included.
+const HistoryBuiltKey = "HISTORY"
+
+// HistoryBuilt is a DynamoDB item marking the user's history as complete or, until it is,
+// as being built in the background.
+// PK: USER#<userId>
+// SK: HISTORY
+type HistoryBuilt struct {
+	PK               string     `dynamodbav:"PK"`
+	SK               string     `dynamodbav:"SK"`
+	BuiltAt          *time.Time `dynamodbav:"built_at,omitempty"`
+	RebuildStartedAt *time.Time `dynamodbav:"rebuild_started_at,omitempty"`
+}
+
+func NewHistoryBuilt(userId string, at time.Time) HistoryBuilt {
+	return HistoryBuilt{PK: UserKey + userId, SK: HistoryBuiltKey, BuiltAt: &at}
+}
+
+// NewHistoryRebuild marks the user's history as being built in the background since the given time.
+func NewHistoryRebuild(userId string, at time.Time) HistoryBuilt {
+	return HistoryBuilt{PK: UserKey + userId, SK: HistoryBuiltKey, RebuildStartedAt: &at}
+}
+
+// Built tells whether the history is complete.
+func (h *HistoryBuilt) Built() bool {
+	return h.BuiltAt != nil
+}
+
+// HistoryPendingError is what reading history gives while the user's history is not complete yet.
+// Start is set for the one read that marked it as being built, and so has to start the rebuild.
+type HistoryPendingError struct {
+	Start bool
+}
+
+func (e *HistoryPendingError) Error() string {
+	return "exercise history is still being built"
+}
+
+func HistoryPrefix(exercise string) string {
+	return HistoryKey + url.PathEscape(exercise) + "#"
+}
+
+// NewHistoryEntries splits a workout into one entry per exercise in it,
+// with the sets of an exercise done more than once in the workout run together.
+func NewHistoryEntries(w *Workout) []HistoryEntry {
+	exercises := slices.Clone(w.Exercises)
+	slices.SortStableFunc(exercises, func(a, b WorkoutExercise) int {
+		return a.ExerciseOrder - b.ExerciseOrder
+	})
+
+	var entries []HistoryEntry
+	index := map[string]int{}
+
+	for _, e := range exercises {
+		if i, ok := index[e.ExerciseID]; ok {
+			entries[i].Sets = append(entries[i].Sets, e.Sets...)
+			continue
+		}
+
+		index[e.ExerciseID] = len(entries)
+		entries = append(entries, HistoryEntry{
+			PK:          w.PK,
+			SK:          HistoryPrefix(e.ExerciseID) + w.ID(),
+			Exercise:    e.ExerciseID,
+			WorkoutID:   w.ID(),
+			WorkoutName: w.Name,
+			Start:       w.Start,
+			Sets:        slices.Clone(e.Sets),
+		})
+	}
+
+	return entries
+}
+
+// LeaveOut drops the entries of the given workouts from the history.
+func LeaveOut(history []HistoryEntry, workouts []Workout) []HistoryEntry {
+	left := map[string]bool{}
+	for i := range workouts {
+		left[workouts[i].ID()] = true
+	}
+	return slices.DeleteFunc(history, func(e HistoryEntry) bool { return left[e.WorkoutID] })
+}
+
+// Workout is the part of the workout the entry copies: its sets of the one exercise.
+func (h *HistoryEntry) Workout() Workout {
+	return Workout{
+		PK:        h.PK,
+		SK:        WorkoutKey + h.WorkoutID,
+		Start:     h.Start,
+		Name:      h.WorkoutName,
+		Exercises: []WorkoutExercise{{ExerciseID: h.Exercise, Sets: h.Sets}},
+	}
+}
+
+type ExerciseHistoryOut struct {
+	WorkoutID string    `json:"workoutId" example:"2025-07-18T05:40:48.329406Z"`
+	Name      string    `json:"name" example:"Legs"`
+	Start     time.Time `json:"start" example:"2023-01-01T12:00:00Z"`
+	Sets      []SetOut  `json:"sets"`
+} // @name ExerciseHistoryEntry
+
+type ExerciseHistoryResponse struct {
+	Exercise string               `json:"exercise" example:"Bench Press"`
+	History  []ExerciseHistoryOut `json:"history"`
+	Cursor   string               `json:"cursor"`
+} // @name ExerciseHistoryResponse
+
+func NewExerciseHistoryOut(h *HistoryEntry) ExerciseHistoryOut {
+	sets := make([]SetOut, len(h.Sets))
+	for i, s := range h.Sets {
+		sets[i] = NewSetOut(&s)
+	}
+
+	return ExerciseHistoryOut{
+		WorkoutID: h.WorkoutID,
+		Name:      h.WorkoutName,
+		Start:     h.Start,
+		Sets:      sets,
+	}
+}
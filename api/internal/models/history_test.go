@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHistoryEntries_OnePerExercise(t *testing.T) {
+	start := time.Date(2025, 7, 18, 5, 0, 0, 0, time.UTC)
+	w := &Workout{
+		PK:    "USER#u1",
+		SK:    WorkoutKey + "w1",
+		Name:  "Push",
+		Start: start,
+		Exercises: []WorkoutExercise{
+			{ExerciseID: "Bench Press", ExerciseOrder: 2, Sets: []Set{{ID: "s3"}}},
+			{ExerciseID: "Bench Press", ExerciseOrder: 0, Sets: []Set{{ID: "s1"}, {ID: "s2"}}},
+			{ExerciseID: "Dips", ExerciseOrder: 1, Sets: []Set{{ID: "s4"}}},
+		},
+	}
+
+	entries := NewHistoryEntries(w)
+
+	require.Len(t, entries, 2)
+	assert.Equal(t, "HIST#Bench%20Press#w1", entries[0].SK)
+	assert.Equal(t, "USER#u1", entries[0].PK)
+	assert.Equal(t, "Push", entries[0].WorkoutName)
+	assert.Equal(t, start, entries[0].Start)
+	assert.Equal(t, []Set{{ID: "s1"}, {ID: "s2"}, {ID: "s3"}}, entries[0].Sets)
+	assert.Equal(t, "HIST#Dips#w1", entries[1].SK)
+
+	// the workout itself is left alone
+	assert.Len(t, w.Exercises[1].Sets, 2)
+}
+
+func TestNewExerciseHistoryOut(t *testing.T) {
+	out := NewExerciseHistoryOut(&HistoryEntry{
+		WorkoutID:   "w1",
+		WorkoutName: "Push",
+		Sets:        []Set{{ID: "s1", Completed: true, Weight: 100, Reps: 5}},
+	})
+
+	assert.Equal(t, "w1", out.WorkoutID)
+	assert.Equal(t, "Push", out.Name)
+	assert.Equal(t, []SetOut{{ID: "s1", Completed: true, Weight: 100, Reps: 5, Type: WorkingSet}}, out.Sets)
+}
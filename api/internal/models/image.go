@@ -9,6 +9,7 @@ type ImageDescription struct {
 	Link   *string `dynamodbav:"link" json:"link" example:"https://example.com/image.jpg" binding:"required"`
 	Width  *int    `dynamodbav:"width" json:"width" example:"100"`
 	Height *int    `dynamodbav:"height" json:"height" example:"100"`
+	Key    *string `dynamodbav:"key,omitempty" json:"-"` // of the object in the media bucket, for images users upload
 } // @name ImageDescription
 
 type HasMimeType struct {
@@ -17,6 +18,17 @@ type HasMimeType struct {
 
 const DefaultMimeType = "image/png"
 
+// Which image of an exercise an upload is for.
+const (
+	ExerciseAsset     = "asset"     // the demo, shown large
+	ExerciseThumbnail = "thumbnail" // shown in lists
+)
+
+type ExerciseImageIn struct {
+	HasMimeType
+	Kind string `json:"kind,omitempty" example:"asset" enums:"asset,thumbnail"` // asset, unless said otherwise
+} // @name ExerciseImageIn
+
 // Extension returns the first file Extension associated with the provided MIME type or an error if none is found.
 func Extension(mimeType string) (string, error) {
 	extensions, err := mime.ExtensionsByType(mimeType)
@@ -0,0 +1,16 @@
+package models
+
+type ImportSkip struct {
+	Line   int    `json:"line" example:"12"`
+	Reason string `json:"reason" example:"rest timer"`
+} // @name ImportSkip
+
+type ImportReport struct {
+	Format    string       `json:"format" example:"strong"`
+	Workouts  int          `json:"workouts" example:"120"`  // workouts imported
+	Imported  int          `json:"imported" example:"2400"` // rows imported
+	Duplicate int          `json:"duplicate" example:"35"`  // rows of workouts already here
+	Skipped   int          `json:"skipped" example:"80"`    // rows that could not be read
+	Exercises []string     `json:"exercises"`               // custom exercises created
+	Skips     []ImportSkip `json:"skips"`                   // the first of the skipped rows, and why
+} // @name ImportReport
@@ -0,0 +1,66 @@
+package models
+
+// ErrorCode is what kind of error a response is, for clients to act on. Once out, a code
+// keeps its meaning; the catalog below holds every code there is.
+type ErrorCode string
+
+const (
+	CodeValidation      ErrorCode = "ValidationError"
+	CodeUnauthorized    ErrorCode = "Unauthorized"
+	CodeForbidden       ErrorCode = "Forbidden"
+	CodeNotFound        ErrorCode = "NotFound"
+	CodeConflict        ErrorCode = "Conflict"
+	CodeUpgradeRequired ErrorCode = "UpgradeRequired"
+	CodeServer          ErrorCode = "ServerError"
+)
+
+// ProblemTypes is where the problem types are described, one per code.
+const ProblemTypes = "/problems/"
+
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// ErrorKind is an entry of the catalog: the status and title every error with the code has.
+type ErrorKind struct {
+	Code   ErrorCode `json:"code" example:"ValidationError"`
+	Type   string    `json:"type" example:"/problems/ValidationError"`
+	Title  string    `json:"title" example:"The request is not valid"`
+	Status int       `json:"status" example:"400"`
+} // @name ErrorKind
+
+// ErrorCatalog lists the codes errors come with.
+var ErrorCatalog = []ErrorKind{
+	newErrorKind(CodeValidation, 400, "The request is not valid"),
+	newErrorKind(CodeUnauthorized, 401, "Not signed in"),
+	newErrorKind(CodeForbidden, 403, "Not allowed"),
+	newErrorKind(CodeNotFound, 404, "Not found"),
+	newErrorKind(CodeConflict, 409, "Changed since the given version"),
+	newErrorKind(CodeUpgradeRequired, 426, "The app needs updating"),
+	newErrorKind(CodeServer, 500, "Internal server error"),
+}
+
+func newErrorKind(code ErrorCode, status int, title string) ErrorKind {
+	return ErrorKind{Code: code, Type: ProblemTypes + string(code), Title: title, Status: status}
+}
+
+// LookUpErrorKind finds the entry of the catalog for the code.
+func LookUpErrorKind(code ErrorCode) (ErrorKind, bool) {
+	for _, k := range ErrorCatalog {
+		if k.Code == code {
+			return k, true
+		}
+	}
+	return ErrorKind{}, false
+}
+
+// Problem is an error response as RFC 7807 problem details, with the code of the error and,
+// for some errors, details such as what is wrong with each field.
+type Problem struct {
+	Type     string         `json:"type" example:"/problems/ValidationError"`
+	Title    string         `json:"title" example:"The request is not valid"`
+	Status   int            `json:"status" example:"400"`
+	Detail   string         `json:"detail,omitempty" example:"end: must not be before the start"`
+	Instance string         `json:"instance,omitempty" example:"/workouts"` // the path of the request
+	Code     ErrorCode      `json:"code" example:"ValidationError"`
+	Details  map[string]any `json:"details,omitempty" swaggertype:"object"`
+} // @name ErrorResponse
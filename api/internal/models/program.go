@@ -0,0 +1,266 @@
+package models
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Program is a training plan, such as 5/3/1 or push/pull/legs, that lays templates out
+// over weeks and days and raises their targets as the weeks go by.
+// PK: USER#<userId>
+// SK: PROGRAM#<programId>
+type Program struct {
+	PK          string        `dynamodbav:"PK"`
+	SK          string        `dynamodbav:"SK"`
+	Name        string        `dynamodbav:"name"`
+	Weeks       int           `dynamodbav:"weeks"`
+	Start       time.Time     `dynamodbav:"start"`  // sessions are counted from here
+	Repeat      bool          `dynamodbav:"repeat"` // starts over after the last week, progression carrying on
+	Slots       []ProgramSlot `dynamodbav:"slots"`
+	Progression []Progression `dynamodbav:"progression"`
+	UpdatedAt   string        `dynamodbav:"updated_at,omitempty"`
+	Version     int           `dynamodbav:"version"` // bumped on every save
+}
+
+func (p *Program) ID() string {
+	return strings.TrimPrefix(p.SK, ProgramKey)
+}
+
+// ProgramSlot puts a template on a day of a week of the program.
+type ProgramSlot struct {
+	Week     int    `dynamodbav:"week" json:"week" binding:"min=1" example:"1"`
+	Day      int    `dynamodbav:"day" json:"day" binding:"min=1" example:"2"` // order within the week
+	Template string `dynamodbav:"template_id" json:"templateId" binding:"required" example:"2"`
+} // @name ProgramSlot
+
+// Progression adds to the targets of templates every week of the program.
+// A rule for an exercise takes the place of the rule for all exercises.
+type Progression struct {
+	Exercise string  `dynamodbav:"exercise,omitempty" json:"exercise,omitempty" example:"Squat"` // all exercises if empty
+	Weight   float64 `dynamodbav:"weight,omitempty" json:"weight,omitempty" example:"2.5"`       // kg per week
+	Reps     int     `dynamodbav:"reps,omitempty" json:"reps,omitempty" example:"1"`             // per week
+} // @name Progression
+
+type ProgramIn struct {
+	ID          string        `json:"id" binding:"required" example:"2025-07-18T05:40:48.329406Z"`
+	Name        string        `json:"name" binding:"required" example:"5/3/1"`
+	Weeks       int           `json:"weeks" binding:"min=1,max=52" example:"4"`
+	Start       *time.Time    `json:"start,omitempty" example:"2025-07-21T00:00:00Z"` // now if not given
+	Repeat      bool          `json:"repeat" example:"true"`
+	Slots       []ProgramSlot `json:"slots" binding:"required,min=1,dive"`
+	Progression []Progression `json:"progression" binding:"dive"`
+	Version     *int          `json:"version,omitempty" example:"3"` // the version this edit is based on
+} // @name ProgramIn
+
+// Validate checks what binding tags cannot: that slots fit in the program and do not overlap.
+func (p *ProgramIn) Validate() error {
+	taken := map[[2]int]bool{}
+	for _, s := range p.Slots {
+		if s.Week > p.Weeks {
+			return fmt.Errorf("week %d is past the %d weeks of the program", s.Week, p.Weeks)
+		}
+		if taken[[2]int{s.Week, s.Day}] {
+			return fmt.Errorf("day %d of week %d has more than one template", s.Day, s.Week)
+		}
+		taken[[2]int{s.Week, s.Day}] = true
+	}
+
+	general := 0
+	for _, r := range p.Progression {
+		if r.Exercise == "" {
+			general++
+		}
+	}
+	if general > 1 {
+		return fmt.Errorf("only one progression can be for all exercises")
+	}
+
+	return nil
+}
+
+func NewProgram(p *ProgramIn, userId string, now time.Time) Program {
+	start := now
+	if p.Start != nil {
+		start = *p.Start
+	}
+
+	return Program{
+		PK:          UserKey + userId,
+		SK:          ProgramKey + p.ID,
+		Name:        p.Name,
+		Weeks:       p.Weeks,
+		Start:       start,
+		Repeat:      p.Repeat,
+		Slots:       p.Slots,
+		Progression: p.Progression,
+	}
+}
+
+// Templates lists the IDs of the templates the program uses, each once.
+func (p *Program) Templates() []string {
+	var ids []string
+	for _, s := range p.Slots {
+		if !slices.Contains(ids, s.Template) {
+			ids = append(ids, s.Template)
+		}
+	}
+	return ids
+}
+
+// Sessions counts the workouts that went towards the program: those started from
+// one of its templates since the program started.
+func (p *Program) Sessions(workouts []Workout) int {
+	templates := p.Templates()
+
+	n := 0
+	for _, w := range workouts {
+		if !w.Start.Before(p.Start) && slices.Contains(templates, w.Template) {
+			n++
+		}
+	}
+	return n
+}
+
+// Next finds the slot that follows the given number of sessions, taking them in order,
+// and the week of the program it falls in, counting past repeats. It reports false
+// once a program that does not repeat is over.
+func (p *Program) Next(done int) (ProgramSlot, int, bool) {
+	slots := slices.SortedFunc(slices.Values(p.Slots), func(a, b ProgramSlot) int {
+		return cmp.Or(cmp.Compare(a.Week, b.Week), cmp.Compare(a.Day, b.Day))
+	})
+
+	if len(slots) == 0 || (!p.Repeat && done >= len(slots)) {
+		return ProgramSlot{}, 0, false
+	}
+
+	slot := slots[done%len(slots)]
+	return slot, done/len(slots)*p.Weeks + slot.Week, true
+}
+
+// Targets copies the template with its sets raised by the progression up to the week,
+// the first week being the template as it is.
+func (p *Program) Targets(t *Template, week int) *Template {
+	steps := week - 1
+
+	targets := *t
+	targets.Exercises = make([]TemplateExercise, len(t.Exercises))
+	for i, e := range t.Exercises {
+		e.Sets = slices.Clone(e.Sets)
+
+		if rule, ok := p.progression(e.ExerciseID); ok && steps > 0 {
+			for j := range e.Sets {
+				if e.Sets[j].Weight > 0 {
+					e.Sets[j].Weight += rule.Weight * float64(steps)
+				}
+				if e.Sets[j].Reps > 0 {
+					e.Sets[j].Reps += rule.Reps * steps
+				}
+			}
+		}
+
+		targets.Exercises[i] = e
+	}
+
+	return &targets
+}
+
+// RenameExercise points the progression rules from one exercise name to another
+// and reports whether any had it.
+func (p *Program) RenameExercise(from, to string) bool {
+	renamed := false
+	p.Progression = slices.Clone(p.Progression)
+	for i := range p.Progression {
+		if p.Progression[i].Exercise == from {
+			p.Progression[i].Exercise = to
+			renamed = true
+		}
+	}
+	return renamed
+}
+
+// RemoveExercise drops the progression rule for an exercise and reports whether there was one.
+// The rule for all exercises stays.
+func (p *Program) RemoveExercise(name string) bool {
+	if name == "" {
+		return false
+	}
+	kept := slices.DeleteFunc(slices.Clone(p.Progression), func(r Progression) bool { return r.Exercise == name })
+	removed := len(kept) < len(p.Progression)
+	p.Progression = kept
+	return removed
+}
+
+func (p *Program) progression(exercise string) (Progression, bool) {
+	var general *Progression
+	for i, r := range p.Progression {
+		if r.Exercise == exercise {
+			return r, true
+		}
+		if r.Exercise == "" {
+			general = &p.Progression[i]
+		}
+	}
+
+	if general != nil {
+		return *general, true
+	}
+	return Progression{}, false
+}
+
+type ProgramOut struct {
+	ID          string        `json:"id" example:"2025-07-18T05:40:48.329406Z"`
+	Name        string        `json:"name" example:"5/3/1"`
+	Weeks       int           `json:"weeks" example:"4"`
+	Start       time.Time     `json:"start" example:"2025-07-21T00:00:00Z"`
+	Repeat      bool          `json:"repeat" example:"true"`
+	Slots       []ProgramSlot `json:"slots"`
+	Progression []Progression `json:"progression"`
+	Version     int           `json:"version" example:"3"`
+} // @name Program
+
+func NewProgramOut(p *Program) ProgramOut {
+	out := ProgramOut{
+		ID:          p.ID(),
+		Name:        p.Name,
+		Weeks:       p.Weeks,
+		Start:       p.Start,
+		Repeat:      p.Repeat,
+		Slots:       p.Slots,
+		Progression: p.Progression,
+		Version:     p.Version,
+	}
+
+	if out.Slots == nil {
+		out.Slots = []ProgramSlot{}
+	}
+	if out.Progression == nil {
+		out.Progression = []Progression{}
+	}
+
+	return out
+}
+
+func NewProgramArray(programs []Program) []ProgramOut {
+	out := make([]ProgramOut, len(programs))
+	for i, p := range programs {
+		out[i] = NewProgramOut(&p)
+	}
+	return out
+}
+
+type ProgramResponse struct {
+	Programs []ProgramOut `json:"programs"`
+} // @name ProgramResponse
+
+// SessionOut is what comes next in a program.
+type SessionOut struct {
+	Program  string     `json:"programId" example:"2025-07-18T05:40:48.329406Z"`
+	Done     int        `json:"done" example:"5"` // sessions done so far
+	Week     int        `json:"week" example:"2"` // of the program, counting past repeats
+	Day      int        `json:"day" example:"2"`  // within the week
+	Finished bool       `json:"finished" example:"false"`
+	Workout  *WorkoutIn `json:"workout,omitempty"` // the template of the day, with the week's targets, unsaved
+} // @name ProgramSession
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ppl() *Program {
+	return &Program{
+		PK:    UserKey + "u1",
+		SK:    ProgramKey + "p1",
+		Weeks: 2,
+		Start: time.Date(2025, 7, 21, 0, 0, 0, 0, time.UTC),
+		Slots: []ProgramSlot{
+			{Week: 2, Day: 1, Template: "push"},
+			{Week: 1, Day: 2, Template: "pull"},
+			{Week: 1, Day: 1, Template: "push"},
+		},
+		Progression: []Progression{
+			{Weight: 2.5},
+			{Exercise: "Pull Up", Reps: 1},
+		},
+	}
+}
+
+func TestProgramIn_Validate(t *testing.T) {
+	valid := ProgramIn{Weeks: 2, Slots: []ProgramSlot{{Week: 1, Day: 1}, {Week: 2, Day: 1}}}
+	assert.NoError(t, valid.Validate())
+
+	pastTheEnd := ProgramIn{Weeks: 1, Slots: []ProgramSlot{{Week: 2, Day: 1}}}
+	assert.ErrorContains(t, pastTheEnd.Validate(), "week 2")
+
+	overlapping := ProgramIn{Weeks: 1, Slots: []ProgramSlot{{Week: 1, Day: 1}, {Week: 1, Day: 1}}}
+	assert.ErrorContains(t, overlapping.Validate(), "more than one template")
+
+	twoGeneralRules := ProgramIn{Weeks: 1, Progression: []Progression{{Weight: 1}, {Reps: 1}}}
+	assert.Error(t, twoGeneralRules.Validate())
+}
+
+func TestProgram_SessionsCountTemplatesSinceStart(t *testing.T) {
+	program := ppl()
+	workouts := []Workout{
+		{Start: program.Start.Add(-time.Hour), Template: "push"}, // before the program
+		{Start: program.Start.Add(time.Hour), Template: "push"},
+		{Start: program.Start.Add(48 * time.Hour), Template: "legs"}, // not in the program
+		{Start: program.Start.Add(72 * time.Hour), Template: "pull"},
+		{Start: program.Start.Add(96 * time.Hour)},
+	}
+
+	assert.Equal(t, 2, program.Sessions(workouts))
+	assert.Equal(t, []string{"push", "pull"}, program.Templates())
+}
+
+func TestProgram_NextGoesThroughSlotsInOrder(t *testing.T) {
+	program := ppl()
+
+	slot, week, ok := program.Next(0)
+	assert.True(t, ok)
+	assert.Equal(t, ProgramSlot{Week: 1, Day: 1, Template: "push"}, slot)
+	assert.Equal(t, 1, week)
+
+	slot, week, _ = program.Next(2)
+	assert.Equal(t, "push", slot.Template)
+	assert.Equal(t, 2, week)
+
+	_, _, ok = program.Next(3)
+	assert.False(t, ok, "a program that does not repeat is over")
+
+	program.Repeat = true
+	slot, week, ok = program.Next(4)
+	assert.True(t, ok)
+	assert.Equal(t, "pull", slot.Template)
+	assert.Equal(t, 3, week) // week one of the second round
+}
+
+func TestProgram_TargetsRaiseByWeek(t *testing.T) {
+	template := &Template{Exercises: []TemplateExercise{
+		{ExerciseID: "Bench Press", Sets: []Set{{Weight: 60, Reps: 5}}},
+		{ExerciseID: "Pull Up", Sets: []Set{{Reps: 6}}},
+	}}
+
+	first := ppl().Targets(template, 1)
+	assert.Equal(t, template.Exercises, first.Exercises)
+
+	third := ppl().Targets(template, 3)
+	assert.Equal(t, Set{Weight: 65, Reps: 5}, third.Exercises[0].Sets[0]) // two weeks of the general rule
+	assert.Equal(t, Set{Reps: 8}, third.Exercises[1].Sets[0])             // its own rule instead
+
+	assert.Equal(t, 60.0, template.Exercises[0].Sets[0].Weight, "the template itself is left alone")
+}
+
+func TestNewSyncResponse_Programs(t *testing.T) {
+	program := *ppl()
+	program.UpdatedAt = "2025-07-22T00:00:00.000000Z"
+
+	out := NewSyncResponse(&Changes{Programs: []Program{program}}, "")
+
+	require.Len(t, out.Programs, 1)
+	assert.Equal(t, "p1", out.Programs[0].ID)
+}
+
+func TestProgram_RenameAndRemoveExercise(t *testing.T) {
+	program := Program{Progression: []Progression{{Weight: 2.5}, {Exercise: "Squat", Weight: 5}}}
+
+	assert.True(t, program.RenameExercise("Squat", "Back Squat"))
+	assert.Equal(t, "Back Squat", program.Progression[1].Exercise)
+
+	assert.False(t, program.RemoveExercise(""), "the rule for all exercises stays")
+	assert.True(t, program.RemoveExercise("Back Squat"))
+	assert.Equal(t, []Progression{{Weight: 2.5}}, program.Progression)
+}
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"cmp"
+	"maps"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+type RecordKind string
+
+const (
+	RecordWeight       RecordKind = "weight"
+	RecordOneRepMax    RecordKind = "oneRepMax"
+	RecordRepsAtWeight RecordKind = "repsAtWeight"
+	RecordDuration     RecordKind = "duration"
+	RecordDistance     RecordKind = "distance"
+)
+
+// maxEstimatedReps is where 1RM formulas stop being useful:
+// a set of 20 tells little about a single.
+const maxEstimatedReps = 12
+
+// EstimateOneRepMax estimates the one-rep max of a set, with Brzycki up to 10 reps,
+// where it is the more accurate, and Epley above. Zero if there is nothing to estimate.
+func EstimateOneRepMax(weight float64, reps int) float64 {
+	var estimate float64
+	switch {
+	case weight <= 0 || reps <= 0 || reps > maxEstimatedReps:
+		return 0
+	case reps == 1:
+		estimate = weight
+	case reps <= 10:
+		estimate = weight * 36 / float64(37-reps)
+	default:
+		estimate = weight * (1 + float64(reps)/30)
+	}
+	return math.Round(estimate*10) / 10
+}
+
+// Record points at the set that holds a personal best.
+type Record struct {
+	Value     float64   `dynamodbav:"value"`
+	WorkoutID string    `dynamodbav:"workout_id"`
+	SetID     string    `dynamodbav:"set_id"`
+	Date      time.Time `dynamodbav:"date"`
+}
+
+// PersonalRecords is a DynamoDB item with the bests of one exercise.
+// PK: USER#<userId>
+// SK: PR#<exercise>
+type PersonalRecords struct {
+	PK           string            `dynamodbav:"PK"`
+	SK           string            `dynamodbav:"SK"`
+	Exercise     string            `dynamodbav:"exercise"`
+	Weight       *Record           `dynamodbav:"weight,omitempty"`
+	OneRepMax    *Record           `dynamodbav:"one_rep_max,omitempty"`
+	RepsAtWeight map[string]Record `dynamodbav:"reps_at_weight,omitempty"` // keyed by weight
+	Duration     *Record           `dynamodbav:"duration,omitempty"`
+	Distance     *Record           `dynamodbav:"distance,omitempty"`
+}
+
+func RecordsSK(exercise string) string {
+	return RecordKey + url.PathEscape(exercise)
+}
+
+func NewPersonalRecords(userId, exercise string) PersonalRecords {
+	return PersonalRecords{
+		PK:       UserKey + userId,
+		SK:       RecordsSK(exercise),
+		Exercise: exercise,
+	}
+}
+
+// Empty reports whether no records have been set yet.
+func (p *PersonalRecords) Empty() bool {
+	return p.Weight == nil && p.OneRepMax == nil && len(p.RepsAtWeight) == 0 && p.Duration == nil && p.Distance == nil
+}
+
+// Apply folds the completed sets the workout has of this exercise, warm-ups aside, into the records.
+// It returns, by set ID, the records the workout now holds that it did not before;
+// a set beaten later in the same workout is not flagged. Apply only ever raises records;
+// when a workout that holds one is edited or deleted, Rebuild brings them back down.
+func (p *PersonalRecords) Apply(w *Workout) map[string][]RecordKind {
+	before := p.Clone()
+
+	workoutId := w.ID()
+	for _, exercise := range w.Exercises {
+		if exercise.ExerciseID != p.Exercise {
+			continue
+		}
+
+		for _, set := range exercise.Sets {
+			if !set.Completed || set.Warmup() {
+				continue
+			}
+
+			holder := func(value float64) Record {
+				return Record{Value: value, WorkoutID: workoutId, SetID: set.ID, Date: w.Start}
+			}
+
+			if set.Weight > 0 {
+				raise(&p.Weight, holder(set.Weight))
+				raise(&p.OneRepMax, holder(EstimateOneRepMax(set.Weight, set.Reps)))
+			}
+
+			if set.Reps > 0 {
+				if p.RepsAtWeight == nil {
+					p.RepsAtWeight = map[string]Record{}
+				}
+				key := weightKey(set.Weight)
+				if current, ok := p.RepsAtWeight[key]; !ok || float64(set.Reps) > current.Value {
+					p.RepsAtWeight[key] = holder(float64(set.Reps))
+				}
+			}
+
+			raise(&p.Duration, holder(set.Duration))
+			raise(&p.Distance, holder(set.Distance))
+		}
+	}
+
+	return p.Broken(&before, workoutId)
+}
+
+// Rebuild recomputes the records from the whole history of the exercise, oldest first,
+// for when a workout that held one has been edited or deleted.
+func (p *PersonalRecords) Rebuild(history []HistoryEntry) {
+	*p = PersonalRecords{PK: p.PK, SK: p.SK, Exercise: p.Exercise}
+
+	history = slices.Clone(history)
+	slices.SortStableFunc(history, func(a, b HistoryEntry) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	for _, entry := range history {
+		w := entry.Workout()
+		p.Apply(&w)
+	}
+}
+
+// HeldBy reports whether any of the records points at a set of the workout.
+func (p *PersonalRecords) HeldBy(workoutId string) bool {
+	for _, r := range []*Record{p.Weight, p.OneRepMax, p.Duration, p.Distance} {
+		if r != nil && r.WorkoutID == workoutId {
+			return true
+		}
+	}
+	for _, r := range p.RepsAtWeight {
+		if r.WorkoutID == workoutId {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone copies the records, so that a later Apply or Rebuild leaves the copy alone.
+func (p *PersonalRecords) Clone() PersonalRecords {
+	clone := *p
+	clone.RepsAtWeight = maps.Clone(p.RepsAtWeight)
+	return clone
+}
+
+// Broken returns, by set ID, the records the workout holds that it did not in before.
+func (p *PersonalRecords) Broken(before *PersonalRecords, workoutId string) map[string][]RecordKind {
+	broken := map[string][]RecordKind{}
+	flag := func(kind RecordKind, old *Record, now *Record) {
+		if now == nil || now.WorkoutID != workoutId {
+			return
+		}
+		if (old == nil || *old != *now) && !slices.Contains(broken[now.SetID], kind) {
+			broken[now.SetID] = append(broken[now.SetID], kind)
+		}
+	}
+
+	flag(RecordWeight, before.Weight, p.Weight)
+	flag(RecordOneRepMax, before.OneRepMax, p.OneRepMax)
+	for key, now := range p.RepsAtWeight {
+		if old, ok := before.RepsAtWeight[key]; ok {
+			flag(RecordRepsAtWeight, &old, &now)
+		} else {
+			flag(RecordRepsAtWeight, nil, &now)
+		}
+	}
+	flag(RecordDuration, before.Duration, p.Duration)
+	flag(RecordDistance, before.Distance, p.Distance)
+
+	return broken
+}
+
+// raise replaces the record if the candidate beats it outright; ties stay with the older set.
+func raise(record **Record, candidate Record) {
+	if candidate.Value <= 0 {
+		return
+	}
+	if *record == nil || candidate.Value > (*record).Value {
+		*record = &candidate
+	}
+}
+
+func weightKey(weight float64) string {
+	return strconv.FormatFloat(weight, 'f', -1, 64)
+}
+
+type RecordOut struct {
+	Value     float64   `json:"value" example:"120"`
+	Weight    *float64  `json:"weight,omitempty" example:"100"` // only for reps at a weight
+	WorkoutID string    `json:"workoutId" example:"2025-07-18T05:40:48.329406Z"`
+	SetID     string    `json:"setId" example:"2025-07-18T05:40:48.329406Z"`
+	Date      time.Time `json:"date" example:"2025-07-18T05:40:48Z"`
+} // @name Record
+
+type PersonalRecordsOut struct {
+	Exercise     string      `json:"exercise" example:"Bench Press"`
+	Weight       *RecordOut  `json:"weight"`    // kg
+	OneRepMax    *RecordOut  `json:"oneRepMax"` // kg, estimated
+	RepsAtWeight []RecordOut `json:"repsAtWeight"`
+	Duration     *RecordOut  `json:"duration"` // seconds
+	Distance     *RecordOut  `json:"distance"` // kilometers
+} // @name PersonalRecords
+
+func newRecordOut(r *Record) *RecordOut {
+	if r == nil {
+		return nil
+	}
+	return &RecordOut{Value: r.Value, WorkoutID: r.WorkoutID, SetID: r.SetID, Date: r.Date}
+}
+
+func NewPersonalRecordsOut(p *PersonalRecords) PersonalRecordsOut {
+	out := PersonalRecordsOut{
+		Exercise:     p.Exercise,
+		Weight:       newRecordOut(p.Weight),
+		OneRepMax:    newRecordOut(p.OneRepMax),
+		RepsAtWeight: make([]RecordOut, 0, len(p.RepsAtWeight)),
+		Duration:     newRecordOut(p.Duration),
+		Distance:     newRecordOut(p.Distance),
+	}
+
+	for key, r := range p.RepsAtWeight {
+		weight, err := strconv.ParseFloat(key, 64)
+		if err != nil {
+			continue
+		}
+		record := newRecordOut(&r)
+		record.Weight = &weight
+		out.RepsAtWeight = append(out.RepsAtWeight, *record)
+	}
+
+	slices.SortFunc(out.RepsAtWeight, func(a, b RecordOut) int {
+		return cmp.Compare(*a.Weight, *b.Weight)
+	})
+
+	return out
+}
+
+// FlagRecords marks the sets that have just set personal records.
+func (w *WorkoutOut) FlagRecords(broken map[string][]RecordKind) {
+	for i := range w.Exercises {
+		for j := range w.Exercises[i].Sets {
+			if kinds, ok := broken[w.Exercises[i].Sets[j].ID]; ok {
+				w.Exercises[i].Sets[j].Records = kinds
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateOneRepMax(t *testing.T) {
+	assert.Equal(t, 100.0, EstimateOneRepMax(100, 1))
+	assert.Equal(t, 112.5, EstimateOneRepMax(100, 5))  // Brzycki
+	assert.Equal(t, 136.7, EstimateOneRepMax(100, 11)) // Epley
+	assert.Zero(t, EstimateOneRepMax(100, 13))
+	assert.Zero(t, EstimateOneRepMax(0, 5))
+}
+
+func benchWorkout(id string, sets ...Set) *Workout {
+	return &Workout{
+		SK:    WorkoutKey + id,
+		Start: time.Date(2025, 7, 18, 5, 0, 0, 0, time.UTC),
+		Exercises: []WorkoutExercise{
+			{ExerciseID: "Bench Press", Sets: sets},
+			{ExerciseID: "Plank", Sets: []Set{{ID: "plank", Completed: true, Duration: 600}}},
+		},
+	}
+}
+
+func TestPersonalRecords_ApplyFirstWorkout(t *testing.T) {
+	records := NewPersonalRecords("u1", "Bench Press")
+	assert.Equal(t, "PR#Bench%20Press", records.SK)
+
+	broken := records.Apply(benchWorkout("w1",
+		Set{ID: "s1", Completed: true, Weight: 80, Reps: 10},
+		Set{ID: "s2", Completed: true, Weight: 100, Reps: 3},
+		Set{ID: "s3", Completed: false, Weight: 120, Reps: 1}, // skipped, not done
+	))
+
+	require.NotNil(t, records.Weight)
+	assert.Equal(t, 100.0, records.Weight.Value)
+	assert.Equal(t, "w1", records.Weight.WorkoutID)
+	assert.Equal(t, 106.7, records.OneRepMax.Value)
+	assert.Equal(t, "s1", records.OneRepMax.SetID)
+	assert.Equal(t, 10.0, records.RepsAtWeight["80"].Value)
+	assert.Nil(t, records.Duration) // the plank is another exercise
+
+	assert.ElementsMatch(t, []RecordKind{RecordOneRepMax, RecordRepsAtWeight}, broken["s1"])
+	assert.ElementsMatch(t, []RecordKind{RecordWeight, RecordRepsAtWeight}, broken["s2"])
+	assert.NotContains(t, broken, "s3")
+	assert.NotContains(t, broken, "plank")
+}
+
+func TestPersonalRecords_ApplySkipsWarmups(t *testing.T) {
+	records := NewPersonalRecords("u1", "Bench Press")
+
+	broken := records.Apply(benchWorkout("w1",
+		Set{ID: "s1", Completed: true, Weight: 60, Reps: 12, Type: WarmupSet},
+		Set{ID: "s2", Completed: true, Weight: 100, Reps: 3, Type: WorkingSet},
+	))
+
+	assert.Equal(t, "s2", records.OneRepMax.SetID)
+	assert.NotContains(t, records.RepsAtWeight, "60")
+	assert.NotContains(t, broken, "s1")
+}
+
+func TestPersonalRecords_ApplyOnlyFlagsImprovements(t *testing.T) {
+	records := NewPersonalRecords("u1", "Bench Press")
+	records.Apply(benchWorkout("w1", Set{ID: "s1", Completed: true, Weight: 100, Reps: 5}))
+
+	// saving the same workout again breaks nothing
+	assert.Empty(t, records.Apply(benchWorkout("w1", Set{ID: "s1", Completed: true, Weight: 100, Reps: 5})))
+
+	// a tie stays with the older set
+	broken := records.Apply(benchWorkout("w2",
+		Set{ID: "s2", Completed: true, Weight: 100, Reps: 5},
+		Set{ID: "s3", Completed: true, Weight: 90, Reps: 8},
+	))
+	assert.Equal(t, "s1", records.Weight.SetID)
+	assert.Equal(t, map[string][]RecordKind{"s3": {RecordRepsAtWeight}}, broken)
+}
+
+func TestPersonalRecords_ApplyBeatenWithinWorkout(t *testing.T) {
+	records := NewPersonalRecords("u1", "Bench Press")
+
+	broken := records.Apply(benchWorkout("w1",
+		Set{ID: "s1", Completed: true, Weight: 100, Reps: 1},
+		Set{ID: "s2", Completed: true, Weight: 105, Reps: 1},
+	))
+
+	assert.Equal(t, "s2", records.Weight.SetID)
+	assert.NotContains(t, broken["s1"], RecordWeight)
+	assert.Contains(t, broken["s2"], RecordWeight)
+}
+
+func TestPersonalRecords_RebuildBringsThemDown(t *testing.T) {
+	older := benchWorkout("w1", Set{ID: "s1", Completed: true, Weight: 100, Reps: 5})
+	newer := benchWorkout("w2", Set{ID: "s2", Completed: true, Weight: 120, Reps: 5})
+	newer.Start = older.Start.Add(24 * time.Hour)
+
+	records := NewPersonalRecords("u1", "Bench Press")
+	records.Apply(older)
+	records.Apply(newer)
+	require.True(t, records.HeldBy("w2"))
+
+	// the 120 was a typo, edited down to 90
+	newer.Exercises[0].Sets[0].Weight = 90
+	before := records.Clone()
+	history := append(NewHistoryEntries(newer)[:1], NewHistoryEntries(older)[:1]...)
+	records.Rebuild(history)
+
+	assert.Equal(t, 100.0, records.Weight.Value)
+	assert.Equal(t, "w1", records.Weight.WorkoutID)
+	assert.Equal(t, 5.0, records.RepsAtWeight["90"].Value)
+	assert.NotContains(t, records.RepsAtWeight, "120")
+	assert.Equal(t, "PR#Bench%20Press", records.SK)
+
+	broken := records.Broken(&before, "w2")
+	assert.Equal(t, map[string][]RecordKind{"s2": {RecordRepsAtWeight}}, broken)
+
+	// and with the workout deleted, nothing of it is left
+	records.Rebuild(NewHistoryEntries(older)[:1])
+	assert.False(t, records.HeldBy("w2"))
+	assert.Len(t, records.RepsAtWeight, 1)
+}
+
+func TestNewPersonalRecordsOut_SortsRepsByWeight(t *testing.T) {
+	records := NewPersonalRecords("u1", "Bench Press")
+	records.Apply(benchWorkout("w1",
+		Set{ID: "s1", Completed: true, Weight: 102.5, Reps: 2},
+		Set{ID: "s2", Completed: true, Weight: 60, Reps: 12},
+	))
+
+	out := NewPersonalRecordsOut(&records)
+
+	assert.Equal(t, "Bench Press", out.Exercise)
+	require.Len(t, out.RepsAtWeight, 2)
+	assert.Equal(t, 60.0, *out.RepsAtWeight[0].Weight)
+	assert.Equal(t, 102.5, *out.RepsAtWeight[1].Weight)
+	assert.Equal(t, 102.5, out.Weight.Value)
+	assert.Nil(t, out.Weight.Weight)
+	assert.Nil(t, out.Distance)
+}
+
+func TestWorkoutOut_FlagRecords(t *testing.T) {
+	out := NewWorkoutOut(benchWorkout("w1", Set{ID: "s1", Completed: true, Weight: 100, Reps: 1}), "")
+	out.FlagRecords(map[string][]RecordKind{"s1": {RecordWeight}})
+
+	assert.Equal(t, []RecordKind{RecordWeight}, out.Exercises[0].Sets[0].Records)
+	assert.Empty(t, out.Exercises[1].Sets[0].Records)
+}
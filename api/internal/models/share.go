@@ -0,0 +1,161 @@
+package models
+
+import (
+	"crypto/rand"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SharedTemplate is a snapshot of a template, published for anyone holding its code to read
+// and copy into their own templates. It is never edited: sharing again publishes a new one.
+// PK: SHARED#<code>
+// SK: SHARED#<code>
+type SharedTemplate struct {
+	PK        string             `dynamodbav:"PK"`
+	SK        string             `dynamodbav:"SK"`
+	Owner     string             `dynamodbav:"owner"` // user ID of whoever shared it
+	Template  string             `dynamodbav:"template_id"`
+	Name      string             `dynamodbav:"name"`
+	Exercises []TemplateExercise `dynamodbav:"exercises"`
+	Custom    []UserExerciseIn   `dynamodbav:"custom_exercises,omitempty"` // own exercises of the owner it uses
+	CreatedAt time.Time          `dynamodbav:"created_at"`
+	Imports   int                `dynamodbav:"imports"` // times it was copied
+}
+
+func (s *SharedTemplate) Code() string {
+	return strings.TrimPrefix(s.PK, SharedKey)
+}
+
+// Share points the owner to a template they shared, so that they can list and revoke it.
+// PK: USER#<userId>
+// SK: SHARE#<code>
+type Share struct {
+	PK       string `dynamodbav:"PK"`
+	SK       string `dynamodbav:"SK"`
+	Template string `dynamodbav:"template_id"`
+}
+
+// shareCodeAlphabet is Crockford's base32, which leaves out letters easily mistaken for digits.
+// Its 32 characters divide a byte evenly, so every one is as likely.
+const shareCodeAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+const shareCodeLength = 10
+
+// NewShareCode makes a random code to publish a template under.
+func NewShareCode() string {
+	b := make([]byte, shareCodeLength)
+	_, _ = rand.Read(b) // never fails, as of Go 1.24
+	for i := range b {
+		b[i] = shareCodeAlphabet[b[i]%32]
+	}
+	return string(b)
+}
+
+// NewSharedTemplate takes the snapshot of the template to publish under the code,
+// along with the owner's pointer to it. custom holds the owner's own exercises;
+// those the template does not use are left out.
+func NewSharedTemplate(t *Template, custom []UserExerciseIn, code string, now time.Time) (SharedTemplate, Share) {
+	shared := SharedTemplate{
+		PK:        SharedKey + code,
+		SK:        SharedKey + code,
+		Owner:     t.UserID(),
+		Template:  t.ID(),
+		Name:      t.Name,
+		Exercises: slices.Clone(t.Exercises),
+		CreatedAt: now,
+	}
+
+	for _, e := range custom {
+		if slices.ContainsFunc(t.Exercises, func(te TemplateExercise) bool { return te.ExerciseID == e.Name }) {
+			shared.Custom = append(shared.Custom, e)
+		}
+	}
+
+	share := Share{
+		PK:       t.PK,
+		SK:       ShareKey + code,
+		Template: t.ID(),
+	}
+
+	return shared, share
+}
+
+// Copy makes a template of the user's out of the snapshot, under a new ID.
+func (s *SharedTemplate) Copy(userId string, id string, order int) Template {
+	return Template{
+		PK:            UserKey + userId,
+		SK:            TemplateKey + id,
+		Name:          s.Name,
+		OrderInParent: order,
+		Exercises:     slices.Clone(s.Exercises),
+	}
+}
+
+// SharedTemplateOut is a shared template as anyone holding its code sees it.
+type SharedTemplateOut struct {
+	Code      string             `json:"code" example:"k3m9q2xw7p"`
+	Name      string             `json:"name" example:"Upper body"`
+	Exercises []TemplateExercise `json:"exercises"`
+	Custom    []UserExerciseIn   `json:"customExercises"` // made for whoever imports it, if they lack them
+	CreatedAt time.Time          `json:"createdAt" example:"2025-07-18T05:40:48.329406Z"`
+	Imports   int                `json:"imports" example:"12"`
+} // @name SharedTemplate
+
+func NewSharedTemplateOut(s *SharedTemplate) SharedTemplateOut {
+	out := SharedTemplateOut{
+		Code:      s.Code(),
+		Name:      s.Name,
+		Exercises: s.Exercises,
+		Custom:    s.Custom,
+		CreatedAt: s.CreatedAt,
+		Imports:   s.Imports,
+	}
+
+	if out.Exercises == nil {
+		out.Exercises = []TemplateExercise{}
+	}
+	if out.Custom == nil {
+		out.Custom = []UserExerciseIn{}
+	}
+
+	return out
+}
+
+// ShareOut is a shared template as its owner sees it.
+type ShareOut struct {
+	Code       string    `json:"code" example:"k3m9q2xw7p"`
+	TemplateID string    `json:"templateId" example:"2025-07-18T05:40:48.329406Z"` // the template it was taken from
+	Name       string    `json:"name" example:"Upper body"`
+	CreatedAt  time.Time `json:"createdAt" example:"2025-07-18T05:40:48.329406Z"`
+	Imports    int       `json:"imports" example:"12"`
+} // @name Share
+
+func NewShareOut(s *SharedTemplate) ShareOut {
+	return ShareOut{
+		Code:       s.Code(),
+		TemplateID: s.Template,
+		Name:       s.Name,
+		CreatedAt:  s.CreatedAt,
+		Imports:    s.Imports,
+	}
+}
+
+func NewShareArray(shared []SharedTemplate) []ShareOut {
+	out := make([]ShareOut, len(shared))
+	for i, s := range shared {
+		out[i] = NewShareOut(&s)
+	}
+	return out
+}
+
+type ShareResponse struct {
+	Shares []ShareOut `json:"shares"`
+} // @name ShareResponse
+
+// SharedImportOut is the template a shared one was copied into, with the exercises
+// that had to be made for it.
+type SharedImportOut struct {
+	Template  TemplateOut `json:"template"`
+	Exercises []string    `json:"exercises"` // names of the exercises made
+} // @name SharedImport
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewShareCode(t *testing.T) {
+	code := NewShareCode()
+
+	assert.Len(t, code, shareCodeLength)
+	for _, r := range code {
+		assert.Contains(t, shareCodeAlphabet, string(r))
+	}
+	assert.NotEqual(t, code, NewShareCode())
+}
+
+func TestNewSharedTemplate_KeepsCustomExercisesInUse(t *testing.T) {
+	template := &Template{
+		PK:   "USER#coach",
+		SK:   "TEMPLATE#t1",
+		Name: "Upper body",
+		Exercises: []TemplateExercise{
+			{ID: "1", ExerciseID: "Bench Press", Sets: []Set{{Reps: 5, Weight: 80}}},
+			{ID: "2", ExerciseID: "Band Pull Apart"},
+		},
+	}
+	custom := []UserExerciseIn{
+		{Name: "Band Pull Apart", Category: "Bands", Target: "Back"},
+		{Name: "Sled Push", Category: "Other", Target: "Legs"},
+	}
+	now := time.Date(2025, 7, 18, 5, 40, 0, 0, time.UTC)
+
+	shared, share := NewSharedTemplate(template, custom, "abc123", now)
+
+	assert.Equal(t, "SHARED#abc123", shared.PK)
+	assert.Equal(t, "abc123", shared.Code())
+	assert.Equal(t, "coach", shared.Owner)
+	assert.Equal(t, "t1", shared.Template)
+	assert.Equal(t, template.Exercises, shared.Exercises)
+	assert.Equal(t, custom[:1], shared.Custom)
+	assert.Equal(t, now, shared.CreatedAt)
+
+	assert.Equal(t, "USER#coach", share.PK)
+	assert.Equal(t, "SHARE#abc123", share.SK)
+	assert.Equal(t, "t1", share.Template)
+}
+
+func TestSharedTemplate_Copy(t *testing.T) {
+	shared := SharedTemplate{
+		PK:        "SHARED#abc123",
+		Name:      "Upper body",
+		Exercises: []TemplateExercise{{ID: "1", ExerciseID: "Bench Press"}},
+		Imports:   3,
+	}
+
+	copied := shared.Copy("client", "t9", 4)
+	copied.Exercises[0].ExerciseID = "bench press"
+
+	assert.Equal(t, "USER#client", copied.PK)
+	assert.Equal(t, "t9", copied.ID())
+	assert.Equal(t, "Upper body", copied.Name)
+	assert.Equal(t, 4, copied.OrderInParent)
+	assert.Equal(t, "Bench Press", shared.Exercises[0].ExerciseID, "the snapshot is not touched")
+}
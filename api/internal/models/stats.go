@@ -0,0 +1,244 @@
+package models
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// OtherGroup holds exercises missing from the catalog, such as ones deleted since.
+const OtherGroup = "Other"
+
+const weekLayout = "2006-01-02"
+
+type Tally struct {
+	Sets     int     `json:"sets" example:"42"`
+	Tonnage  float64 `json:"tonnage" example:"12500"` // kg, weight × reps
+	Duration float64 `json:"duration" example:"1800"` // seconds
+	Distance float64 `json:"distance" example:"5"`    // kilometers
+	Rest     int     `json:"rest" example:"2700"`     // seconds, after each set, or once per round in a group
+} // @name Tally
+
+func (t *Tally) add(s *Set) {
+	t.Sets++
+	t.Tonnage += s.Weight * float64(s.Reps)
+	t.Duration += s.Duration
+	t.Distance += s.Distance
+	t.Rest += s.Rest
+}
+
+type GroupStats struct {
+	Name string `json:"name" example:"Chest"`
+	Tally
+} // @name GroupStats
+
+// GroupingStats tallies the exercises done one way, straight or in groups of a type,
+// counting each group of a workout as one.
+type GroupingStats struct {
+	Name   string `json:"name" example:"superset"`
+	Groups int    `json:"groups" example:"6"`  // none for straight sets
+	Rounds int    `json:"rounds" example:"24"` // a set of each exercise of a group, in turn
+	Tally
+} // @name GroupingStats
+
+type WeekStats struct {
+	Week     string `json:"week" example:"2025-07-14"` // the Monday the week starts on
+	Workouts int    `json:"workouts" example:"3"`
+	Tally
+} // @name WeekStats
+
+type StatsTotals struct {
+	Workouts int `json:"workouts" example:"36"`
+	Tally
+} // @name StatsTotals
+
+type StatsResponse struct {
+	From       time.Time       `json:"from" example:"2025-04-21T00:00:00Z"`
+	To         time.Time       `json:"to" example:"2025-07-18T23:59:59Z"`
+	Totals     StatsTotals     `json:"totals"`
+	ByTarget   []GroupStats    `json:"byTarget"`
+	ByCategory []GroupStats    `json:"byCategory"`
+	ByGrouping []GroupingStats `json:"byGrouping"` // by group type, Straight for exercises outside any
+	Weeks      []WeekStats     `json:"weeks"`
+} // @name Stats
+
+// NewStats aggregates the completed sets of the workouts, warm-ups aside, grouped by the target and category
+// the catalog has for each exercise, by how the exercise was grouped (supersets, circuits...),
+// and by the week, in loc, each workout started in. The rest of a round in a group counts once,
+// as the longest logged in it. Weeks without workouts are kept so that charts have no gaps.
+func NewStats(workouts []Workout, catalog map[string]Exercise, from, to time.Time, loc *time.Location) StatsResponse {
+	stats := StatsResponse{
+		From:       from,
+		To:         to,
+		ByTarget:   []GroupStats{},
+		ByCategory: []GroupStats{},
+		ByGrouping: []GroupingStats{},
+		Weeks:      []WeekStats{},
+	}
+
+	byTarget := map[string]*Tally{}
+	byCategory := map[string]*Tally{}
+	byGrouping := map[string]*GroupingStats{}
+	byWeek := map[string]*WeekStats{}
+
+	for week := startOfWeek(from.In(loc)); !week.After(to); week = week.AddDate(0, 0, 7) {
+		key := week.Format(weekLayout)
+		byWeek[key] = &WeekStats{Week: key}
+	}
+
+	for _, w := range workouts {
+		week, ok := byWeek[startOfWeek(w.Start.In(loc)).Format(weekLayout)]
+		if !ok {
+			continue // the ID was in range but the start was not
+		}
+
+		week.Workouts++
+		stats.Totals.Workouts++
+
+		// in the order done, for the rounds of a group to end on the right exercise
+		exercises := slices.Clone(w.Exercises)
+		slices.SortStableFunc(exercises, func(a, b WorkoutExercise) int {
+			return a.ExerciseOrder - b.ExerciseOrder
+		})
+
+		rounds := newRounds(exercises)
+		for _, group := range rounds {
+			if len(group.rest) == 0 {
+				continue // nothing of it done
+			}
+			g := grouping(byGrouping, string(group.kind))
+			g.Groups++
+			g.Rounds += len(group.rest)
+		}
+
+		for i, e := range exercises {
+			target, category := OtherGroup, OtherGroup
+			if exercise, ok := catalog[e.ExerciseID]; ok {
+				target = cmp.Or(exercise.Target, OtherGroup)
+				category = cmp.Or(exercise.Category, OtherGroup)
+			}
+			group, grouped := rounds[e.Group]
+			kind := Straight
+			if grouped {
+				kind = string(group.kind)
+			}
+
+			round := 0
+			for _, s := range e.Sets {
+				if !s.Completed || s.Warmup() {
+					continue
+				}
+				if grouped {
+					s.Rest = group.restAfter(i, round)
+				}
+				round++
+
+				stats.Totals.add(&s)
+				week.add(&s)
+				tally(byTarget, target).add(&s)
+				tally(byCategory, category).add(&s)
+				grouping(byGrouping, kind).add(&s)
+			}
+		}
+	}
+
+	stats.ByTarget = groups(byTarget)
+	stats.ByCategory = groups(byCategory)
+	for _, g := range byGrouping {
+		stats.ByGrouping = append(stats.ByGrouping, *g)
+	}
+	slices.SortFunc(stats.ByGrouping, func(a, b GroupingStats) int {
+		return cmp.Or(cmp.Compare(b.Tonnage, a.Tonnage), cmp.Compare(b.Sets, a.Sets), cmp.Compare(a.Name, b.Name))
+	})
+
+	for _, week := range byWeek {
+		stats.Weeks = append(stats.Weeks, *week)
+	}
+	slices.SortFunc(stats.Weeks, func(a, b WeekStats) int { return cmp.Compare(a.Week, b.Week) })
+
+	return stats
+}
+
+func tally(m map[string]*Tally, name string) *Tally {
+	t, ok := m[name]
+	if !ok {
+		t = &Tally{}
+		m[name] = t
+	}
+	return t
+}
+
+func grouping(m map[string]*GroupingStats, name string) *GroupingStats {
+	g, ok := m[name]
+	if !ok {
+		g = &GroupingStats{Name: name}
+		m[name] = g
+	}
+	return g
+}
+
+// groupRounds is how the sets of a group in a workout fall into rounds: the n-th set
+// of each exercise makes round n.
+type groupRounds struct {
+	kind GroupType
+	rest []int // the longest rest logged in each round
+	last []int // the index of the exercise that ends each round
+}
+
+// newRounds splits the completed working sets of each group in the exercises, in the order
+// done, into rounds, by group.
+func newRounds(exercises []WorkoutExercise) map[string]*groupRounds {
+	out := map[string]*groupRounds{}
+	for i, e := range exercises {
+		if e.Group == "" || e.GroupType == "" {
+			continue
+		}
+		group, ok := out[e.Group]
+		if !ok {
+			group = &groupRounds{kind: e.GroupType}
+			out[e.Group] = group
+		}
+
+		round := 0
+		for _, s := range e.Sets {
+			if !s.Completed || s.Warmup() {
+				continue
+			}
+			if round == len(group.rest) {
+				group.rest = append(group.rest, 0)
+				group.last = append(group.last, 0)
+			}
+			group.rest[round] = max(group.rest[round], s.Rest)
+			group.last[round] = i
+			round++
+		}
+	}
+	return out
+}
+
+// restAfter is the rest counted for the set of the round done in the exercise at index i:
+// that of the round if the set ends it, none otherwise.
+func (g *groupRounds) restAfter(i int, round int) int {
+	if g.last[round] != i {
+		return 0
+	}
+	return g.rest[round]
+}
+
+// groups lists the tallies heaviest first.
+func groups(m map[string]*Tally) []GroupStats {
+	out := make([]GroupStats, 0, len(m))
+	for name, t := range m {
+		out = append(out, GroupStats{Name: name, Tally: *t})
+	}
+	slices.SortFunc(out, func(a, b GroupStats) int {
+		return cmp.Or(cmp.Compare(b.Tonnage, a.Tonnage), cmp.Compare(b.Sets, a.Sets), cmp.Compare(a.Name, b.Name))
+	})
+	return out
+}
+
+// startOfWeek returns midnight of the Monday on or before t, in t's location.
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStats_GroupsByCatalogAndWeek(t *testing.T) {
+	catalog := map[string]Exercise{
+		"Bench Press": {Name: "Bench Press", Target: "Chest", Category: "Barbell"},
+		"Running":     {Name: "Running", Target: "Cardio", Category: "Cardio"},
+	}
+
+	workouts := []Workout{
+		{
+			Start: time.Date(2025, 7, 14, 18, 0, 0, 0, time.UTC), // Monday
+			Exercises: []WorkoutExercise{
+				{ExerciseID: "Bench Press", Sets: []Set{
+					{Completed: true, Weight: 100, Reps: 5},
+					{Completed: true, Weight: 100, Reps: 5},
+					{Completed: false, Weight: 100, Reps: 5},
+					{Completed: true, Weight: 60, Reps: 10, Type: WarmupSet}, // does not count
+				}},
+				{ExerciseID: "Gone", Sets: []Set{{Completed: true, Reps: 20}}},
+			},
+		},
+		{
+			Start: time.Date(2025, 7, 27, 7, 0, 0, 0, time.UTC), // Sunday
+			Exercises: []WorkoutExercise{
+				{ExerciseID: "Running", Sets: []Set{{Completed: true, Duration: 1800, Distance: 5}}},
+			},
+		},
+	}
+
+	from := time.Date(2025, 7, 9, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 28, 12, 0, 0, 0, time.UTC)
+	stats := NewStats(workouts, catalog, from, to, time.UTC)
+
+	assert.Equal(t, 2, stats.Totals.Workouts)
+	assert.Equal(t, 4, stats.Totals.Sets)
+	assert.Equal(t, 1000.0, stats.Totals.Tonnage)
+	assert.Equal(t, 1800.0, stats.Totals.Duration)
+	assert.Equal(t, 5.0, stats.Totals.Distance)
+
+	require.Len(t, stats.ByTarget, 3)
+	assert.Equal(t, GroupStats{Name: "Chest", Tally: Tally{Sets: 2, Tonnage: 1000}}, stats.ByTarget[0])
+	assert.Equal(t, "Cardio", stats.ByTarget[1].Name) // ties go by name
+	assert.Equal(t, "Other", stats.ByTarget[2].Name)
+	assert.Len(t, stats.ByCategory, 3)
+
+	// every week of the range, empty ones included
+	require.Len(t, stats.Weeks, 4)
+	assert.Equal(t, "2025-07-07", stats.Weeks[0].Week)
+	assert.Equal(t, 0, stats.Weeks[0].Workouts)
+	assert.Equal(t, WeekStats{Week: "2025-07-14", Workouts: 1, Tally: Tally{Sets: 3, Tonnage: 1000}}, stats.Weeks[1])
+	assert.Equal(t, 1, stats.Weeks[2].Workouts)
+	assert.Equal(t, "2025-07-28", stats.Weeks[3].Week)
+}
+
+func TestNewStats_WeeksFollowTheTimeZone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// Sunday night in UTC is already Monday in Tokyo
+	workouts := []Workout{{Start: time.Date(2025, 7, 20, 20, 0, 0, 0, time.UTC)}}
+	from := time.Date(2025, 7, 14, 0, 0, 0, 0, tokyo)
+	to := time.Date(2025, 7, 27, 0, 0, 0, 0, tokyo)
+
+	stats := NewStats(workouts, nil, from, to, tokyo)
+
+	require.Len(t, stats.Weeks, 2)
+	assert.Equal(t, 0, stats.Weeks[0].Workouts)
+	assert.Equal(t, "2025-07-21", stats.Weeks[1].Week)
+	assert.Equal(t, 1, stats.Weeks[1].Workouts)
+}
+
+func TestNewStats_EmptyListsNotNull(t *testing.T) {
+	now := time.Now()
+	stats := NewStats(nil, nil, now, now, time.UTC)
+
+	assert.NotNil(t, stats.ByTarget)
+	assert.NotNil(t, stats.ByCategory)
+	assert.NotNil(t, stats.ByGrouping)
+	assert.Len(t, stats.Weeks, 1)
+}
+
+func TestNewStats_GroupsBySupersetAndCircuit(t *testing.T) {
+	workouts := []Workout{{
+		Start: time.Date(2025, 7, 14, 18, 0, 0, 0, time.UTC),
+		Exercises: []WorkoutExercise{
+			{ExerciseID: "Bench Press", ExerciseOrder: 0, Group: "a", GroupType: Superset, Sets: []Set{{Completed: true, Weight: 100, Reps: 5}}},
+			{ExerciseID: "Row", ExerciseOrder: 1, Group: "a", GroupType: Superset, Sets: []Set{{Completed: true, Weight: 80, Reps: 5, Rest: 120}}},
+			// the rest is logged after each exercise of the round, and counts once, the longest
+			{ExerciseID: "Burpee", ExerciseOrder: 2, Group: "b", GroupType: Circuit, Sets: []Set{{Completed: true, Reps: 20, Rest: 30}}},
+			{ExerciseID: "Jump Rope", ExerciseOrder: 3, Group: "b", GroupType: Circuit, Sets: []Set{{Completed: true, Duration: 60, Rest: 60}}},
+			{ExerciseID: "Deadlift", ExerciseOrder: 4, Sets: []Set{{Completed: true, Weight: 140, Reps: 3, Rest: 180}}},
+			// a second superset, of two rounds, the curls done last
+			{ExerciseID: "Curl", ExerciseOrder: 6, Group: "c", GroupType: Superset, Sets: []Set{
+				{Completed: true, Weight: 10, Reps: 10, Rest: 90},
+				{Completed: true, Weight: 10, Reps: 10, Rest: 90},
+			}},
+			{ExerciseID: "Pushdown", ExerciseOrder: 5, Group: "c", GroupType: Superset, Sets: []Set{
+				{Completed: true, Weight: 20, Reps: 10},
+				{Completed: true, Weight: 20, Reps: 10},
+			}},
+		},
+	}}
+
+	from := time.Date(2025, 7, 14, 0, 0, 0, 0, time.UTC)
+	stats := NewStats(workouts, nil, from, from.AddDate(0, 0, 6), time.UTC)
+
+	assert.Equal(t, 120+60+180+2*90, stats.Totals.Rest)
+	assert.Equal(t, []GroupingStats{
+		{Name: string(Superset), Groups: 2, Rounds: 3, Tally: Tally{Sets: 6, Tonnage: 1500, Rest: 300}},
+		{Name: Straight, Tally: Tally{Sets: 1, Tonnage: 420, Rest: 180}},
+		{Name: string(Circuit), Groups: 1, Rounds: 1, Tally: Tally{Sets: 2, Duration: 60, Rest: 60}},
+	}, stats.ByGrouping)
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// TimestampLayout has a fixed number of fractional digits, unlike time.RFC3339Nano,
+// so that timestamps stored as strings sort the same way the instants they stand for do.
+const TimestampLayout = "2006-01-02T15:04:05.000000Z"
+
+// Timestamp formats t for the updated_at attribute.
+func Timestamp(t time.Time) string {
+	return t.UTC().Format(TimestampLayout)
+}
+
+// TombstoneRetention is how long deletions are remembered for clients that sync.
+// A client that hasn't synced for longer has to start over.
+const TombstoneRetention = 90 * 24 * time.Hour
+
+const (
+	KindWorkout  = "workout"
+	KindTemplate = "template"
+	KindProgram  = "program"
+	KindExercise = "exercise"
+)
+
+// Tombstone records that an item was deleted, so that offline clients can learn about it.
+// PK: USER#<userId>
+// SK: DELETED#<original SK>
+type Tombstone struct {
+	PK        string `dynamodbav:"PK"`
+	SK        string `dynamodbav:"SK"`
+	Kind      string `dynamodbav:"kind"`
+	ID        string `dynamodbav:"id"`
+	UpdatedAt string `dynamodbav:"updated_at"`
+	ExpiresAt int64  `dynamodbav:"scheduled_for_deletion_at"` // table TTL
+}
+
+func NewTombstone(userId, kind, sk, id string, at time.Time) Tombstone {
+	return Tombstone{
+		PK:        UserKey + userId,
+		SK:        TombstoneKey + sk,
+		Kind:      kind,
+		ID:        id,
+		UpdatedAt: Timestamp(at),
+		ExpiresAt: at.Add(TombstoneRetention).Unix(),
+	}
+}
+
+type TombstoneOut struct {
+	Kind string `json:"kind" example:"workout" enums:"workout,template,program,exercise"`
+	ID   string `json:"id" example:"2025-07-18T05:40:48.329406Z"`
+} // @name Tombstone
+
+// SyncToken marks how far a client has read the change feed of its user.
+// SK is the item last handed out at UpdatedAt, if any, so that items sharing a timestamp aren't skipped.
+// During a first, Full download, the items are read by sort key instead: SK is the last one handed out
+// and UpdatedAt is when the download started, for the feed to pick up from once it is done.
+type SyncToken struct {
+	UpdatedAt string `json:"t"`
+	SK        string `json:"k,omitempty"`
+	Full      bool   `json:"f,omitempty"`
+}
+
+// SyncedKeys are the sort key prefixes of the items a first sync downloads.
+var SyncedKeys = []string{WorkoutKey, TemplateKey, ProgramKey, ExerciseKey}
+
+func (t SyncToken) Encode() string {
+	raw, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func (t SyncToken) Time() (time.Time, error) {
+	return time.Parse(TimestampLayout, t.UpdatedAt)
+}
+
+func DecodeSyncToken(s string) (*SyncToken, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("malformed sync token")
+	}
+
+	var token SyncToken
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return nil, errors.New("malformed sync token")
+	}
+
+	if _, err := token.Time(); err != nil {
+		return nil, errors.New("malformed sync token")
+	}
+
+	return &token, nil
+}
+
+// Changes is one page of a user's change feed, oldest first.
+type Changes struct {
+	Workouts   []Workout
+	Templates  []Template
+	Programs   []Program
+	Exercises  []Exercise
+	Tombstones []Tombstone
+}
+
+type SyncResponse struct {
+	Workouts  []WorkoutOut   `json:"workouts"`
+	Templates []TemplateOut  `json:"templates"`
+	Programs  []ProgramOut   `json:"programs"`
+	Exercises []ExerciseOut  `json:"exercises"`
+	Deleted   []TombstoneOut `json:"deleted"`
+	Token     string         `json:"token" example:"eyJ0IjoiMjAyNS0wNy0xOFQwNTo0MDo0OC4zMjk0MDZaIn0"`
+	HasMore   bool           `json:"hasMore" example:"false"`
+	Reset     bool           `json:"reset" example:"false"` // the client must drop its local copy before applying this page
+} // @name SyncResponse
+
+// NewSyncResponse shapes a page of changes for the client.
+// When an item was deleted and recreated within the page, only the newest state is kept.
+func NewSyncResponse(c *Changes, mediaDomain string) SyncResponse {
+	deletedAt := make(map[string]string, len(c.Tombstones))
+	for _, t := range c.Tombstones {
+		deletedAt[t.Kind+"#"+t.ID] = t.UpdatedAt
+	}
+
+	out := SyncResponse{
+		Workouts:  []WorkoutOut{},
+		Templates: []TemplateOut{},
+		Programs:  []ProgramOut{},
+		Exercises: []ExerciseOut{},
+		Deleted:   []TombstoneOut{},
+	}
+
+	// an item survives if it was saved after its latest deletion
+	live := func(kind, id, updatedAt string) bool {
+		at, ok := deletedAt[kind+"#"+id]
+		if !ok || updatedAt > at {
+			delete(deletedAt, kind+"#"+id)
+			return true
+		}
+		return false
+	}
+
+	for _, w := range c.Workouts {
+		if live(KindWorkout, w.ID(), w.UpdatedAt) {
+			out.Workouts = append(out.Workouts, NewWorkoutOut(&w, mediaDomain))
+		}
+	}
+
+	for _, t := range c.Templates {
+		if live(KindTemplate, t.ID(), t.UpdatedAt) {
+			out.Templates = append(out.Templates, NewTemplateOut(&t))
+		}
+	}
+
+	for _, p := range c.Programs {
+		if live(KindProgram, p.ID(), p.UpdatedAt) {
+			out.Programs = append(out.Programs, NewProgramOut(&p))
+		}
+	}
+
+	for _, e := range c.Exercises {
+		if live(KindExercise, e.Name, e.UpdatedAt) {
+			ex := NewExerciseOut(&e)
+			own := true
+			ex.Own = &own
+			out.Exercises = append(out.Exercises, ex)
+		}
+	}
+
+	for _, t := range c.Tombstones {
+		if _, ok := deletedAt[t.Kind+"#"+t.ID]; ok {
+			out.Deleted = append(out.Deleted, TombstoneOut{Kind: t.Kind, ID: t.ID})
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestamp_SortsLikeTime(t *testing.T) {
+	whole := time.Date(2025, 7, 18, 5, 40, 48, 0, time.UTC)
+	fraction := whole.Add(100 * time.Millisecond)
+
+	assert.Equal(t, "2025-07-18T05:40:48.000000Z", Timestamp(whole))
+	assert.Less(t, Timestamp(whole), Timestamp(fraction))
+
+	// always UTC
+	est := time.FixedZone("EST", -5*60*60)
+	assert.Equal(t, Timestamp(whole), Timestamp(whole.In(est)))
+}
+
+func TestSyncToken_RoundTrip(t *testing.T) {
+	token := SyncToken{UpdatedAt: "2025-07-18T05:40:48.329406Z", SK: "WORKOUT#w1"}
+
+	decoded, err := DecodeSyncToken(token.Encode())
+	require.NoError(t, err)
+	assert.Equal(t, token, *decoded)
+}
+
+func TestDecodeSyncToken_Malformed(t *testing.T) {
+	for _, raw := range []string{"not base64!", "bm90IGpzb24", SyncToken{UpdatedAt: "yesterday"}.Encode()} {
+		_, err := DecodeSyncToken(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestNewSyncResponse_KeepsNewestStatePerItem(t *testing.T) {
+	changes := &Changes{
+		Workouts: []Workout{
+			{SK: WorkoutKey + "recreated", UpdatedAt: "2025-07-18T05:40:48.000002Z"},
+			{SK: WorkoutKey + "deleted-later", UpdatedAt: "2025-07-18T05:40:48.000001Z"},
+		},
+		Templates: []Template{{SK: TemplateKey + "t1", UpdatedAt: "2025-07-18T05:40:48.000001Z"}},
+		Exercises: []Exercise{{Name: "Cable Fly", UpdatedAt: "2025-07-18T05:40:48.000001Z"}},
+		Tombstones: []Tombstone{
+			{Kind: KindWorkout, ID: "recreated", UpdatedAt: "2025-07-18T05:40:48.000001Z"},
+			{Kind: KindWorkout, ID: "deleted-later", UpdatedAt: "2025-07-18T05:40:48.000003Z"},
+			{Kind: KindTemplate, ID: "t2", UpdatedAt: "2025-07-18T05:40:48.000001Z"},
+		},
+	}
+
+	out := NewSyncResponse(changes, "https://media.example.test")
+
+	require.Len(t, out.Workouts, 1)
+	assert.Equal(t, "recreated", out.Workouts[0].ID)
+	require.Len(t, out.Templates, 1)
+	require.Len(t, out.Exercises, 1)
+	assert.True(t, *out.Exercises[0].Own)
+	assert.ElementsMatch(t, []TombstoneOut{{Kind: KindWorkout, ID: "deleted-later"}, {Kind: KindTemplate, ID: "t2"}}, out.Deleted)
+}
+
+func TestNewSyncResponse_EmptyListsNotNull(t *testing.T) {
+	out := NewSyncResponse(&Changes{}, "")
+	assert.NotNil(t, out.Workouts)
+	assert.NotNil(t, out.Templates)
+	assert.NotNil(t, out.Exercises)
+	assert.NotNil(t, out.Deleted)
+}
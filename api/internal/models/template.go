@@ -2,7 +2,10 @@ package models
 
 import (
 	"fmt"
+	"math"
+	"slices"
 	"strings"
+	"time"
 )
 
 type Template struct {
@@ -11,6 +14,18 @@ type Template struct {
 	Name          string             `dynamodbav:"name"`
 	OrderInParent int                `dynamodbav:"order"`
 	Exercises     []TemplateExercise `dynamodbav:"exercises"`
+	UpdatedAt     string             `dynamodbav:"updated_at,omitempty"`
+	Version       int                `dynamodbav:"version"` // bumped on every save
+	TemplateUse
+}
+
+// TemplateUse is kept up as workouts started from a template are saved, apart from edits
+// to the template itself, which neither touch it nor are bumped by it.
+type TemplateUse struct {
+	LastPerformed string `dynamodbav:"last_performed,omitempty"` // start of the latest workout, as a Timestamp
+	Performed     int    `dynamodbav:"performed,omitempty"`      // workouts started from it
+	PlannedSets   int    `dynamodbav:"planned_sets,omitempty"`   // sets it had when they were started
+	CompletedSets int    `dynamodbav:"completed_sets,omitempty"` // of those, sets completed
 }
 
 func (t *Template) UserID() string {
@@ -21,11 +36,48 @@ func (t *Template) ID() string {
 	return strings.TrimPrefix(t.SK, "TEMPLATE#")
 }
 
+// RenameExercise points the template from one exercise name to another
+// and reports whether it used the exercise.
+func (t *Template) RenameExercise(from, to string) bool {
+	renamed := false
+	t.Exercises = slices.Clone(t.Exercises)
+	for i := range t.Exercises {
+		if t.Exercises[i].ExerciseID == from {
+			t.Exercises[i].ExerciseID = to
+			renamed = true
+		}
+	}
+	return renamed
+}
+
+// RemoveExercise takes an exercise out of the template and reports whether it was in it.
+// A group it leaves with a single exercise is undone.
+func (t *Template) RemoveExercise(name string) bool {
+	kept := slices.DeleteFunc(slices.Clone(t.Exercises), func(e TemplateExercise) bool { return e.ExerciseID == name })
+	removed := len(kept) < len(t.Exercises)
+	t.Exercises = kept
+
+	groups := make([]string, len(kept))
+	for i, e := range kept {
+		groups[i] = e.Group
+	}
+	alone := loners(groups)
+	for i := range t.Exercises {
+		if alone[t.Exercises[i].Group] {
+			t.Exercises[i].Group, t.Exercises[i].GroupType = "", ""
+		}
+	}
+
+	return removed
+}
+
 type TemplateExercise struct {
-	ID            string `dynamodbav:"id" json:"id" example:"2025-07-18T05:40:48.329406Z"`
-	ExerciseID    string `dynamodbav:"exercise" json:"exercise"`
-	ExerciseOrder int    `dynamodbav:"order" json:"order"`
-	Sets          []Set  `dynamodbav:"sets" json:"sets"`
+	ID            string    `dynamodbav:"id" json:"id" example:"2025-07-18T05:40:48.329406Z"`
+	ExerciseID    string    `dynamodbav:"exercise" json:"exercise"`
+	ExerciseOrder int       `dynamodbav:"order" json:"order"`
+	Sets          []Set     `dynamodbav:"sets" json:"sets"`
+	Group         string    `dynamodbav:"group,omitempty" json:"group,omitempty" example:"a"` // shared by the exercises done in turn
+	GroupType     GroupType `dynamodbav:"group_type,omitempty" json:"groupType,omitempty" example:"superset" enums:"superset,circuit,giant"`
 } // @name TemplateExercise
 
 type TemplateIn struct {
@@ -33,6 +85,7 @@ type TemplateIn struct {
 	Name      string             `json:"name"`
 	Order     int                `json:"order"`
 	Exercises []TemplateExercise `json:"exercises"`
+	Version   *int               `json:"version,omitempty" example:"3"` // the version this edit is based on
 } // @name TemplateIn
 
 func NewTemplate(t *TemplateIn, userId string) Template {
@@ -45,20 +98,55 @@ func NewTemplate(t *TemplateIn, userId string) Template {
 	}
 }
 
+// TemplateOrderIn lists template IDs in the order the templates go in, at most as many as
+// fit in one transaction.
+type TemplateOrderIn struct {
+	IDs []string `json:"ids" binding:"required,min=1,max=100,unique"`
+} // @name TemplateOrderIn
+
+// Duplicate copies the template under a new ID, with its usage starting over.
+func (t *Template) Duplicate(id string, order int) Template {
+	return Template{
+		PK:            t.PK,
+		SK:            TemplateKey + id,
+		Name:          t.Name + " (copy)",
+		OrderInParent: order,
+		Exercises:     slices.Clone(t.Exercises),
+	}
+}
+
 type TemplateOut struct {
 	ID        string             `json:"id" example:"2"`
 	Name      string             `json:"name" example:"Legs & Shoulders"`
 	Order     int                `json:"order" example:"1"`
 	Exercises []TemplateExercise `json:"exercises"`
+	Version   int                `json:"version" example:"3"`
+	// usage, read-only
+	LastPerformed *time.Time `json:"lastPerformed,omitempty" example:"2025-07-18T05:40:48.329406Z"`
+	Performed     int        `json:"performed" example:"12"`
+	Adherence     *float64   `json:"adherence,omitempty" example:"0.92"` // share of planned sets completed
 } // @name Template
 
 func NewTemplateOut(t *Template) TemplateOut {
-	return TemplateOut{
+	out := TemplateOut{
 		ID:        t.ID(),
 		Name:      t.Name,
 		Order:     t.OrderInParent,
 		Exercises: t.Exercises,
+		Version:   t.Version,
+		Performed: t.Performed,
+	}
+
+	if last, err := time.Parse(TimestampLayout, t.LastPerformed); err == nil {
+		out.LastPerformed = &last
+	}
+
+	if t.PlannedSets > 0 {
+		adherence := math.Round(float64(t.CompletedSets)/float64(t.PlannedSets)*100) / 100
+		out.Adherence = &adherence
 	}
+
+	return out
 }
 
 type TemplateResponse struct {
@@ -72,3 +160,128 @@ func NewTemplateArray(templates []Template) []TemplateOut {
 	}
 	return out
 }
+
+// StartWorkout lays out a new workout from the template, unsaved. Sets take their numbers
+// from the last time each exercise was done, set by set, where there is one; exercises
+// planned without sets get as many as were done last time.
+func (t *Template) StartWorkout(last map[string][]Set, now time.Time) WorkoutIn {
+	id := t.ID()
+	in := WorkoutIn{
+		ID:        Timestamp(now),
+		Name:      t.Name,
+		Start:     now,
+		Exercises: make([]WorkoutExerciseIn, len(t.Exercises)),
+		Template:  &id,
+	}
+
+	n := 0
+	nextID := func() string {
+		n++
+		return Timestamp(now.Add(time.Duration(n) * time.Microsecond))
+	}
+
+	for i, e := range t.Exercises {
+		var done []Set
+		for _, s := range last[e.ExerciseID] {
+			if s.Completed {
+				done = append(done, s)
+			}
+		}
+
+		planned := e.Sets
+		if len(planned) == 0 {
+			planned = done
+		}
+
+		sets := make([]Set, len(planned))
+		for j, s := range planned {
+			if j < len(done) {
+				s.Weight, s.Reps, s.Duration, s.Distance = done[j].Weight, done[j].Reps, done[j].Duration, done[j].Distance
+			}
+			s.ID = nextID()
+			s.Completed = false
+			sets[j] = s
+		}
+
+		in.Exercises[i] = WorkoutExerciseIn{
+			ID:        nextID(),
+			Exercise:  e.ExerciseID,
+			Order:     e.ExerciseOrder,
+			Sets:      sets,
+			Group:     e.Group,
+			GroupType: e.GroupType,
+		}
+	}
+
+	return in
+}
+
+// UpdateFrom replaces the template's exercises with what was done in a workout:
+// its completed sets, to be done again. It reports false if nothing was completed.
+func (t *Template) UpdateFrom(w *Workout) bool {
+	var exercises []TemplateExercise
+	for _, e := range w.Exercises {
+		var sets []Set
+		for _, s := range e.Sets {
+			if s.Completed {
+				s.Completed = false
+				sets = append(sets, s)
+			}
+		}
+
+		if len(sets) > 0 {
+			exercises = append(exercises, TemplateExercise{
+				ID:            e.ID,
+				ExerciseID:    e.ExerciseID,
+				ExerciseOrder: len(exercises),
+				Sets:          sets,
+				Group:         e.Group,
+				GroupType:     e.GroupType,
+			})
+		}
+	}
+
+	if len(exercises) == 0 {
+		return false
+	}
+
+	// a group whose other exercises were skipped is done straight
+	groups := make([]string, len(exercises))
+	for i, e := range exercises {
+		groups[i] = e.Group
+	}
+	alone := loners(groups)
+	for i := range exercises {
+		if alone[exercises[i].Group] {
+			exercises[i].Group, exercises[i].GroupType = "", ""
+		}
+	}
+
+	t.Exercises = exercises
+	return true
+}
+
+// Adherence counts the sets the template plans and how many of them a workout completed,
+// exercise by exercise, so that extra sets of one exercise do not make up for skipping another.
+func (t *Template) Adherence(w *Workout) (planned, completed int) {
+	plannedBy := map[string]int{}
+	for _, e := range t.Exercises {
+		plannedBy[e.ExerciseID] += len(e.Sets)
+	}
+
+	doneBy := map[string]int{}
+	for _, e := range w.Exercises {
+		for _, s := range e.Sets {
+			if s.Completed {
+				doneBy[e.ExerciseID]++
+			}
+		}
+	}
+
+	for exercise, n := range plannedBy {
+		planned += n
+		completed += min(n, doneBy[exercise])
+	}
+
+	return planned, completed
+}
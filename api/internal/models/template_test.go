@@ -2,8 +2,11 @@ package models
 
 import (
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTemplate_StructFields(t *testing.T) {
@@ -1063,3 +1066,120 @@ func TestNewTemplate_EdgeCases(t *testing.T) {
 		assert.Equal(t, TemplateKey+longId, result.SK)
 	})
 }
+
+func pushTemplate() *Template {
+	return &Template{
+		PK:   UserKey + "u1",
+		SK:   TemplateKey + "t1",
+		Name: "Push",
+		Exercises: []TemplateExercise{
+			{ID: "e1", ExerciseID: "Bench Press", ExerciseOrder: 0, Sets: []Set{{ID: "s1", Reps: 8}, {ID: "s2", Reps: 8}, {ID: "s3", Reps: 8}}},
+			{ID: "e2", ExerciseID: "Dip", ExerciseOrder: 1},
+		},
+	}
+}
+
+func TestTemplate_StartWorkoutPrefillsFromLastTime(t *testing.T) {
+	now := time.Date(2025, 8, 1, 7, 0, 0, 0, time.UTC)
+	last := map[string][]Set{
+		"Bench Press": {{Completed: true, Weight: 80, Reps: 6}, {Completed: false, Weight: 85, Reps: 2}, {Completed: true, Weight: 75, Reps: 8}},
+		"Dip":         {{Completed: true, Reps: 12}, {Completed: true, Reps: 10}},
+	}
+
+	in := pushTemplate().StartWorkout(last, now)
+
+	assert.Equal(t, Timestamp(now), in.ID)
+	assert.Equal(t, "Push", in.Name)
+	require.NotNil(t, in.Template)
+	assert.Equal(t, "t1", *in.Template)
+
+	require.Len(t, in.Exercises, 2)
+	bench := in.Exercises[0].Sets
+	require.Len(t, bench, 3) // as planned
+	assert.Equal(t, Set{ID: bench[0].ID, Weight: 80, Reps: 6}, bench[0])
+	assert.Equal(t, Set{ID: bench[1].ID, Weight: 75, Reps: 8}, bench[1]) // the failed set is not repeated
+	assert.Equal(t, Set{ID: bench[2].ID, Reps: 8}, bench[2])             // nothing to go by
+
+	dips := in.Exercises[1].Sets
+	require.Len(t, dips, 2) // none planned, so as many as last time
+	assert.Equal(t, 12, dips[0].Reps)
+	assert.False(t, dips[0].Completed)
+
+	ids := map[string]bool{in.ID: true}
+	for _, e := range in.Exercises {
+		ids[e.ID] = true
+		for _, s := range e.Sets {
+			ids[s.ID] = true
+		}
+	}
+	assert.Len(t, ids, 8)
+}
+
+func TestTemplate_UpdateFromKeepsCompletedSets(t *testing.T) {
+	template := pushTemplate()
+	ok := template.UpdateFrom(&Workout{Exercises: []WorkoutExercise{
+		{ID: "w1", ExerciseID: "Skipped", Sets: []Set{{ID: "a", Reps: 5}}},
+		{ID: "w2", ExerciseID: "Bench Press", Sets: []Set{{ID: "b", Completed: true, Weight: 82.5, Reps: 8}, {ID: "c", Reps: 8}}},
+	}})
+
+	assert.True(t, ok)
+	assert.Equal(t, []TemplateExercise{
+		{ID: "w2", ExerciseID: "Bench Press", ExerciseOrder: 0, Sets: []Set{{ID: "b", Weight: 82.5, Reps: 8}}},
+	}, template.Exercises)
+
+	assert.False(t, template.UpdateFrom(&Workout{}))
+	assert.Len(t, template.Exercises, 1)
+}
+
+func TestTemplate_AdherenceCapsEachExercise(t *testing.T) {
+	planned, completed := pushTemplate().Adherence(&Workout{Exercises: []WorkoutExercise{
+		{ExerciseID: "Bench Press", Sets: []Set{{Completed: true}, {Completed: true}, {Completed: true}, {Completed: true}, {Completed: false}}},
+		{ExerciseID: "Dip", Sets: []Set{{Completed: true}}},
+	}})
+
+	assert.Equal(t, 3, planned)
+	assert.Equal(t, 3, completed)
+}
+
+func TestNewTemplateOut_Usage(t *testing.T) {
+	template := pushTemplate()
+	assert.Nil(t, NewTemplateOut(template).Adherence)
+	assert.Nil(t, NewTemplateOut(template).LastPerformed)
+
+	template.TemplateUse = TemplateUse{LastPerformed: "2025-08-01T07:00:00.000000Z", Performed: 4, PlannedSets: 12, CompletedSets: 11}
+	out := NewTemplateOut(template)
+
+	assert.Equal(t, 4, out.Performed)
+	require.NotNil(t, out.Adherence)
+	assert.Equal(t, 0.92, *out.Adherence)
+	require.NotNil(t, out.LastPerformed)
+	assert.True(t, out.LastPerformed.Equal(time.Date(2025, 8, 1, 7, 0, 0, 0, time.UTC)))
+}
+
+func TestTemplate_UsageIsStoredFlat(t *testing.T) {
+	template := pushTemplate()
+	template.Performed = 2
+
+	item, err := attributevalue.MarshalMap(template)
+	require.NoError(t, err)
+	assert.Contains(t, item, "performed")
+
+	var back Template
+	require.NoError(t, attributevalue.UnmarshalMap(item, &back))
+	assert.Equal(t, 2, back.Performed)
+}
+
+func TestTemplate_DuplicateStartsUsageOver(t *testing.T) {
+	template := pushTemplate()
+	template.Version = 7
+	template.Performed = 3
+
+	copied := template.Duplicate("t2", 4)
+
+	assert.Equal(t, "t2", copied.ID())
+	assert.Equal(t, "Push (copy)", copied.Name)
+	assert.Equal(t, 4, copied.OrderInParent)
+	assert.Equal(t, template.Exercises, copied.Exercises)
+	assert.Zero(t, copied.Version)
+	assert.Zero(t, copied.Performed)
+}
@@ -21,15 +21,22 @@ type UserIn struct {
 	user
 } // @name UserIn
 
+// UserInternal is the account as it is stored; it goes out as JSON only in a personal data export.
 type UserInternal struct {
-	PK                      string     `dynamodbav:"PK"`
-	SK                      string     `dynamodbav:"SK"`
-	Username                *string    `dynamodbav:"username"`
-	Email                   string     `dynamodbav:"email"`
-	FirebaseUID             string     `dynamodbav:"firebase_uid"`
-	AvatarUrl               *string    `dynamodbav:"avatar"`
-	AccountDeletionSchedule *string    `dynamodbav:"account_deletion_schedule"`
-	ScheduledForDeletionAt  *time.Time `dynamodbav:"scheduled_for_deletion_at"`
+	PK                      string     `dynamodbav:"PK" json:"pk"`
+	SK                      string     `dynamodbav:"SK" json:"sk"`
+	Username                *string    `dynamodbav:"username" json:"username"`
+	Email                   string     `dynamodbav:"email" json:"email"`
+	FirebaseUID             string     `dynamodbav:"firebase_uid" json:"firebase_uid"`
+	AvatarUrl               *string    `dynamodbav:"avatar" json:"avatar"`
+	AccountDeletionSchedule *string    `dynamodbav:"account_deletion_schedule" json:"account_deletion_schedule"`
+	ScheduledForDeletionAt  *time.Time `dynamodbav:"scheduled_for_deletion_at" json:"scheduled_for_deletion_at"`
+}
+
+// ItemKey is the primary key of an item in the workouts table.
+type ItemKey struct {
+	PK string `dynamodbav:"PK"`
+	SK string `dynamodbav:"SK"`
 }
 
 type UserPublic struct {
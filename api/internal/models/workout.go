@@ -1,18 +1,27 @@
 package models
 
 import (
+	"cmp"
 	"fmt"
 	"path"
+	"slices"
 	"strings"
 	"time"
 )
 
 const (
-	UserKey     = "USER#"
-	WorkoutKey  = "WORKOUT#"
-	TemplateKey = "TEMPLATE#"
-	ExerciseKey = "EXERCISE#"
-	ProgressKey = "PROGRESS#"
+	UserKey      = "USER#"
+	WorkoutKey   = "WORKOUT#"
+	TemplateKey  = "TEMPLATE#"
+	ProgramKey   = "PROGRAM#"
+	ShareKey     = "SHARE#"
+	SharedKey    = "SHARED#"
+	ExerciseKey  = "EXERCISE#"
+	AliasKey     = "ALIAS#"
+	ProgressKey  = "PROGRESS#"
+	RecordKey    = "PR#"
+	HistoryKey   = "HIST#"
+	TombstoneKey = "DELETED#"
 )
 
 type Image struct {
@@ -51,6 +60,9 @@ type Workout struct {
 	Name      string            `dynamodbav:"name,omitempty"`
 	Exercises []WorkoutExercise `dynamodbav:"exercises"`
 	ImageKeys *[]string         `dynamodbav:"images,omitempty" json:"-"`
+	Template  string            `dynamodbav:"template_id,omitempty"` // the template it was started from
+	UpdatedAt string            `dynamodbav:"updated_at,omitempty"`
+	Version   int               `dynamodbav:"version"` // bumped on every save
 }
 
 func (w *Workout) String() string {
@@ -61,13 +73,72 @@ func (w *Workout) ID() string {
 	return strings.TrimPrefix(w.SK, WorkoutKey)
 }
 
+// RenameExercise moves what was logged under one exercise name to another
+// and reports whether there was any. Copies sharing the exercises are left alone.
+func (w *Workout) RenameExercise(from, to string) bool {
+	renamed := false
+	w.Exercises = slices.Clone(w.Exercises)
+	for i := range w.Exercises {
+		if w.Exercises[i].ExerciseID == from {
+			w.Exercises[i].ExerciseID = to
+			renamed = true
+		}
+	}
+	return renamed
+}
+
+// RemoveExercise takes an exercise out of the workout, sets and all,
+// and reports whether it was in it. Copies sharing the exercises are left alone.
+func (w *Workout) RemoveExercise(name string) bool {
+	kept := slices.DeleteFunc(slices.Clone(w.Exercises), func(e WorkoutExercise) bool { return e.ExerciseID == name })
+	removed := len(kept) < len(w.Exercises)
+	w.Exercises = kept
+
+	// the exercise it was paired with is now done straight
+	groups := make([]string, len(kept))
+	for i, e := range kept {
+		groups[i] = e.Group
+	}
+	alone := loners(groups)
+	for i := range w.Exercises {
+		if alone[w.Exercises[i].Group] {
+			w.Exercises[i].Group, w.Exercises[i].GroupType = "", ""
+		}
+	}
+
+	return removed
+}
+
 type WorkoutExercise struct {
-	ID            string `dynamodbav:"id"`
-	ExerciseID    string `dynamodbav:"exercise_id"` // same as Exercise.Name
-	ExerciseOrder int    `dynamodbav:"exercise_order"`
-	Sets          []Set  `dynamodbav:"sets"`
+	ID            string    `dynamodbav:"id"`
+	ExerciseID    string    `dynamodbav:"exercise_id"` // same as Exercise.Name
+	ExerciseOrder int       `dynamodbav:"exercise_order"`
+	Sets          []Set     `dynamodbav:"sets"`
+	Group         string    `dynamodbav:"group,omitempty"` // shared by the exercises done in turn
+	GroupType     GroupType `dynamodbav:"group_type,omitempty"`
 }
 
+// SetType tells what a set is for. Sets without one are working sets.
+type SetType string
+
+const (
+	WarmupSet  SetType = "warmup"
+	WorkingSet SetType = "working"
+	DropSet    SetType = "drop"
+	FailureSet SetType = "failure"
+	AMRAPSet   SetType = "amrap" // as many reps as possible
+)
+
+// SetTypes are the types a set can have.
+var SetTypes = []SetType{WarmupSet, WorkingSet, DropSet, FailureSet, AMRAPSet}
+
+const (
+	MaxRPE      = 10
+	MaxRIR      = 10
+	MaxRest     = 3600 // seconds
+	MaxNoteSize = 500  // characters
+)
+
 type Set struct {
 	ID        string  `dynamodbav:"id" json:"id" binding:"required" example:"2025-07-18T05:40:48.329406Z"`
 	Completed bool    `dynamodbav:"completed" json:"completed" binding:"required" example:"true"`
@@ -75,13 +146,26 @@ type Set struct {
 	Reps      int     `dynamodbav:"reps,omitempty" json:"reps,omitempty" example:"10"`
 	Duration  float64 `dynamodbav:"duration,omitempty" json:"duration,omitempty" example:"10"` // seconds
 	Distance  float64 `dynamodbav:"distance,omitempty" json:"distance,omitempty" example:"10"` // kilometers
+	Type      SetType `dynamodbav:"type,omitempty" json:"type,omitempty" example:"working" enums:"warmup,working,drop,failure,amrap"`
+	RPE       float64 `dynamodbav:"rpe,omitempty" json:"rpe,omitempty" example:"8.5"` // rate of perceived exertion, 1 to 10 in halves
+	RIR       *int    `dynamodbav:"rir,omitempty" json:"rir,omitempty" example:"2"`   // reps in reserve, 0 to 10
+	Tempo     string  `dynamodbav:"tempo,omitempty" json:"tempo,omitempty" example:"3-1-1-0"`
+	Rest      int     `dynamodbav:"rest,omitempty" json:"rest,omitempty" example:"90"` // seconds, after the set
+	Note      string  `dynamodbav:"note,omitempty" json:"note,omitempty" example:"Felt easy"`
 } // @name SetIn
 
+// Warmup tells whether the set only prepares for the ones that count, so it is left out of stats and records.
+func (s *Set) Warmup() bool {
+	return s.Type == WarmupSet
+}
+
 type WorkoutExerciseIn struct {
-	ID       string `json:"id" binding:"required" example:"2025-07-18T05:40:48.329406Z"`
-	Exercise string `json:"exercise" binding:"required" example:"Push Up"`
-	Sets     []Set  `json:"sets"`
-	Order    int    `json:"order" example:"1"`
+	ID        string    `json:"id" binding:"required" example:"2025-07-18T05:40:48.329406Z"`
+	Exercise  string    `json:"exercise" binding:"required" example:"Push Up"`
+	Sets      []Set     `json:"sets"`
+	Order     int       `json:"order" example:"1"`
+	Group     string    `json:"group,omitempty" example:"a"` // shared by the exercises done in turn
+	GroupType GroupType `json:"groupType,omitempty" example:"superset" enums:"superset,circuit,giant"`
 } // @name WorkoutExerciseIn
 
 type WorkoutIn struct {
@@ -90,21 +174,32 @@ type WorkoutIn struct {
 	Start     time.Time           `json:"start" binding:"required" example:"2023-01-01T12:00:00Z"`
 	End       *time.Time          `json:"end,omitempty" example:"2023-01-01T12:00:00Z"`
 	Exercises []WorkoutExerciseIn `json:"exercises" binding:"required"`
+	Template  *string             `json:"templateId,omitempty" example:"2"` // the template it was started from
+	Version   *int                `json:"version,omitempty" example:"3"`    // the version this edit is based on
 } // @name WorkoutIn
 
 type SetOut struct {
-	ID        string  `json:"id" binding:"required" example:"2025-07-18T05:40:48.329406Z"`
-	Completed bool    `json:"completed" binding:"required" example:"true"`
-	Weight    float64 `json:"weight" example:"100"`
-	Reps      int     `json:"reps" example:"10"`
-	Duration  float64 `json:"duration" example:"10"`
-	Distance  float64 `json:"distance" example:"10"`
+	ID        string       `json:"id" binding:"required" example:"2025-07-18T05:40:48.329406Z"`
+	Completed bool         `json:"completed" binding:"required" example:"true"`
+	Weight    float64      `json:"weight" example:"100"`
+	Reps      int          `json:"reps" example:"10"`
+	Duration  float64      `json:"duration" example:"10"`
+	Distance  float64      `json:"distance" example:"10"`
+	Type      SetType      `json:"type" example:"working" enums:"warmup,working,drop,failure,amrap"`
+	RPE       float64      `json:"rpe,omitempty" example:"8.5"`
+	RIR       *int         `json:"rir,omitempty" example:"2"`
+	Tempo     string       `json:"tempo,omitempty" example:"3-1-1-0"`
+	Rest      int          `json:"rest,omitempty" example:"90"` // seconds, after the set
+	Note      string       `json:"note,omitempty" example:"Felt easy"`
+	Records   []RecordKind `json:"records,omitempty"` // personal records this set has just set
 } // @name Set
 
 type WorkoutExerciseOut struct {
-	ID       string   `json:"id" example:"2025-07-18T05:40:48.329406Z"`
-	Exercise *string  `json:"exercise" example:"Push Up"`
-	Sets     []SetOut `json:"sets"`
+	ID        string    `json:"id" example:"2025-07-18T05:40:48.329406Z"`
+	Exercise  *string   `json:"exercise" example:"Push Up"`
+	Sets      []SetOut  `json:"sets"`
+	Group     string    `json:"group,omitempty" example:"a"`
+	GroupType GroupType `json:"groupType,omitempty" example:"superset" enums:"superset,circuit,giant"`
 } // @name WorkoutExercise
 
 type WorkoutOut struct {
@@ -114,6 +209,8 @@ type WorkoutOut struct {
 	End       *time.Time           `json:"end" example:"2023-01-01T12:00:00Z"`
 	Exercises []WorkoutExerciseOut `json:"exercises"`
 	Images    *[]ImageOut          `json:"images,omitempty"`
+	Template  *string              `json:"templateId,omitempty" example:"2"`
+	Version   int                  `json:"version" example:"3"`
 } // @name Workout
 
 func NewSetOut(s *Set) SetOut {
@@ -124,6 +221,12 @@ func NewSetOut(s *Set) SetOut {
 		Reps:      s.Reps,
 		Duration:  s.Duration,
 		Distance:  s.Distance,
+		Type:      cmp.Or(s.Type, WorkingSet),
+		RPE:       s.RPE,
+		RIR:       s.RIR,
+		Tempo:     s.Tempo,
+		Rest:      s.Rest,
+		Note:      s.Note,
 	}
 }
 
@@ -135,9 +238,11 @@ func NewWorkoutExerciseOut(e *WorkoutExercise) WorkoutExerciseOut {
 	}
 
 	return WorkoutExerciseOut{
-		ID:       e.ID,
-		Exercise: &e.ExerciseID,
-		Sets:     sets,
+		ID:        e.ID,
+		Exercise:  &e.ExerciseID,
+		Sets:      sets,
+		Group:     e.Group,
+		GroupType: e.GroupType,
 	}
 }
 
@@ -152,12 +257,18 @@ func NewWorkout(w *WorkoutIn, userId string) Workout {
 		Exercises: make([]WorkoutExercise, len(w.Exercises)),
 	}
 
+	if w.Template != nil {
+		workout.Template = *w.Template
+	}
+
 	for i, exercise := range w.Exercises {
 		workout.Exercises[i] = WorkoutExercise{
 			ID:            exercise.ID,
 			ExerciseID:    exercise.Exercise,
 			ExerciseOrder: exercise.Order,
 			Sets:          make([]Set, len(exercise.Sets)),
+			Group:         exercise.Group,
+			GroupType:     exercise.GroupType,
 		}
 
 		for j, set := range exercise.Sets {
@@ -168,6 +279,12 @@ func NewWorkout(w *WorkoutIn, userId string) Workout {
 				Reps:      set.Reps,
 				Duration:  set.Duration,
 				Distance:  set.Distance,
+				Type:      set.Type,
+				RPE:       set.RPE,
+				RIR:       set.RIR,
+				Tempo:     set.Tempo,
+				Rest:      set.Rest,
+				Note:      set.Note,
 			}
 		}
 	}
@@ -200,6 +317,11 @@ func NewWorkoutOut(w *Workout, mediaDomain string) WorkoutOut {
 		}
 	}
 
+	var template *string
+	if w.Template != "" {
+		template = &w.Template
+	}
+
 	return WorkoutOut{
 		ID:        strings.TrimPrefix(w.SK, WorkoutKey),
 		Name:      w.Name,
@@ -207,9 +329,23 @@ func NewWorkoutOut(w *Workout, mediaDomain string) WorkoutOut {
 		End:       w.End,
 		Exercises: exercises,
 		Images:    &images,
+		Template:  template,
+		Version:   w.Version,
 	}
 }
 
+// WorkoutFilter narrows down a listing of workouts; zero fields do not filter.
+type WorkoutFilter struct {
+	From     time.Time
+	To       time.Time
+	Exercise string // has at least one exercise with this name
+	Name     string // case-insensitive, any part of the name
+}
+
+func (f WorkoutFilter) MatchesName(name string) bool {
+	return strings.Contains(strings.ToLower(name), strings.ToLower(f.Name))
+}
+
 type WorkoutResponse struct {
 	Workouts []WorkoutOut `json:"workouts"`
 	Cursor   string       `json:"cursor"`
@@ -257,3 +393,21 @@ func ProgressCursorFromSK(sk string) string {
 	// expects SK like: "PROGRESS#<workoutId>#<photoId>"
 	return strings.TrimPrefix(sk, "PROGRESS#")
 }
+
+// WorkoutKeyRange bounds sort keys ending in a workout ID by time; zero times leave that end open.
+// Workout IDs are the UTC timestamps the workouts were started at, at whatever precision
+// the client sent, so the bounds are cut at the second and the upper one sorts after
+// anything within its second.
+func WorkoutKeyRange(prefix string, from, to time.Time) (string, string) {
+	const layout = "2006-01-02T15:04:05"
+
+	lower, upper := prefix, prefix+"~"
+	if !from.IsZero() {
+		lower = prefix + from.UTC().Format(layout)
+	}
+	if !to.IsZero() {
+		upper = prefix + to.UTC().Format(layout) + "~"
+	}
+
+	return lower, upper
+}
@@ -1135,3 +1135,42 @@ func TestProgressModels_ZeroValues(t *testing.T) {
 		assert.Nil(t, r.Cursor)
 	})
 }
+
+func TestWorkout_RenameAndRemoveExercise(t *testing.T) {
+	original := Workout{Exercises: []WorkoutExercise{
+		{ID: "1", ExerciseID: "Bench"},
+		{ID: "2", ExerciseID: "Squat"},
+		{ID: "3", ExerciseID: "Bench"},
+	}}
+
+	renamed := original
+	assert.True(t, renamed.RenameExercise("Bench", "Bench Press"))
+	assert.Equal(t, "Bench Press", renamed.Exercises[2].ExerciseID)
+	assert.Equal(t, "Bench", original.Exercises[0].ExerciseID, "the original copy is left alone")
+	assert.False(t, renamed.RenameExercise("Deadlift", "Pull"))
+
+	removed := original
+	assert.True(t, removed.RemoveExercise("Bench"))
+	assert.Equal(t, []WorkoutExercise{{ID: "2", ExerciseID: "Squat"}}, removed.Exercises)
+	assert.Len(t, original.Exercises, 3)
+	assert.False(t, removed.RemoveExercise("Bench"))
+}
+
+func TestNewWorkout_KeepsSetDetails(t *testing.T) {
+	rir := 2
+	set := Set{ID: "s1", Completed: true, Weight: 100, Reps: 5, Type: FailureSet, RPE: 9.5, RIR: &rir, Tempo: "3-1-1-0", Rest: 120, Note: "Grip slipped"}
+	in := WorkoutIn{ID: "w1", Exercises: []WorkoutExerciseIn{{ID: "e1", Exercise: "Bench Press", Sets: []Set{set}}}}
+
+	workout := NewWorkout(&in, "u1")
+	assert.Equal(t, set, workout.Exercises[0].Sets[0])
+
+	out := NewWorkoutOut(&workout, "")
+	assert.Equal(t, SetOut{
+		ID: "s1", Completed: true, Weight: 100, Reps: 5,
+		Type: FailureSet, RPE: 9.5, RIR: &rir, Tempo: "3-1-1-0", Rest: 120, Note: "Grip slipped",
+	}, out.Exercises[0].Sets[0])
+}
+
+func TestNewSetOut_WorkingByDefault(t *testing.T) {
+	assert.Equal(t, WorkingSet, NewSetOut(&Set{ID: "s1"}).Type)
+}
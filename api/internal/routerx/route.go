@@ -1,7 +1,11 @@
 package routerx
 
 import (
+	"errors"
+	"fmt"
+	"heart/internal/middleware"
 	"heart/internal/models"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -14,13 +18,13 @@ func Authenticated(handler AuthHandler) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		raw, exists := c.Get("userID")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "userID not found in context"})
+			middleware.Abort(c, models.NewUnauthorizedError("Not signed in", errors.New("userID not found in context")))
 			return
 		}
 
 		userID, ok := raw.(string)
 		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "userID has invalid type"})
+			middleware.Abort(c, fmt.Errorf("userID has invalid type %T", raw))
 			return
 		}
 
@@ -32,21 +36,45 @@ func Authenticated(handler AuthHandler) gin.HandlerFunc {
 	}
 }
 
+// Public runs a handler that anyone can call, signed in or not.
+func Public(handler Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		runHandler(c,
+			func() (any, error) {
+				return handler(c)
+			},
+		)
+	}
+}
+
 func runHandler(c *gin.Context, handler func() (any, error)) {
 	result, err := handler()
 
+	// a streamed response is already on its way; too late to turn it into an error
+	if c.Writer.Written() {
+		if err != nil {
+			log.Printf("[ERROR] streaming %s: %v", c.Request.URL.Path, err)
+		}
+		return
+	}
+
 	if result == models.NoContent {
 		c.Status(http.StatusNoContent)
 		return
 	}
 
+	if result == models.NotModified {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if result == models.Accepted {
+		c.Status(http.StatusAccepted)
+		return
+	}
+
 	if err != nil {
-		switch e := err.(type) {
-		case models.HTTPError:
-			c.Data(e.Status(), "application/json", e.JSON())
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		middleware.Abort(c, err)
 		return
 	}
 
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
@@ -67,6 +68,36 @@ func TestRunHandler_NoContent(t *testing.T) {
 	assert.Empty(t, rec.Body.String(), "204 should have empty body")
 }
 
+func TestRunHandler_NotModified(t *testing.T) {
+	r := setupTestRouter()
+	r.GET("/t", func(c *gin.Context) {
+		c.Set("userID", "u1")
+		Authenticated(func(c *gin.Context, userID string) (any, error) {
+			c.Header("ETag", `"abc"`)
+			return models.NotModified, nil
+		})(c)
+	})
+
+	rec := performRequest(r, http.MethodGet, "/t", nil)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, `"abc"`, rec.Header().Get("ETag"))
+	assert.Empty(t, rec.Body.String(), "304 should have empty body")
+}
+
+func TestRunHandler_Accepted(t *testing.T) {
+	r := setupTestRouter()
+	r.GET("/t", func(c *gin.Context) {
+		c.Set("userID", "u1")
+		Authenticated(func(c *gin.Context, userID string) (any, error) {
+			return models.Accepted, nil
+		})(c)
+	})
+
+	rec := performRequest(r, http.MethodGet, "/t", nil)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, rec.Body.String(), "202 should have empty body")
+}
+
 func TestRunHandler_HTTPError(t *testing.T) {
 	r := setupTestRouter()
 	r.GET("/t", func(c *gin.Context) {
@@ -79,7 +110,23 @@ func TestRunHandler_HTTPError(t *testing.T) {
 
 	rec := performRequest(r, http.MethodGet, "/t", nil)
 	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"/problems/Forbidden","title":"Not allowed","status":403,"detail":"Action not allowed","instance":"/t","code":"Forbidden"}`, rec.Body.String())
+}
+
+func TestRunHandler_HTTPErrorForOlderApps(t *testing.T) {
+	r := setupTestRouter()
+	config.App.ProblemDetailsSince = semver.MustParse("2.9.0")
+	t.Cleanup(func() { config.App.ProblemDetailsSince = nil })
+	r.GET("/t", Authenticated(func(c *gin.Context, userID string) (any, error) { return nil, nil }))
+
+	rec := performRequest(r, http.MethodGet, "/t", map[string]string{"X-App-Version": "2.8.0"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error":"Not signed in","code":"Unauthorized"}`, rec.Body.String())
+
+	rec = performRequest(r, http.MethodGet, "/t", map[string]string{"X-App-Version": "2.9.0"})
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
 }
 
 func TestRunHandler_GenericError(t *testing.T) {
@@ -93,6 +140,7 @@ func TestRunHandler_GenericError(t *testing.T) {
 
 	rec := performRequest(r, http.MethodGet, "/t", nil)
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "boom", "internals stay in the logs")
 }
 
 func TestAllowedOrigins(t *testing.T) {
@@ -142,3 +190,14 @@ func TestCORSMiddleware_DisallowsUnknownOrigin(t *testing.T) {
 	assert.Equal(t, 204, rec2.Code)
 	assert.Empty(t, rec2.Header().Get("Access-Control-Allow-Origin"))
 }
+
+func TestPublic_RunsWithoutUserID(t *testing.T) {
+	r := setupTestRouter()
+	r.GET("/t", Public(func(c *gin.Context) (any, error) {
+		return gin.H{"ok": true}, nil
+	}))
+
+	rec := performRequest(r, http.MethodGet, "/t", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"ok":true}`, rec.Body.String())
+}
@@ -1,7 +1,9 @@
 package routerx
 
 import (
+	"heart/internal/awsx"
 	"heart/internal/config"
+	"heart/internal/dbx"
 	"heart/internal/handlers"
 	"heart/internal/middleware"
 	"net/http"
@@ -12,10 +14,13 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func Router(origins string) *gin.Engine {
+// Router routes the API, with the handlers reading and writing through the given stores,
+// and keeping files and running jobs through the given services. Each router keeps its own
+// copy of the exercise catalog in memory.
+func Router(origins string, stores dbx.Stores, services awsx.Services) *gin.Engine {
 	r := gin.Default()
 
-	r.Use(CORSMiddleware(origins))
+	r.Use(CORSMiddleware(origins), middleware.Stores(stores), middleware.Services(services), handlers.Catalog(handlers.CatalogTTL))
 
 	r.GET(
 		"/health",
@@ -31,6 +36,16 @@ func Router(origins string) *gin.Engine {
 		},
 	)
 
+	// what the types of problem details link to
+	r.GET("/problems", Public(handlers.GetErrorCatalog))
+	r.GET("/problems/:code", Public(handlers.GetErrorKind))
+
+	// objects kept on disk instead of S3 are uploaded and served here
+	if awsx.LocalFiles() != nil {
+		r.POST("/files/:bucket", Public(handlers.UploadFile))
+		r.GET("/files/:bucket/*key", Public(handlers.GetFile))
+	}
+
 	if config.App.SwaggerConfig.DocsEnabled {
 		r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	}
@@ -40,31 +55,72 @@ func Router(origins string) *gin.Engine {
 	exercisesGroup.GET("", Authenticated(handlers.GetExercises))
 	exercisesGroup.POST("", Authenticated(handlers.MakeExercise))
 	exercisesGroup.PUT(":exerciseName", Authenticated(handlers.EditExercise))
+	exercisesGroup.DELETE(":exerciseName", Authenticated(handlers.DeleteExercise))
+	exercisesGroup.POST(":exerciseName/rename", Authenticated(handlers.RenameExercise))
+	exercisesGroup.POST(":exerciseName/merge", Authenticated(handlers.MergeExercise))
+	exercisesGroup.PUT(":exerciseName/images", Authenticated(handlers.MakeExercisePresignedUrl))
+	exercisesGroup.GET(":exerciseName/records", Authenticated(handlers.GetExerciseRecords))
+	exercisesGroup.GET(":exerciseName/history", Authenticated(handlers.GetExerciseHistory))
 
 	workoutsGroup := r.Group("/workouts")
 	workoutsGroup.Use(middleware.Version(), middleware.Authentication())
 	workoutsGroup.GET("", Authenticated(handlers.GetWorkouts))
 	workoutsGroup.POST("", Authenticated(handlers.MakeWorkout))
+	workoutsGroup.GET("export", Authenticated(handlers.ExportWorkouts))
+	workoutsGroup.POST("import", Authenticated(handlers.ImportWorkouts))
 	workoutsGroup.GET(":workoutId", Authenticated(handlers.GetWorkout))
 	workoutsGroup.GET("images", Authenticated(handlers.GetWorkoutGallery))
 	workoutsGroup.PUT(":workoutId/images", Authenticated(handlers.MakeWorkoutPresignedUrl))
 	workoutsGroup.DELETE(":workoutId/images", Authenticated(handlers.DeleteWorkoutImage))
 	workoutsGroup.DELETE(":workoutId", Authenticated(handlers.DeleteWorkout))
 
+	statsGroup := r.Group("/stats")
+	statsGroup.Use(middleware.Version(), middleware.Authentication())
+	statsGroup.GET("", Authenticated(handlers.GetStats))
+
 	templatesGroup := r.Group("/templates")
 	templatesGroup.Use(middleware.Version(), middleware.Authentication())
 	templatesGroup.GET("", Authenticated(handlers.GetTemplates))
 	templatesGroup.POST("", Authenticated(handlers.MakeTemplate))
+	templatesGroup.PATCH("order", Authenticated(handlers.ReorderTemplates))
 	templatesGroup.GET(":templateId", Authenticated(handlers.GetTemplate))
+	templatesGroup.PUT(":templateId", Authenticated(handlers.UpdateTemplate))
 	templatesGroup.DELETE(":templateId", Authenticated(handlers.DeleteTemplate))
+	templatesGroup.POST(":templateId/duplicate", Authenticated(handlers.DuplicateTemplate))
+	templatesGroup.POST(":templateId/start", Authenticated(handlers.StartWorkout))
+	templatesGroup.POST(":templateId/share", Authenticated(handlers.ShareTemplate))
+	templatesGroup.PUT(":templateId/from/:workoutId", Authenticated(handlers.UpdateTemplateFromWorkout))
+
+	// shared templates can be read by anyone holding the code
+	sharedGroup := r.Group("/shared")
+	sharedGroup.Use(middleware.Version())
+	sharedGroup.GET(":code", Public(handlers.GetSharedTemplate))
+	sharedGroup.GET("", middleware.Authentication(), Authenticated(handlers.GetShares))
+	sharedGroup.POST(":code/import", middleware.Authentication(), Authenticated(handlers.ImportSharedTemplate))
+	sharedGroup.DELETE(":code", middleware.Authentication(), Authenticated(handlers.RevokeShare))
+
+	programsGroup := r.Group("/programs")
+	programsGroup.Use(middleware.Version(), middleware.Authentication())
+	programsGroup.GET("", Authenticated(handlers.GetPrograms))
+	programsGroup.POST("", Authenticated(handlers.MakeProgram))
+	programsGroup.GET(":programId", Authenticated(handlers.GetProgram))
+	programsGroup.PUT(":programId", Authenticated(handlers.UpdateProgram))
+	programsGroup.DELETE(":programId", Authenticated(handlers.DeleteProgram))
+	programsGroup.GET(":programId/next", Authenticated(handlers.GetNextSession))
 
 	accountGroup := r.Group("/accounts")
 	accountGroup.Use(middleware.Version(), middleware.Authentication())
 	accountGroup.POST("", Authenticated(handlers.RegisterAccount))
 	accountGroup.DELETE("", Authenticated(handlers.DeleteAccount))
+	accountGroup.POST("export", Authenticated(handlers.ExportAccountData))
+	accountGroup.GET("export", Authenticated(handlers.GetAccountDataExport))
 	accountGroup.PUT(":accountId", Authenticated(handlers.EditAccount))
 	accountGroup.GET(":accountId", Authenticated(handlers.GetAccount))
 
+	syncGroup := r.Group("/sync")
+	syncGroup.Use(middleware.Version(), middleware.Authentication())
+	syncGroup.GET("", Authenticated(handlers.GetChanges))
+
 	feedbackGroup := r.Group("/feedback")
 	feedbackGroup.Use(middleware.Version(), middleware.Authentication())
 	feedbackGroup.POST("", Authenticated(handlers.LeaveFeedback))
@@ -110,7 +166,8 @@ func corsHeaders(c *gin.Context, origin string) {
 	c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 	c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 	c.Writer.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
-	c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+	c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag,Content-Disposition")
 }
 
-const allowedHeaders = `Content-Type,Authorization,Accept,Accept-Language,X-Timezone,X-App-Version,Referer,User-Agent,`
+const allowedHeaders = `Content-Type,Authorization,Accept,Accept-Language,X-Timezone,X-App-Version,If-Match,If-None-Match,Referer,User-Agent,`
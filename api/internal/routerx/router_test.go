@@ -1,10 +1,20 @@
 package routerx
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"heart/internal/awsx"
+	"heart/internal/background"
 	"heart/internal/config"
+	"heart/internal/dbx"
+	"heart/internal/localdb"
+	"heart/internal/middleware"
+	"heart/internal/models"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,7 +23,7 @@ import (
 
 func TestRouter_Health(t *testing.T) {
 	config.App = &config.AppConfig{SwaggerConfig: config.SwaggerConfig{DocsEnabled: false}}
-	r := Router("")
+	r := Router("", dbx.NewStores(localdb.NewMemory()), awsx.NewServices())
 
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
@@ -27,7 +37,7 @@ func TestRouter_Health(t *testing.T) {
 
 func TestRouter_Version(t *testing.T) {
 	config.App = &config.AppConfig{SwaggerConfig: config.SwaggerConfig{DocsEnabled: false}}
-	r := Router("")
+	r := Router("", dbx.NewStores(localdb.NewMemory()), awsx.NewServices())
 
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/version", nil)
@@ -40,10 +50,133 @@ func TestRouter_Version(t *testing.T) {
 	assert.NotEmpty(t, payload["deployedAt"])
 }
 
+func TestRouter_ProblemTypes(t *testing.T) {
+	config.App = &config.AppConfig{SwaggerConfig: config.SwaggerConfig{DocsEnabled: false}}
+	r := Router("", dbx.NewStores(localdb.NewMemory()), awsx.NewServices())
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/problems/NotFound", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"code":"NotFound","type":"/problems/NotFound","title":"Not found","status":404}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/problems/Teapot", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+}
+
+func TestRouter_HandsTheStoresToHandlers(t *testing.T) {
+	config.App = &config.AppConfig{SwaggerConfig: config.SwaggerConfig{DocsEnabled: false}}
+	store := localdb.NewMemory()
+	shared, err := store.ShareTemplate(context.Background(), &models.Template{PK: models.UserKey + "u1", SK: models.TemplateKey + "t1", Name: "Legs"}, nil)
+	require.NoError(t, err)
+	r := Router("", dbx.NewStores(store), awsx.NewServices())
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/shared/"+shared.Code(), nil)
+	req.Header.Set("X-App-Version", "1.0.0")
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"Legs"`)
+}
+
+func TestRouter_ServesLocalFiles(t *testing.T) {
+	config.App = &config.AppConfig{SwaggerConfig: config.SwaggerConfig{DocsEnabled: false}}
+	r := Router("", dbx.NewStores(localdb.NewMemory()), awsx.NewServices())
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/media/a.jpg", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "no file routes with S3")
+
+	_, err := awsx.UseFiles(t.TempDir(), "http://example.com/files")
+	require.NoError(t, err)
+	r = Router("", dbx.NewStores(localdb.NewMemory()), awsx.NewServices())
+
+	link, err := awsx.GeneratePresignedPostURL(context.Background(), "uploads", "avatars/u1", "image/jpeg", nil)
+	require.NoError(t, err)
+	image := bytes.Repeat([]byte{7}, 1024)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range link.Values {
+		require.NoError(t, form.WriteField(name, value))
+	}
+	file, err := form.CreateFormFile("file", "avatar.jpg")
+	require.NoError(t, err)
+	_, _ = file.Write(image)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/files/uploads", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/uploads/avatars/u1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, image, rec.Body.Bytes())
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/uploads/avatars/u2", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRouter_RunsJobsOffline(t *testing.T) {
+	ctx := context.Background()
+	config.App = &config.AppConfig{}
+	config.App.MediaBucket, config.App.UploadBucket, config.App.AccountDeletionOffset = "media", "uploads", 30
+	_, err := awsx.UseFiles(t.TempDir(), "http://example.com/files")
+	require.NoError(t, err)
+	middleware.TrustBearerTokens()
+
+	store := localdb.NewMemory()
+	_, err = store.SaveAccount(ctx, "u1", models.User{})
+	require.NoError(t, err)
+	stores := dbx.NewStores(store)
+	jobs, err := background.NewLocal(ctx, stores, store, config.App.AccountDeletionOffset)
+	require.NoError(t, err)
+	r := Router("", stores, awsx.Services{Objects: awsx.NewServices().Objects, Jobs: jobs})
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer u1")
+		req.Header.Set("X-App-Version", "1.0.0")
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/accounts/export", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	jobs.Wait()
+	export, err := store.GetDataExport(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportReady, export.Status)
+	assert.NotNil(t, export.ObjectKey)
+
+	rec = send(http.MethodDelete, "/accounts", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	account, err := store.GetAccount(ctx, "u1")
+	require.NoError(t, err)
+	require.NotNil(t, account.AccountDeletionSchedule)
+	assert.Equal(t, "account-deletion-u1", *account.AccountDeletionSchedule)
+	schedules, err := store.GetSchedules(ctx)
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, "u1", schedules[0].UserID)
+
+	rec = send(http.MethodPost, "/feedback", `{"message":"Good job!"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), "http://example.com/files")
+}
+
 func TestRouter_SwaggerToggle(t *testing.T) {
 	// Disabled: route should 404
 	config.App = &config.AppConfig{SwaggerConfig: config.SwaggerConfig{DocsEnabled: false}}
-	r := Router("")
+	r := Router("", dbx.NewStores(localdb.NewMemory()), awsx.NewServices())
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/swagger/index.html", nil)
 	r.ServeHTTP(rec, req)
@@ -51,7 +184,7 @@ func TestRouter_SwaggerToggle(t *testing.T) {
 
 	// Enabled: route should be handled (often 200)
 	config.App = &config.AppConfig{SwaggerConfig: config.SwaggerConfig{DocsEnabled: true}}
-	r2 := Router("")
+	r2 := Router("", dbx.NewStores(localdb.NewMemory()), awsx.NewServices())
 	rec2 := httptest.NewRecorder()
 	req2 := httptest.NewRequest(http.MethodGet, "/swagger/index.html", nil)
 	r2.ServeHTTP(rec2, req2)
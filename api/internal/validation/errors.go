@@ -0,0 +1,56 @@
+// Package validation checks requests beyond what binding tags can, collecting every violation
+// under the JSON path of the field at fault, such as exercises[2].sets[0].reps, so that clients
+// can point at it.
+package validation
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Errors maps the JSON path of each field at fault to what is wrong with it.
+type Errors map[string][]string
+
+func (e Errors) add(path, format string, args ...any) {
+	e[path] = append(e[path], fmt.Sprintf(format, args...))
+}
+
+// Error lists the violations by path.
+func (e Errors) Error() string {
+	paths := slices.Sorted(maps.Keys(e))
+	parts := make([]string, len(paths))
+	for i, path := range paths {
+		parts[i] = path + ": " + strings.Join(e[path], ", ")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Details is what goes in the details of the error response.
+func (e Errors) Details() map[string]any {
+	details := make(map[string]any, len(e))
+	for path, messages := range e {
+		details[path] = messages
+	}
+	return details
+}
+
+// err returns the violations, or nil if there are none.
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func field(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func index(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"heart/internal/models"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxNameSize         = 100  // characters, of names, categories and targets
+	maxInstructionsSize = 5000 // characters
+)
+
+// UserExercise checks that an exercise of the user's own has a usable name, category and target.
+func UserExercise(in *models.UserExerciseIn) error {
+	errs := Errors{}
+
+	name := strings.TrimSpace(in.Name)
+	if !IsValidName(name) {
+		errs.add("name", "can only contain letters, numbers and spaces")
+	}
+
+	for path, value := range map[string]string{"name": name, "category": in.Category, "target": in.Target} {
+		if strings.TrimSpace(value) == "" {
+			errs.add(path, "must not be blank")
+		} else if utf8.RuneCountInString(value) > maxNameSize {
+			errs.add(path, "must be at most %d characters", maxNameSize)
+		}
+	}
+
+	if in.Instructions != nil && utf8.RuneCountInString(*in.Instructions) > maxInstructionsSize {
+		errs.add("instructions", "must be at most %d characters", maxInstructionsSize)
+	}
+
+	return errs.err()
+}
+
+// IsValidName tells whether an exercise name has only letters, numbers and spaces.
+// Names end up in paths and keys, which are kept to those.
+func IsValidName(name string) bool {
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+	})
+}
@@ -0,0 +1,23 @@
+package validation
+
+import (
+	"heart/internal/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserExercise(t *testing.T) {
+	instructions := strings.Repeat("a", maxInstructionsSize+1)
+
+	assert.NoError(t, UserExercise(&models.UserExerciseIn{Name: "Push Up", Category: "Body weight", Target: "Chest"}))
+
+	errs := violations(t, UserExercise(&models.UserExerciseIn{Name: "Push/Up", Category: " ", Target: strings.Repeat("a", maxNameSize+1), Instructions: &instructions}))
+	assert.Equal(t, Errors{
+		"name":         {"can only contain letters, numbers and spaces"},
+		"category":     {"must not be blank"},
+		"target":       {"must be at most 100 characters"},
+		"instructions": {"must be at most 5000 characters"},
+	}, errs)
+}
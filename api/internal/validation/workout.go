@@ -0,0 +1,188 @@
+package validation
+
+import (
+	"cmp"
+	"heart/internal/models"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Known tells whether there is an exercise of that name, in the catalog, among the user's own
+// or merged into another. Nil takes every name as known.
+type Known func(name string) bool
+
+// tempoPattern is the usual four phases of a rep in seconds, eccentric first, X for explosive:
+// "3-1-1-0" or "31X0".
+var tempoPattern = regexp.MustCompile(`(?i)^(?:[0-9X]-){3}[0-9X]$|^[0-9X]{4}$`)
+
+// exercise is what the exercises of workouts and templates have in common.
+type exercise struct {
+	id        string
+	name      string
+	order     int
+	group     string
+	groupType models.GroupType
+	sets      []models.Set
+}
+
+// Workout checks that a workout does not end before it starts, and its exercises and sets.
+func Workout(in *models.WorkoutIn, known Known) error {
+	errs := Errors{}
+	if in.End != nil && in.End.Before(in.Start) {
+		errs.add("end", "must not be before the start")
+	}
+
+	exercises := make([]exercise, len(in.Exercises))
+	for i, e := range in.Exercises {
+		exercises[i] = exercise{e.ID, e.Exercise, e.Order, e.Group, e.GroupType, e.Sets}
+	}
+	checkExercises(errs, exercises, known)
+
+	return errs.err()
+}
+
+// Template checks the exercises and sets of a template.
+func Template(in *models.TemplateIn, known Known) error {
+	errs := Errors{}
+
+	exercises := make([]exercise, len(in.Exercises))
+	for i, e := range in.Exercises {
+		exercises[i] = exercise{e.ID, e.ExerciseID, e.ExerciseOrder, e.Group, e.GroupType, e.Sets}
+	}
+	checkExercises(errs, exercises, known)
+
+	return errs.err()
+}
+
+// checkExercises checks that exercises are known, that their IDs, orders and the IDs of their sets
+// are not repeated, that their sets are in range and that their groups hold together.
+func checkExercises(errs Errors, exercises []exercise, known Known) {
+	ids := map[string]string{}
+	orders := map[int]string{}
+	setIDs := map[string]string{}
+
+	for i, e := range exercises {
+		path := index("exercises", i)
+
+		unique(errs, ids, field(path, "id"), e.id)
+
+		if strings.TrimSpace(e.name) == "" {
+			errs.add(field(path, "exercise"), "must not be blank")
+		} else if known != nil && !known(e.name) {
+			errs.add(field(path, "exercise"), "is not a known exercise")
+		}
+
+		if e.order < 0 {
+			errs.add(field(path, "order"), "must not be negative")
+		} else if first, ok := orders[e.order]; ok {
+			errs.add(field(path, "order"), "is the same as %s", first)
+		} else {
+			orders[e.order] = field(path, "order")
+		}
+
+		for j, s := range e.sets {
+			setPath := index(field(path, "sets"), j)
+			unique(errs, setIDs, field(setPath, "id"), s.ID)
+			checkSet(errs, setPath, &s)
+		}
+	}
+
+	checkGroups(errs, exercises)
+}
+
+// unique reports an ID already seen, keeping where it was first.
+func unique(errs Errors, seen map[string]string, path, id string) {
+	if id == "" {
+		return
+	}
+	if first, ok := seen[id]; ok {
+		errs.add(path, "is the same as %s", first)
+		return
+	}
+	seen[id] = path
+}
+
+// checkSet checks that the values of a set are in range.
+func checkSet(errs Errors, path string, s *models.Set) {
+	for name, value := range map[string]float64{
+		"weight":   s.Weight,
+		"reps":     float64(s.Reps),
+		"duration": s.Duration,
+		"distance": s.Distance,
+	} {
+		if value < 0 {
+			errs.add(field(path, name), "must not be negative")
+		}
+	}
+
+	if s.Type != "" && !slices.Contains(models.SetTypes, s.Type) {
+		errs.add(field(path, "type"), "must be one of %v", models.SetTypes)
+	}
+	if s.RPE != 0 && (s.RPE < 1 || s.RPE > models.MaxRPE || s.RPE*2 != math.Trunc(s.RPE*2)) {
+		errs.add(field(path, "rpe"), "must be from 1 to %d, in steps of 0.5", models.MaxRPE)
+	}
+	if s.RIR != nil && (*s.RIR < 0 || *s.RIR > models.MaxRIR) {
+		errs.add(field(path, "rir"), "must be from 0 to %d", models.MaxRIR)
+	}
+	if s.Tempo != "" && !tempoPattern.MatchString(s.Tempo) {
+		errs.add(field(path, "tempo"), "must be four phases, such as 3-1-1-0 or 31X0")
+	}
+	if s.Rest < 0 || s.Rest > models.MaxRest {
+		errs.add(field(path, "rest"), "must be from 0 to %d seconds", models.MaxRest)
+	}
+	if utf8.RuneCountInString(s.Note) > models.MaxNoteSize {
+		errs.add(field(path, "note"), "must be at most %d characters", models.MaxNoteSize)
+	}
+}
+
+// checkGroups checks that every group has a known type shared by all its exercises,
+// has more than one exercise, and that its exercises follow one another in order.
+func checkGroups(errs Errors, exercises []exercise) {
+	byOrder := make([]int, len(exercises))
+	for i := range exercises {
+		byOrder[i] = i
+	}
+	slices.SortStableFunc(byOrder, func(a, b int) int { return cmp.Compare(exercises[a].order, exercises[b].order) })
+
+	types := map[string]models.GroupType{}
+	first := map[string]int{}
+	sizes := map[string]int{}
+	previous := ""
+	for _, i := range byOrder {
+		e, path := exercises[i], index("exercises", i)
+		if e.group == "" {
+			if e.groupType != "" {
+				errs.add(field(path, "groupType"), "needs a group")
+			}
+			previous = ""
+			continue
+		}
+
+		if !slices.Contains(models.GroupTypes, e.groupType) {
+			errs.add(field(path, "groupType"), "must be one of %v", models.GroupTypes)
+		}
+		if t, seen := types[e.group]; seen {
+			if t != e.groupType {
+				errs.add(field(path, "groupType"), "is not the type of the rest of group %s", e.group)
+			}
+			if previous != e.group {
+				errs.add(field(path, "group"), "must follow the other exercises of group %s", e.group)
+			}
+		} else {
+			types[e.group] = e.groupType
+			first[e.group] = i
+		}
+
+		sizes[e.group]++
+		previous = e.group
+	}
+
+	for group, size := range sizes {
+		if size < 2 {
+			errs.add(field(index("exercises", first[group]), "group"), "has no other exercise in group %s", group)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"heart/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func violations(t *testing.T, err error) Errors {
+	t.Helper()
+	var errs Errors
+	require.ErrorAs(t, err, &errs)
+	return errs
+}
+
+func TestWorkout_CollectsEveryViolation(t *testing.T) {
+	start := time.Date(2025, 7, 18, 6, 0, 0, 0, time.UTC)
+	end := start.Add(-time.Hour)
+	in := models.WorkoutIn{
+		ID:    "w1",
+		Start: start,
+		End:   &end,
+		Exercises: []models.WorkoutExerciseIn{
+			{ID: "e1", Exercise: "Bench Press", Order: 0, Sets: []models.Set{{ID: "s1", Weight: -5, Reps: 5}}},
+			{ID: "e2", Exercise: "Moon Press", Order: 1, Sets: []models.Set{{ID: "s2"}}},
+			{ID: "e1", Exercise: "Squat", Order: 1, Sets: []models.Set{{ID: "s3"}, {ID: "s1", Reps: -1}}},
+		},
+	}
+	known := func(name string) bool { return name != "Moon Press" }
+
+	errs := violations(t, Workout(&in, known))
+
+	assert.Equal(t, Errors{
+		"end":                         {"must not be before the start"},
+		"exercises[0].sets[0].weight": {"must not be negative"},
+		"exercises[1].exercise":       {"is not a known exercise"},
+		"exercises[2].id":             {"is the same as exercises[0].id"},
+		"exercises[2].order":          {"is the same as exercises[1].order"},
+		"exercises[2].sets[1].id":     {"is the same as exercises[0].sets[0].id"},
+		"exercises[2].sets[1].reps":   {"must not be negative"},
+	}, errs)
+}
+
+func TestWorkout_Valid(t *testing.T) {
+	start := time.Date(2025, 7, 18, 6, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	in := models.WorkoutIn{ID: "w1", Start: start, End: &end, Exercises: []models.WorkoutExerciseIn{
+		{ID: "e1", Exercise: "Bench Press", Order: 0, Group: "a", GroupType: models.Superset, Sets: []models.Set{{ID: "s1", Weight: 100, Reps: 5}}},
+		{ID: "e2", Exercise: "Row", Order: 1, Group: "a", GroupType: models.Superset, Sets: []models.Set{{ID: "s2", Weight: 80, Reps: 8}}},
+	}}
+
+	assert.NoError(t, Workout(&in, nil))
+}
+
+func TestTemplate_CollectsEveryViolation(t *testing.T) {
+	in := models.TemplateIn{Exercises: []models.TemplateExercise{
+		{ID: "t1", ExerciseID: " ", ExerciseOrder: -1},
+		{ID: "t2", ExerciseID: "Squat", ExerciseOrder: 0, Sets: []models.Set{{ID: "s1", RPE: 12}}},
+	}}
+
+	errs := violations(t, Template(&in, nil))
+
+	assert.Equal(t, Errors{
+		"exercises[0].exercise":    {"must not be blank"},
+		"exercises[0].order":       {"must not be negative"},
+		"exercises[1].sets[0].rpe": {"must be from 1 to 10, in steps of 0.5"},
+	}, errs)
+}
+
+func TestCheckSet(t *testing.T) {
+	rir := func(n int) *int { return &n }
+
+	tests := []struct {
+		name  string
+		set   models.Set
+		field string // at fault, none if empty
+	}{
+		{"plain", models.Set{ID: "s"}, ""},
+		{"everything", models.Set{ID: "s", Type: models.AMRAPSet, RPE: 8.5, RIR: rir(0), Tempo: "3-1-x-0", Rest: 90, Note: "Felt easy"}, ""},
+		{"compact tempo", models.Set{ID: "s", Tempo: "31X0"}, ""},
+		{"unknown type", models.Set{ID: "s", Type: "cluster"}, "type"},
+		{"RPE too low", models.Set{ID: "s", RPE: 0.5}, "rpe"},
+		{"RPE too high", models.Set{ID: "s", RPE: 11}, "rpe"},
+		{"RPE off the half steps", models.Set{ID: "s", RPE: 7.3}, "rpe"},
+		{"RIR below zero", models.Set{ID: "s", RIR: rir(-1)}, "rir"},
+		{"RIR too high", models.Set{ID: "s", RIR: rir(11)}, "rir"},
+		{"tempo with three phases", models.Set{ID: "s", Tempo: "3-1-1"}, "tempo"},
+		{"tempo in words", models.Set{ID: "s", Tempo: "slow"}, "tempo"},
+		{"negative rest", models.Set{ID: "s", Rest: -1}, "rest"},
+		{"rest over an hour", models.Set{ID: "s", Rest: models.MaxRest + 1}, "rest"},
+		{"long note", models.Set{ID: "s", Note: strings.Repeat("ä", models.MaxNoteSize+1)}, "note"},
+		{"note at the limit", models.Set{ID: "s", Note: strings.Repeat("ä", models.MaxNoteSize)}, ""},
+		{"negative duration", models.Set{ID: "s", Duration: -30}, "duration"},
+		{"negative distance", models.Set{ID: "s", Distance: -1}, "distance"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Errors{}
+			checkSet(errs, "sets[0]", &tt.set)
+			if tt.field == "" {
+				assert.Empty(t, errs)
+			} else {
+				assert.Len(t, errs, 1)
+				assert.Contains(t, errs, "sets[0]."+tt.field)
+			}
+		})
+	}
+}
+
+func TestCheckGroups(t *testing.T) {
+	ex := func(order int, group string, groupType models.GroupType) exercise {
+		return exercise{order: order, group: group, groupType: groupType}
+	}
+
+	tests := []struct {
+		name      string
+		exercises []exercise
+		field     string // at fault, none if empty
+	}{
+		{"no groups", []exercise{ex(0, "", ""), ex(1, "", "")}, ""},
+		{"superset", []exercise{ex(0, "a", models.Superset), ex(1, "a", models.Superset), ex(2, "", "")}, ""},
+		{"listed out of order", []exercise{ex(2, "a", models.Circuit), ex(0, "", ""), ex(1, "a", models.Circuit)}, ""},
+		{"two groups back to back", []exercise{
+			ex(0, "a", models.Superset), ex(1, "a", models.Superset),
+			ex(2, "b", models.GiantSet), ex(3, "b", models.GiantSet), ex(4, "b", models.GiantSet),
+		}, ""},
+		{"split by another exercise", []exercise{ex(0, "a", models.Superset), ex(1, "", ""), ex(2, "a", models.Superset)}, "exercises[2].group"},
+		{"alone", []exercise{ex(0, "a", models.Superset), ex(1, "", "")}, "exercises[0].group"},
+		{"mixed types", []exercise{ex(0, "a", models.Superset), ex(1, "a", models.Circuit)}, "exercises[1].groupType"},
+		{"unknown type", []exercise{ex(0, "a", "pyramid"), ex(1, "a", "pyramid")}, "exercises[0].groupType"},
+		{"no type", []exercise{ex(0, "a", ""), ex(1, "a", "")}, "exercises[0].groupType"},
+		{"type without a group", []exercise{ex(0, "", models.Superset)}, "exercises[0].groupType"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Errors{}
+			checkGroups(errs, tt.exercises)
+			if tt.field == "" {
+				assert.Empty(t, errs)
+			} else {
+				assert.Contains(t, errs, tt.field)
+			}
+		})
+	}
+}
+
+func TestErrors_GoInTheDetailsOfTheResponse(t *testing.T) {
+	errs := Errors{"exercises[0].sets[1].reps": {"must not be negative"}, "end": {"must not be before the start"}}
+
+	validation := models.NewValidationError(errs)
+
+	var body struct {
+		Error   string              `json:"error"`
+		Details map[string][]string `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(validation.JSON(), &body))
+	assert.Equal(t, "end: must not be before the start; exercises[0].sets[1].reps: must not be negative", body.Error)
+	assert.Equal(t, map[string][]string(errs), body.Details)
+
+	// details only come with errors that have them
+	assert.NotContains(t, string(models.NewValidationError(errors.New("bad")).JSON()), "details")
+}
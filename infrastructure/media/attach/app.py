@@ -1,6 +1,9 @@
 import os
+from datetime import datetime, timezone
+from urllib.parse import quote
 
 import boto3
+from botocore.exceptions import ClientError
 
 _dynamo = None
 _s3 = None
@@ -22,6 +25,10 @@ def s3():
     return _s3
 
 
+def now() -> str:
+    return datetime.now(timezone.utc).strftime('%Y-%m-%dT%H:%M:%S.%fZ')
+
+
 def write(*, user_id: str, workout_id: str, url: str, image_key: str) -> dict:
     pk = f'USER#{user_id}'
     workout_sk = f'WORKOUT#{workout_id}'
@@ -37,12 +44,19 @@ def write(*, user_id: str, workout_id: str, url: str, image_key: str) -> dict:
                         'PK': {'S': pk},
                         'SK': {'S': workout_sk},
                     },
-                    'UpdateExpression': 'ADD #images :imageset',
+                    # a change like any other, for other devices to sync
+                    'UpdateExpression': 'ADD #images :imageset '
+                                        'SET #updated = :now, #version = if_not_exists(#version, :zero) + :one',
                     'ExpressionAttributeNames': {
                         '#images': 'images',
+                        '#updated': 'updated_at',
+                        '#version': 'version',
                     },
                     'ExpressionAttributeValues': {
                         ':imageset': {'SS': [image_key]},
+                        ':now': {'S': now()},
+                        ':zero': {'N': '0'},
+                        ':one': {'N': '1'},
                     },
                 }
             },
@@ -62,7 +76,58 @@ def write(*, user_id: str, workout_id: str, url: str, image_key: str) -> dict:
     )
 
 
-def update_workout_on_image(bucket: str, key: str) -> None:
+def write_exercise(*, user_id: str, exercise: str, kind: str, url: str, image_key: str, width: str,
+                   height: str) -> str | None:
+    image = {
+        'link': {'S': url},
+        'width': {'N': width},
+        'height': {'N': height},
+        'key': {'S': image_key},
+    }
+
+    result = dynamo().update_item(
+        TableName=workouts_table,
+        Key={
+            'PK': {'S': f'USER#{user_id}'},
+            'SK': {'S': f"EXERCISE#{quote(exercise, safe='')}"},
+        },
+        UpdateExpression='SET #image = :image, #updated = :now',
+        ConditionExpression='attribute_exists(#PK)',
+        ExpressionAttributeNames={
+            '#PK': 'PK',
+            '#image': kind,
+            '#updated': 'updated_at',
+        },
+        ExpressionAttributeValues={
+            ':image': {'M': image},
+            ':now': {'S': now()},
+        },
+        ReturnValues='UPDATED_OLD',
+    )
+
+    previous = result.get('Attributes', {}).get(kind, {}).get('M', {})
+    return previous.get('key', {}).get('S')
+
+
+def update_exercise_on_image(*, bucket: str, key: str, user_id: str, exercise: str, kind: str, width: str,
+                             height: str) -> None:
+    url = f'{media_distribution}/{key}'
+    try:
+        previous = write_exercise(user_id=user_id, exercise=exercise, kind=kind, url=url, image_key=key,
+                                  width=width, height=height)
+    except ClientError as e:
+        if e.response['Error']['Code'] != 'ConditionalCheckFailedException':
+            raise
+        # the exercise was deleted or renamed while the image was on its way
+        s3().delete_object(Bucket=bucket, Key=key)
+        return
+
+    # the image it replaces is no longer shown anywhere
+    if previous and previous != key:
+        s3().delete_object(Bucket=bucket, Key=previous)
+
+
+def attach_image(bucket: str, key: str) -> None:
     tag_set = s3().get_object_tagging(Bucket=bucket, Key=key)
     tags = {tag['Key']: tag['Value'] for tag in tag_set['TagSet']}
 
@@ -70,6 +135,10 @@ def update_workout_on_image(bucket: str, key: str) -> None:
         case {'userId': user_id, 'workoutId': workout_id}:
             url = f'{media_distribution}/{key}'
             write(user_id=user_id, workout_id=workout_id, url=url, image_key=key)
+        case {'userId': user_id, 'exercise': exercise, 'image': ('asset' | 'thumbnail') as kind,
+              'width': width, 'height': height}:
+            update_exercise_on_image(bucket=bucket, key=key, user_id=user_id, exercise=exercise, kind=kind,
+                                     width=width, height=height)
         case _:
             raise ValueError(f'Invalid tags: {tags}')
 
@@ -89,7 +158,7 @@ def handler(event: dict, _) -> dict:
                 },
             ],
         } if f'{s3_event}'.startswith('ObjectCreated:'):
-            update_workout_on_image(bucket, key)
+            attach_image(bucket, key)
         case _:
             raise ValueError(f'Invalid event: {event}')
     return {'status': 'ok'}
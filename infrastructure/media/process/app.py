@@ -1,7 +1,7 @@
 import boto3
 from PIL import Image
 import io
-from urllib.parse import unquote_plus
+from urllib.parse import unquote_plus, urlencode
 
 s3 = boto3.client('s3')
 
@@ -9,6 +9,12 @@ MAX_SIZE = 1024
 MAX_BYTES = 1024 * 200
 
 
+def sized_tags(tags: dict, image: Image.Image) -> str:
+    # the size travels on with the tags, for whatever the image gets attached to
+    width, height = image.size
+    return urlencode({**tags, 'width': width, 'height': height})
+
+
 def handler(event: dict, _) -> dict:
     match event:
         case {
@@ -27,7 +33,6 @@ def handler(event: dict, _) -> dict:
             tags = {tag['Key']: tag['Value'] for tag in tagging['TagSet']}
             destination = tags.get('destination')
 
-            dst_tags = '&'.join([f'{k}={v}' for k, v in tags.items()])
             if not destination:
                 print(f'No destination tag found for {key}, skipping.')
                 return {}
@@ -44,7 +49,7 @@ def handler(event: dict, _) -> dict:
                             Key=key,
                             Body=raw,
                             ContentType=obj['ContentType'],
-                            Tagging=dst_tags,
+                            Tagging=sized_tags(tags, image),
                         )
                         print('Uploaded without resizing')
                         return {}
@@ -62,7 +67,7 @@ def handler(event: dict, _) -> dict:
                     Key=key,
                     Body=output,
                     ContentType='image/jpeg',
-                    Tagging=dst_tags,
+                    Tagging=sized_tags(tags, image),
                 )
                 print('Uploaded resized image')
             return {}